@@ -0,0 +1,237 @@
+// Package client is a typed Go client for the wallfacer HTTP API. It speaks
+// the same JSON shapes as the handler package, so scripts and tools can drive
+// a running server without hand-rolling requests:
+//
+//	c := client.New("http://localhost:8080", os.Getenv("WALLFACER_API_TOKEN"))
+//	task, err := c.CreateTask(ctx, client.CreateTaskRequest{Prompt: "fix the build"})
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// Client calls a wallfacer server. The zero value is not usable; create one
+// with New.
+type Client struct {
+	baseURL string
+	token   string
+
+	// HTTPClient sends the requests. New sets it to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL (e.g.
+// "http://localhost:8080"). A non-empty token is sent as a bearer token, as
+// required by servers started with -api-token.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// APIError is returned when the server answers with a non-success status.
+type APIError struct {
+	StatusCode int
+	Message    string // response body, trimmed
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wallfacer: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// CreateTaskRequest is the body of POST /api/tasks. Only Prompt (or
+// PromptFile) is required.
+type CreateTaskRequest struct {
+	Prompt         string            `json:"prompt,omitempty"`
+	PromptFile     string            `json:"prompt_file,omitempty"` // host path inside a workspace, read by the server
+	Timeout        int               `json:"timeout,omitempty"`     // minutes; 0 uses the server default
+	MountWorktrees bool              `json:"mount_worktrees,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	Scratch        bool              `json:"scratch,omitempty"`
+	Status         string            `json:"status,omitempty"` // initial status; default backlog
+
+	ExtraInstructions string `json:"extra_instructions,omitempty"`
+	SnapshotSubpath   string `json:"snapshot_subpath,omitempty"`
+
+	DependsOn    []uuid.UUID `json:"depends_on,omitempty"`
+	AllowedPaths []string    `json:"allowed_paths,omitempty"`
+
+	// IdempotencyKey, when set, is sent as the Idempotency-Key header so a
+	// retried request returns the task created by the first one.
+	IdempotencyKey string `json:"-"`
+}
+
+// CreateTask creates a task and returns it as stored by the server.
+func (c *Client) CreateTask(ctx context.Context, req CreateTaskRequest) (*store.Task, error) {
+	var header http.Header
+	if req.IdempotencyKey != "" {
+		header = http.Header{"Idempotency-Key": {req.IdempotencyKey}}
+	}
+	var task store.Task
+	if err := c.do(ctx, http.MethodPost, "/api/tasks", header, req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTask returns one task. id may be a full UUID or a unique prefix of one.
+func (c *Client) GetTask(ctx context.Context, id string) (*store.Task, error) {
+	var task store.Task
+	if err := c.do(ctx, http.MethodGet, "/api/tasks/"+url.PathEscape(id), nil, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks returns all tasks, including archived ones when includeArchived
+// is set.
+func (c *Client) ListTasks(ctx context.Context, includeArchived bool) ([]store.Task, error) {
+	var tasks []store.Task
+	if err := c.do(ctx, http.MethodGet, "/api/tasks"+archivedQuery(includeArchived), nil, nil, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// CancelTask cancels a task, killing its container if it is running.
+func (c *Client) CancelTask(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/api/tasks/"+id.String()+"/cancel", nil, nil, nil)
+}
+
+// GetInstructions returns the workspace CLAUDE.md content.
+func (c *Client) GetInstructions(ctx context.Context) (string, error) {
+	var resp struct {
+		Content string `json:"content"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/instructions", nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// UpdateInstructions replaces the workspace CLAUDE.md with content.
+func (c *Client) UpdateInstructions(ctx context.Context, content string) error {
+	body := struct {
+		Content string `json:"content"`
+	}{content}
+	return c.do(ctx, http.MethodPut, "/api/instructions", nil, body, nil)
+}
+
+// StreamTasks subscribes to GET /api/tasks/stream and calls fn with the full
+// task list the server sends on connect and after every change. It returns
+// when ctx is cancelled (with ctx.Err()), when the server closes the stream,
+// or with the first error fn returns.
+func (c *Client) StreamTasks(ctx context.Context, includeArchived bool, fn func([]store.Task) error) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/tasks/stream"+archivedQuery(includeArchived), http.Header{"Accept": {"text/event-stream"}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var data bytes.Buffer
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+			var tasks []store.Task
+			if err := json.Unmarshal(data.Bytes(), &tasks); err != nil {
+				return fmt.Errorf("decode task stream: %w", err)
+			}
+			data.Reset()
+			if err := fn(tasks); err != nil {
+				return err
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(v, " "))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return sc.Err()
+}
+
+// archivedQuery returns the query string of the task list endpoints.
+func archivedQuery(includeArchived bool) string {
+	if includeArchived {
+		return "?include_archived=true"
+	}
+	return ""
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out unless out is nil.
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.send(ctx, method, path, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send performs the request and turns non-2xx responses into *APIError. On
+// success the caller must close the response body.
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"changkun.de/wallfacer/internal/handler"
+	"changkun.de/wallfacer/internal/runner"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// newTestServer serves the client-facing routes from a real handler backed by
+// a fresh store, wired as in buildMux, behind bearer auth with token.
+func newTestServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := runner.NewRunner(s, runner.RunnerConfig{Command: "echo"})
+	h := handler.NewHandler(s, r, t.TempDir(), nil)
+
+	withID := func(fn func(http.ResponseWriter, *http.Request, uuid.UUID)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			id, err := uuid.Parse(r.PathValue("id"))
+			if err != nil {
+				http.Error(w, "invalid task id", http.StatusBadRequest)
+				return
+			}
+			fn(w, r, id)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/instructions", h.GetInstructions)
+	mux.HandleFunc("PUT /api/instructions", h.UpdateInstructions)
+	mux.HandleFunc("GET /api/tasks", h.ListTasks)
+	mux.HandleFunc("GET /api/tasks/stream", h.StreamTasks)
+	mux.HandleFunc("POST /api/tasks", h.CreateTask)
+	mux.HandleFunc("GET /api/tasks/{id}", h.GetTask)
+	mux.HandleFunc("POST /api/tasks/{id}/cancel", withID(h.CancelTask))
+
+	srv := httptest.NewServer(handler.BearerAuth(token, mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientTaskLifecycle(t *testing.T) {
+	srv := newTestServer(t, "secret")
+	c := New(srv.URL+"/", "secret")
+	ctx := context.Background()
+
+	task, err := c.CreateTask(ctx, CreateTaskRequest{
+		Prompt:       "add a test",
+		Timeout:      30,
+		AllowedPaths: []string{"docs/**"},
+	})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if task.Prompt != "add a test" || task.Status != "backlog" || task.Timeout != 30 {
+		t.Fatalf("created task = %+v", task)
+	}
+	if len(task.AllowedPaths) != 1 || task.AllowedPaths[0] != "docs/**" {
+		t.Fatalf("AllowedPaths = %v", task.AllowedPaths)
+	}
+
+	got, err := c.GetTask(ctx, task.ID.String()[:8])
+	if err != nil {
+		t.Fatalf("GetTask by prefix: %v", err)
+	}
+	if got.ID != task.ID {
+		t.Fatalf("GetTask returned %s, want %s", got.ID, task.ID)
+	}
+
+	if err := c.CancelTask(ctx, task.ID); err != nil {
+		t.Fatalf("CancelTask: %v", err)
+	}
+	tasks, err := c.ListTasks(ctx, false)
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Status != "cancelled" {
+		t.Fatalf("tasks after cancel = %+v", tasks)
+	}
+
+	// Cancelling again is rejected by the server.
+	var apiErr *APIError
+	if err := c.CancelTask(ctx, task.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("second CancelTask error = %v, want 400 APIError", err)
+	}
+}
+
+func TestClientIdempotencyKey(t *testing.T) {
+	c := New(newTestServer(t, "").URL, "")
+	ctx := context.Background()
+	req := CreateTaskRequest{Prompt: "once", IdempotencyKey: "k1"}
+	a, err := c.CreateTask(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.CreateTask(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != b.ID {
+		t.Fatalf("retried create made a new task: %s != %s", a.ID, b.ID)
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	c := New(newTestServer(t, "secret").URL, "wrong")
+	_, err := c.ListTasks(context.Background(), false)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("error = %v, want 401 APIError", err)
+	}
+}
+
+func TestClientInstructions(t *testing.T) {
+	c := New(newTestServer(t, "").URL, "")
+	ctx := context.Background()
+
+	content, err := c.GetInstructions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "" {
+		t.Fatalf("initial instructions = %q, want empty", content)
+	}
+	if err := c.UpdateInstructions(ctx, "# Rules\n"); err != nil {
+		t.Fatal(err)
+	}
+	content, err = c.GetInstructions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "# Rules\n" {
+		t.Fatalf("instructions = %q", content)
+	}
+}
+
+func TestClientStreamTasks(t *testing.T) {
+	c := New(newTestServer(t, "").URL, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates := make(chan []store.Task)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.StreamTasks(ctx, false, func(tasks []store.Task) error {
+			select {
+			case updates <- tasks:
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}()
+
+	if initial := <-updates; len(initial) != 0 {
+		t.Fatalf("initial snapshot has %d tasks, want 0", len(initial))
+	}
+	task, err := c.CreateTask(ctx, CreateTaskRequest{Prompt: "streamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case tasks := <-updates:
+			if len(tasks) == 1 && tasks[0].ID == task.ID {
+				cancel()
+				if err := <-done; !errors.Is(err, context.Canceled) {
+					t.Fatalf("StreamTasks returned %v, want context.Canceled", err)
+				}
+				return
+			}
+		case err := <-done:
+			t.Fatalf("stream ended early: %v", err)
+		}
+	}
+}
@@ -1,59 +1,265 @@
 package gitutil
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// RebaseOptions customises the git rebase run by RebaseOntoDefault.
+type RebaseOptions struct {
+	// Args are extra flags appended to `git rebase` (e.g. --autosquash).
+	// They must pass ValidateRebaseArgs.
+	Args []string
+	// Rerere enables git's reuse-recorded-resolution in the repository's
+	// config so that conflicts resolved once are replayed automatically by
+	// later rebases.
+	Rerere bool
+	// DefaultBranch is the branch to rebase onto. Empty resolves it with
+	// DefaultBranch; callers running several git operations against the same
+	// repo resolve it once and pass it here to save the lookups.
+	DefaultBranch string
+}
+
+// forbiddenRebaseArgs are rebase flags that would run arbitrary commands,
+// change the rebase target, or control an in-progress rebase.
+var forbiddenRebaseArgs = map[string]bool{
+	"-x": true, "--exec": true,
+	"-i": true, "--interactive": true, "--edit-todo": true,
+	"--onto": true, "--root": true,
+	"--continue": true, "--abort": true, "--skip": true, "--quit": true,
+}
+
+// shortRebaseArgsWithValue are the short rebase flags whose value may be
+// attached to them (e.g. -Xours); the rest of such a cluster is that value.
+const shortRebaseArgsWithValue = "sXCS"
+
+// ValidateRebaseArgs rejects extra rebase arguments that are not flags or
+// that appear in the forbidden set (e.g. --exec). Since git accepts any
+// unambiguous prefix of a long option, abbreviations such as --exe or --ont
+// are rejected too. Bundled short flags such as -ix are rejected when any of
+// them is -x or -i.
+func ValidateRebaseArgs(args []string) error {
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			return fmt.Errorf("rebase argument %q is not a flag", a)
+		}
+		name, _, _ := strings.Cut(a, "=")
+		if forbiddenRebaseArgs[name] || forbiddenLongPrefix(name) || forbiddenShortCluster(a) {
+			return fmt.Errorf("rebase argument %q is not allowed", a)
+		}
+	}
+	return nil
+}
+
+// forbiddenLongPrefix reports whether name is a double-dash option that git
+// could expand to one of the forbidden long options.
+func forbiddenLongPrefix(name string) bool {
+	if !strings.HasPrefix(name, "--") {
+		return false
+	}
+	for opt := range forbiddenRebaseArgs {
+		if strings.HasPrefix(opt, "--") && strings.HasPrefix(opt, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenShortCluster reports whether a single-dash argument bundles -x
+// or -i among its short flags.
+func forbiddenShortCluster(a string) bool {
+	if strings.HasPrefix(a, "--") {
+		return false
+	}
+	for _, c := range a[1:] {
+		if c == 'x' || c == 'i' {
+			return true
+		}
+		if strings.ContainsRune(shortRebaseArgsWithValue, c) {
+			return false
+		}
+	}
+	return false
+}
+
+// enableRerere turns on rerere with autoupdate in the repository's config.
+// Unlike a per-invocation -c, the setting is shared by every worktree, so
+// resolutions recorded while a conflict is resolved are reused later.
+func enableRerere(ctx context.Context, repoPath string) error {
+	for _, kv := range [][2]string{{"rerere.enabled", "true"}, {"rerere.autoupdate", "true"}} {
+		if out, err := combinedOutput(ctx, repoPath, "config", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("git config %s in %s: %w\n%s", kv[0], repoPath, err, out)
+		}
+	}
+	return nil
+}
+
+// rebaseInProgress reports whether worktreePath is stopped in the middle of
+// a rebase.
+func rebaseInProgress(ctx context.Context, worktreePath string) bool {
+	for _, state := range []string{"rebase-merge", "rebase-apply"} {
+		out, err := output(ctx, worktreePath, "rev-parse", "--git-path", state)
+		if err != nil {
+			return false
+		}
+		path := strings.TrimSpace(string(out))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(worktreePath, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // RebaseOntoDefault rebases the task branch (currently checked out in worktreePath)
 // onto the default branch of repoPath. On conflict it aborts the rebase and returns
-// ErrConflict so the caller can invoke conflict resolution and retry.
-func RebaseOntoDefault(repoPath, worktreePath string) error {
-	defBranch, err := DefaultBranch(repoPath)
-	if err != nil {
+// a *ConflictError (wrapping ErrConflict) listing the conflicted files, and
+// which of them are binary, so the caller can invoke conflict resolution and
+// retry. The rebase is killed when
+// ctx is done; the follow-up abort still runs so the worktree is not left
+// mid-rebase. With opts.Rerere, conflicts that a recorded resolution fully
+// resolves do not stop the rebase.
+func RebaseOntoDefault(ctx context.Context, repoPath, worktreePath string, opts RebaseOptions) error {
+	if err := ValidateRebaseArgs(opts.Args); err != nil {
 		return err
 	}
-	out, err := exec.Command("git", "-C", worktreePath, "rebase", defBranch).CombinedOutput()
+	defBranch := opts.DefaultBranch
+	if defBranch == "" {
+		var err error
+		if defBranch, err = DefaultBranch(ctx, repoPath); err != nil {
+			return err
+		}
+	}
+	if opts.Rerere {
+		if err := enableRerere(ctx, repoPath); err != nil {
+			return err
+		}
+	}
+	args := []string{"-C", worktreePath, "rebase"}
+	autosquash := false
+	for _, a := range opts.Args {
+		if a == "--autosquash" {
+			autosquash = true
+		}
+	}
+	if autosquash {
+		// Before git 2.44 --autosquash only takes effect in interactive mode;
+		// accept the generated todo list unchanged so no editor is opened.
+		args = append(args, "-i")
+	}
+	args = append(args, opts.Args...)
+	args = append(args, defBranch)
+	cmd := Command(ctx, args...)
+	cmd.Env = append(cmd.Env, "GIT_SEQUENCE_EDITOR=true", "GIT_EDITOR=true")
+	out, err := timed(cmd, cmd.CombinedOutput)
+	for err != nil && opts.Rerere && rebaseInProgress(ctx, worktreePath) {
+		// rerere has staged its recorded resolutions; carry on when they
+		// left nothing unmerged, as long as each step makes progress.
+		if files, ferr := ConflictedFiles(ctx, worktreePath); ferr != nil || len(files) > 0 {
+			break
+		}
+		head, _ := output(ctx, worktreePath, "rev-parse", "HEAD")
+		cont := Command(ctx, "-C", worktreePath, "rebase", "--continue")
+		cont.Env = cmd.Env
+		out, err = timed(cont, cont.CombinedOutput)
+		if after, _ := output(ctx, worktreePath, "rev-parse", "HEAD"); err != nil && bytes.Equal(head, after) {
+			break
+		}
+	}
 	if err != nil {
+		// Capture the unmerged paths before aborting discards them; their
+		// presence, not git's message text, decides whether this was a
+		// conflict.
+		files, _ := ConflictedFiles(context.Background(), worktreePath)
+		// Binary files get no conflict markers, so a text-based resolver
+		// cannot fix them. Compare the upstream side (HEAD) with the commit
+		// being replayed (REBASE_HEAD) to find them.
+		binary, _ := BinaryFiles(context.Background(), worktreePath, "HEAD", "REBASE_HEAD", files)
 		// Abort so the repo is not stuck mid-rebase.
-		exec.Command("git", "-C", worktreePath, "rebase", "--abort").Run()
-		if IsConflictOutput(string(out)) {
-			return fmt.Errorf("%w in %s", ErrConflict, worktreePath)
+		run(context.Background(), worktreePath, "rebase", "--abort")
+		if len(files) > 0 {
+			return &ConflictError{Path: worktreePath, Files: files, Binary: binary}
 		}
 		return fmt.Errorf("git rebase in %s: %w\n%s", worktreePath, err, out)
 	}
 	return nil
 }
 
+// ResetHard resets the worktree to commit and removes untracked files, so
+// the working tree matches commit exactly (ignored files are kept).
+func ResetHard(ctx context.Context, worktreePath, commit string) error {
+	if out, err := combinedOutput(ctx, worktreePath, "reset", "--hard", commit); err != nil {
+		return fmt.Errorf("git reset --hard %s in %s: %w\n%s", commit, worktreePath, err, out)
+	}
+	if out, err := combinedOutput(ctx, worktreePath, "clean", "-fd"); err != nil {
+		return fmt.Errorf("git clean in %s: %w\n%s", worktreePath, err, out)
+	}
+	return nil
+}
+
 // FFMerge fast-forward merges branchName into the default branch of repoPath.
-func FFMerge(repoPath, branchName string) error {
-	defBranch, err := DefaultBranch(repoPath)
+func FFMerge(ctx context.Context, repoPath, branchName string) error {
+	defBranch, err := DefaultBranch(ctx, repoPath)
 	if err != nil {
 		return err
 	}
-	if out, err := exec.Command("git", "-C", repoPath, "checkout", defBranch).CombinedOutput(); err != nil {
-		return fmt.Errorf("git checkout %s in %s: %w\n%s", defBranch, repoPath, err, out)
+	return FFMergeInto(ctx, repoPath, defBranch, branchName)
+}
+
+// FFMergeInto fast-forward merges branchName into defBranch of repoPath, for
+// callers that have already resolved the default branch. When defBranch is
+// checked out in repoPath it is merged there so the working tree follows;
+// otherwise only the ref is advanced, leaving the user's checkout (and any
+// uncommitted changes in it) alone.
+func FFMergeInto(ctx context.Context, repoPath, defBranch, branchName string) error {
+	if cur, err := output(ctx, repoPath, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil && strings.TrimSpace(string(cur)) == defBranch {
+		out, err := combinedOutput(ctx, repoPath, "merge", "--ff-only", branchName)
+		if err != nil {
+			return fmt.Errorf("git merge --ff-only %s in %s: %w\n%s", branchName, repoPath, err, out)
+		}
+		return nil
 	}
-	out, err := exec.Command("git", "-C", repoPath, "merge", "--ff-only", branchName).CombinedOutput()
+	ref := "refs/heads/" + defBranch
+	old, err := GetCommitHashForRef(ctx, repoPath, ref)
 	if err != nil {
-		return fmt.Errorf("git merge --ff-only %s in %s: %w\n%s", branchName, repoPath, err, out)
+		return err
+	}
+	tip, err := GetCommitHashForRef(ctx, repoPath, branchName+"^{commit}")
+	if err != nil {
+		return err
+	}
+	if err := run(ctx, repoPath, "merge-base", "--is-ancestor", old, tip); err != nil {
+		return fmt.Errorf("%s is not a fast-forward of %s in %s: %w", branchName, defBranch, repoPath, err)
+	}
+	// Passing the old value makes the update fail if defBranch moved since.
+	if out, err := combinedOutput(ctx, repoPath, "update-ref", ref, tip, old); err != nil {
+		return fmt.Errorf("git update-ref %s in %s: %w\n%s", ref, repoPath, err, out)
 	}
 	return nil
 }
 
 // CommitsBehind returns the number of commits the default branch has ahead of
 // the worktree's HEAD (i.e. how many commits the task branch is behind).
-func CommitsBehind(repoPath, worktreePath string) (int, error) {
-	defBranch, err := DefaultBranch(repoPath)
+func CommitsBehind(ctx context.Context, repoPath, worktreePath string) (int, error) {
+	defBranch, err := DefaultBranch(ctx, repoPath)
 	if err != nil {
 		return 0, err
 	}
-	out, err := exec.Command(
-		"git", "-C", worktreePath,
+	return CommitsBehindBranch(ctx, worktreePath, defBranch)
+}
+
+// CommitsBehindBranch is CommitsBehind for an already resolved default branch.
+func CommitsBehindBranch(ctx context.Context, worktreePath, defBranch string) (int, error) {
+	out, err := output(ctx, worktreePath,
 		"rev-list", "--count", "HEAD.."+defBranch,
-	).Output()
+	)
 	if err != nil {
 		return 0, fmt.Errorf("git rev-list in %s: %w", worktreePath, err)
 	}
@@ -62,11 +268,10 @@ func CommitsBehind(repoPath, worktreePath string) (int, error) {
 }
 
 // HasCommitsAheadOf reports whether worktreePath has commits not yet in baseBranch.
-func HasCommitsAheadOf(worktreePath, baseBranch string) (bool, error) {
-	out, err := exec.Command(
-		"git", "-C", worktreePath,
+func HasCommitsAheadOf(ctx context.Context, worktreePath, baseBranch string) (bool, error) {
+	out, err := output(ctx, worktreePath,
 		"rev-list", "--count", baseBranch+"..HEAD",
-	).Output()
+	)
 	if err != nil {
 		return false, fmt.Errorf("git rev-list in %s: %w", worktreePath, err)
 	}
@@ -76,17 +281,61 @@ func HasCommitsAheadOf(worktreePath, baseBranch string) (bool, error) {
 
 // MergeBase returns the best common ancestor (merge-base) of two refs,
 // evaluated in the given repository/worktree path.
-func MergeBase(repoPath, ref1, ref2 string) (string, error) {
-	out, err := exec.Command("git", "-C", repoPath, "merge-base", ref1, ref2).Output()
+func MergeBase(ctx context.Context, repoPath, ref1, ref2 string) (string, error) {
+	out, err := output(ctx, repoPath, "merge-base", ref1, ref2)
 	if err != nil {
 		return "", fmt.Errorf("git merge-base %s %s in %s: %w", ref1, ref2, repoPath, err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-// IsConflictOutput reports whether git output text indicates a merge conflict.
-func IsConflictOutput(s string) bool {
-	return strings.Contains(s, "CONFLICT") ||
-		strings.Contains(s, "Merge conflict") ||
-		strings.Contains(s, "conflict")
+// ConflictedFiles returns the paths with unresolved merge conflicts in
+// worktreePath, relative to its root. It reads the unmerged ("u") entries of
+// `git status --porcelain=v2`, which are stable across git versions and
+// locales, rather than parsing human-readable output. A non-empty result
+// after a failed rebase or merge is the definitive sign of a conflict.
+func ConflictedFiles(ctx context.Context, worktreePath string) ([]string, error) {
+	out, err := output(ctx, worktreePath,
+		"status", "--porcelain=v2", "-z", "--untracked-files=no",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("git status in %s: %w", worktreePath, err)
+	}
+	var files []string
+	records := strings.Split(string(out), "\x00")
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		switch {
+		case strings.HasPrefix(rec, "u "):
+			// u <XY> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>
+			if f := strings.SplitN(rec, " ", 11); len(f) == 11 {
+				files = append(files, f[10])
+			}
+		case strings.HasPrefix(rec, "2 "):
+			i++ // rename/copy entries carry the original path as an extra record
+		}
+	}
+	return files, nil
+}
+
+// BinaryFiles returns the subset of paths that git treats as binary in the
+// diff between the from and to revisions in dir, i.e. those reported with
+// "-" for both line counts by `git diff --numstat`.
+func BinaryFiles(ctx context.Context, dir, from, to string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"diff", "--numstat", "-z", "--no-renames", from, to, "--"}, paths...)
+	out, err := output(ctx, dir, args...)
+	if err != nil {
+		return nil, fmt.Errorf("git diff --numstat in %s: %w", dir, err)
+	}
+	var binary []string
+	for _, rec := range strings.Split(string(out), "\x00") {
+		// <added>\t<deleted>\t<path>
+		if f := strings.SplitN(rec, "\t", 3); len(f) == 3 && f[0] == "-" && f[1] == "-" {
+			binary = append(binary, f[2])
+		}
+	}
+	return binary, nil
 }
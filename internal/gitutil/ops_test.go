@@ -1,30 +1,16 @@
 package gitutil
 
 import (
+	"context"
 	"errors"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestIsConflictOutput(t *testing.T) {
-	cases := []struct {
-		in   string
-		want bool
-	}{
-		{"CONFLICT (content): Merge conflict in file.txt", true},
-		{"Merge conflict detected", true},
-		{"auto-merging file; conflict detected", true},
-		{"Already up to date.", false},
-		{"Fast-forward\n file.txt | 1 +", false},
-		{"", false},
-	}
-	for _, c := range cases {
-		if got := IsConflictOutput(c.in); got != c.want {
-			t.Errorf("IsConflictOutput(%q) = %v, want %v", c.in, got, c.want)
-		}
-	}
-}
-
 func TestMergeBase(t *testing.T) {
 	t.Run("returns correct ancestor for diverged branches", func(t *testing.T) {
 		repo := setupRepo(t)
@@ -45,7 +31,7 @@ func TestMergeBase(t *testing.T) {
 		gitRun(t, wtDir, "add", ".")
 		gitRun(t, wtDir, "commit", "-m", "task advance")
 
-		base, err := MergeBase(wtDir, "HEAD", "main")
+		base, err := MergeBase(context.Background(), wtDir, "HEAD", "main")
 		if err != nil {
 			t.Fatalf("MergeBase: %v", err)
 		}
@@ -56,7 +42,7 @@ func TestMergeBase(t *testing.T) {
 
 	t.Run("returns error for invalid refs", func(t *testing.T) {
 		repo := setupRepo(t)
-		_, err := MergeBase(repo, "HEAD", "nonexistent-branch")
+		_, err := MergeBase(context.Background(), repo, "HEAD", "nonexistent-branch")
 		if err == nil {
 			t.Error("expected error for invalid ref, got nil")
 		}
@@ -70,7 +56,7 @@ func TestCommitsBehind(t *testing.T) {
 		gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
 		t.Cleanup(func() { RemoveWorktree(repo, wtDir, "task") })
 
-		n, err := CommitsBehind(repo, wtDir)
+		n, err := CommitsBehind(context.Background(), repo, wtDir)
 		if err != nil || n != 0 {
 			t.Errorf("CommitsBehind = %d, %v; want 0, nil", n, err)
 		}
@@ -88,7 +74,7 @@ func TestCommitsBehind(t *testing.T) {
 			gitRun(t, repo, "commit", "-m", f)
 		}
 
-		n, err := CommitsBehind(repo, wtDir)
+		n, err := CommitsBehind(context.Background(), repo, wtDir)
 		if err != nil || n != 2 {
 			t.Errorf("CommitsBehind = %d, %v; want 2, nil", n, err)
 		}
@@ -96,7 +82,7 @@ func TestCommitsBehind(t *testing.T) {
 
 	t.Run("non-git worktree path returns error", func(t *testing.T) {
 		repo := setupRepo(t)
-		if _, err := CommitsBehind(repo, t.TempDir()); err == nil {
+		if _, err := CommitsBehind(context.Background(), repo, t.TempDir()); err == nil {
 			t.Error("expected error, got nil")
 		}
 	})
@@ -105,7 +91,7 @@ func TestCommitsBehind(t *testing.T) {
 func TestHasCommitsAheadOf(t *testing.T) {
 	t.Run("false when at same commit", func(t *testing.T) {
 		repo := setupRepo(t)
-		ahead, err := HasCommitsAheadOf(repo, "main")
+		ahead, err := HasCommitsAheadOf(context.Background(), repo, "main")
 		if err != nil || ahead {
 			t.Errorf("HasCommitsAheadOf = %v, %v; want false, nil", ahead, err)
 		}
@@ -121,14 +107,14 @@ func TestHasCommitsAheadOf(t *testing.T) {
 		gitRun(t, wtDir, "add", ".")
 		gitRun(t, wtDir, "commit", "-m", "task commit")
 
-		ahead, err := HasCommitsAheadOf(wtDir, "main")
+		ahead, err := HasCommitsAheadOf(context.Background(), wtDir, "main")
 		if err != nil || !ahead {
 			t.Errorf("HasCommitsAheadOf = %v, %v; want true, nil", ahead, err)
 		}
 	})
 
 	t.Run("non-git path returns error", func(t *testing.T) {
-		if _, err := HasCommitsAheadOf(t.TempDir(), "main"); err == nil {
+		if _, err := HasCommitsAheadOf(context.Background(), t.TempDir(), "main"); err == nil {
 			t.Error("expected error, got nil")
 		}
 	})
@@ -149,7 +135,7 @@ func TestRebaseOntoDefault(t *testing.T) {
 		gitRun(t, wtDir, "add", ".")
 		gitRun(t, wtDir, "commit", "-m", "task change")
 
-		if err := RebaseOntoDefault(repo, wtDir); err != nil {
+		if err := RebaseOntoDefault(context.Background(), repo, wtDir, RebaseOptions{}); err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
 	})
@@ -168,11 +154,267 @@ func TestRebaseOntoDefault(t *testing.T) {
 		gitRun(t, wtDir, "add", ".")
 		gitRun(t, wtDir, "commit", "-m", "task: change file.txt")
 
-		err := RebaseOntoDefault(repo, wtDir)
+		err := RebaseOntoDefault(context.Background(), repo, wtDir, RebaseOptions{})
 		if !errors.Is(err, ErrConflict) {
 			t.Errorf("expected ErrConflict, got %v", err)
 		}
 	})
+
+	t.Run("conflict error lists conflicted files", func(t *testing.T) {
+		repo := setupRepo(t)
+		wtDir := filepath.Join(t.TempDir(), "wt")
+		gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
+		t.Cleanup(func() { RemoveWorktree(repo, wtDir, "task") })
+
+		writeFile(t, filepath.Join(repo, "file.txt"), "main version\n")
+		writeFile(t, filepath.Join(repo, "b.txt"), "main b\n")
+		writeFile(t, filepath.Join(repo, "clean.txt"), "main only\n")
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-m", "main: change files")
+
+		writeFile(t, filepath.Join(wtDir, "file.txt"), "task version\n")
+		writeFile(t, filepath.Join(wtDir, "b.txt"), "task b\n")
+		gitRun(t, wtDir, "add", ".")
+		gitRun(t, wtDir, "commit", "-m", "task: change files")
+
+		var ce *ConflictError
+		if err := RebaseOntoDefault(context.Background(), repo, wtDir, RebaseOptions{}); !errors.As(err, &ce) {
+			t.Fatalf("expected *ConflictError, got %v", err)
+		}
+		if got := strings.Join(ce.Files, ","); got != "b.txt,file.txt" {
+			t.Errorf("Files = %q, want %q", got, "b.txt,file.txt")
+		}
+		// The rebase must still have been aborted.
+		if out := gitRun(t, wtDir, "status", "--porcelain"); out != "" {
+			t.Errorf("worktree should be clean after abort, got:\n%s", out)
+		}
+	})
+}
+
+// TestRebaseOntoDefaultNonConflictFailure verifies that a rebase failing for
+// a reason other than a conflict is not reported as one, even when git's
+// output mentions a file named "conflict".
+func TestRebaseOntoDefaultNonConflictFailure(t *testing.T) {
+	repo := setupRepo(t)
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
+	t.Cleanup(func() { RemoveWorktree(repo, wtDir, "task") })
+
+	writeFile(t, filepath.Join(repo, "conflict.txt"), "main\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "main: add conflict.txt")
+
+	writeFile(t, filepath.Join(wtDir, "task.txt"), "task\n")
+	gitRun(t, wtDir, "add", ".")
+	gitRun(t, wtDir, "commit", "-m", "task change")
+	// An untracked file in the way makes the rebase refuse to start.
+	writeFile(t, filepath.Join(wtDir, "conflict.txt"), "untracked\n")
+
+	err := RebaseOntoDefault(context.Background(), repo, wtDir, RebaseOptions{})
+	if err == nil {
+		t.Fatal("expected rebase to fail")
+	}
+	if !strings.Contains(err.Error(), "conflict.txt") {
+		t.Fatalf("expected git output naming conflict.txt, got %v", err)
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Errorf("non-conflict failure reported as ErrConflict: %v", err)
+	}
+}
+
+func TestRebaseOntoDefaultBinaryConflict(t *testing.T) {
+	repo := setupRepo(t)
+	writeFile(t, filepath.Join(repo, "image.bin"), "base\x00binary")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "add image.bin")
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
+	t.Cleanup(func() { RemoveWorktree(repo, wtDir, "task") })
+
+	writeFile(t, filepath.Join(repo, "image.bin"), "main\x00binary")
+	writeFile(t, filepath.Join(repo, "notes.txt"), "main\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "main: change image.bin")
+
+	writeFile(t, filepath.Join(wtDir, "image.bin"), "task\x00binary")
+	writeFile(t, filepath.Join(wtDir, "notes.txt"), "task\n")
+	gitRun(t, wtDir, "add", ".")
+	gitRun(t, wtDir, "commit", "-m", "task: change image.bin")
+
+	err := RebaseOntoDefault(context.Background(), repo, wtDir, RebaseOptions{})
+	var ce *ConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected *ConflictError, got %v", err)
+	}
+	if len(ce.Binary) != 1 || ce.Binary[0] != "image.bin" {
+		t.Errorf("Binary = %v, want [image.bin]", ce.Binary)
+	}
+	if !strings.Contains(err.Error(), "binary conflict in image.bin") {
+		t.Errorf("error should name the binary conflict, got %v", err)
+	}
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("binary conflict should still wrap ErrConflict")
+	}
+}
+
+func TestConflictedFiles(t *testing.T) {
+	repo := setupRepo(t)
+	writeFile(t, filepath.Join(repo, "a b.txt"), "base\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "base")
+	gitRun(t, repo, "checkout", "-b", "side")
+	writeFile(t, filepath.Join(repo, "a b.txt"), "side\n")
+	gitRun(t, repo, "commit", "-am", "side")
+	gitRun(t, repo, "checkout", "main")
+	writeFile(t, filepath.Join(repo, "a b.txt"), "main\n")
+	writeFile(t, filepath.Join(repo, "conflict.txt"), "clean\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "main")
+
+	files, err := ConflictedFiles(context.Background(), repo)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("before merge: %v, %v; want none", files, err)
+	}
+
+	exec.Command("git", "-C", repo, "merge", "side").Run() // conflicts
+	files, err = ConflictedFiles(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(files, ","); got != "a b.txt" {
+		t.Errorf("ConflictedFiles = %q, want %q", got, "a b.txt")
+	}
+}
+
+func TestRebaseOntoDefaultAutosquash(t *testing.T) {
+	repo := setupRepo(t)
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
+	t.Cleanup(func() { RemoveWorktree(repo, wtDir, "task") })
+
+	writeFile(t, filepath.Join(repo, "main-only.txt"), "main\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "main change")
+
+	writeFile(t, filepath.Join(wtDir, "feature.txt"), "v1\n")
+	gitRun(t, wtDir, "add", ".")
+	gitRun(t, wtDir, "commit", "-m", "add feature")
+	writeFile(t, filepath.Join(wtDir, "feature.txt"), "v2\n")
+	gitRun(t, wtDir, "add", ".")
+	gitRun(t, wtDir, "commit", "-m", "fixup! add feature")
+
+	opts := RebaseOptions{Args: []string{"--autosquash"}, Rerere: true}
+	if err := RebaseOntoDefault(context.Background(), repo, wtDir, opts); err != nil {
+		t.Fatalf("RebaseOntoDefault: %v", err)
+	}
+	if got := gitRun(t, wtDir, "rev-list", "--count", "main..HEAD"); got != "1" {
+		t.Errorf("expected fixup to be folded into 1 commit, got %s", got)
+	}
+	if got := gitRun(t, wtDir, "show", "HEAD:feature.txt"); got != "v2" {
+		t.Errorf("feature.txt = %q, want v2", got)
+	}
+}
+
+func TestRebaseOntoDefaultReusesRecordedResolution(t *testing.T) {
+	repo := setupRepo(t)
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
+	t.Cleanup(func() { RemoveWorktree(repo, wtDir, "task") })
+
+	writeFile(t, filepath.Join(repo, "file.txt"), "main version\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "main: change file.txt")
+	writeFile(t, filepath.Join(wtDir, "file.txt"), "task version\n")
+	gitRun(t, wtDir, "add", ".")
+	gitRun(t, wtDir, "commit", "-m", "task: change file.txt")
+	taskHead := gitRun(t, wtDir, "rev-parse", "HEAD")
+
+	opts := RebaseOptions{Rerere: true}
+	if err := RebaseOntoDefault(context.Background(), repo, wtDir, opts); !errors.Is(err, ErrConflict) {
+		t.Fatalf("first rebase: expected ErrConflict, got %v", err)
+	}
+	if got := gitRun(t, repo, "config", "rerere.enabled"); got != "true" {
+		t.Fatalf("rerere.enabled = %q, want true in the repo config", got)
+	}
+
+	// Resolve the conflict by hand, as the conflict resolver would, so
+	// rerere records the resolution.
+	exec.Command("git", "-C", wtDir, "rebase", "main").Run() // stops on the conflict
+	writeFile(t, filepath.Join(wtDir, "file.txt"), "resolved\n")
+	gitRun(t, wtDir, "add", "file.txt")
+	gitRun(t, wtDir, "-c", "core.editor=true", "rebase", "--continue")
+
+	// The same conflict again is resolved from the recording.
+	gitRun(t, wtDir, "reset", "--hard", taskHead)
+	if err := RebaseOntoDefault(context.Background(), repo, wtDir, opts); err != nil {
+		t.Fatalf("second rebase: %v", err)
+	}
+	if got := gitRun(t, wtDir, "show", "HEAD:file.txt"); got != "resolved" {
+		t.Errorf("file.txt = %q, want the recorded resolution", got)
+	}
+	if got := gitRun(t, wtDir, "rev-list", "--count", "main..HEAD"); got != "1" {
+		t.Errorf("expected the task commit on top of main, got %s commits", got)
+	}
+}
+
+func TestValidateRebaseArgs(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"--autosquash"},
+		{"--rerere-autoupdate", "--no-verify"},
+		{"-Xours"},
+		{"-Xignore-space-change"},
+		{"-kv"},
+		{"--empty=drop"},
+		{"--strategy-option=ours"},
+		{"--ignore-date"},
+	} {
+		if err := ValidateRebaseArgs(args); err != nil {
+			t.Errorf("ValidateRebaseArgs(%q) = %v, want nil", args, err)
+		}
+	}
+	for _, args := range [][]string{
+		{"--exec", "rm -rf /"},
+		{"--exec=make"},
+		{"-x"},
+		{"-xmake"},
+		{"-ixcmd"},
+		{"-vi"},
+		{"-i"},
+		{"--onto"},
+		{"--exe='echo PWNED'"},
+		{"--ex", "make"},
+		{"--inter"},
+		{"--ont=main"},
+		{"--ro"},
+		{"--edit"},
+		{"--cont"},
+		{"--"},
+		{"main"},
+	} {
+		if err := ValidateRebaseArgs(args); err == nil {
+			t.Errorf("ValidateRebaseArgs(%q) = nil, want error", args)
+		}
+	}
+}
+
+func TestResetHard(t *testing.T) {
+	repo := setupRepo(t)
+	base := gitRun(t, repo, "rev-parse", "HEAD")
+
+	writeFile(t, filepath.Join(repo, "file.txt"), "changed\n")
+	gitRun(t, repo, "commit", "-am", "bad change")
+	writeFile(t, filepath.Join(repo, "untracked.txt"), "junk\n")
+
+	if err := ResetHard(context.Background(), repo, base); err != nil {
+		t.Fatalf("ResetHard: %v", err)
+	}
+	if got := gitRun(t, repo, "rev-parse", "HEAD"); got != base {
+		t.Errorf("HEAD = %s, want %s", got, base)
+	}
+	if out := gitRun(t, repo, "status", "--porcelain"); out != "" {
+		t.Errorf("expected clean tree after reset, got:\n%s", out)
+	}
 }
 
 func TestFFMerge(t *testing.T) {
@@ -184,7 +426,7 @@ func TestFFMerge(t *testing.T) {
 		gitRun(t, repo, "commit", "-m", "task commit")
 		gitRun(t, repo, "checkout", "main")
 
-		if err := FFMerge(repo, "task"); err != nil {
+		if err := FFMerge(context.Background(), repo, "task"); err != nil {
 			t.Errorf("FFMerge failed: %v", err)
 		}
 	})
@@ -201,8 +443,78 @@ func TestFFMerge(t *testing.T) {
 		gitRun(t, repo, "add", ".")
 		gitRun(t, repo, "commit", "-m", "diverging main commit")
 
-		if err := FFMerge(repo, "task"); err == nil {
+		if err := FFMerge(context.Background(), repo, "task"); err == nil {
+			t.Error("expected error for non-ff merge, got nil")
+		}
+	})
+
+	t.Run("branch not checked out is advanced without checkout", func(t *testing.T) {
+		repo := setupRepo(t)
+		gitRun(t, repo, "branch", "develop")
+		gitRun(t, repo, "checkout", "-b", "task", "develop")
+		writeFile(t, filepath.Join(repo, "task.txt"), "task\n")
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-m", "task commit")
+		gitRun(t, repo, "checkout", "main")
+		writeFile(t, filepath.Join(repo, "file.txt"), "uncommitted\n")
+		taskTip := gitRun(t, repo, "rev-parse", "task")
+
+		if err := FFMergeInto(context.Background(), repo, "develop", "task"); err != nil {
+			t.Fatalf("FFMergeInto failed: %v", err)
+		}
+		if got := gitRun(t, repo, "rev-parse", "develop"); got != taskTip {
+			t.Errorf("develop = %s, want %s", got, taskTip)
+		}
+		if got := gitRun(t, repo, "branch", "--show-current"); got != "main" {
+			t.Errorf("checked-out branch = %q, want main", got)
+		}
+		if got := gitRun(t, repo, "status", "--porcelain"); got != "M file.txt" {
+			t.Errorf("uncommitted change lost; status:\n%s", got)
+		}
+	})
+
+	t.Run("branch not checked out refuses non-ff", func(t *testing.T) {
+		repo := setupRepo(t)
+		gitRun(t, repo, "checkout", "-b", "develop")
+		writeFile(t, filepath.Join(repo, "develop.txt"), "develop\n")
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-m", "develop commit")
+		gitRun(t, repo, "checkout", "-b", "task", "main")
+		writeFile(t, filepath.Join(repo, "task.txt"), "task\n")
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-m", "task commit")
+		gitRun(t, repo, "checkout", "main")
+		developTip := gitRun(t, repo, "rev-parse", "develop")
+
+		if err := FFMergeInto(context.Background(), repo, "develop", "task"); err == nil {
 			t.Error("expected error for non-ff merge, got nil")
 		}
+		if got := gitRun(t, repo, "rev-parse", "develop"); got != developTip {
+			t.Errorf("develop moved to %s on a rejected merge", got)
+		}
 	})
 }
+
+// TestGitCommandCancelled verifies that a hung git is killed when the
+// context deadline passes instead of blocking the caller forever.
+func TestGitCommandCancelled(t *testing.T) {
+	bin := t.TempDir()
+	// The sleep runs as a child so it keeps the output pipe open after the
+	// fake git itself is killed, like a stuck credential helper would.
+	writeFile(t, filepath.Join(bin, "git"), "#!/bin/sh\nsleep 30\n")
+	if err := os.Chmod(filepath.Join(bin, "git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := HasCommitsAheadOf(ctx, t.TempDir(), "main")
+	if err == nil {
+		t.Fatal("expected an error from the cancelled git command")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("git was not cancelled promptly: took %v", elapsed)
+	}
+}
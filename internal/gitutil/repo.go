@@ -1,27 +1,58 @@
 package gitutil
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
 // ErrConflict is returned by RebaseOntoDefault when a merge conflict is detected.
 var ErrConflict = errors.New("rebase conflict")
 
+// ConflictError describes a rebase that stopped on merge conflicts.
+// It wraps ErrConflict so errors.Is(err, ErrConflict) holds.
+type ConflictError struct {
+	Path   string   // worktree in which the rebase ran
+	Files  []string // conflicted paths relative to Path
+	Binary []string // subset of Files that are binary and cannot be merged as text
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Binary) > 0 {
+		return fmt.Sprintf("binary conflict in %s: %s in %s cannot be resolved as text",
+			strings.Join(e.Binary, ", "), ErrConflict, e.Path)
+	}
+	msg := fmt.Sprintf("%s in %s", ErrConflict, e.Path)
+	if len(e.Files) > 0 {
+		msg += " (" + strings.Join(e.Files, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *ConflictError) Unwrap() error { return ErrConflict }
+
 // IsGitRepo reports whether path is inside a git repository.
-func IsGitRepo(path string) bool {
-	return exec.Command("git", "-C", path, "rev-parse", "--git-dir").Run() == nil
+func IsGitRepo(ctx context.Context, path string) bool {
+	return run(ctx, path, "rev-parse", "--git-dir") == nil
+}
+
+// IsShallow reports whether the repository at path is a shallow clone,
+// whose truncated history may lack the merge-base a rebase needs.
+func IsShallow(path string) bool {
+	out, err := output(context.Background(), path, "rev-parse", "--is-shallow-repository")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
 }
 
 // DefaultBranch returns the default branch name for a repo (tries the current
 // local HEAD branch first, falls back to origin/HEAD, then "main").
-func DefaultBranch(repoPath string) (string, error) {
+func DefaultBranch(ctx context.Context, repoPath string) (string, error) {
 	// Prefer the currently checked-out branch so that tasks merge back to
 	// whatever branch the user is working on (e.g. "develop"), not the
 	// remote's default (which is typically "main").
-	out, err := exec.Command("git", "-C", repoPath, "branch", "--show-current").Output()
+	out, err := output(ctx, repoPath, "branch", "--show-current")
 	if err == nil {
 		branch := strings.TrimSpace(string(out))
 		if branch != "" {
@@ -29,7 +60,7 @@ func DefaultBranch(repoPath string) (string, error) {
 		}
 	}
 	// Detached HEAD — fall back to origin/HEAD (most reliable for cloned repos).
-	out, err = exec.Command("git", "-C", repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output()
+	out, err = output(ctx, repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
 	if err == nil {
 		// output is e.g. "origin/main" — strip the "origin/" prefix.
 		branch := strings.TrimSpace(strings.TrimPrefix(string(out), "origin/"))
@@ -40,28 +71,57 @@ func DefaultBranch(repoPath string) (string, error) {
 	return "main", nil
 }
 
+// BranchOverride returns the branch configured for repoPath in overrides,
+// keyed by the repo path or its basename (the path wins), or "" when
+// neither is present.
+func BranchOverride(repoPath string, overrides map[string]string) string {
+	if b := overrides[filepath.Clean(repoPath)]; b != "" {
+		return b
+	}
+	return overrides[filepath.Base(repoPath)]
+}
+
+// DefaultBranchWithOverride returns the branch configured for repoPath in
+// overrides (see BranchOverride) and falls back to DefaultBranch.
+func DefaultBranchWithOverride(ctx context.Context, repoPath string, overrides map[string]string) (string, error) {
+	if b := BranchOverride(repoPath, overrides); b != "" {
+		return b, nil
+	}
+	return DefaultBranch(ctx, repoPath)
+}
+
 // RemoteDefaultBranch returns the default branch of the "origin" remote
 // (e.g. "main" or "master"). It does NOT consider the current checkout.
 func RemoteDefaultBranch(repoPath string) string {
-	out, err := exec.Command("git", "-C", repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output()
+	out, err := output(context.Background(), repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
 	if err == nil {
 		branch := strings.TrimSpace(strings.TrimPrefix(string(out), "origin/"))
 		if branch != "" && branch != strings.TrimSpace(string(out)) {
 			return branch
 		}
 	}
-	if exec.Command("git", "-C", repoPath, "rev-parse", "--verify", "origin/main").Run() == nil {
+	if run(context.Background(), repoPath, "rev-parse", "--verify", "origin/main") == nil {
 		return "main"
 	}
-	if exec.Command("git", "-C", repoPath, "rev-parse", "--verify", "origin/master").Run() == nil {
+	if run(context.Background(), repoPath, "rev-parse", "--verify", "origin/master") == nil {
 		return "master"
 	}
 	return "main"
 }
 
+// RemoteURL returns the URL of the "origin" remote of repoPath, or "" when it
+// has none.
+func RemoteURL(repoPath string) string {
+	out, err := output(context.Background(), repoPath, "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
 // GetCommitHash returns the current HEAD commit hash in repoPath.
-func GetCommitHash(repoPath string) (string, error) {
-	out, err := exec.Command("git", "-C", repoPath, "rev-parse", "HEAD").Output()
+func GetCommitHash(ctx context.Context, repoPath string) (string, error) {
+	out, err := output(ctx, repoPath, "rev-parse", "HEAD")
 	if err != nil {
 		return "", fmt.Errorf("git rev-parse HEAD in %s: %w", repoPath, err)
 	}
@@ -69,10 +129,29 @@ func GetCommitHash(repoPath string) (string, error) {
 }
 
 // GetCommitHashForRef returns the commit hash for a specific ref in repoPath.
-func GetCommitHashForRef(repoPath, ref string) (string, error) {
-	out, err := exec.Command("git", "-C", repoPath, "rev-parse", ref).Output()
+func GetCommitHashForRef(ctx context.Context, repoPath, ref string) (string, error) {
+	out, err := output(ctx, repoPath, "rev-parse", ref)
 	if err != nil {
 		return "", fmt.Errorf("git rev-parse %s in %s: %w", ref, repoPath, err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
+
+// CreateTag creates the lightweight tag tagName pointing at ref in repoPath.
+// An existing tag is never moved: one that already points at ref's commit is
+// accepted, so a retried merge can tag again, and any other fails.
+func CreateTag(ctx context.Context, repoPath, tagName, ref string) error {
+	out, err := combinedOutput(ctx, repoPath, "tag", tagName, ref)
+	if err == nil {
+		return nil
+	}
+	existing, terr := output(ctx, repoPath, "rev-parse", "--verify", "-q", "refs/tags/"+tagName+"^{commit}")
+	if terr != nil {
+		return fmt.Errorf("git tag %s in %s: %w\n%s", tagName, repoPath, err, out)
+	}
+	want, rerr := output(ctx, repoPath, "rev-parse", "--verify", "-q", ref+"^{commit}")
+	if rerr == nil && bytes.Equal(existing, want) {
+		return nil
+	}
+	return fmt.Errorf("tag %s in %s already points at %s", tagName, repoPath, strings.TrimSpace(string(existing)))
+}
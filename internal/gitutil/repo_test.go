@@ -1,6 +1,7 @@
 package gitutil
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 )
@@ -10,21 +11,21 @@ func TestIsGitRepo(t *testing.T) {
 	plain := t.TempDir()
 	missing := filepath.Join(t.TempDir(), "no-such-dir")
 
-	if !IsGitRepo(repo) {
-		t.Errorf("IsGitRepo(%q) = false, want true", repo)
+	if !IsGitRepo(context.Background(), repo) {
+		t.Errorf("IsGitRepo(context.Background(), %q) = false, want true", repo)
 	}
-	if IsGitRepo(plain) {
-		t.Errorf("IsGitRepo(%q) = true, want false (plain dir)", plain)
+	if IsGitRepo(context.Background(), plain) {
+		t.Errorf("IsGitRepo(context.Background(), %q) = true, want false (plain dir)", plain)
 	}
-	if IsGitRepo(missing) {
-		t.Errorf("IsGitRepo(%q) = true, want false (missing path)", missing)
+	if IsGitRepo(context.Background(), missing) {
+		t.Errorf("IsGitRepo(context.Background(), %q) = true, want false (missing path)", missing)
 	}
 }
 
 func TestDefaultBranch(t *testing.T) {
 	t.Run("local HEAD branch without remote", func(t *testing.T) {
 		repo := setupRepo(t)
-		branch, err := DefaultBranch(repo)
+		branch, err := DefaultBranch(context.Background(), repo)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -41,7 +42,7 @@ func TestDefaultBranch(t *testing.T) {
 		gitRun(t, repo, "push", "origin", "main")
 		gitRun(t, repo, "remote", "set-head", "origin", "main")
 
-		branch, err := DefaultBranch(repo)
+		branch, err := DefaultBranch(context.Background(), repo)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -61,7 +62,7 @@ func TestDefaultBranch(t *testing.T) {
 		// Switch to a different branch — DefaultBranch should return it,
 		// not origin/HEAD (which is "main").
 		gitRun(t, repo, "checkout", "-b", "develop")
-		branch, err := DefaultBranch(repo)
+		branch, err := DefaultBranch(context.Background(), repo)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -75,7 +76,7 @@ func TestDefaultBranch(t *testing.T) {
 		hash := gitRun(t, repo, "rev-parse", "HEAD")
 		gitRun(t, repo, "checkout", hash)
 
-		branch, err := DefaultBranch(repo)
+		branch, err := DefaultBranch(context.Background(), repo)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -85,6 +86,31 @@ func TestDefaultBranch(t *testing.T) {
 	})
 }
 
+func TestDefaultBranchWithOverride(t *testing.T) {
+	repo := setupRepo(t)
+	cases := []struct {
+		name      string
+		overrides map[string]string
+		want      string
+	}{
+		{"no overrides auto-detects", nil, "main"},
+		{"basename", map[string]string{filepath.Base(repo): "develop"}, "develop"},
+		{"path wins over basename", map[string]string{filepath.Base(repo): "develop", repo: "release"}, "release"},
+		{"other repo ignored", map[string]string{"elsewhere": "develop"}, "main"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DefaultBranchWithOverride(context.Background(), repo, tc.overrides)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestGetCommitHashForRef(t *testing.T) {
 	t.Run("returns main HEAD when on different branch", func(t *testing.T) {
 		repo := setupRepo(t)
@@ -96,8 +122,8 @@ func TestGetCommitHashForRef(t *testing.T) {
 		gitRun(t, repo, "add", ".")
 		gitRun(t, repo, "commit", "-m", "feature commit")
 
-		// GetCommitHashForRef("main") should return main's HEAD, not feature's.
-		hash, err := GetCommitHashForRef(repo, "main")
+		// GetCommitHashForRef(context.Background(), "main") should return main's HEAD, not feature's.
+		hash, err := GetCommitHashForRef(context.Background(), repo, "main")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -106,7 +132,7 @@ func TestGetCommitHashForRef(t *testing.T) {
 		}
 
 		// Verify it differs from HEAD (which is on feature).
-		headHash, _ := GetCommitHash(repo)
+		headHash, _ := GetCommitHash(context.Background(), repo)
 		if hash == headHash {
 			t.Error("main hash should differ from HEAD (feature branch)")
 		}
@@ -114,13 +140,13 @@ func TestGetCommitHashForRef(t *testing.T) {
 
 	t.Run("error for invalid ref", func(t *testing.T) {
 		repo := setupRepo(t)
-		if _, err := GetCommitHashForRef(repo, "nonexistent-ref-xyz"); err == nil {
+		if _, err := GetCommitHashForRef(context.Background(), repo, "nonexistent-ref-xyz"); err == nil {
 			t.Error("expected error for invalid ref")
 		}
 	})
 
 	t.Run("non-git directory returns error", func(t *testing.T) {
-		if _, err := GetCommitHashForRef(t.TempDir(), "main"); err == nil {
+		if _, err := GetCommitHashForRef(context.Background(), t.TempDir(), "main"); err == nil {
 			t.Error("expected error for non-git path")
 		}
 	})
@@ -129,7 +155,7 @@ func TestGetCommitHashForRef(t *testing.T) {
 func TestGetCommitHash(t *testing.T) {
 	t.Run("valid repo returns 40-char SHA", func(t *testing.T) {
 		repo := setupRepo(t)
-		hash, err := GetCommitHash(repo)
+		hash, err := GetCommitHash(context.Background(), repo)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -139,8 +165,49 @@ func TestGetCommitHash(t *testing.T) {
 	})
 
 	t.Run("non-git directory returns error", func(t *testing.T) {
-		if _, err := GetCommitHash(t.TempDir()); err == nil {
+		if _, err := GetCommitHash(context.Background(), t.TempDir()); err == nil {
 			t.Error("expected error for non-git path, got nil")
 		}
 	})
+
+	t.Run("cancelled context returns error", func(t *testing.T) {
+		repo := setupRepo(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := GetCommitHash(ctx, repo); err == nil {
+			t.Error("expected error for cancelled context, got nil")
+		}
+		if IsGitRepo(ctx, repo) {
+			t.Error("IsGitRepo should report false for a cancelled context")
+		}
+	})
+}
+
+func TestCreateTag(t *testing.T) {
+	repo := setupRepo(t)
+	head := gitRun(t, repo, "rev-parse", "HEAD")
+
+	if err := CreateTag(context.Background(), repo, "wallfacer/abcd1234", head); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	if got := gitRun(t, repo, "rev-parse", "wallfacer/abcd1234"); got != head {
+		t.Errorf("tag resolves to %q, want %q", got, head)
+	}
+
+	// Tagging the same commit again is accepted.
+	if err := CreateTag(context.Background(), repo, "wallfacer/abcd1234", head); err != nil {
+		t.Fatalf("CreateTag (same commit): %v", err)
+	}
+
+	// An existing tag is never moved to another commit.
+	writeFile(t, filepath.Join(repo, "next.txt"), "next\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "next")
+	next := gitRun(t, repo, "rev-parse", "HEAD")
+	if err := CreateTag(context.Background(), repo, "wallfacer/abcd1234", next); err == nil {
+		t.Fatal("CreateTag moved an existing tag to another commit")
+	}
+	if got := gitRun(t, repo, "rev-parse", "wallfacer/abcd1234"); got != head {
+		t.Errorf("tag resolves to %q after a refused move, want %q", got, head)
+	}
 }
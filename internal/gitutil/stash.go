@@ -1,28 +1,29 @@
 package gitutil
 
 import (
-	"log/slog"
-	"os/exec"
+	"context"
 	"strings"
+
+	"changkun.de/wallfacer/internal/logger"
 )
 
 // StashIfDirty stashes uncommitted changes in worktreePath if the working tree
 // is dirty. Returns true if a stash entry was created.
 func StashIfDirty(worktreePath string) bool {
-	out, _ := exec.Command("git", "-C", worktreePath, "status", "--porcelain").Output()
+	out, _ := output(context.Background(), worktreePath, "status", "--porcelain")
 	if len(strings.TrimSpace(string(out))) == 0 {
 		return false
 	}
-	err := exec.Command("git", "-C", worktreePath, "stash", "--include-untracked").Run()
+	err := run(context.Background(), worktreePath, "stash", "--include-untracked")
 	return err == nil
 }
 
 // StashPop restores the most recent stash entry.
 // Errors are logged at warn level but are not fatal.
 func StashPop(worktreePath string) {
-	out, err := exec.Command("git", "-C", worktreePath, "stash", "pop").CombinedOutput()
+	out, err := combinedOutput(context.Background(), worktreePath, "stash", "pop")
 	if err != nil {
-		slog.Default().With("component", "git").Warn("stash pop failed",
+		logger.Git.Warn("stash pop failed",
 			"path", worktreePath, "error", err, "output", string(out))
 	}
 }
@@ -1,12 +1,19 @@
 package gitutil
 
 import (
-	"os/exec"
+	"context"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"changkun.de/wallfacer/internal/logger"
 )
 
+// refreshFetchTimeout bounds the fetch of WorkspaceStatusRefresh, so an
+// unreachable remote delays the status instead of hanging it.
+const refreshFetchTimeout = 30 * time.Second
+
 // WorkspaceGitStatus holds the git state for a single workspace directory.
 type WorkspaceGitStatus struct {
 	Path             string `json:"path"`
@@ -27,27 +34,27 @@ func WorkspaceStatus(path string) WorkspaceGitStatus {
 		Name: filepath.Base(path),
 	}
 
-	if err := exec.Command("git", "-C", path, "rev-parse", "--git-dir").Run(); err != nil {
+	if err := run(context.Background(), path, "rev-parse", "--git-dir"); err != nil {
 		return s
 	}
 	s.IsGitRepo = true
 
-	if out, err := exec.Command("git", "-C", path, "branch", "--show-current").Output(); err == nil {
+	if out, err := output(context.Background(), path, "branch", "--show-current"); err == nil {
 		s.Branch = strings.TrimSpace(string(out))
 	}
 
 	// Does it have a remote tracking branch?
-	if err := exec.Command("git", "-C", path, "rev-parse", "--abbrev-ref", "@{u}").Run(); err != nil {
+	if err := run(context.Background(), path, "rev-parse", "--abbrev-ref", "@{u}"); err != nil {
 		return s
 	}
 	s.HasRemote = true
 
-	if out, err := exec.Command("git", "-C", path, "rev-list", "--count", "@{u}..HEAD").Output(); err == nil {
+	if out, err := output(context.Background(), path, "rev-list", "--count", "@{u}..HEAD"); err == nil {
 		n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
 		s.AheadCount = n
 	}
 
-	if out, err := exec.Command("git", "-C", path, "rev-list", "--count", "HEAD..@{u}").Output(); err == nil {
+	if out, err := output(context.Background(), path, "rev-list", "--count", "HEAD..@{u}"); err == nil {
 		n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
 		s.BehindCount = n
 	}
@@ -56,7 +63,7 @@ func WorkspaceStatus(path string) WorkspaceGitStatus {
 	mainBranch := RemoteDefaultBranch(path)
 	s.MainBranch = mainBranch
 	if s.Branch != "" && s.Branch != mainBranch {
-		if out, err := exec.Command("git", "-C", path, "rev-list", "--count", "HEAD..origin/"+mainBranch).Output(); err == nil {
+		if out, err := output(context.Background(), path, "rev-list", "--count", "HEAD..origin/"+mainBranch); err == nil {
 			n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
 			s.BehindMainCount = n
 		}
@@ -64,3 +71,22 @@ func WorkspaceStatus(path string) WorkspaceGitStatus {
 
 	return s
 }
+
+// WorkspaceStatusRefresh is WorkspaceStatus preceded by `git fetch --quiet
+// origin`, so BehindCount and BehindMainCount reflect the remote as it is now
+// rather than as of the last fetch. The fetch is skipped for directories
+// that are not git repositories and is cancelled with ctx, e.g. when the
+// requesting client goes away. A failed fetch (offline, no origin, timeout,
+// cancellation) is logged and the status computed from the refs already
+// present.
+func WorkspaceStatusRefresh(ctx context.Context, path string) WorkspaceGitStatus {
+	if run(ctx, path, "rev-parse", "--git-dir") == nil {
+		fetchCtx, cancel := context.WithTimeout(ctx, refreshFetchTimeout)
+		out, err := combinedOutput(fetchCtx, path, "fetch", "--quiet", "origin")
+		cancel()
+		if err != nil {
+			logger.Git.Warn("status refresh fetch", "path", path, "error", err, "output", strings.TrimSpace(string(out)))
+		}
+	}
+	return WorkspaceStatus(path)
+}
@@ -1,6 +1,7 @@
 package gitutil
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 )
@@ -63,3 +64,47 @@ func TestWorkspaceStatus(t *testing.T) {
 		}
 	})
 }
+
+// TestWorkspaceStatusRefresh verifies that commits pushed to the remote by
+// someone else only show up in the behind counts after a refresh.
+func TestWorkspaceStatusRefresh(t *testing.T) {
+	origin := t.TempDir()
+	gitRun(t, origin, "init", "--bare", "-b", "main")
+	repo := setupRepo(t)
+	gitRun(t, repo, "remote", "add", "origin", origin)
+	gitRun(t, repo, "push", "-u", "origin", "main")
+	gitRun(t, repo, "checkout", "-b", "feature")
+	gitRun(t, repo, "push", "-u", "origin", "feature")
+
+	// Another clone advances origin/main.
+	other := filepath.Join(t.TempDir(), "other")
+	gitRun(t, origin, "clone", origin, other)
+	gitRun(t, other, "config", "user.email", "test@test.com")
+	gitRun(t, other, "config", "user.name", "Test")
+	writeFile(t, filepath.Join(other, "upstream.txt"), "upstream\n")
+	gitRun(t, other, "add", ".")
+	gitRun(t, other, "commit", "-m", "upstream commit")
+	gitRun(t, other, "push", "origin", "main")
+
+	if s := WorkspaceStatus(repo); s.BehindMainCount != 0 {
+		t.Errorf("BehindMainCount before refresh = %d, want 0 (stale)", s.BehindMainCount)
+	}
+	s := WorkspaceStatusRefresh(context.Background(), repo)
+	if s.MainBranch != "main" || s.BehindMainCount != 1 {
+		t.Errorf("after refresh MainBranch=%q BehindMainCount=%d, want main 1", s.MainBranch, s.BehindMainCount)
+	}
+	if s.BehindCount != 0 {
+		t.Errorf("BehindCount = %d, want 0 (feature did not move)", s.BehindCount)
+	}
+}
+
+func TestWorkspaceStatusRefreshWithoutRemote(t *testing.T) {
+	repo := setupRepo(t)
+	if s := WorkspaceStatusRefresh(context.Background(), repo); !s.IsGitRepo || s.HasRemote {
+		t.Errorf("got %+v, want a git repo without remote", s)
+	}
+	dir := t.TempDir()
+	if s := WorkspaceStatusRefresh(context.Background(), dir); s.IsGitRepo {
+		t.Errorf("plain directory reported as git repo: %+v", s)
+	}
+}
@@ -1,8 +1,9 @@
 package gitutil
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -10,18 +11,22 @@ import (
 // If branchName already exists (e.g. the worktree directory was lost after a server
 // restart but the branch was preserved), it checks out the existing branch instead.
 func CreateWorktree(repoPath, worktreePath, branchName string) error {
-	out, err := exec.Command(
-		"git", "-C", repoPath,
-		"worktree", "add", "-b", branchName, worktreePath, "HEAD",
-	).CombinedOutput()
+	return CreateWorktreeFrom(repoPath, worktreePath, branchName, "HEAD")
+}
+
+// CreateWorktreeFrom is CreateWorktree with the new branch starting at start
+// instead of the current HEAD.
+func CreateWorktreeFrom(repoPath, worktreePath, branchName, start string) error {
+	out, err := combinedOutput(context.Background(), repoPath,
+		"worktree", "add", "-b", branchName, worktreePath, start,
+	)
 	if err != nil && strings.Contains(string(out), "already exists") {
 		// A stale branch was left behind by a previous failed cleanup. Force-delete
-		// the orphaned branch and retry so the task can start fresh from HEAD.
-		exec.Command("git", "-C", repoPath, "branch", "-D", branchName).Run()
-		out, err = exec.Command(
-			"git", "-C", repoPath,
-			"worktree", "add", "-b", branchName, worktreePath, "HEAD",
-		).CombinedOutput()
+		// the orphaned branch and retry so the task can start fresh from start.
+		run(context.Background(), repoPath, "branch", "-D", branchName)
+		out, err = combinedOutput(context.Background(), repoPath,
+			"worktree", "add", "-b", branchName, worktreePath, start,
+		)
 	}
 	if err != nil {
 		// Branch may already exist when the worktree directory was deleted but the
@@ -30,10 +35,9 @@ func CreateWorktree(repoPath, worktreePath, branchName string) error {
 		// cases are resolved by checking out the existing branch with --force.
 		if strings.Contains(string(out), "already exists") ||
 			strings.Contains(string(out), "already registered worktree") {
-			out2, err2 := exec.Command(
-				"git", "-C", repoPath,
+			out2, err2 := combinedOutput(context.Background(), repoPath,
 				"worktree", "add", "--force", worktreePath, branchName,
-			).CombinedOutput()
+			)
 			if err2 != nil {
 				return fmt.Errorf("git worktree add (existing branch) in %s: %w\n%s", repoPath, err2, out2)
 			}
@@ -44,25 +48,135 @@ func CreateWorktree(repoPath, worktreePath, branchName string) error {
 	return nil
 }
 
+// BranchWorktree reports whether branchName exists in repoPath and, if it is
+// checked out in a worktree, that worktree's path. Registrations of worktrees
+// whose directory is gone are pruned first, so a branch left behind by a
+// crashed run reports no worktree.
+func BranchWorktree(repoPath, branchName string) (exists bool, worktreePath string, err error) {
+	ctx := context.Background()
+	if run(ctx, repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/"+branchName) != nil {
+		return false, "", nil
+	}
+	run(ctx, repoPath, "worktree", "prune")
+	out, err := output(ctx, repoPath, "worktree", "list", "--porcelain")
+	if err != nil {
+		return true, "", fmt.Errorf("git worktree list in %s: %w", repoPath, err)
+	}
+	var current string
+	for _, line := range strings.Split(string(out), "\n") {
+		if p, ok := strings.CutPrefix(line, "worktree "); ok {
+			current = p
+		} else if line == "branch refs/heads/"+branchName {
+			return true, current, nil
+		}
+	}
+	return true, "", nil
+}
+
+// IsAncestor reports whether commit ancestor is reachable from descendant in
+// repoPath (a commit is its own ancestor).
+func IsAncestor(repoPath, ancestor, descendant string) bool {
+	return run(context.Background(), repoPath, "merge-base", "--is-ancestor", ancestor, descendant) == nil
+}
+
+// AddWorktreeForBranch checks out the existing branchName in a new worktree
+// at worktreePath.
+func AddWorktreeForBranch(repoPath, worktreePath, branchName string) error {
+	out, err := combinedOutput(context.Background(), repoPath, "worktree", "add", worktreePath, branchName)
+	if err != nil {
+		return fmt.Errorf("git worktree add %s in %s: %w\n%s", branchName, repoPath, err, out)
+	}
+	return nil
+}
+
+// DeleteBranch force-deletes branchName in repoPath.
+func DeleteBranch(repoPath, branchName string) error {
+	out, err := combinedOutput(context.Background(), repoPath, "branch", "-D", branchName)
+	if err != nil {
+		return fmt.Errorf("git branch -D %s in %s: %w\n%s", branchName, repoPath, err, out)
+	}
+	return nil
+}
+
 // RemoveWorktree removes a worktree and deletes the associated branch.
+// An empty branchName leaves the branch ref in place.
 func RemoveWorktree(repoPath, worktreePath, branchName string) error {
-	out, err := exec.Command(
-		"git", "-C", repoPath,
+	out, err := combinedOutput(context.Background(), repoPath,
 		"worktree", "remove", "--force", worktreePath,
-	).CombinedOutput()
+	)
 	if err != nil {
 		// If the directory is already gone, prune stale refs and carry on so
 		// that the branch deletion below still runs.
 		if strings.Contains(string(out), "not a worktree") ||
 			strings.Contains(string(out), "not a working tree") ||
 			strings.Contains(string(out), "not found") {
-			exec.Command("git", "-C", repoPath, "worktree", "prune").Run()
+			run(context.Background(), repoPath, "worktree", "prune")
 		} else {
 			return fmt.Errorf("git worktree remove %s: %w\n%s", worktreePath, err, out)
 		}
 	}
 	// Delete the branch (best-effort) — always attempted so stale branches
 	// are cleaned up even when the worktree directory was already missing.
-	exec.Command("git", "-C", repoPath, "branch", "-D", branchName).Run()
+	if branchName != "" {
+		run(context.Background(), repoPath, "branch", "-D", branchName)
+	}
+	return nil
+}
+
+// CreateShallowClone creates a standalone depth-1 clone of the default branch
+// of repoPath at clonePath and checks out a new branchName there. Unlike a
+// linked worktree the clone has its own object store and does not reference
+// the host repository's .git directory, so only the tip snapshot is reachable
+// from inside it.
+func CreateShallowClone(repoPath, clonePath, branchName string) error {
+	defBranch, err := DefaultBranch(context.Background(), repoPath)
+	if err != nil {
+		return err
+	}
+	return CreateShallowCloneFrom(repoPath, clonePath, branchName, defBranch)
+}
+
+// CreateShallowCloneFrom is CreateShallowClone for an already resolved
+// default branch.
+func CreateShallowCloneFrom(repoPath, clonePath, branchName, defBranch string) error {
+	absRepo, err := filepath.Abs(repoPath)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", repoPath, err)
+	}
+	// --depth is ignored for plain local paths; the file:// URL forces the
+	// regular transport so the clone is actually shallow.
+	out, err := combinedOutput(context.Background(), "", "clone", "--depth", "1", "--branch", defBranch,
+		"file://"+filepath.ToSlash(absRepo), clonePath,
+	)
+	if err != nil {
+		return fmt.Errorf("git clone --depth 1 %s: %w\n%s", repoPath, err, out)
+	}
+	out, err = combinedOutput(context.Background(), clonePath, "checkout", "-b", branchName)
+	if err != nil {
+		return fmt.Errorf("git checkout -b %s in %s: %w\n%s", branchName, clonePath, err, out)
+	}
+	return nil
+}
+
+// Unshallow fetches the full history of the shallow repository at repoPath
+// from its origin remote.
+func Unshallow(ctx context.Context, repoPath string) error {
+	out, err := combinedOutput(ctx, repoPath, "fetch", "--unshallow", "origin")
+	if err != nil {
+		return fmt.Errorf("git fetch --unshallow in %s: %w\n%s", repoPath, err, out)
+	}
+	return nil
+}
+
+// FetchBranch fetches branchName from the repository at srcPath into repoPath,
+// creating or force-updating the local branch of the same name. Used to bring
+// a task branch back from a shallow clone before merging.
+func FetchBranch(ctx context.Context, repoPath, srcPath, branchName string) error {
+	out, err := combinedOutput(ctx, repoPath,
+		"fetch", srcPath, "+"+branchName+":"+branchName,
+	)
+	if err != nil {
+		return fmt.Errorf("git fetch %s from %s: %w\n%s", branchName, srcPath, err, out)
+	}
 	return nil
 }
@@ -1,9 +1,14 @@
 package gitutil
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateWorktree(t *testing.T) {
@@ -78,3 +83,72 @@ func TestRemoveWorktree(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateShallowClone(t *testing.T) {
+	repo := setupRepo(t)
+	writeFile(t, filepath.Join(repo, "file.txt"), "second\n")
+	gitRun(t, repo, "commit", "-am", "second commit")
+
+	cloneDir := filepath.Join(t.TempDir(), "clone")
+	if err := CreateShallowClone(repo, cloneDir, "task/shallow"); err != nil {
+		t.Fatalf("CreateShallowClone failed: %v", err)
+	}
+	if got := gitRun(t, cloneDir, "rev-parse", "--is-shallow-repository"); got != "true" {
+		t.Errorf("expected shallow repository, got %q", got)
+	}
+	if got := gitRun(t, cloneDir, "branch", "--show-current"); got != "task/shallow" {
+		t.Errorf("expected branch task/shallow, got %q", got)
+	}
+	// The clone must not be registered as a linked worktree of the host repo.
+	if out := gitRun(t, repo, "worktree", "list"); strings.Contains(out, cloneDir) {
+		t.Errorf("shallow clone should not be a linked worktree:\n%s", out)
+	}
+}
+
+func TestFetchBranch(t *testing.T) {
+	repo := setupRepo(t)
+	cloneDir := filepath.Join(t.TempDir(), "clone")
+	if err := CreateShallowClone(repo, cloneDir, "task/fetch"); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, cloneDir, "config", "user.email", "test@example.com")
+	gitRun(t, cloneDir, "config", "user.name", "Test")
+	writeFile(t, filepath.Join(cloneDir, "new.txt"), "new\n")
+	gitRun(t, cloneDir, "add", ".")
+	gitRun(t, cloneDir, "commit", "-m", "task change")
+	want := gitRun(t, cloneDir, "rev-parse", "HEAD")
+
+	if err := FetchBranch(context.Background(), repo, cloneDir, "task/fetch"); err != nil {
+		t.Fatalf("FetchBranch failed: %v", err)
+	}
+	if got := gitRun(t, repo, "rev-parse", "task/fetch"); got != want {
+		t.Errorf("task/fetch = %s, want %s", got, want)
+	}
+}
+
+// TestFetchBranchAuthRequiredFailsFast verifies that a remote demanding
+// credentials makes git fail immediately instead of prompting.
+func TestFetchBranchAuthRequiredFailsFast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	// A prompt helper that would block if git consulted it.
+	t.Setenv("SSH_ASKPASS", "/bin/cat")
+
+	repo := setupRepo(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := FetchBranch(ctx, repo, srv.URL+"/repo.git", "main")
+	if err == nil {
+		t.Fatal("expected fetch from an auth-protected remote to fail")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("fetch blocked until the deadline: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("fetch took %v, want a prompt failure", elapsed)
+	}
+}
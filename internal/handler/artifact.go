@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// TaskArtifact streams the files a task produced as a zip archive, without
+// merging anything. For scratch tasks this is the whole scratch directory.
+// For other tasks each live worktree contributes, under its repo's basename,
+// the files changed since the task's base commit (including uncommitted and
+// untracked files); a non-git snapshot contributes its whole tree.
+func (h *Handler) TaskArtifact(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	task, err := h.store.GetTask(r.Context(), id)
+	if err != nil {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	// Collect archive entries (name → host path) before writing any output
+	// so failures can still be reported with a proper status code.
+	entries := make(map[string]string)
+	if task.Scratch {
+		dir := h.store.ScratchDir(id)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			http.Error(w, "no artifact for this task yet", http.StatusNotFound)
+			return
+		}
+		if err := addDirEntries(entries, dir, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		live := 0
+		for repoPath, worktreePath := range task.WorktreePaths {
+			if _, err := os.Stat(worktreePath); err != nil {
+				continue
+			}
+			live++
+			prefix := filepath.Base(repoPath) + "/"
+			if !gitutil.IsGitRepo(r.Context(), repoPath) {
+				err = addDirEntries(entries, worktreePath, prefix)
+			} else {
+				err = h.addChangedEntries(r.Context(), entries, task, repoPath, worktreePath, prefix)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if live == 0 {
+			http.Error(w, "task has no worktrees to archive", http.StatusNotFound)
+			return
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+h.runner.ShortID(id)+`.zip"`)
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		if err := zipFile(zw, name, entries[name]); err != nil {
+			// Headers are already sent; the truncated archive signals the failure.
+			logger.Handler.Error("write artifact", "task", id, "file", name, "error", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logger.Handler.Error("write artifact", "task", id, "error", err)
+	}
+}
+
+// addChangedEntries adds the files of a git worktree that differ from the
+// task's diff base, plus untracked files. Deleted files are omitted.
+func (h *Handler) addChangedEntries(ctx context.Context, entries map[string]string, task *store.Task, repoPath, worktreePath, prefix string) error {
+	base, ok := h.worktreeDiffBase(ctx, task, repoPath, worktreePath)
+	if !ok {
+		return fmt.Errorf("no diff base for %s", repoPath)
+	}
+	changed, err := gitutil.Command(ctx, "-C", worktreePath,
+		"diff", "--name-only", "-z", "--diff-filter=d", base).Output()
+	if err != nil {
+		return fmt.Errorf("git diff --name-only in %s: %w", worktreePath, err)
+	}
+	untracked, err := gitutil.Command(ctx, "-C", worktreePath,
+		"ls-files", "-z", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return fmt.Errorf("git ls-files in %s: %w", worktreePath, err)
+	}
+	for _, rel := range strings.Split(string(changed)+string(untracked), "\x00") {
+		if rel == "" {
+			continue
+		}
+		path := filepath.Join(worktreePath, filepath.FromSlash(rel))
+		if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		entries[prefix+rel] = path
+	}
+	return nil
+}
+
+// addDirEntries adds every regular file under root, skipping .git, named by
+// prefix plus its slash-separated path relative to root. Symlinks and special
+// files are skipped.
+func addDirEntries(entries map[string]string, root, prefix string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entries[prefix+filepath.ToSlash(rel)] = path
+		return nil
+	})
+}
+
+// zipFile copies the file at path into zw under name.
+func zipFile(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Method = zip.Deflate
+	dst, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
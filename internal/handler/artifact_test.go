@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestScratchTaskArtifact runs a scratch task whose fake container writes a
+// file into the directory mounted at /workspace/scratch, then downloads it.
+func TestScratchTaskArtifact(t *testing.T) {
+	h := fakeScriptHandler(t, `prev=""
+for a in "$@"; do
+  if [ "$prev" = "-v" ]; then
+    case "$a" in
+      *:/workspace/scratch:z) echo "generated" > "${a%%:/workspace/scratch:z}/hello.txt" ;;
+    esac
+  fi
+  prev="$a"
+done
+echo '{"result":"made it","session_id":"s1","stop_reason":"end_turn","is_error":false}'
+`)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/run-sync?timeout=1m", strings.NewReader(`{"prompt":"write hello","scratch":true}`))
+	w := httptest.NewRecorder()
+	h.RunTaskSync(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("RunTaskSync returned %d: %s", w.Code, w.Body.String())
+	}
+	var resp runSyncResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "done" {
+		t.Fatalf("status = %q, want done", resp.Status)
+	}
+	waitRunSettled(t, h, resp.ID)
+
+	w = httptest.NewRecorder()
+	h.TaskArtifact(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+resp.ID.String()+"/artifact", nil), resp.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("TaskArtifact returned %d: %s", w.Code, w.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "hello.txt" {
+		t.Fatalf("zip entries = %v, want [hello.txt]", zr.File)
+	}
+	f, _ := zr.File[0].Open()
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if string(content) != "generated\n" {
+		t.Errorf("hello.txt = %q, want %q", content, "generated\n")
+	}
+}
+
+// TestTaskArtifactWorktreeChanges verifies that a worktree task's artifact
+// holds the committed, uncommitted, and untracked files it changed, and not
+// the files it left alone.
+func TestTaskArtifactWorktreeChanges(t *testing.T) {
+	repo := setupRepo(t)
+	os.WriteFile(filepath.Join(repo, "untouched.txt"), []byte("same\n"), 0644)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "add untouched")
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	gitRun(t, repo, "worktree", "add", "-b", "task", wtDir, "HEAD")
+	os.WriteFile(filepath.Join(wtDir, "committed.txt"), []byte("c\n"), 0644)
+	gitRun(t, wtDir, "add", ".")
+	gitRun(t, wtDir, "commit", "-m", "task commit")
+	os.WriteFile(filepath.Join(wtDir, "file.txt"), []byte("modified\n"), 0644)
+	os.WriteFile(filepath.Join(wtDir, "new.txt"), []byte("new\n"), 0644)
+
+	task, _ := h.store.CreateTask(ctx, "test", 5, false)
+	h.store.UpdateTaskWorktrees(ctx, task.ID, map[string]string{repo: wtDir}, "task")
+
+	w := httptest.NewRecorder()
+	h.TaskArtifact(w, httptest.NewRequest(http.MethodGet, "/", nil), task.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("TaskArtifact returned %d: %s", w.Code, w.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	base := filepath.Base(repo)
+	want := []string{base + "/committed.txt", base + "/file.txt", base + "/new.txt"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("zip entries = %v, want %v", names, want)
+	}
+}
+
+func TestTaskArtifactNoWorktrees(t *testing.T) {
+	h := newTestHandler(t)
+	task, _ := h.store.CreateTask(context.Background(), "p", 5, false)
+	w := httptest.NewRecorder()
+	h.TaskArtifact(w, httptest.NewRequest(http.MethodGet, "/", nil), task.ID)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
@@ -6,10 +6,30 @@ import (
 	"changkun.de/wallfacer/internal/instructions"
 )
 
-// GetConfig returns the server configuration (workspaces, instructions path).
+// Healthz reports that the server is up.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GetConfig returns the server configuration (workspaces, instructions path)
+// and the runner's effective configuration under "runner".
 func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{
-		"workspaces":        h.runner.Workspaces(),
-		"instructions_path": instructions.FilePath(h.configDir, h.workspaces),
+		"workspaces":              h.runner.Workspaces(),
+		"instructions_path":       instructions.FilePath(h.configDir, h.workspaces),
+		"instructions_workspaces": h.instructionsWorkspaces(),
+		"rsync_available":         h.runner.RsyncAvailable(),
+		"runner":                  h.runner.EffectiveConfig(),
 	})
 }
+
+// instructionsWorkspaces returns the workspace set recorded for the current
+// instructions file, falling back to the configured workspaces when no
+// record has been written yet.
+func (h *Handler) instructionsWorkspaces() []string {
+	ws, err := instructions.RecordedWorkspaces(h.configDir, instructions.Key(h.workspaces))
+	if err != nil {
+		return h.workspaces
+	}
+	return ws
+}
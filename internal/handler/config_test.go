@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"changkun.de/wallfacer/internal/runner"
+	"changkun.de/wallfacer/internal/store"
+)
+
+// TestGetConfigReportsRsyncAvailability verifies that the config endpoint
+// reports whether rsync was found when the runner was created.
+func TestGetConfigReportsRsyncAvailability(t *testing.T) {
+	t.Setenv("PATH", "")
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(s, runner.NewRunner(s, runner.RunnerConfig{}), t.TempDir(), nil)
+
+	w := httptest.NewRecorder()
+	h.GetConfig(w, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetConfig returned %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	available, ok := resp["rsync_available"].(bool)
+	if !ok {
+		t.Fatalf("rsync_available missing from config: %s", w.Body.String())
+	}
+	if available {
+		t.Error("rsync_available should be false when rsync is not on PATH")
+	}
+}
+
+// TestGetConfigReportsEffectiveRunnerConfig verifies that the config endpoint
+// includes the runner's effective configuration, with defaults filled in and
+// credentials masked.
+func TestGetConfigReportsEffectiveRunnerConfig(t *testing.T) {
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := runner.NewRunner(s, runner.RunnerConfig{
+		Command:      "podman",
+		SandboxImage: "wallfacer:test",
+		EnvFile:      "/secret/.env",
+		NotifyURL:    "https://hooks.example.com/token",
+	})
+	h := NewHandler(s, r, t.TempDir(), nil)
+
+	w := httptest.NewRecorder()
+	h.GetConfig(w, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetConfig returned %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Runner map[string]any `json:"runner"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"command":                "podman",
+		"sandbox_image":          "wallfacer:test",
+		"task_timeout":           "15m0s",
+		"waiting_timeout_action": runner.WaitingTimeoutFail,
+		"env_file":               "***",
+		"notify_url":             "***",
+	} {
+		if got := resp.Runner[key]; got != want {
+			t.Errorf("runner.%s = %v, want %v", key, got, want)
+		}
+	}
+	if strings.Contains(w.Body.String(), "/secret/.env") {
+		t.Errorf("env file path leaked: %s", w.Body.String())
+	}
+}
@@ -3,9 +3,10 @@ package handler
 import "net/http"
 
 // GetContainers returns the list of wallfacer sandbox containers visible to the
-// container runtime, mimicking `docker ps -a --filter name=wallfacer`.
+// container runtime, mimicking `docker ps -a --filter name=wallfacer`, each
+// annotated with its task's status and whether it is orphaned.
 func (h *Handler) GetContainers(w http.ResponseWriter, r *http.Request) {
-	containers, err := h.runner.ListContainers()
+	containers, err := h.runner.ListContainersWithTasks()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
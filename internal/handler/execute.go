@@ -1,9 +1,10 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"changkun.de/wallfacer/internal/store"
@@ -70,7 +71,13 @@ func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request, id uuid.U
 
 	if task.SessionID != nil && *task.SessionID != "" {
 		// Transition to "committing" while auto-commit runs in the background.
-		if err := h.store.UpdateTaskStatus(r.Context(), id, "committing"); err != nil {
+		// The version check makes sure no concurrent transition (e.g. the
+		// waiting timeout) got there first.
+		if err := h.store.UpdateTaskStatusIfVersion(r.Context(), id, "waiting", "committing", task.Version); err != nil {
+			if errors.Is(err, store.ErrVersionConflict) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -78,26 +85,7 @@ func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request, id uuid.U
 			"from": "waiting",
 			"to":   "committing",
 		})
-		sessionID := *task.SessionID
-		go func() {
-			bgCtx := context.Background()
-			if err := h.runner.Commit(id, sessionID); err != nil {
-				h.store.UpdateTaskStatus(bgCtx, id, "failed")
-				h.store.InsertEvent(bgCtx, id, store.EventTypeError, map[string]string{
-					"error": "commit failed: " + err.Error(),
-				})
-				h.store.InsertEvent(bgCtx, id, store.EventTypeStateChange, map[string]string{
-					"from": "committing",
-					"to":   "failed",
-				})
-				return
-			}
-			h.store.UpdateTaskStatus(bgCtx, id, "done")
-			h.store.InsertEvent(bgCtx, id, store.EventTypeStateChange, map[string]string{
-				"from": "committing",
-				"to":   "done",
-			})
-		}()
+		go h.runner.RunCommit(id, *task.SessionID)
 	} else {
 		// No session to commit — go directly to done.
 		if err := h.store.UpdateTaskStatus(r.Context(), id, "done"); err != nil {
@@ -125,7 +113,9 @@ func (h *Handler) CancelTask(w http.ResponseWriter, r *http.Request, id uuid.UUI
 		"backlog":     true,
 		"in_progress": true,
 		"waiting":     true,
+		"committing":  true,
 		"failed":      true,
+		"dead":        true,
 	}
 	if !cancellable[task.Status] {
 		http.Error(w, "task cannot be cancelled in its current status", http.StatusBadRequest)
@@ -134,9 +124,18 @@ func (h *Handler) CancelTask(w http.ResponseWriter, r *http.Request, id uuid.UUI
 
 	oldStatus := task.Status
 
-	// For in_progress tasks: kill the running container first.
+	// A committing task can only be stopped during its pre-merge delay;
+	// once the merge has started it must be left to finish.
+	if oldStatus == "committing" && !h.runner.CancelPreMerge(id) {
+		http.Error(w, "task is merging and can no longer be cancelled", http.StatusConflict)
+		return
+	}
+
+	// For in_progress tasks: kill the running container first, and abort
+	// the merge if the automatic commit is in its pre-merge delay.
 	if oldStatus == "in_progress" {
 		h.runner.KillContainer(id)
+		h.runner.CancelPreMerge(id)
 	}
 
 	// Persist the cancelled status BEFORE cleaning up worktrees.
@@ -157,6 +156,38 @@ func (h *Handler) CancelTask(w http.ResponseWriter, r *http.Request, id uuid.UUI
 	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
 }
 
+// cancelWorkspaceResponse lists the tasks CancelWorkspaceTasks cancelled.
+type cancelWorkspaceResponse struct {
+	Cancelled []uuid.UUID `json:"cancelled"`
+}
+
+// CancelWorkspaceTasks cancels every unfinished task that runs against the
+// workspace named by its basename, backlog tasks included, e.g. before
+// taking the repo offline.
+func (h *Handler) CancelWorkspaceTasks(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var ws string
+	for _, p := range h.workspaces {
+		if filepath.Base(p) == name {
+			ws = p
+			break
+		}
+	}
+	if ws == "" {
+		http.Error(w, "unknown workspace", http.StatusNotFound)
+		return
+	}
+	cancelled, err := h.runner.CancelByWorkspace(r.Context(), ws)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if cancelled == nil {
+		cancelled = []uuid.UUID{}
+	}
+	writeJSON(w, http.StatusOK, cancelWorkspaceResponse{Cancelled: cancelled})
+}
+
 // ResumeTask resumes a failed task using its existing session.
 func (h *Handler) ResumeTask(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
 	var req struct {
@@ -231,6 +262,37 @@ func (h *Handler) UnarchiveTask(w http.ResponseWriter, r *http.Request, id uuid.
 	writeJSON(w, http.StatusOK, map[string]string{"status": "unarchived"})
 }
 
+// ResetTask hard-resets a waiting or failed task's worktrees to their base
+// commits and re-runs the original prompt in a fresh session.
+func (h *Handler) ResetTask(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	task, err := h.store.GetTask(r.Context(), id)
+	if err != nil {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if task.Status != "waiting" && task.Status != "failed" {
+		http.Error(w, "only waiting or failed tasks can be reset", http.StatusBadRequest)
+		return
+	}
+	if err := h.runner.ResetWorktree(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	oldStatus := task.Status
+	if err := h.store.UpdateTaskStatus(r.Context(), id, "in_progress"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.store.InsertEvent(r.Context(), id, store.EventTypeStateChange, map[string]string{
+		"from": oldStatus,
+		"to":   "in_progress",
+	})
+
+	go h.runner.Run(id, task.Prompt, "", false)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+}
+
 // SyncTask rebases task worktrees onto the latest default branch without merging.
 func (h *Handler) SyncTask(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
 	task, err := h.store.GetTask(r.Context(), id)
@@ -1,26 +1,36 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"changkun.de/wallfacer/internal/gitutil"
 	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/runner"
+	"changkun.de/wallfacer/internal/store"
 	"github.com/google/uuid"
 )
 
-// GitStatus returns git status for every configured workspace.
+// GitStatus returns git status for every configured workspace. With
+// ?refresh=true each workspace fetches origin first, so the behind counts
+// are current rather than as of the last fetch.
 func (h *Handler) GitStatus(w http.ResponseWriter, r *http.Request) {
+	status := gitutil.WorkspaceStatus
+	if r.URL.Query().Get("refresh") == "true" {
+		status = func(path string) gitutil.WorkspaceGitStatus {
+			return gitutil.WorkspaceStatusRefresh(r.Context(), path)
+		}
+	}
 	workspaces := h.runner.Workspaces()
 	statuses := make([]gitutil.WorkspaceGitStatus, 0, len(workspaces))
 	for _, ws := range workspaces {
-		statuses = append(statuses, gitutil.WorkspaceStatus(ws))
+		statuses = append(statuses, status(ws))
 	}
 	writeJSON(w, http.StatusOK, statuses)
 }
@@ -100,7 +110,7 @@ func (h *Handler) GitPush(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Git.Info("push", "workspace", req.Workspace)
-	out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "push").CombinedOutput()
+	out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "push").CombinedOutput()
 	if err != nil {
 		logger.Git.Error("push failed", "workspace", req.Workspace, "error", err)
 		http.Error(w, string(out), http.StatusInternalServerError)
@@ -127,17 +137,18 @@ func (h *Handler) GitSyncWorkspace(w http.ResponseWriter, r *http.Request) {
 
 	logger.Git.Info("sync workspace", "workspace", req.Workspace)
 
-	if out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "fetch").CombinedOutput(); err != nil {
+	if out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "fetch").CombinedOutput(); err != nil {
 		logger.Git.Error("fetch failed", "workspace", req.Workspace, "error", err)
 		http.Error(w, "fetch failed: "+string(out), http.StatusInternalServerError)
 		return
 	}
 
-	out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "rebase", "@{u}").CombinedOutput()
+	out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "rebase", "@{u}").CombinedOutput()
 	if err != nil {
-		exec.Command("git", "-C", req.Workspace, "rebase", "--abort").Run()
+		conflicts, _ := gitutil.ConflictedFiles(context.Background(), req.Workspace)
+		gitutil.Command(context.Background(), "-C", req.Workspace, "rebase", "--abort").Run()
 		logger.Git.Error("sync rebase failed", "workspace", req.Workspace, "error", err)
-		if gitutil.IsConflictOutput(string(out)) {
+		if len(conflicts) > 0 {
 			http.Error(w, "rebase conflict: resolve manually in "+req.Workspace, http.StatusConflict)
 			return
 		}
@@ -178,18 +189,19 @@ func (h *Handler) GitRebaseOnMain(w http.ResponseWriter, r *http.Request) {
 	logger.Git.Info("rebase-on-main", "workspace", req.Workspace, "main", mainBranch)
 
 	// Fetch the remote default branch.
-	if out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "fetch", "origin", mainBranch).CombinedOutput(); err != nil {
+	if out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "fetch", "origin", mainBranch).CombinedOutput(); err != nil {
 		logger.Git.Error("fetch failed", "workspace", req.Workspace, "error", err)
 		http.Error(w, "fetch failed: "+string(out), http.StatusInternalServerError)
 		return
 	}
 
 	// Rebase onto origin/<main>.
-	out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "rebase", "origin/"+mainBranch).CombinedOutput()
+	out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "rebase", "origin/"+mainBranch).CombinedOutput()
 	if err != nil {
-		exec.Command("git", "-C", req.Workspace, "rebase", "--abort").Run()
+		conflicts, _ := gitutil.ConflictedFiles(context.Background(), req.Workspace)
+		gitutil.Command(context.Background(), "-C", req.Workspace, "rebase", "--abort").Run()
 		logger.Git.Error("rebase-on-main failed", "workspace", req.Workspace, "error", err)
-		if gitutil.IsConflictOutput(string(out)) {
+		if len(conflicts) > 0 {
 			http.Error(w, "rebase conflict: resolve manually in "+req.Workspace, http.StatusConflict)
 			return
 		}
@@ -219,24 +231,29 @@ func (h *Handler) TaskDiff(w http.ResponseWriter, r *http.Request, id uuid.UUID)
 		// If the worktree directory no longer exists, fall back to stored commit hashes.
 		if _, statErr := os.Stat(worktreePath); statErr != nil {
 			commitHash := task.CommitHashes[repoPath]
+			baseHash := task.BaseCommitHashes[repoPath]
 			var out []byte
-			if commitHash != "" {
-				if baseHash := task.BaseCommitHashes[repoPath]; baseHash != "" {
-					out, _ = exec.CommandContext(r.Context(), "git", "-C", repoPath,
-						"diff", baseHash, commitHash).Output()
-				} else {
-					out, _ = exec.CommandContext(r.Context(), "git", "-C", repoPath,
-						"show", commitHash).Output()
-				}
+			if commitHash != "" && baseHash != "" {
+				// Merged: what the merge brought into the default branch.
+				out, _ = gitutil.Command(r.Context(), "-C", repoPath,
+					"diff", baseHash, commitHash).Output()
+			} else if start := task.BaseCommits[repoPath]; commitHash != "" && start != "" {
+				// A Phase 1 commit that was never merged: everything since
+				// the worktree was created.
+				out, _ = gitutil.Command(r.Context(), "-C", repoPath,
+					"diff", start, commitHash).Output()
+			} else if commitHash != "" {
+				out, _ = gitutil.Command(r.Context(), "-C", repoPath,
+					"show", commitHash).Output()
 			} else if task.BranchName != "" {
-				if defBranch, err := gitutil.DefaultBranch(repoPath); err == nil {
+				if defBranch, err := h.runner.DefaultBranch(r.Context(), repoPath); err == nil {
 					// Use merge-base so we only see changes introduced on the task
 					// branch, not the inverse of commits that advanced main.
-					if base, mbErr := gitutil.MergeBase(repoPath, defBranch, task.BranchName); mbErr == nil {
-						out, _ = exec.CommandContext(r.Context(), "git", "-C", repoPath,
+					if base, mbErr := gitutil.MergeBase(r.Context(), repoPath, defBranch, task.BranchName); mbErr == nil {
+						out, _ = gitutil.Command(r.Context(), "-C", repoPath,
 							"diff", base, task.BranchName).Output()
 					} else {
-						out, _ = exec.CommandContext(r.Context(), "git", "-C", repoPath,
+						out, _ = gitutil.Command(r.Context(), "-C", repoPath,
 							"diff", defBranch+".."+task.BranchName).Output()
 					}
 				}
@@ -250,31 +267,26 @@ func (h *Handler) TaskDiff(w http.ResponseWriter, r *http.Request, id uuid.UUID)
 			continue
 		}
 
-		defBranch, err := gitutil.DefaultBranch(repoPath)
-		if err != nil {
+		base, ok := h.worktreeDiffBase(r.Context(), task, repoPath, worktreePath)
+		if !ok {
 			continue
 		}
-		// Use merge-base to diff only this task's changes since it diverged,
-		// ignoring any commits that advanced the default branch from other tasks.
-		// Fall back to diffing against the default branch tip if merge-base fails.
-		base, err := gitutil.MergeBase(worktreePath, "HEAD", defBranch)
-		if err != nil {
-			base = defBranch
-		}
-		out, _ := exec.CommandContext(r.Context(), "git", "-C", worktreePath, "diff", base).Output()
+		out, _ := gitutil.Command(r.Context(), "-C", worktreePath, "diff", base).Output()
 
 		// Include untracked files via --no-index diffs.
-		if untrackedRaw, err := exec.CommandContext(r.Context(), "git", "-C", worktreePath,
+		if untrackedRaw, err := gitutil.Command(r.Context(), "-C", worktreePath,
 			"ls-files", "--others", "--exclude-standard").Output(); err == nil {
 			for _, file := range strings.Split(strings.TrimSpace(string(untrackedRaw)), "\n") {
 				if file == "" {
 					continue
 				}
-				fd, _ := exec.CommandContext(r.Context(), "git", "-C", worktreePath,
+				fd, _ := gitutil.Command(r.Context(), "-C", worktreePath,
 					"diff", "--no-index", "/dev/null", file).Output()
 				out = append(out, fd...)
 			}
 		}
+		// Writes a read-only-workspace task has not promoted yet.
+		out = append(out, runner.OverlayDiff(r.Context(), worktreePath)...)
 
 		if len(out) > 0 {
 			if len(task.WorktreePaths) > 1 {
@@ -282,8 +294,10 @@ func (h *Handler) TaskDiff(w http.ResponseWriter, r *http.Request, id uuid.UUID)
 			}
 			combined.Write(out)
 		}
-		if n, err := gitutil.CommitsBehind(repoPath, worktreePath); err == nil && n > 0 {
-			behindCounts[filepath.Base(repoPath)] = n
+		if defBranch, err := h.runner.DefaultBranch(r.Context(), repoPath); err == nil {
+			if n, err := gitutil.CommitsBehindBranch(r.Context(), worktreePath, defBranch); err == nil && n > 0 {
+				behindCounts[filepath.Base(repoPath)] = n
+			}
 		}
 	}
 
@@ -293,6 +307,35 @@ func (h *Handler) TaskDiff(w http.ResponseWriter, r *http.Request, id uuid.UUID)
 	})
 }
 
+// worktreeDiffBase returns the ref a live task worktree should be diffed
+// against to show only the task's changes. It reports false when no base can
+// be determined.
+func (h *Handler) worktreeDiffBase(ctx context.Context, task *store.Task, repoPath, worktreePath string) (string, bool) {
+	if !gitutil.IsGitRepo(ctx, repoPath) {
+		// Non-git snapshot: its initial commit is a copy of the original
+		// workspace, so diffing against it shows the task's changes.
+		if base := task.BaseCommits[repoPath]; base != "" {
+			return base, true
+		}
+		return "HEAD", true
+	}
+	defBranch, err := h.runner.DefaultBranch(ctx, repoPath)
+	if err != nil {
+		return "", false
+	}
+	// Use merge-base to diff only this task's changes since it diverged,
+	// ignoring any commits that advanced the default branch from other tasks.
+	// If merge-base fails, fall back to the commit the worktree was
+	// created at, then to the default branch tip.
+	if base, err := gitutil.MergeBase(ctx, worktreePath, "HEAD", defBranch); err == nil {
+		return base, true
+	}
+	if recorded := task.BaseCommits[repoPath]; recorded != "" {
+		return recorded, true
+	}
+	return defBranch, true
+}
+
 // GitBranches returns the list of local branches for a workspace.
 func (h *Handler) GitBranches(w http.ResponseWriter, r *http.Request) {
 	ws := r.URL.Query().Get("workspace")
@@ -305,7 +348,7 @@ func (h *Handler) GitBranches(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	out, err := exec.CommandContext(r.Context(), "git", "-C", ws,
+	out, err := gitutil.Command(r.Context(), "-C", ws,
 		"branch", "--list", "--format=%(refname:short)").Output()
 	if err != nil {
 		http.Error(w, "failed to list branches", http.StatusInternalServerError)
@@ -321,7 +364,7 @@ func (h *Handler) GitBranches(w http.ResponseWriter, r *http.Request) {
 	}
 
 	current := ""
-	if curOut, err := exec.CommandContext(r.Context(), "git", "-C", ws,
+	if curOut, err := gitutil.Command(r.Context(), "-C", ws,
 		"branch", "--show-current").Output(); err == nil {
 		current = strings.TrimSpace(string(curOut))
 	}
@@ -366,7 +409,7 @@ func (h *Handler) GitCheckout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Git.Info("checkout", "workspace", req.Workspace, "branch", req.Branch)
-	out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "checkout", req.Branch).CombinedOutput()
+	out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "checkout", req.Branch).CombinedOutput()
 	if err != nil {
 		logger.Git.Error("checkout failed", "workspace", req.Workspace, "branch", req.Branch, "error", err)
 		http.Error(w, string(out), http.StatusInternalServerError)
@@ -410,7 +453,7 @@ func (h *Handler) GitCreateBranch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Git.Info("create-branch", "workspace", req.Workspace, "branch", req.Branch)
-	out, err := exec.CommandContext(r.Context(), "git", "-C", req.Workspace, "checkout", "-b", req.Branch).CombinedOutput()
+	out, err := gitutil.Command(r.Context(), "-C", req.Workspace, "checkout", "-b", req.Branch).CombinedOutput()
 	if err != nil {
 		logger.Git.Error("create-branch failed", "workspace", req.Workspace, "branch", req.Branch, "error", err)
 		http.Error(w, string(out), http.StatusInternalServerError)
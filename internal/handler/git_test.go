@@ -158,6 +158,38 @@ func TestTaskDiffIncludesUntrackedFiles(t *testing.T) {
 	}
 }
 
+// TestTaskDiffNonGitSnapshot verifies that a non-git workspace's snapshot is
+// diffed against its initial (copied) state, even when no base commit was
+// recorded for the task.
+func TestTaskDiffNonGitSnapshot(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "notes.txt"), []byte("original\n"), 0644)
+
+	// Mirror what the runner does: copy the workspace and track it in a
+	// fresh local repository. Its branch deliberately differs from "main".
+	snapshot := t.TempDir()
+	gitRun(t, snapshot, "init", "-b", "master")
+	gitRun(t, snapshot, "config", "user.email", "test@example.com")
+	gitRun(t, snapshot, "config", "user.name", "Test")
+	os.WriteFile(filepath.Join(snapshot, "notes.txt"), []byte("original\n"), 0644)
+	gitRun(t, snapshot, "add", ".")
+	gitRun(t, snapshot, "commit", "-m", "snapshot")
+
+	os.WriteFile(filepath.Join(snapshot, "notes.txt"), []byte("edited by task\n"), 0644)
+
+	task, _ := h.store.CreateTask(ctx, "test", 5, false)
+	h.store.UpdateTaskWorktrees(ctx, task.ID, map[string]string{workspace: snapshot}, "task")
+
+	resp := callTaskDiff(t, h, task.ID)
+
+	if !strings.Contains(resp.Diff, "notes.txt") || !strings.Contains(resp.Diff, "edited by task") {
+		t.Errorf("expected snapshot diff to include notes.txt change, got:\n%s", resp.Diff)
+	}
+}
+
 func TestTaskDiffEmptyWhenNoChanges(t *testing.T) {
 	repo := setupRepo(t)
 	h := newTestHandler(t)
@@ -204,6 +236,41 @@ func TestTaskDiffFallbackToCommitHashes(t *testing.T) {
 	}
 }
 
+// TestTaskDiffFallbackToUnmergedCommit verifies that a Phase 1 commit that
+// was never merged (no base commit hash recorded) is diffed against the
+// worktree's starting point, covering all of the task's commits.
+func TestTaskDiffFallbackToUnmergedCommit(t *testing.T) {
+	repo := setupRepo(t)
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	start := gitRun(t, repo, "rev-parse", "HEAD")
+	gitRun(t, repo, "checkout", "-b", "task-y")
+	os.WriteFile(filepath.Join(repo, "first.txt"), []byte("one\n"), 0644)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "first task commit")
+	os.WriteFile(filepath.Join(repo, "second.txt"), []byte("two\n"), 0644)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "second task commit")
+	commitHash := gitRun(t, repo, "rev-parse", "HEAD")
+	gitRun(t, repo, "checkout", "main")
+	gitRun(t, repo, "branch", "-D", "task-y")
+
+	task, _ := h.store.CreateTask(ctx, "test", 5, false)
+	nonexistent := filepath.Join(t.TempDir(), "gone")
+	h.store.UpdateTaskWorktrees(ctx, task.ID, map[string]string{repo: nonexistent}, "task-y")
+	h.store.UpdateTaskBaseCommits(ctx, task.ID, map[string]string{repo: start})
+	h.store.UpdateTaskCommitHashes(ctx, task.ID, map[string]string{repo: commitHash})
+
+	resp := callTaskDiff(t, h, task.ID)
+
+	for _, f := range []string{"first.txt", "second.txt"} {
+		if !strings.Contains(resp.Diff, f) {
+			t.Errorf("expected fallback diff to show %s, got:\n%s", f, resp.Diff)
+		}
+	}
+}
+
 func TestTaskDiffFallbackBranchUseMergeBase(t *testing.T) {
 	repo := setupRepo(t)
 	h := newTestHandler(t)
@@ -3,7 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 
+	"changkun.de/wallfacer/internal/instructions"
 	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/runner"
 	"changkun.de/wallfacer/internal/store"
@@ -16,8 +19,24 @@ type Handler struct {
 	configDir  string
 	workspaces []string
 	envFile    string
+
+	// instructionsOpts shapes the instructions file rebuilt by
+	// ReinitInstructions.
+	instructionsOpts instructions.Options
+
+	// createLimiter bounds the task creation rate; nil means unlimited.
+	createLimiter *tokenBucket
+
+	// idemMu guards idemPending, the Idempotency-Keys whose CreateTask is
+	// still in flight, so that concurrent retries cannot both miss the lookup.
+	idemMu      sync.Mutex
+	idemPending map[string]chan struct{}
+	idemWindow  time.Duration
 }
 
+// defaultIdempotencyWindow is how long an Idempotency-Key is remembered.
+const defaultIdempotencyWindow = 24 * time.Hour
+
 // NewHandler constructs a Handler with the given dependencies.
 func NewHandler(s *store.Store, r *runner.Runner, configDir string, workspaces []string) *Handler {
 	return &Handler{
@@ -26,9 +45,24 @@ func NewHandler(s *store.Store, r *runner.Runner, configDir string, workspaces [
 		configDir:  configDir,
 		workspaces: workspaces,
 		envFile:    r.EnvFile(),
+		idemWindow: defaultIdempotencyWindow,
 	}
 }
 
+// SetIdempotencyWindow sets how long CreateTask remembers Idempotency-Key
+// headers. Non-positive values keep the default.
+func (h *Handler) SetIdempotencyWindow(d time.Duration) {
+	if d > 0 {
+		h.idemWindow = d
+	}
+}
+
+// SetInstructionsOptions sets the options used when the workspace
+// instructions file is rebuilt.
+func (h *Handler) SetInstructionsOptions(opts instructions.Options) {
+	h.instructionsOpts = opts
+}
+
 // writeJSON serialises v as JSON and writes it with the given HTTP status code.
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"changkun.de/wallfacer/internal/instructions"
+	"changkun.de/wallfacer/internal/logger"
 )
 
 // GetInstructions returns the current workspace CLAUDE.md content.
@@ -33,8 +35,13 @@ func (h *Handler) UpdateInstructions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	path := instructions.FilePath(h.configDir, h.workspaces)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		http.Error(w, "cannot create instructions directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if err := os.WriteFile(path, []byte(req.Content), 0644); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.Handler.Error("write instructions", "path", path, "error", err)
+		http.Error(w, "cannot write instructions file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
@@ -42,7 +49,7 @@ func (h *Handler) UpdateInstructions(w http.ResponseWriter, r *http.Request) {
 
 // ReinitInstructions rebuilds the workspace CLAUDE.md from defaults and repo files.
 func (h *Handler) ReinitInstructions(w http.ResponseWriter, r *http.Request) {
-	path, err := instructions.Reinit(h.configDir, h.workspaces)
+	path, err := instructions.Reinit(h.configDir, h.workspaces, h.instructionsOpts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
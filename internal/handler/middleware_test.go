@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	h := CORS(CORSConfig{
+		AllowedOrigins: []string{"https://dash.example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+	}, okHandler())
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/tasks", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight returned %d, want 204", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://dash.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT, PATCH, DELETE",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	h := CORS(CORSConfig{AllowedOrigins: []string{"https://dash.example.com"}}, okHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+}
+
+// TestCORSDisabledByDefault verifies that an empty config sends no CORS
+// headers, keeping the API same-origin only.
+func TestCORSDisabledByDefault(t *testing.T) {
+	h := CORS(CORSConfig{}, okHandler())
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/tasks", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	h := BearerAuth("s3cret", okHandler())
+
+	call := func(path, auth string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if got := call("/api/tasks", ""); got != http.StatusUnauthorized {
+		t.Errorf("no token: got %d, want 401", got)
+	}
+	if got := call("/api/tasks", "Bearer wrong"); got != http.StatusUnauthorized {
+		t.Errorf("wrong token: got %d, want 401", got)
+	}
+	if got := call("/api/tasks", "Bearer s3cret"); got != http.StatusOK {
+		t.Errorf("valid token: got %d, want 200", got)
+	}
+	if got := call("/healthz", ""); got != http.StatusOK {
+		t.Errorf("/healthz: got %d, want 200", got)
+	}
+}
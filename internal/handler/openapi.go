@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"changkun.de/wallfacer/internal/gitutil"
+	"changkun.de/wallfacer/internal/runner"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// apiOperation describes one HTTP route for the OpenAPI document. Request and
+// Response hold zero values of the Go types the handler decodes and encodes;
+// their JSON schemas are derived by reflection so the spec follows the code.
+type apiOperation struct {
+	Method   string
+	Path     string
+	Summary  string
+	Query    []string // optional query parameters (all strings)
+	Request  any      // JSON request body, nil when the route takes none
+	TextBody bool     // also accepts a text/plain body (e.g. a raw prompt)
+	Response any      // JSON response body, nil for non-JSON or empty bodies
+	Status   int      // success status code; 0 means 200
+	Produces string   // content type for non-JSON responses
+}
+
+// statusResponse is the {"status": ...} acknowledgement returned by most
+// task action endpoints.
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// contentResponse is the {"content": ...} shape used by the instructions API.
+type contentResponse struct {
+	Content string `json:"content"`
+}
+
+// outputResponse carries the combined output of a git command.
+type outputResponse struct {
+	Output string `json:"output"`
+}
+
+// workspaceRequest selects one configured workspace.
+type workspaceRequest struct {
+	Workspace string `json:"workspace"`
+}
+
+// apiOperations lists every route registered in buildMux. Keep it in sync
+// when adding or changing handlers.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/healthz", Summary: "Liveness probe", Response: statusResponse{}},
+	{Method: "GET", Path: "/api/openapi.json", Summary: "This OpenAPI document", Response: map[string]any{}},
+
+	{Method: "GET", Path: "/api/containers", Summary: "List task containers with their task status", Response: []runner.ContainerTaskInfo{}},
+
+	{Method: "GET", Path: "/api/config", Summary: "Server configuration", Response: struct {
+		Workspaces       []string               `json:"workspaces"`
+		InstructionsPath string                 `json:"instructions_path"`
+		RsyncAvailable   bool                   `json:"rsync_available"`
+		Runner           runner.EffectiveConfig `json:"runner"`
+	}{}},
+	{Method: "GET", Path: "/api/env", Summary: "Env file configuration with tokens masked", Response: envConfigResponse{}},
+	{Method: "PUT", Path: "/api/env", Summary: "Update the env file", Request: struct {
+		OAuthToken *string `json:"oauth_token"`
+		APIKey     *string `json:"api_key"`
+		BaseURL    *string `json:"base_url"`
+		Model      *string `json:"model"`
+	}{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/instructions", Summary: "Workspace CLAUDE.md content", Response: contentResponse{}},
+	{Method: "PUT", Path: "/api/instructions", Summary: "Save workspace CLAUDE.md", Request: contentResponse{}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/instructions/reinit", Summary: "Rebuild workspace CLAUDE.md", Response: contentResponse{}},
+
+	{Method: "GET", Path: "/api/git/status", Summary: "Git status of every workspace", Query: []string{"refresh"}, Response: []gitutil.WorkspaceGitStatus{}},
+	{Method: "GET", Path: "/api/git/stream", Summary: "Git status stream", Produces: "text/event-stream"},
+	{Method: "POST", Path: "/api/git/push", Summary: "Push a workspace", Request: workspaceRequest{}, Response: outputResponse{}},
+	{Method: "POST", Path: "/api/git/sync", Summary: "Fetch and rebase a workspace onto its upstream", Request: workspaceRequest{}, Response: outputResponse{}},
+	{Method: "POST", Path: "/api/git/rebase-on-main", Summary: "Rebase a workspace onto the remote default branch", Request: workspaceRequest{}, Response: outputResponse{}},
+	{Method: "GET", Path: "/api/git/branches", Summary: "List local branches of a workspace", Query: []string{"workspace"}, Response: struct {
+		Branches []string `json:"branches"`
+		Current  string   `json:"current"`
+	}{}},
+	{Method: "POST", Path: "/api/git/checkout", Summary: "Switch a workspace branch", Request: struct {
+		Workspace string `json:"workspace"`
+		Branch    string `json:"branch"`
+	}{}, Response: struct {
+		Branch string `json:"branch"`
+	}{}},
+	{Method: "POST", Path: "/api/git/create-branch", Summary: "Create and switch to a branch", Request: struct {
+		Workspace string `json:"workspace"`
+		Branch    string `json:"branch"`
+	}{}, Response: struct {
+		Branch string `json:"branch"`
+	}{}},
+
+	{Method: "GET", Path: "/api/tasks", Summary: "List tasks", Query: []string{"include_archived"}, Response: []store.Task{}},
+	{Method: "GET", Path: "/api/tasks/stream", Summary: "Task list stream", Query: []string{"include_archived"}, Produces: "text/event-stream"},
+	{Method: "POST", Path: "/api/tasks", Summary: "Create a backlog task", Request: createTaskRequest{}, TextBody: true, Response: store.Task{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/api/tasks/generate-titles", Summary: "Generate missing task titles", Query: []string{"limit"}, Response: struct {
+		Queued            int      `json:"queued"`
+		TotalWithoutTitle int      `json:"total_without_title"`
+		TaskIDs           []string `json:"task_ids"`
+	}{}},
+	{Method: "POST", Path: "/api/tasks/run-sync", Summary: "Create, run, and wait for a task", Query: []string{"timeout"}, Request: createTaskRequest{}, TextBody: true, Response: runSyncResponse{}},
+	{Method: "POST", Path: "/api/backlog/reorder", Summary: "Move backlog tasks to the front in the given order", Request: reorderBacklogRequest{}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/workspaces/{name}/cancel-all", Summary: "Cancel every unfinished task that runs against the workspace (by basename), including backlog tasks", Response: cancelWorkspaceResponse{}},
+	{Method: "GET", Path: "/api/scheduler", Summary: "Whether task launching is paused", Response: schedulerResponse{}},
+	{Method: "POST", Path: "/api/scheduler/pause", Summary: "Pause launching of backlog tasks", Response: schedulerResponse{}},
+	{Method: "POST", Path: "/api/scheduler/resume", Summary: "Resume launching of backlog tasks", Response: schedulerResponse{}},
+
+	{Method: "GET", Path: "/api/tasks/{id}", Summary: "Get a task by UUID or unique short ID prefix", Response: store.Task{}},
+	{Method: "PATCH", Path: "/api/tasks/{id}", Summary: "Update a task", Request: updateTaskRequest{}, Response: store.Task{}},
+	{Method: "DELETE", Path: "/api/tasks/{id}", Summary: "Delete a task", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/tasks/{id}/events", Summary: "Task event timeline", Response: []store.TaskEvent{}},
+	{Method: "POST", Path: "/api/tasks/{id}/feedback", Summary: "Answer a waiting task", Request: struct {
+		Message string `json:"message"`
+	}{}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/done", Summary: "Commit and complete a waiting task", Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/cancel", Summary: "Cancel a task", Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/resume", Summary: "Resume a failed task", Request: struct {
+		Timeout *int `json:"timeout"`
+	}{}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/archive", Summary: "Archive a task", Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/unarchive", Summary: "Unarchive a task", Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/sync", Summary: "Rebase task worktrees onto the default branch", Response: statusResponse{}},
+	{Method: "POST", Path: "/api/tasks/{id}/reset", Summary: "Reset task worktrees to their base commits and rerun", Response: statusResponse{}},
+	{Method: "GET", Path: "/api/tasks/{id}/diff", Summary: "Diff of task changes", Response: struct {
+		Diff         string         `json:"diff"`
+		BehindCounts map[string]int `json:"behind_counts"`
+	}{}},
+	{Method: "GET", Path: "/api/tasks/{id}/artifact", Summary: "Zip of the files a task changed or a scratch task produced", Produces: "application/zip"},
+	{Method: "GET", Path: "/api/tasks/{id}/logs", Summary: "Container log stream", Produces: "text/plain"},
+	{Method: "GET", Path: "/api/tasks/{id}/stream", Summary: "Live agent output stream (text and tool calls)", Produces: "text/event-stream"},
+	{Method: "GET", Path: "/api/tasks/{id}/transcript", Summary: "Redacted conversation transcript of all turns", Produces: "application/x-ndjson"},
+	{Method: "GET", Path: "/api/tasks/{id}/outputs/{filename}", Summary: "Raw turn output file", Produces: "application/octet-stream"},
+}
+
+// OpenAPI serves the OpenAPI 3 description of the HTTP API.
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec())
+}
+
+// openAPISpec builds the OpenAPI document from apiOperations.
+func openAPISpec() map[string]any {
+	sb := &schemaBuilder{components: map[string]any{}}
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = sb.operation(op)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Wallfacer API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": sb.components},
+	}
+}
+
+// schemaBuilder converts Go types into JSON schemas, collecting named struct
+// types under components/schemas and referencing them by $ref.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+func (sb *schemaBuilder) operation(op apiOperation) map[string]any {
+	out := map[string]any{"summary": op.Summary}
+
+	var params []any
+	for _, name := range pathParams(op.Path) {
+		schema := map[string]any{"type": "string"}
+		if name == "id" {
+			schema["format"] = "uuid"
+		}
+		params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": schema})
+	}
+	for _, name := range op.Query {
+		params = append(params, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": "string"}})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		content := map[string]any{
+			"application/json": map[string]any{"schema": sb.schema(reflect.TypeOf(op.Request))},
+		}
+		if op.TextBody {
+			content["text/plain"] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		out["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.Response != nil:
+		resp["content"] = map[string]any{
+			"application/json": map[string]any{"schema": sb.schema(reflect.TypeOf(op.Response))},
+		}
+	case op.Produces != "":
+		resp["content"] = map[string]any{
+			op.Produces: map[string]any{"schema": map[string]any{"type": "string"}},
+		}
+	}
+	out["responses"] = map[string]any{strconv.Itoa(status): resp}
+	return out
+}
+
+var (
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the JSON schema for t.
+func (sb *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := sb.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": sb.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": sb.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sb.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := sb.components[name]; !ok {
+			sb.components[name] = nil // reserve the name before recursing
+			sb.components[name] = sb.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns an object schema with one property per JSON field.
+func (sb *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = sb.schema(f.Type)
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// schemaName returns the component name for a named struct type, with the
+// first letter upper-cased so unexported handler types read naturally.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// pathParams returns the {name} segments of an http.ServeMux pattern path.
+func pathParams(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			names = append(names, strings.TrimSuffix(seg[1:len(seg)-1], "..."))
+		}
+	}
+	return names
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	h := newTestHandler(t)
+	w := httptest.NewRecorder()
+	h.OpenAPI(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("OpenAPI returned %d", w.Code)
+	}
+
+	var spec struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+	for path, method := range map[string]string{
+		"/api/tasks":             "post",
+		"/api/tasks/{id}":        "patch",
+		"/api/instructions":      "put",
+		"/api/config":            "get",
+		"/api/tasks/{id}/diff":   "get",
+		"/api/tasks/run-sync":    "post",
+		"/api/git/create-branch": "post",
+	} {
+		if _, ok := spec.Paths[path][method]; !ok {
+			t.Errorf("spec missing %s %s", strings.ToUpper(method), path)
+		}
+	}
+	if _, ok := spec.Components.Schemas["Task"].Properties["prompt"]; !ok {
+		t.Error("Task schema missing prompt property")
+	}
+}
+
+// TestOpenAPICoversRoutes verifies that every API route registered in
+// server.go has an entry in apiOperations.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	src, err := os.ReadFile("../../server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	re := regexp.MustCompile(`mux\.HandleFunc\("([A-Z]+ /api/[^"]*)"`)
+	matches := re.FindAllStringSubmatch(string(src), -1)
+	if len(matches) == 0 {
+		t.Fatal("no routes found in server.go")
+	}
+	for _, m := range matches {
+		if !documented[m[1]] {
+			t.Errorf("route %q is not described in apiOperations", m[1])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token-bucket rate limiter. It starts full and
+// refills at rate tokens per second up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// allow consumes one token and reports whether one was available.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetCreateRateLimit limits task creation (POST /api/tasks and
+// POST /api/tasks/run-sync) to rate tasks per second with the given burst.
+// A rate of zero or less removes the limit.
+func (h *Handler) SetCreateRateLimit(rate float64, burst int) {
+	if rate <= 0 {
+		h.createLimiter = nil
+		return
+	}
+	h.createLimiter = newTokenBucket(rate, burst)
+}
+
+// allowCreate reports whether a task-creation request may proceed.
+func (h *Handler) allowCreate() bool {
+	return h.createLimiter == nil || h.createLimiter.allow()
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRefills(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2, 1)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	if !b.allow() {
+		t.Fatal("first request should be allowed")
+	}
+	if b.allow() {
+		t.Fatal("second request should be limited")
+	}
+	now = now.Add(500 * time.Millisecond) // 2/s refills one token
+	if !b.allow() {
+		t.Fatal("request after refill should be allowed")
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import "net/http"
+
+// errLaunchPaused is the error returned when a request would launch a task
+// while task launching is paused.
+const errLaunchPaused = "task launching is paused; resume it with POST /api/scheduler/resume"
+
+// schedulerResponse is the body returned by the /api/scheduler endpoints.
+type schedulerResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// GetScheduler reports whether task launching is paused.
+func (h *Handler) GetScheduler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, schedulerResponse{Paused: h.runner.Paused()})
+}
+
+// PauseScheduler stops backlog tasks from being started. Running tasks
+// continue to completion.
+func (h *Handler) PauseScheduler(w http.ResponseWriter, r *http.Request) {
+	h.runner.Pause()
+	writeJSON(w, http.StatusOK, schedulerResponse{Paused: true})
+}
+
+// ResumeScheduler allows backlog tasks to be started again.
+func (h *Handler) ResumeScheduler(w http.ResponseWriter, r *http.Request) {
+	h.runner.Resume()
+	writeJSON(w, http.StatusOK, schedulerResponse{Paused: false})
+}
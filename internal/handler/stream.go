@@ -68,6 +68,55 @@ func (h *Handler) StreamTasks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StreamTaskOutput relays the agent output of a task as SSE while its
+// container runs: one JSON runner.LiveChunk per event. Clients that read too
+// slowly receive periodic snapshots instead of every chunk. The stream stays
+// open across turns until the client disconnects.
+func (h *Handler) StreamTaskOutput(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if _, err := h.store.GetTask(r.Context(), id); err != nil {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	subID, ch := h.runner.SubscribeLive(id)
+	defer h.runner.UnsubscribeLive(id, subID)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case chunk := <-ch:
+			data, err := json.Marshal(chunk)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
 // StreamLogs streams live container logs for an in-progress task, or serves
 // saved turn outputs for tasks that are no longer running.
 func (h *Handler) StreamLogs(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
@@ -88,8 +137,7 @@ func (h *Handler) StreamLogs(w http.ResponseWriter, r *http.Request, id uuid.UUI
 		return
 	}
 
-	containerName := "wallfacer-" + id.String()
-	cmd := exec.CommandContext(r.Context(), h.runner.Command(), "logs", "-f", "--tail", "100", containerName)
+	cmd := exec.CommandContext(r.Context(), h.runner.Command(), "logs", "-f", "--tail", "100", h.runner.ContainerName(id))
 
 	// Merge container stdout and stderr.
 	pr, pw := io.Pipe()
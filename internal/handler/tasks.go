@@ -1,13 +1,24 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/store"
 	"github.com/google/uuid"
 )
@@ -26,30 +37,227 @@ func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, tasks)
 }
 
-// CreateTask creates a new task in backlog status.
+// createTaskRequest is the JSON body accepted by CreateTask and RunTaskSync.
+type createTaskRequest struct {
+	Prompt         string            `json:"prompt"`
+	PromptFile     string            `json:"prompt_file,omitempty"` // host path read as the prompt
+	Timeout        int               `json:"timeout"`
+	MountWorktrees bool              `json:"mount_worktrees"`
+	Env            map[string]string `json:"env"`
+	Scratch        bool              `json:"scratch"`          // run in an empty dir; output via /artifact
+	Status         string            `json:"status,omitempty"` // initial status; default backlog
+
+	ExtraInstructions string `json:"extra_instructions,omitempty"` // appended to this task's CLAUDE.md
+	SnapshotSubpath   string `json:"snapshot_subpath,omitempty"`   // non-git workspaces: snapshot only this subtree
+
+	DependsOn    []uuid.UUID `json:"depends_on,omitempty"`    // tasks this one builds on
+	AllowedPaths []string    `json:"allowed_paths,omitempty"` // repo-relative globs the task may change
+
+	Inputs []taskInput `json:"inputs,omitempty"` // files mounted at /workspace/.tasks/inputs
+}
+
+// taskInput is a file attached to a task at creation, e.g. a spec document
+// or sample data that should not live in the repository.
+type taskInput struct {
+	Name    string `json:"name"`    // plain file name, no directories
+	Content string `json:"content"` // file content, text or JSON
+}
+
+// maxPromptBytes bounds prompts read from a text/plain body or prompt_file.
+const maxPromptBytes = 1 << 20
+
+// maxInputBytes bounds the total size of the files attached to one task.
+const maxInputBytes = 8 << 20
+
+// resolvePromptFile resolves the symlinks of a prompt_file path and checks
+// that it names a file inside one of the configured workspaces, so the API
+// cannot read arbitrary host files. The env file, which holds the API
+// credentials, is refused wherever it lives.
+func (h *Handler) resolvePromptFile(name string) (string, error) {
+	if !filepath.IsAbs(name) {
+		return "", errors.New("must be an absolute path")
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+	if h.envFile != "" {
+		if env, err := filepath.EvalSymlinks(h.envFile); err == nil && env == resolved {
+			return "", errors.New("the env file cannot be used as a prompt")
+		}
+	}
+	for _, ws := range h.runner.Workspaces() {
+		root, err := filepath.EvalSymlinks(ws)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != "." && filepath.IsLocal(rel) {
+			return resolved, nil
+		}
+	}
+	return "", errors.New("must be a file inside a configured workspace")
+}
+
+// decodeCreateTaskRequest parses a task creation request. A text/plain body is
+// taken verbatim as the prompt with all other options at their defaults; a
+// JSON body may name a host file via prompt_file instead of an inline prompt.
+func (h *Handler) decodeCreateTaskRequest(r *http.Request) (createTaskRequest, error) {
+	var req createTaskRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/plain" {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPromptBytes+1))
+		if err != nil {
+			return req, fmt.Errorf("read body: %w", err)
+		}
+		if len(body) > maxPromptBytes {
+			return req, fmt.Errorf("prompt exceeds %d bytes", maxPromptBytes)
+		}
+		req.Prompt = string(body)
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, errors.New("invalid JSON")
+	}
+
+	if req.PromptFile != "" {
+		if req.Prompt != "" {
+			return req, errors.New("prompt and prompt_file are mutually exclusive")
+		}
+		promptFile, err := h.resolvePromptFile(req.PromptFile)
+		if err != nil {
+			return req, fmt.Errorf("prompt_file: %w", err)
+		}
+		info, err := os.Stat(promptFile)
+		if err != nil {
+			return req, fmt.Errorf("prompt_file: %w", err)
+		}
+		if !info.Mode().IsRegular() || info.Size() > maxPromptBytes {
+			return req, fmt.Errorf("prompt_file must be a regular file of at most %d bytes", maxPromptBytes)
+		}
+		content, err := os.ReadFile(promptFile)
+		if err != nil {
+			return req, fmt.Errorf("prompt_file: %w", err)
+		}
+		req.Prompt = string(content)
+		req.PromptFile = ""
+	}
+
+	if strings.TrimSpace(req.Prompt) == "" {
+		return req, errors.New("prompt is required")
+	}
+	if err := validateTaskEnv(req.Env); err != nil {
+		return req, err
+	}
+	if req.SnapshotSubpath != "" {
+		sub, err := cleanSnapshotSubpath(req.SnapshotSubpath)
+		if err != nil {
+			return req, err
+		}
+		req.SnapshotSubpath = sub
+	}
+	for _, g := range req.AllowedPaths {
+		if _, err := path.Match(g, ""); err != nil || path.IsAbs(g) {
+			return req, fmt.Errorf("invalid allowed path %q", g)
+		}
+	}
+	if err := validateTaskInputs(req.Inputs); err != nil {
+		return req, err
+	}
+	if req.Status == "" {
+		req.Status = "backlog"
+	} else if !store.IsInitialStatus(req.Status) {
+		return req, fmt.Errorf("invalid initial status %q", req.Status)
+	}
+	return req, nil
+}
+
+// reserveIdempotencyKey returns the ID of the task already created for key
+// within the idempotency window, or reserves key for the caller and returns a
+// release function to call once the new task's ID has been recorded. A
+// request finding key reserved waits for the holder to release it, so the
+// lock is only held around the lookup and never across task creation.
+func (h *Handler) reserveIdempotencyKey(ctx context.Context, key string) (uuid.UUID, func(), error) {
+	for {
+		h.idemMu.Lock()
+		if id, ok := h.store.LookupIdempotencyKey(ctx, key, h.idemWindow); ok {
+			h.idemMu.Unlock()
+			return id, nil, nil
+		}
+		pending, busy := h.idemPending[key]
+		if !busy {
+			done := make(chan struct{})
+			if h.idemPending == nil {
+				h.idemPending = make(map[string]chan struct{})
+			}
+			h.idemPending[key] = done
+			h.idemMu.Unlock()
+			return uuid.Nil, func() {
+				h.idemMu.Lock()
+				delete(h.idemPending, key)
+				h.idemMu.Unlock()
+				close(done)
+			}, nil
+		}
+		h.idemMu.Unlock()
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return uuid.Nil, nil, ctx.Err()
+		}
+	}
+}
+
+// CreateTask creates a new task, in backlog unless the request names another
+// initial status (e.g. done for a historical record). When the request carries an
+// Idempotency-Key header that was already used within the idempotency window,
+// the original task is returned with 200 instead of creating a duplicate.
 func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Prompt         string `json:"prompt"`
-		Timeout        int    `json:"timeout"`
-		MountWorktrees bool   `json:"mount_worktrees"`
+	idemKey := r.Header.Get("Idempotency-Key")
+	release := func() {}
+	if idemKey != "" {
+		id, rel, err := h.reserveIdempotencyKey(r.Context(), idemKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestTimeout)
+			return
+		}
+		if rel == nil {
+			task, err := h.store.GetTask(r.Context(), id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, task)
+			return
+		}
+		release = sync.OnceFunc(rel)
+		defer release()
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+	if !h.allowCreate() {
+		http.Error(w, "task creation rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
-	if strings.TrimSpace(req.Prompt) == "" {
-		http.Error(w, "prompt is required", http.StatusBadRequest)
+	req, err := h.decodeCreateTaskRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	task, err := h.store.CreateTask(r.Context(), req.Prompt, req.Timeout, req.MountWorktrees)
+	task, err := h.store.CreateTaskWithStatus(r.Context(), req.Prompt, req.Timeout, req.MountWorktrees, req.Status)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if idemKey != "" {
+		if err := h.store.RecordIdempotencyKey(r.Context(), idemKey, task.ID, h.idemWindow); err != nil {
+			logger.Handler.Warn("record idempotency key", "task", task.ID, "error", err)
+		}
+		release()
+	}
+	if err := h.applyCreateOptions(r.Context(), task, req); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	h.store.InsertEvent(r.Context(), task.ID, store.EventTypeStateChange, map[string]string{
-		"to": "backlog",
+		"to": task.Status,
 	})
 
 	go h.runner.GenerateTitle(task.ID, task.Prompt)
@@ -57,16 +265,221 @@ func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, task)
 }
 
+// applyCreateOptions stores the optional per-task settings from req on a
+// freshly created task and mirrors them onto task.
+func (h *Handler) applyCreateOptions(ctx context.Context, task *store.Task, req createTaskRequest) error {
+	if len(req.Env) > 0 {
+		if err := h.store.UpdateTaskEnv(ctx, task.ID, req.Env); err != nil {
+			return err
+		}
+		task.Env = req.Env
+	}
+	if req.Scratch {
+		if err := h.store.SetTaskScratch(ctx, task.ID, true); err != nil {
+			return err
+		}
+		task.Scratch = true
+	}
+	if req.ExtraInstructions != "" {
+		if err := h.store.UpdateTaskExtraInstructions(ctx, task.ID, req.ExtraInstructions); err != nil {
+			return err
+		}
+		task.ExtraInstructions = req.ExtraInstructions
+	}
+	if req.SnapshotSubpath != "" {
+		if err := h.store.UpdateTaskSnapshotSubpath(ctx, task.ID, req.SnapshotSubpath); err != nil {
+			return err
+		}
+		task.SnapshotSubpath = req.SnapshotSubpath
+	}
+	if len(req.DependsOn) > 0 {
+		if err := h.store.UpdateTaskDependsOn(ctx, task.ID, req.DependsOn); err != nil {
+			return err
+		}
+		task.DependsOn = req.DependsOn
+	}
+	if len(req.AllowedPaths) > 0 {
+		if err := h.store.UpdateTaskAllowedPaths(ctx, task.ID, req.AllowedPaths); err != nil {
+			return err
+		}
+		task.AllowedPaths = req.AllowedPaths
+	}
+	if len(req.Inputs) > 0 {
+		files := make(map[string][]byte, len(req.Inputs))
+		for _, in := range req.Inputs {
+			files[in.Name] = []byte(in.Content)
+		}
+		if err := h.store.SetTaskInputs(ctx, task.ID, files); err != nil {
+			return err
+		}
+		task.Inputs = slices.Sorted(maps.Keys(files))
+	}
+	return nil
+}
+
+// cleanSnapshotSubpath normalises a snapshot subpath and rejects ones that
+// are absolute or would escape the workspace.
+func cleanSnapshotSubpath(sub string) (string, error) {
+	sub = filepath.Clean(filepath.FromSlash(sub))
+	if filepath.IsAbs(sub) || sub == ".." || strings.HasPrefix(sub, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid snapshot_subpath %q: must be relative to the workspace", sub)
+	}
+	if sub == "." {
+		return "", nil
+	}
+	return sub, nil
+}
+
+// validateTaskEnv rejects per-task environment variable names that cannot be
+// passed to the container runtime as -e KEY=VALUE.
+func validateTaskEnv(env map[string]string) error {
+	for k := range env {
+		if k == "" || strings.ContainsAny(k, "= \t\n") {
+			return fmt.Errorf("invalid env variable name %q", k)
+		}
+	}
+	return nil
+}
+
+// validateTaskInputs rejects attached files with unusable or repeated names
+// and attachments larger than maxInputBytes in total.
+func validateTaskInputs(inputs []taskInput) error {
+	seen := make(map[string]bool, len(inputs))
+	total := 0
+	for _, in := range inputs {
+		if err := store.ValidateInputName(in.Name); err != nil {
+			return err
+		}
+		if seen[in.Name] {
+			return fmt.Errorf("input file %q is attached twice", in.Name)
+		}
+		seen[in.Name] = true
+		total += len(in.Content)
+	}
+	if total > maxInputBytes {
+		return fmt.Errorf("input files exceed %d bytes", maxInputBytes)
+	}
+	return nil
+}
+
+// defaultRunSyncTimeout bounds how long RunTaskSync blocks when the caller
+// does not pass ?timeout=.
+const defaultRunSyncTimeout = 30 * time.Minute
+
+// runSyncResponse is the JSON body returned by RunTaskSync.
+type runSyncResponse struct {
+	ID           uuid.UUID         `json:"id"`
+	Status       string            `json:"status"`
+	Result       *string           `json:"result"`
+	CommitHashes map[string]string `json:"commit_hashes,omitempty"`
+	TimedOut     bool              `json:"timed_out,omitempty"`
+}
+
+// RunTaskSync creates a task, starts it immediately, and blocks until it
+// reaches done, failed, cancelled, or waiting. The optional ?timeout= query
+// parameter (a Go duration such as "10m") bounds the wait; on expiry the
+// current state is returned with 504 and the task keeps running.
+func (h *Handler) RunTaskSync(w http.ResponseWriter, r *http.Request) {
+	if !h.allowCreate() {
+		http.Error(w, "task creation rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	req, err := h.decodeCreateTaskRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Status != "backlog" {
+		http.Error(w, "run-sync tasks always start from backlog", http.StatusBadRequest)
+		return
+	}
+	if h.runner.Paused() {
+		http.Error(w, errLaunchPaused, http.StatusConflict)
+		return
+	}
+	wait := defaultRunSyncTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid timeout", http.StatusBadRequest)
+			return
+		}
+		wait = d
+	}
+
+	// Subscribe before starting the run so no state change is missed.
+	subID, ch := h.store.Subscribe()
+	defer h.store.Unsubscribe(subID)
+
+	task, err := h.store.CreateTask(r.Context(), req.Prompt, req.Timeout, req.MountWorktrees)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.applyCreateOptions(r.Context(), task, req); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.store.InsertEvent(r.Context(), task.ID, store.EventTypeStateChange, map[string]string{
+		"to": "backlog",
+	})
+	if err := h.store.UpdateTaskStatus(r.Context(), task.ID, "in_progress"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.store.InsertEvent(r.Context(), task.ID, store.EventTypeStateChange, map[string]string{
+		"from": "backlog",
+		"to":   "in_progress",
+	})
+
+	go h.runner.GenerateTitle(task.ID, task.Prompt)
+	go h.runner.Run(task.ID, task.Prompt, "", false)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		cur, err := h.store.GetTask(r.Context(), task.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := runSyncResponse{
+			ID:           cur.ID,
+			Status:       cur.Status,
+			Result:       cur.Result,
+			CommitHashes: cur.CommitHashes,
+		}
+		switch cur.Status {
+		case "done", "failed", "cancelled", "waiting":
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		select {
+		case <-ch:
+		case <-timer.C:
+			resp.TimedOut = true
+			writeJSON(w, http.StatusGatewayTimeout, resp)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// updateTaskRequest is the JSON body accepted by UpdateTask. Nil fields are
+// left unchanged.
+type updateTaskRequest struct {
+	Status         *string `json:"status"`
+	Position       *int    `json:"position"`
+	Prompt         *string `json:"prompt"`
+	Timeout        *int    `json:"timeout"`
+	FreshStart     *bool   `json:"fresh_start"`
+	MountWorktrees *bool   `json:"mount_worktrees"`
+}
+
 // UpdateTask handles PATCH requests: status transitions, position, prompt, etc.
 func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
-	var req struct {
-		Status         *string `json:"status"`
-		Position       *int    `json:"position"`
-		Prompt         *string `json:"prompt"`
-		Timeout        *int    `json:"timeout"`
-		FreshStart     *bool   `json:"fresh_start"`
-		MountWorktrees *bool   `json:"mount_worktrees"`
-	}
+	var req updateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
 		return
@@ -97,8 +510,8 @@ func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request, id uuid.UUI
 		oldStatus := task.Status
 		newStatus := *req.Status
 
-		// Handle retry: done/failed/waiting/cancelled → backlog
-		if newStatus == "backlog" && (oldStatus == "done" || oldStatus == "failed" || oldStatus == "cancelled" || oldStatus == "waiting") {
+		// Handle retry: done/failed/waiting/cancelled/dead → backlog
+		if newStatus == "backlog" && store.IsRetryable(oldStatus) {
 			// Clean up any existing worktrees before resetting.
 			if len(task.WorktreePaths) > 0 {
 				h.runner.CleanupWorktrees(id, task.WorktreePaths, task.BranchName)
@@ -121,8 +534,16 @@ func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request, id uuid.UUI
 				"to":   "backlog",
 			})
 		} else {
+			if newStatus == "in_progress" && oldStatus == "backlog" && h.runner.Paused() {
+				http.Error(w, errLaunchPaused, http.StatusConflict)
+				return
+			}
 			if err := h.store.UpdateTaskStatus(r.Context(), id, newStatus); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				code := http.StatusInternalServerError
+				if errors.Is(err, store.ErrInvalidTransition) {
+					code = http.StatusBadRequest
+				}
+				http.Error(w, err.Error(), code)
 				return
 			}
 			h.store.InsertEvent(r.Context(), id, store.EventTypeStateChange, map[string]string{
@@ -148,6 +569,52 @@ func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request, id uuid.UUI
 	writeJSON(w, http.StatusOK, updated)
 }
 
+// GetTask returns a single task. The {id} path segment may be a full UUID or
+// any unique prefix of one, such as the short ID shown on the board.
+func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
+	raw := r.PathValue("id")
+	var task *store.Task
+	var err error
+	if id, parseErr := uuid.Parse(raw); parseErr == nil {
+		task, err = h.store.GetTask(r.Context(), id)
+	} else {
+		task, err = h.store.GetTaskByShortID(r.Context(), raw)
+	}
+	if errors.Is(err, store.ErrAmbiguousID) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// reorderBacklogRequest is the JSON body accepted by ReorderBacklog.
+type reorderBacklogRequest struct {
+	IDs []uuid.UUID `json:"ids"` // backlog tasks to move to the front, in order
+}
+
+// ReorderBacklog moves the listed backlog tasks to the front of the backlog
+// column in the given order.
+func (h *Handler) ReorderBacklog(w http.ResponseWriter, r *http.Request) {
+	var req reorderBacklogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.ReorderBacklog(r.Context(), req.IDs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reordered"})
+}
+
 // DeleteTask removes a task and its data.
 func (h *Handler) DeleteTask(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
 	if task, err := h.store.GetTask(r.Context(), id); err == nil && len(task.WorktreePaths) > 0 {
@@ -195,6 +662,23 @@ func (h *Handler) ServeOutput(w http.ResponseWriter, r *http.Request, id uuid.UU
 	http.ServeFile(w, r, path)
 }
 
+// GetTranscript serves the task's redacted conversation transcript: one
+// JSON object per line, the prompts wallfacer sent followed by the agent's
+// stream-json output for each turn.
+func (h *Handler) GetTranscript(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	task, err := h.store.GetTask(r.Context(), id)
+	if err != nil {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if task.Transcript == "" {
+		http.Error(w, "task has no transcript yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	http.ServeFile(w, r, h.store.TranscriptPath(id))
+}
+
 // GenerateMissingTitles triggers background title generation for untitled tasks.
 func (h *Handler) GenerateMissingTitles(w http.ResponseWriter, r *http.Request) {
 	limit := 10
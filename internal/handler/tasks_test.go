@@ -0,0 +1,611 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/wallfacer/internal/runner"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// fakeCmdHandler creates a Handler whose runner executes a shell script that
+// prints output and exits with exitCode instead of a real container runtime.
+func fakeCmdHandler(t *testing.T, output string, exitCode int) *Handler {
+	t.Helper()
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(dataPath, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fakeScriptHandler(t, fmt.Sprintf("cat %s\nexit %d\n", dataPath, exitCode))
+}
+
+// fakeScriptHandler creates a Handler whose runner executes body as a shell
+// script in place of the container runtime.
+func fakeScriptHandler(t *testing.T, body string) *Handler {
+	t.Helper()
+	scriptPath := filepath.Join(t.TempDir(), "fake-cmd")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	r := runner.NewRunner(s, runner.RunnerConfig{
+		Command:      scriptPath,
+		SandboxImage: "test:latest",
+		WorktreesDir: t.TempDir(),
+	})
+	return NewHandler(s, r, t.TempDir(), nil)
+}
+
+func TestRunTaskSyncReturnsResult(t *testing.T) {
+	h := fakeCmdHandler(t, `{"result":"all done","session_id":"s1","stop_reason":"end_turn","is_error":false}`, 0)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/run-sync?timeout=1m", strings.NewReader(`{"prompt":"do it"}`))
+	w := httptest.NewRecorder()
+	h.RunTaskSync(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("RunTaskSync returned %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp runSyncResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "done" {
+		t.Errorf("status = %q, want done", resp.Status)
+	}
+	if resp.Result == nil || *resp.Result != "all done" {
+		t.Errorf("result = %v, want %q", resp.Result, "all done")
+	}
+
+	waitRunSettled(t, h, resp.ID)
+}
+
+// waitRunSettled waits for the final state_change event, which the runner
+// records just after the status flips to done, and for the title so no
+// goroutine outlives the test.
+func waitRunSettled(t *testing.T, h *Handler, id uuid.UUID) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		events, _ := h.store.GetEvents(context.Background(), id)
+		task, _ := h.store.GetTask(context.Background(), id)
+		if len(events) > 0 && strings.Contains(string(events[len(events)-1].Data), `"to":"done"`) && task.Title != "" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("run did not settle")
+}
+
+func TestRunTaskSyncRejectsBadTimeout(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/run-sync?timeout=soon", strings.NewReader(`{"prompt":"x"}`))
+	w := httptest.NewRecorder()
+	h.RunTaskSync(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestCreateTaskStoresEnv(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x","env":{"FOO":"bar"}}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	task, err := h.store.GetTask(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Env["FOO"] != "bar" {
+		t.Errorf("Env = %v, want FOO=bar", task.Env)
+	}
+	if created.Env["FOO"] != "***" {
+		t.Errorf("Env in response = %v, want the value masked", created.Env)
+	}
+}
+
+func TestCreateTaskStoresExtraInstructions(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x","extra_instructions":"use tabs"}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	task, err := h.store.GetTask(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ExtraInstructions != "use tabs" {
+		t.Errorf("ExtraInstructions = %q, want %q", task.ExtraInstructions, "use tabs")
+	}
+}
+
+func TestCreateTaskSnapshotSubpath(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x","snapshot_subpath":"src/"}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.SnapshotSubpath != "src" {
+		t.Errorf("SnapshotSubpath = %q, want %q", created.SnapshotSubpath, "src")
+	}
+
+	for _, sub := range []string{"../other", "/abs"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x","snapshot_subpath":"`+sub+`"}`))
+		w := httptest.NewRecorder()
+		h.CreateTask(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("snapshot_subpath %q: expected 400, got %d", sub, w.Code)
+		}
+	}
+}
+
+func TestGetTaskByShortIDPrefix(t *testing.T) {
+	h := newTestHandler(t)
+	task, err := h.store.CreateTask(context.Background(), "find me", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{task.ID.String(), task.ID.String()[:8]} {
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+id, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		h.GetTask(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s returned %d: %s", id, w.Code, w.Body.String())
+		}
+		var got store.Task
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != task.ID {
+			t.Errorf("GET %s resolved %s, want %s", id, got.ID, task.ID)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/zz", nil)
+	req.SetPathValue("id", "zz")
+	w := httptest.NewRecorder()
+	h.GetTask(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown id: expected 404, got %d", w.Code)
+	}
+}
+
+// TestUpdateTaskRetriesDeadTask verifies that a dead task can be moved back
+// to backlog, the only way to revive it.
+func TestUpdateTaskRetriesDeadTask(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	task, _ := h.store.CreateTask(ctx, "flaky", 5, false)
+	for _, st := range []string{"in_progress", "failed", "dead"} {
+		if err := h.store.UpdateTaskStatus(ctx, task.ID, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/tasks/"+task.ID.String(), strings.NewReader(`{"status":"backlog"}`))
+	w := httptest.NewRecorder()
+	h.UpdateTask(w, req, task.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if got, _ := h.store.GetTask(ctx, task.ID); got.Status != "backlog" {
+		t.Fatalf("status = %q, want backlog", got.Status)
+	}
+}
+
+func TestPauseBlocksTaskLaunch(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "launched")
+	h := fakeScriptHandler(t, fmt.Sprintf("touch %s\necho '%s'\n", marker,
+		`{"result":"ok","session_id":"s1","stop_reason":"end_turn","is_error":false}`))
+	ctx := context.Background()
+
+	w := httptest.NewRecorder()
+	h.PauseScheduler(w, httptest.NewRequest(http.MethodPost, "/api/scheduler/pause", nil))
+	if !h.runner.Paused() {
+		t.Fatal("runner should be paused")
+	}
+	task, _ := h.store.CreateTask(ctx, "p", 5, false)
+
+	start := func() int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/tasks/"+task.ID.String(), strings.NewReader(`{"status":"in_progress"}`))
+		w := httptest.NewRecorder()
+		h.UpdateTask(w, req, task.ID)
+		return w.Code
+	}
+	if code := start(); code != http.StatusConflict {
+		t.Fatalf("start while paused: got %d, want 409", code)
+	}
+	if got, _ := h.store.GetTask(ctx, task.ID); got.Status != "backlog" {
+		t.Errorf("status = %q, want backlog", got.Status)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("no container should launch while paused")
+	}
+
+	h.ResumeScheduler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/scheduler/resume", nil))
+	if code := start(); code != http.StatusOK {
+		t.Fatalf("start after resume: got %d, want 200", code)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got, _ := h.store.GetTask(ctx, task.ID)
+		if got.Status == "done" || got.Status == "failed" || got.Status == "waiting" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task did not finish after resume (status %q)", got.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("container should launch after resume")
+	}
+}
+
+func TestCreateTaskStoresInputs(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x","inputs":[{"name":"data.json","content":"{\"a\":1}"}]}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Inputs) != 1 || created.Inputs[0] != "data.json" {
+		t.Errorf("Inputs = %v, want [data.json]", created.Inputs)
+	}
+	data, err := os.ReadFile(filepath.Join(h.store.InputsDir(created.ID), "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":1}` {
+		t.Errorf("data.json = %q", data)
+	}
+}
+
+func TestCreateTaskRejectsInvalidInputs(t *testing.T) {
+	h := newTestHandler(t)
+	for _, body := range []string{
+		`{"prompt":"x","inputs":[{"name":"../escape","content":""}]}`,
+		`{"prompt":"x","inputs":[{"name":"","content":""}]}`,
+		`{"prompt":"x","inputs":[{"name":"a","content":""},{"name":"a","content":""}]}`,
+	} {
+		w := httptest.NewRecorder()
+		h.CreateTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestCreateTaskRejectsInvalidEnvName(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x","env":{"A=B":"c"}}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestCreateTaskRateLimited(t *testing.T) {
+	h := newTestHandler(t)
+	const burst = 3
+	h.SetCreateRateLimit(0.001, burst)
+
+	var codes []int
+	for i := 0; i < burst+2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x"}`))
+		w := httptest.NewRecorder()
+		h.CreateTask(w, req)
+		codes = append(codes, w.Code)
+	}
+	for i, code := range codes {
+		want := http.StatusCreated
+		if i >= burst {
+			want = http.StatusTooManyRequests
+		}
+		if code != want {
+			t.Errorf("request %d: got %d, want %d", i, code, want)
+		}
+	}
+	tasks, _ := h.store.ListTasks(context.Background(), true)
+	if len(tasks) != burst {
+		t.Errorf("created %d tasks, want %d", len(tasks), burst)
+	}
+}
+
+func TestCreateTaskIdempotencyKey(t *testing.T) {
+	h := newTestHandler(t)
+
+	create := func() (int, store.Task) {
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"x"}`))
+		req.Header.Set("Idempotency-Key", "retry-1")
+		w := httptest.NewRecorder()
+		h.CreateTask(w, req)
+		var task store.Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+		return w.Code, task
+	}
+
+	code1, first := create()
+	code2, second := create()
+	if code1 != http.StatusCreated || code2 != http.StatusOK {
+		t.Fatalf("codes = %d, %d; want 201, 200", code1, code2)
+	}
+	if first.ID != second.ID {
+		t.Errorf("repeated key returned %s, want %s", second.ID, first.ID)
+	}
+	tasks, _ := h.store.ListTasks(context.Background(), true)
+	if len(tasks) != 1 {
+		t.Errorf("created %d tasks, want 1", len(tasks))
+	}
+}
+
+func TestReserveIdempotencyKeyOnlyBlocksSameKey(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	_, releaseA, err := h.reserveIdempotencyKey(ctx, "a")
+	if err != nil || releaseA == nil {
+		t.Fatalf("reserve a: release=%v err=%v", releaseA != nil, err)
+	}
+
+	// Another key, and a request without a key, proceed while "a" is held.
+	_, releaseB, err := h.reserveIdempotencyKey(ctx, "b")
+	if err != nil || releaseB == nil {
+		t.Fatalf("reserve b: release=%v err=%v", releaseB != nil, err)
+	}
+	releaseB()
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"other"}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unkeyed CreateTask returned %d while a key was reserved", w.Code)
+	}
+
+	// The same key waits until the holder records its task.
+	task, err := h.store.CreateTask(ctx, "first", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan uuid.UUID, 1)
+	go func() {
+		id, _, _ := h.reserveIdempotencyKey(ctx, "a")
+		got <- id
+	}()
+	select {
+	case <-got:
+		t.Fatal("second reservation of a held key did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := h.store.RecordIdempotencyKey(ctx, "a", task.ID, h.idemWindow); err != nil {
+		t.Fatal(err)
+	}
+	releaseA()
+	select {
+	case id := <-got:
+		if id != task.ID {
+			t.Errorf("waiter got %s, want %s", id, task.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter not released")
+	}
+}
+
+func TestCreateTaskTextPlainPrompt(t *testing.T) {
+	h := newTestHandler(t)
+	prompt := "Refactor the parser.\n\nKeep \"quotes\" and {braces} intact.\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(prompt))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Prompt != prompt {
+		t.Errorf("prompt = %q, want %q", created.Prompt, prompt)
+	}
+}
+
+func TestCreateTaskInitialStatus(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"already shipped","status":"done"}`))
+	w := httptest.NewRecorder()
+	h.CreateTask(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Status != "done" {
+		t.Errorf("status = %q, want done", created.Status)
+	}
+
+	for _, status := range []string{"in_progress", "bogus"} {
+		req = httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"prompt":"p","status":"`+status+`"}`))
+		w = httptest.NewRecorder()
+		h.CreateTask(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status %q: got %d, want 400", status, w.Code)
+		}
+	}
+}
+
+func TestCreateTaskPromptFile(t *testing.T) {
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := t.TempDir()
+	envFile := filepath.Join(ws, ".env")
+	h := NewHandler(s, runner.NewRunner(s, runner.RunnerConfig{Workspaces: ws, EnvFile: envFile}), t.TempDir(), nil)
+	outside := filepath.Join(t.TempDir(), "secret.md")
+	link := filepath.Join(ws, "link.md")
+	for path, content := range map[string]string{
+		filepath.Join(ws, "prompt.md"): "from a file",
+		envFile:                        "ANTHROPIC_API_KEY=sk-secret\n",
+		outside:                        "not for the API",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	create := func(promptFile string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"prompt_file": promptFile})
+		w := httptest.NewRecorder()
+		h.CreateTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(string(body))))
+		return w
+	}
+
+	w := create(filepath.Join(ws, "prompt.md"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTask returned %d: %s", w.Code, w.Body.String())
+	}
+	var created store.Task
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Prompt != "from a file" {
+		t.Errorf("prompt = %q, want %q", created.Prompt, "from a file")
+	}
+
+	// Missing files, files outside the workspaces (directly or through a
+	// symlink), relative paths, and the env file are rejected.
+	for _, path := range []string{
+		filepath.Join(ws, "missing.md"),
+		outside,
+		link,
+		"prompt.md",
+		envFile,
+	} {
+		if w := create(path); w.Code != http.StatusBadRequest {
+			t.Errorf("prompt_file %s: got %d, want 400", path, w.Code)
+		} else if strings.Contains(w.Body.String(), "sk-secret") || strings.Contains(w.Body.String(), "not for the API") {
+			t.Errorf("prompt_file %s: response leaks the file: %s", path, w.Body.String())
+		}
+	}
+}
+
+// TestGetTranscript verifies that a task's transcript is served once it has
+// one, and that a task without one gets a 404.
+func TestGetTranscript(t *testing.T) {
+	h := newTestHandler(t)
+	task, _ := h.store.CreateTask(context.Background(), "p", 5, false)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.GetTranscript(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+task.ID.String()+"/transcript", nil), task.ID)
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Fatalf("before any turn: got %d, want 404", w.Code)
+	}
+
+	line := `{"type":"result","result":"done"}` + "\n"
+	if err := h.store.AppendTranscript(task.ID, []byte(line)); err != nil {
+		t.Fatal(err)
+	}
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	if w.Body.String() != line {
+		t.Errorf("body = %q, want %q", w.Body.String(), line)
+	}
+}
+
+// TestCancelWorkspaceTasks verifies that the workspace is looked up by
+// basename and that an unknown name is rejected.
+func TestCancelWorkspaceTasks(t *testing.T) {
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := filepath.Join(t.TempDir(), "repo")
+	r := runner.NewRunner(s, runner.RunnerConfig{Workspaces: ws})
+	h := NewHandler(s, r, t.TempDir(), []string{ws})
+	cancelAll := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/workspaces/"+name+"/cancel-all", nil)
+		req.SetPathValue("name", name)
+		w := httptest.NewRecorder()
+		h.CancelWorkspaceTasks(w, req)
+		return w
+	}
+
+	if w := cancelAll("other"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown workspace: got %d, want 404", w.Code)
+	}
+	w := cancelAll("repo")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"cancelled":[]}` {
+		t.Errorf("body = %s, want an empty cancelled list", got)
+	}
+}
+
+// TestCancelCommittingTaskOutsideDelay verifies that a committing task whose
+// merge is not in its pre-merge delay cannot be cancelled.
+func TestCancelCommittingTaskOutsideDelay(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+	task, _ := h.store.CreateTaskWithStatus(ctx, "p", 5, false, "waiting")
+	if err := h.store.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.CancelTask(w, httptest.NewRequest(http.MethodPost, "/", nil), task.ID)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("got %d, want 409", w.Code)
+	}
+	if got, _ := h.store.GetTask(ctx, task.ID); got.Status != "committing" {
+		t.Errorf("status = %q, want committing", got.Status)
+	}
+}
@@ -2,6 +2,7 @@ package instructions
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,11 @@ import (
 	"strings"
 )
 
+// ReviewRequestMarker is the line an agent ends its final message with to
+// ask for a person's review before its changes merge. The runner then holds
+// the task in waiting instead of committing it.
+const ReviewRequestMarker = "WALLFACER: REVIEW REQUESTED"
+
 // defaultTemplate is the baseline CLAUDE.md content written into every
 // new workspace instructions file. It provides general guidance for Claude Code
 // operating inside a Wallfacer-managed task.
@@ -24,17 +30,25 @@ This file provides guidance to Claude Code when working on tasks in this workspa
 - Run tests if available to verify your changes work correctly.
 - Write clear, descriptive commit messages explaining the "why" not just the "what".
 - Do not create documentation files or README updates unless explicitly requested.
+- If a change is risky and a person should review it before it merges, end your
+  final message with a line containing only ` + "`" + ReviewRequestMarker + "`" + `.
+`
 
+// boardContextSection is appended to the default template unless the board
+// context is disabled, in which case no board.json is mounted to describe.
+const boardContextSection = `
 ## Board Context
 
 A read-only board context is mounted at ` + "`/workspace/.tasks/board.json`" + `.
 It contains a JSON manifest of all active tasks on the board including their
 prompts, statuses, results, and branch names. Your task is marked with
-` + "`\"is_self\": true`" + `.
+` + "`\"is_self\": true`" + `; its ` + "`commits_behind`" + ` counts the commits the
+default branch has gained that your worktree lacks.
 
 Use this to avoid conflicting changes with sibling tasks or reference
 completed work. If sibling worktrees are mounted, they appear under
 ` + "`/workspace/.tasks/worktrees/<short-id>/<repo>/`" + ` as read-only directories.
+Files attached to your task, if any, are under ` + "`/workspace/.tasks/inputs/`" + `.
 `
 
 // workspaceLayoutSection is appended to the default template with the actual
@@ -48,6 +62,32 @@ create files or directories directly under ` + "`/workspace/`" + `.
 
 `
 
+// Placements of the repo CLAUDE.md files accepted by Options.Order.
+const (
+	OrderAppend  = "append"
+	OrderPrepend = "prepend"
+)
+
+// Options adjusts how the workspace instructions file is built. The zero
+// value produces the default content.
+type Options struct {
+	// OmitLayout leaves out the "## Workspace Layout" section listing the
+	// mount path of each workspace.
+	OmitLayout bool `json:"omit_layout,omitempty"`
+
+	// OmitBoard leaves out the "## Board Context" section describing the
+	// board.json manifest, for runners started with the board disabled.
+	OmitBoard bool `json:"omit_board,omitempty"`
+
+	// Order places the repo CLAUDE.md files after (OrderAppend, the
+	// default) or before (OrderPrepend) the wallfacer template.
+	Order string `json:"order,omitempty"`
+
+	// OmitRepos leaves out the repo CLAUDE.md files, for runners that mount
+	// each repo's own file at its repo path instead of merging them.
+	OmitRepos bool `json:"omit_repos,omitempty"`
+}
+
 // Key returns a stable 16-char hex key for a given set of workspace paths.
 // The key is derived from the SHA-256 of the sorted, colon-joined absolute paths,
 // so the same set of workspaces always maps to the same file regardless of order.
@@ -69,12 +109,16 @@ func FilePath(configDir string, workspaces []string) string {
 // Ensure ensures the CLAUDE.md for the given workspace set exists.
 // If it does not exist yet it is created from the default template plus any CLAUDE.md
 // files found in the workspace directories. Returns the path to the file.
-func Ensure(configDir string, workspaces []string) (string, error) {
+// The workspace set and opts are recorded in a sidecar file so ReinitAll can
+// rebuild it the same way.
+func Ensure(configDir string, workspaces []string, opts Options) (string, error) {
 	dir := filepath.Join(configDir, "instructions")
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("create instructions dir: %w", err)
 	}
-
+	if err := writeSidecar(configDir, workspaces, opts); err != nil {
+		return "", err
+	}
 	path := FilePath(configDir, workspaces)
 
 	// Already exists — honour the user's edits, do not overwrite.
@@ -82,7 +126,7 @@ func Ensure(configDir string, workspaces []string) (string, error) {
 		return path, nil
 	}
 
-	content := BuildContent(workspaces)
+	content := BuildContent(workspaces, opts)
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("write instructions: %w", err)
 	}
@@ -91,48 +135,174 @@ func Ensure(configDir string, workspaces []string) (string, error) {
 
 // Reinit rebuilds the workspace CLAUDE.md from the default template plus any
 // per-repo CLAUDE.md files, overwriting any existing content.
-func Reinit(configDir string, workspaces []string) (string, error) {
+func Reinit(configDir string, workspaces []string, opts Options) (string, error) {
 	dir := filepath.Join(configDir, "instructions")
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("create instructions dir: %w", err)
 	}
-
+	if err := writeSidecar(configDir, workspaces, opts); err != nil {
+		return "", err
+	}
 	path := FilePath(configDir, workspaces)
-	content := BuildContent(workspaces)
+	content := BuildContent(workspaces, opts)
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("write instructions: %w", err)
 	}
 	return path, nil
 }
 
+// ReinitAll rebuilds every instructions file whose workspace set was
+// recorded by Ensure or Reinit, e.g. to pick up a new default template after
+// an upgrade. Each file is rebuilt with the Options recorded alongside it, so
+// it matches what the server that last wrote it would produce. Files without
+// a sidecar (created before sidecars existed) are left alone until the next
+// Ensure for their workspaces records one. Returns the paths rebuilt.
+func ReinitAll(configDir string) ([]string, error) {
+	sidecars, err := filepath.Glob(filepath.Join(configDir, "instructions", "*"+sidecarExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(sidecars)
+	var paths []string
+	for _, sc := range sidecars {
+		key := strings.TrimSuffix(filepath.Base(sc), sidecarExt)
+		rec, err := readSidecar(configDir, key)
+		if err != nil {
+			return paths, err
+		}
+		path, err := Reinit(configDir, rec.Workspaces, rec.Options)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// sidecarExt is the extension of the <key>.json file next to each
+// instructions file that records its workspace set and build options.
+const sidecarExt = ".json"
+
+// sidecar is the JSON content of a workspace-set record.
+type sidecar struct {
+	Workspaces []string `json:"workspaces"`
+	Options    Options  `json:"options"`
+}
+
+// RecordedWorkspaces returns the sorted workspace set recorded for the
+// instructions file with the given key, making the one-way Key readable.
+func RecordedWorkspaces(configDir, key string) ([]string, error) {
+	rec, err := readSidecar(configDir, key)
+	if err != nil {
+		return nil, err
+	}
+	return rec.Workspaces, nil
+}
+
+// readSidecar loads the record stored next to the instructions file with
+// the given key.
+func readSidecar(configDir, key string) (sidecar, error) {
+	var rec sidecar
+	raw, err := os.ReadFile(filepath.Join(configDir, "instructions", key+sidecarExt))
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return rec, fmt.Errorf("parse workspace record for %s: %w", key, err)
+	}
+	return rec, nil
+}
+
+// writeSidecar records workspaces and opts next to their instructions file.
+func writeSidecar(configDir string, workspaces []string, opts Options) error {
+	sorted := make([]string, len(workspaces))
+	copy(sorted, workspaces)
+	sort.Strings(sorted)
+	raw, err := json.MarshalIndent(sidecar{Workspaces: sorted, Options: opts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, "instructions", Key(workspaces)+sidecarExt)
+	if err := os.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+		return fmt.Errorf("write workspace record: %w", err)
+	}
+	return nil
+}
+
 // BuildContent assembles CLAUDE.md content from:
 //  1. The default wallfacer instructions template.
-//  2. Any CLAUDE.md found in the workspace directories (appended in order).
-func BuildContent(workspaces []string) string {
-	var sb strings.Builder
-	sb.WriteString(defaultTemplate)
+//  2. The board context section, unless opts.OmitBoard is set.
+//  3. The workspace layout section, unless opts.OmitLayout is set.
+//  4. Any CLAUDE.md found in the workspace directories, in workspace order,
+//     unless opts.OmitRepos is set.
+//
+// The repo files come last by default; with opts.Order set to OrderPrepend
+// they come first so their rules take precedence over wallfacer's defaults.
+func BuildContent(workspaces []string, opts Options) string {
+	var base strings.Builder
+	base.WriteString(defaultTemplate)
+	if !opts.OmitBoard {
+		base.WriteString(boardContextSection)
+	}
 
 	// Append workspace layout section so Claude knows where each repo lives.
-	sb.WriteString(workspaceLayoutSection)
-	for _, ws := range workspaces {
-		name := filepath.Base(ws)
-		sb.WriteString(fmt.Sprintf("- `/workspace/%s/`\n", name))
+	if !opts.OmitLayout {
+		base.WriteString(workspaceLayoutSection)
+		for _, ws := range workspaces {
+			name := filepath.Base(ws)
+			base.WriteString(fmt.Sprintf("- `/workspace/%s/`\n", name))
+		}
+		base.WriteByte('\n')
 	}
-	sb.WriteByte('\n')
 
+	if opts.OmitRepos {
+		return base.String()
+	}
+
+	var repos strings.Builder
+	for _, f := range repoFiles(workspaces) {
+		names := make([]string, len(f.names))
+		for i, name := range f.names {
+			names[i] = "`" + name + "`"
+		}
+		repos.WriteString(fmt.Sprintf("\n---\n\n## Instructions from %s\n\n", strings.Join(names, ", ")))
+		repos.Write(f.content)
+		if len(f.content) > 0 && f.content[len(f.content)-1] != '\n' {
+			repos.WriteByte('\n')
+		}
+	}
+
+	if opts.Order == OrderPrepend && repos.Len() > 0 {
+		return strings.TrimPrefix(repos.String(), "\n") + "\n---\n\n" + base.String()
+	}
+	return base.String() + repos.String()
+}
+
+// repoFile is one distinct CLAUDE.md body and the workspaces that carry it.
+type repoFile struct {
+	names   []string
+	content []byte
+}
+
+// repoFiles reads the CLAUDE.md of each workspace. Byte-identical files (a
+// shared file symlinked or copied into several repos) are merged into one
+// entry listing every contributing workspace, ordered by first appearance.
+func repoFiles(workspaces []string) []*repoFile {
+	var files []*repoFile
+	byContent := make(map[string]*repoFile)
 	for _, ws := range workspaces {
-		claudePath := filepath.Join(ws, "CLAUDE.md")
-		raw, err := os.ReadFile(claudePath)
+		raw, err := os.ReadFile(filepath.Join(ws, "CLAUDE.md"))
 		if err != nil {
 			continue
 		}
 		name := filepath.Base(ws)
-		sb.WriteString(fmt.Sprintf("\n---\n\n## Instructions from `%s`\n\n", name))
-		sb.Write(raw)
-		if len(raw) > 0 && raw[len(raw)-1] != '\n' {
-			sb.WriteByte('\n')
+		if f, ok := byContent[string(raw)]; ok {
+			f.names = append(f.names, name)
+			continue
 		}
+		f := &repoFile{names: []string{name}, content: raw}
+		byContent[string(raw)] = f
+		files = append(files, f)
 	}
-
-	return sb.String()
+	return files
 }
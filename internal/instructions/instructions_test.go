@@ -60,7 +60,7 @@ func TestInstructionsKeyLength(t *testing.T) {
 // workspace layout section but no per-repo instructions sections.
 func TestBuildInstructionsContentDefault(t *testing.T) {
 	dir := t.TempDir() // no CLAUDE.md inside
-	content := BuildContent([]string{dir})
+	content := BuildContent([]string{dir}, Options{})
 	if !strings.HasPrefix(content, defaultTemplate) {
 		t.Fatal("content should start with the default template")
 	}
@@ -85,7 +85,7 @@ func TestBuildInstructionsContentWithWorkspaceCLAUDE(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	content := BuildContent([]string{dir})
+	content := BuildContent([]string{dir}, Options{})
 
 	if !strings.HasPrefix(content, defaultTemplate) {
 		t.Fatal("content should start with the default template")
@@ -106,7 +106,7 @@ func TestBuildInstructionsContentWithWorkspaceCLAUDE(t *testing.T) {
 // a CLAUDE.md is silently skipped (no per-repo instructions section appended).
 func TestBuildInstructionsContentMissingCLAUDE(t *testing.T) {
 	dir := t.TempDir() // no CLAUDE.md
-	content := BuildContent([]string{dir})
+	content := BuildContent([]string{dir}, Options{})
 	if !strings.HasPrefix(content, defaultTemplate) {
 		t.Fatal("content should start with the default template")
 	}
@@ -131,7 +131,7 @@ func TestBuildInstructionsContentMultipleWorkspaces(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	content := BuildContent([]string{dirA, dirB, dirC})
+	content := BuildContent([]string{dirA, dirB, dirC}, Options{})
 
 	if !strings.Contains(content, "instructions for A") {
 		t.Error("missing instructions from workspace A")
@@ -164,13 +164,106 @@ func TestBuildInstructionsContentTrailingNewline(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	content := BuildContent([]string{dir})
+	content := BuildContent([]string{dir}, Options{})
 
 	if !strings.HasSuffix(content, "\n") {
 		t.Fatal("content should end with a newline even when CLAUDE.md lacks one")
 	}
 }
 
+// TestBuildInstructionsContentOmitLayout verifies that OmitLayout drops the
+// workspace layout section while keeping the rest of the content.
+func TestBuildInstructionsContentOmitLayout(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "CLAUDE.md"), []byte("repo rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := BuildContent([]string{dir}, Options{OmitLayout: true})
+
+	if strings.Contains(content, "Workspace Layout") {
+		t.Error("content should not contain the workspace layout section")
+	}
+	if !strings.Contains(content, "repo rules") {
+		t.Error("content should still include the workspace CLAUDE.md")
+	}
+}
+
+// TestBuildInstructionsContentOmitRepos verifies that OmitRepos keeps only
+// the wallfacer sections, for repo files mounted separately.
+func TestBuildInstructionsContentOmitRepos(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "CLAUDE.md"), []byte("repo rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := BuildContent([]string{dir}, Options{OmitRepos: true})
+
+	if strings.Contains(content, "repo rules") || strings.Contains(content, "Instructions from") {
+		t.Error("content should not include the workspace CLAUDE.md")
+	}
+	if !strings.Contains(content, "Workspace Layout") {
+		t.Error("content should still contain the workspace layout section")
+	}
+}
+
+// TestBuildInstructionsContentPrepend verifies that OrderPrepend places the
+// repo instructions, with their usual header, before the default template.
+func TestBuildInstructionsContentPrepend(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "CLAUDE.md"), []byte("repo rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := BuildContent([]string{dir}, Options{Order: OrderPrepend})
+
+	header := "## Instructions from `" + filepath.Base(dir) + "`"
+	if !strings.HasPrefix(content, "---\n\n"+header) {
+		t.Fatalf("content should start with the repo header, got:\n%.80s", content)
+	}
+	repoIdx := strings.Index(content, "repo rules")
+	templateIdx := strings.Index(content, "# Workspace Instructions")
+	if repoIdx < 0 || templateIdx < 0 || repoIdx > templateIdx {
+		t.Errorf("repo rules (at %d) should precede the template (at %d)", repoIdx, templateIdx)
+	}
+}
+
+// TestBuildInstructionsContentDeduplicates verifies that byte-identical
+// CLAUDE.md files are included once under a header naming every workspace.
+func TestBuildInstructionsContentDeduplicates(t *testing.T) {
+	root := t.TempDir()
+	dirA := filepath.Join(root, "repo-a")
+	dirB := filepath.Join(root, "repo-b")
+	dirC := filepath.Join(root, "repo-c")
+	for _, d := range []string{dirA, dirB, dirC} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shared := []byte("shared monorepo rules\n")
+	if err := os.WriteFile(filepath.Join(dirA, "CLAUDE.md"), shared, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dirA, "CLAUDE.md"), filepath.Join(dirB, "CLAUDE.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirC, "CLAUDE.md"), []byte("repo c rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := BuildContent([]string{dirA, dirB, dirC}, Options{})
+
+	if n := strings.Count(content, "shared monorepo rules"); n != 1 {
+		t.Errorf("shared content appears %d times, want 1", n)
+	}
+	if !strings.Contains(content, "## Instructions from `repo-a`, `repo-b`\n") {
+		t.Error("expected a combined header for repo-a and repo-b")
+	}
+	if !strings.Contains(content, "## Instructions from `repo-c`\n") {
+		t.Error("expected a separate header for repo-c")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Ensure
 // ---------------------------------------------------------------------------
@@ -181,7 +274,7 @@ func TestEnsureWorkspaceInstructionsCreatesFile(t *testing.T) {
 	configDir := t.TempDir()
 	ws := t.TempDir()
 
-	path, err := Ensure(configDir, []string{ws})
+	path, err := Ensure(configDir, []string{ws}, Options{})
 	if err != nil {
 		t.Fatal("Ensure:", err)
 	}
@@ -202,7 +295,7 @@ func TestEnsureWorkspaceInstructionsIdempotent(t *testing.T) {
 	configDir := t.TempDir()
 	ws := t.TempDir()
 
-	path, err := Ensure(configDir, []string{ws})
+	path, err := Ensure(configDir, []string{ws}, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -213,7 +306,7 @@ func TestEnsureWorkspaceInstructionsIdempotent(t *testing.T) {
 	}
 
 	// Calling again should not overwrite the custom content.
-	path2, err := Ensure(configDir, []string{ws})
+	path2, err := Ensure(configDir, []string{ws}, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -238,7 +331,7 @@ func TestEnsureWorkspaceInstructionsIncludesWorkspaceCLAUDE(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	path, err := Ensure(configDir, []string{ws})
+	path, err := Ensure(configDir, []string{ws}, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -260,7 +353,7 @@ func TestReinitWorkspaceInstructionsOverwrites(t *testing.T) {
 	ws := t.TempDir()
 
 	// First write stale content.
-	path, err := Ensure(configDir, []string{ws})
+	path, err := Ensure(configDir, []string{ws}, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -274,7 +367,7 @@ func TestReinitWorkspaceInstructionsOverwrites(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	path2, err := Reinit(configDir, []string{ws})
+	path2, err := Reinit(configDir, []string{ws}, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -290,3 +383,103 @@ func TestReinitWorkspaceInstructionsOverwrites(t *testing.T) {
 		t.Fatalf("Reinit should include fresh workspace CLAUDE.md; got:\n%s", data)
 	}
 }
+
+// TestReinitAllRebuildsRecordedFiles verifies that ReinitAll rebuilds every
+// instructions file from the workspace set and Options Ensure recorded for it.
+func TestReinitAllRebuildsRecordedFiles(t *testing.T) {
+	configDir := t.TempDir()
+	wsA := t.TempDir()
+	wsB := t.TempDir()
+
+	pathA, err := Ensure(configDir, []string{wsA}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathB, err := Ensure(configDir, []string{wsA, wsB}, Options{OmitLayout: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{pathA, pathB} {
+		if err := os.WriteFile(p, []byte("old template"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(wsB, "CLAUDE.md"), []byte("repo b rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := ReinitAll(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("ReinitAll rebuilt %d files, want 2: %v", len(paths), paths)
+	}
+
+	dataA, _ := os.ReadFile(pathA)
+	dataB, _ := os.ReadFile(pathB)
+	for name, data := range map[string][]byte{"A": dataA, "B": dataB} {
+		if strings.Contains(string(data), "old template") {
+			t.Errorf("file %s was not rebuilt", name)
+		}
+		if !strings.Contains(string(data), "# Workspace Instructions") {
+			t.Errorf("file %s lacks the default template", name)
+		}
+	}
+	if strings.Contains(string(dataA), "repo b rules") {
+		t.Error("file A should only contain its own workspace")
+	}
+	if !strings.Contains(string(dataB), "repo b rules") {
+		t.Error("file B should include repo b's CLAUDE.md")
+	}
+	if !strings.Contains(string(dataA), "## Workspace Layout") {
+		t.Error("file A should keep the default layout section")
+	}
+	if strings.Contains(string(dataB), "## Workspace Layout") {
+		t.Error("file B should be rebuilt with its recorded OmitLayout option")
+	}
+}
+
+// TestEnsureWritesWorkspaceRecord verifies that Ensure records the sorted
+// workspace set in a <key>.json sidecar readable via RecordedWorkspaces.
+func TestEnsureWritesWorkspaceRecord(t *testing.T) {
+	configDir := t.TempDir()
+	ws := []string{"/home/user/zeta", "/home/user/alpha"}
+
+	if _, err := Ensure(configDir, ws, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sidecar := filepath.Join(configDir, "instructions", Key(ws)+".json")
+	raw, err := os.ReadFile(sidecar)
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	if !strings.Contains(string(raw), "/home/user/alpha") || !strings.Contains(string(raw), "/home/user/zeta") {
+		t.Errorf("sidecar should list both workspaces, got:\n%s", raw)
+	}
+
+	got, err := RecordedWorkspaces(configDir, Key(ws))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "/home/user/alpha,/home/user/zeta" {
+		t.Errorf("RecordedWorkspaces = %v, want sorted workspace list", got)
+	}
+}
+
+// TestBuildInstructionsContentOmitBoard verifies that OmitBoard drops the
+// board context section while keeping the general notes.
+func TestBuildInstructionsContentOmitBoard(t *testing.T) {
+	content := BuildContent(nil, Options{OmitBoard: true})
+
+	if strings.Contains(content, "Board Context") || strings.Contains(content, "board.json") {
+		t.Error("content should not describe the board context")
+	}
+	if !strings.HasPrefix(content, defaultTemplate) {
+		t.Error("content should still start with the default template")
+	}
+	if !strings.Contains(BuildContent(nil, Options{}), "## Board Context") {
+		t.Error("default content should include the board context section")
+	}
+}
@@ -0,0 +1,113 @@
+// Package layout resolves where wallfacer keeps its files on the host. All
+// paths derive from a single root directory (~/.wallfacer by default, or
+// $WALLFACER_HOME), so packagers can relocate everything at once:
+//
+//	<root>/
+//	├── .env                  container env file (tokens, model)
+//	├── data/<key>/<uuid>/    task store, one per workspace set (see store)
+//	├── instructions/<key>.md workspace CLAUDE.md files (see instructions)
+//	├── worktrees/<uuid>/     per-task git worktrees
+//	└── instances/<name>/     data/, instructions/ and worktrees/ of a named
+//	                          instance (see ForInstance)
+//
+// <key> is instructions.Key of the workspace set the server was started with.
+package layout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"changkun.de/wallfacer/internal/instructions"
+	"github.com/google/uuid"
+)
+
+// Layout holds the resolved host paths. DataDir and EnvFile may be
+// overridden after New (the -data and -env-file flags do so); the other
+// paths always follow Root.
+type Layout struct {
+	Root            string
+	EnvFile         string
+	DataDir         string
+	InstructionsDir string
+	WorktreesDir    string
+}
+
+// New returns the default layout under root.
+func New(root string) Layout {
+	return Layout{
+		Root:            root,
+		EnvFile:         filepath.Join(root, ".env"),
+		DataDir:         filepath.Join(root, "data"),
+		InstructionsDir: filepath.Join(root, "instructions"),
+		WorktreesDir:    filepath.Join(root, "worktrees"),
+	}
+}
+
+// ForInstance returns the layout of the named instance, so several servers
+// can share one root without sharing task stores, instructions, or
+// worktrees: those move under <root>/instances/<name>/. The env file stays
+// shared. The empty name is the default instance, laid out as by New.
+func (l Layout) ForInstance(name string) Layout {
+	if name == "" {
+		return l
+	}
+	dir := filepath.Join(l.Root, "instances", name)
+	l.DataDir = filepath.Join(dir, "data")
+	l.InstructionsDir = filepath.Join(dir, "instructions")
+	l.WorktreesDir = filepath.Join(dir, "worktrees")
+	return l
+}
+
+// InstructionsBase returns the directory the instructions package takes as
+// its configDir, whose "instructions" subdirectory is InstructionsDir.
+func (l Layout) InstructionsBase() string {
+	return filepath.Dir(l.InstructionsDir)
+}
+
+// StoreDir returns the task store directory for a workspace set.
+func (l Layout) StoreDir(workspaces []string) string {
+	return filepath.Join(l.DataDir, instructions.Key(workspaces))
+}
+
+// MigrateLegacyStore moves task directories left directly under DataDir by
+// releases that did not scope the store per workspace set into
+// StoreDir(workspaces), so the first server started after an upgrade adopts
+// them. Tasks already present in the target are left where they are. It
+// returns the IDs of the moved tasks; with no legacy tasks it does nothing.
+func (l Layout) MigrateLegacyStore(workspaces []string) ([]uuid.UUID, error) {
+	entries, err := os.ReadDir(l.DataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	target := l.StoreDir(workspaces)
+	var moved []uuid.UUID
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		id, err := uuid.Parse(e.Name())
+		if err != nil {
+			continue // workspace-set keys and unrelated directories
+		}
+		src := filepath.Join(l.DataDir, e.Name())
+		if _, err := os.Stat(filepath.Join(src, "task.json")); err != nil {
+			continue
+		}
+		dst := filepath.Join(target, e.Name())
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return moved, err
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return moved, fmt.Errorf("migrate task %s: %w", id, err)
+		}
+		moved = append(moved, id)
+	}
+	return moved, nil
+}
@@ -0,0 +1,99 @@
+package layout
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"changkun.de/wallfacer/internal/store"
+)
+
+// TestNewResolvesFromRoot verifies that every path derives from the root.
+func TestNewResolvesFromRoot(t *testing.T) {
+	l := New("/srv/wallfacer")
+	for got, want := range map[string]string{
+		l.EnvFile:                     "/srv/wallfacer/.env",
+		l.DataDir:                     "/srv/wallfacer/data",
+		l.InstructionsDir:             "/srv/wallfacer/instructions",
+		l.WorktreesDir:                "/srv/wallfacer/worktrees",
+		filepath.Dir(l.StoreDir(nil)): "/srv/wallfacer/data",
+	} {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// TestMigrateLegacyStore verifies that tasks kept directly in the data
+// directory are moved into the workspace set's store, events included, and
+// that the store loads them afterwards.
+func TestMigrateLegacyStore(t *testing.T) {
+	l := New(t.TempDir())
+	workspaces := []string{"/repos/app"}
+
+	// Create a task with the current store, then move it to the legacy
+	// location directly under the data directory.
+	legacy, err := store.NewStore(l.StoreDir(workspaces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	task, _ := legacy.CreateTask(ctx, "legacy task", 5, false)
+	legacy.InsertEvent(ctx, task.ID, store.EventTypeSystem, map[string]string{"result": "kept"})
+	legacy.Close()
+	if err := os.Rename(filepath.Join(l.StoreDir(workspaces), task.ID.String()), filepath.Join(l.DataDir, task.ID.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := l.MigrateLegacyStore(workspaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 || moved[0] != task.ID {
+		t.Fatalf("moved = %v, want [%s]", moved, task.ID)
+	}
+	if _, err := os.Stat(filepath.Join(l.DataDir, task.ID.String())); !os.IsNotExist(err) {
+		t.Error("legacy task directory should be gone")
+	}
+
+	s, err := store.NewStore(l.StoreDir(workspaces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, err := s.GetTask(ctx, task.ID)
+	if err != nil || got.Prompt != "legacy task" {
+		t.Fatalf("migrated task = %v, %v", got, err)
+	}
+	if events, _ := s.GetEvents(ctx, task.ID); len(events) != 1 {
+		t.Errorf("expected the task's event to survive, got %d events", len(events))
+	}
+
+	// A second run finds nothing left to migrate.
+	if moved, err := l.MigrateLegacyStore(workspaces); err != nil || len(moved) != 0 {
+		t.Errorf("second migration = %v, %v; want nothing", moved, err)
+	}
+}
+
+// TestForInstance verifies that a named instance gets its own data,
+// instructions and worktrees while sharing the env file, and that the
+// default instance keeps the plain layout.
+func TestForInstance(t *testing.T) {
+	base := New("/srv/wallfacer")
+	if got := base.ForInstance(""); got != base {
+		t.Errorf("ForInstance(\"\") = %+v, want %+v", got, base)
+	}
+	l := base.ForInstance("ci")
+	for got, want := range map[string]string{
+		l.EnvFile:            "/srv/wallfacer/.env",
+		l.DataDir:            "/srv/wallfacer/instances/ci/data",
+		l.InstructionsDir:    "/srv/wallfacer/instances/ci/instructions",
+		l.WorktreesDir:       "/srv/wallfacer/instances/ci/worktrees",
+		l.InstructionsBase(): "/srv/wallfacer/instances/ci",
+	} {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
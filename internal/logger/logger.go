@@ -8,6 +8,8 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	"changkun.de/wallfacer/internal/util"
 )
 
 // Package-level named loggers, populated by Init.
@@ -207,7 +209,7 @@ func prettyValue(v slog.Value) string {
 	} else {
 		s = fmt.Sprintf("%v", v.Any())
 	}
-	if isUUID(s) {
+	if util.IsUUID(s) {
 		return s[:8]
 	}
 	const maxLen = 200
@@ -220,26 +222,6 @@ func prettyValue(v slog.Value) string {
 	return s
 }
 
-// isUUID reports whether s has the canonical UUID string format (36 chars).
-func isUUID(s string) bool {
-	if len(s) != 36 {
-		return false
-	}
-	for i, r := range s {
-		switch i {
-		case 8, 13, 18, 23:
-			if r != '-' {
-				return false
-			}
-		default:
-			if !((r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')) {
-				return false
-			}
-		}
-	}
-	return true
-}
-
 // needsQuoting reports whether s must be wrapped in quotes for visual clarity.
 func needsQuoting(s string) bool {
 	if s == "" {
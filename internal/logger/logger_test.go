@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"changkun.de/wallfacer/internal/util"
 )
 
 // TestInit verifies that Init populates all named loggers for both formats.
@@ -383,8 +385,8 @@ func TestIsUUID(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		if got := isUUID(tc.s); got != tc.want {
-			t.Errorf("isUUID(%q) = %v, want %v", tc.s, got, tc.want)
+		if got := util.IsUUID(tc.s); got != tc.want {
+			t.Errorf("IsUUID(%q) = %v, want %v", tc.s, got, tc.want)
 		}
 	}
 }
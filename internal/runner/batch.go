@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// CommitBatch runs the commit pipeline for several tasks one after another,
+// ordering them so that every task is merged after the tasks of the batch it
+// depends on (Task.DependsOn). Dependencies outside the batch are ignored;
+// tasks without an ordering constraint keep their order in taskIDs. Each
+// merge takes the repo lock as Commit does, and the next one starts only
+// once it has finished, so a dependent branch is always rebased onto its
+// dependency.
+//
+// Every task must be waiting. Each one is moved to committing with a
+// compare-and-swap, as CompleteTask does, just before its pipeline runs, and
+// settled to done or failed like RunCommit. The batch stops at the first task whose
+// pipeline fails (e.g. an unresolved rebase conflict, which is aborted): the
+// tasks merged before it stay merged and done, it is failed, and the
+// remaining ones are left waiting. The returned error names the failing task.
+func (r *Runner) CommitBatch(taskIDs []uuid.UUID) error {
+	ctx := context.Background()
+	order, err := r.batchOrder(taskIDs)
+	if err != nil {
+		return err
+	}
+	for i, id := range order {
+		task, err := r.store.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := r.store.UpdateTaskStatusIfVersion(ctx, id, "waiting", "committing", task.Version); err != nil {
+			return fmt.Errorf("commit task %s: %w", id, err)
+		}
+		r.store.InsertEvent(ctx, id, store.EventTypeStateChange, map[string]string{
+			"from": "waiting",
+			"to":   "committing",
+		})
+		sessionID := ""
+		if task.SessionID != nil {
+			sessionID = *task.SessionID
+		}
+		err = r.Commit(id, sessionID)
+		r.settleCommit(id, err)
+		if err != nil {
+			logger.Runner.Error("batch commit aborted", "task", id, "merged", i, "remaining", len(order)-i-1, "error", err)
+			return fmt.Errorf("commit task %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// batchOrder returns taskIDs sorted topologically by Task.DependsOn,
+// preferring the given order among tasks that are ready at the same time.
+// It fails on unknown, duplicate, or non-waiting tasks and on dependency
+// cycles.
+func (r *Runner) batchOrder(taskIDs []uuid.UUID) ([]uuid.UUID, error) {
+	inBatch := make(map[uuid.UUID]bool, len(taskIDs))
+	deps := make(map[uuid.UUID][]uuid.UUID, len(taskIDs))
+	for _, id := range taskIDs {
+		if inBatch[id] {
+			return nil, fmt.Errorf("task %s appears twice in the batch", id)
+		}
+		inBatch[id] = true
+	}
+	for _, id := range taskIDs {
+		task, err := r.store.GetTask(context.Background(), id)
+		if err != nil {
+			return nil, err
+		}
+		if task.Status != "waiting" {
+			return nil, fmt.Errorf("task %s is %s, not waiting", id, task.Status)
+		}
+		for _, dep := range task.DependsOn {
+			if inBatch[dep] {
+				deps[id] = append(deps[id], dep)
+			}
+		}
+	}
+
+	order := make([]uuid.UUID, 0, len(taskIDs))
+	placed := make(map[uuid.UUID]bool, len(taskIDs))
+	for len(order) < len(taskIDs) {
+		progressed := false
+		for _, id := range taskIDs {
+			if placed[id] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps[id] {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, id)
+				placed[id] = true
+				progressed = true
+				break // rescan so earlier tasks unblocked by id go first
+			}
+		}
+		if !progressed {
+			return nil, fmt.Errorf("dependency cycle among batch tasks")
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// batchTask creates a waiting task whose worktree adds file.
+func batchTask(t *testing.T, s *store.Store, r *Runner, repo, prompt, file string) *store.Task {
+	t.Helper()
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, prompt, 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := r.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePaths[repo], file), []byte(prompt+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "waiting")
+	return task
+}
+
+// TestCommitBatchMergesDependencyFirst verifies that CommitBatch merges a
+// task before the task depending on it, whatever order they are passed in.
+func TestCommitBatchMergesDependencyFirst(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	a := batchTask(t, s, r, repo, "task A", "a.txt")
+	b := batchTask(t, s, r, repo, "task B", "b.txt")
+	if err := s.UpdateTaskDependsOn(ctx, b.ID, []uuid.UUID{a.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.CommitBatch([]uuid.UUID{b.ID, a.ID}); err != nil {
+		t.Fatalf("CommitBatch: %v", err)
+	}
+
+	gotA, _ := s.GetTask(ctx, a.ID)
+	gotB, _ := s.GetTask(ctx, b.ID)
+	hashA, hashB := gotA.CommitHashes[repo], gotB.CommitHashes[repo]
+	if hashA == "" || hashB == "" {
+		t.Fatalf("both tasks should be merged; hashes A=%q B=%q", hashA, hashB)
+	}
+	if _, err := gitRunMayFail(repo, "merge-base", "--is-ancestor", hashA, hashB); err != nil {
+		t.Fatalf("A's commit %s should precede B's %s:\n%s", hashA, hashB, gitRun(t, repo, "log", "--oneline"))
+	}
+}
+
+// TestCommitBatchRejectsCycle verifies that a dependency cycle aborts the
+// batch before anything is merged.
+func TestCommitBatchRejectsCycle(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	a := batchTask(t, s, r, repo, "task A", "a.txt")
+	b := batchTask(t, s, r, repo, "task B", "b.txt")
+	s.UpdateTaskDependsOn(ctx, a.ID, []uuid.UUID{b.ID})
+	s.UpdateTaskDependsOn(ctx, b.ID, []uuid.UUID{a.ID})
+	head := gitRun(t, repo, "rev-parse", "HEAD")
+
+	if err := r.CommitBatch([]uuid.UUID{a.ID, b.ID}); err == nil {
+		t.Fatal("expected an error for a dependency cycle")
+	}
+	if got := gitRun(t, repo, "rev-parse", "HEAD"); got != head {
+		t.Fatal("nothing should be merged when the batch has a cycle")
+	}
+}
+
+// TestCommitBatchSettlesStatuses verifies that merged tasks end in done, the
+// task the batch stopped at in failed, and the tasks it never reached stay
+// waiting.
+func TestCommitBatchSettlesStatuses(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	a := batchTask(t, s, r, repo, "task A", "a.txt")
+	b := batchTask(t, s, r, repo, "task B", "README.md")
+	c := batchTask(t, s, r, repo, "task C", "c.txt")
+	// B's README.md edit conflicts with main's; the dummy runtime cannot
+	// resolve it.
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("# Main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "commit", "-am", "conflicting change on main")
+
+	if err := r.CommitBatch([]uuid.UUID{a.ID, b.ID, c.ID}); err == nil {
+		t.Fatal("expected the batch to stop at the conflicting task")
+	}
+	for id, want := range map[uuid.UUID]string{a.ID: "done", b.ID: "failed", c.ID: "waiting"} {
+		if got, _ := s.GetTask(ctx, id); got.Status != want {
+			t.Errorf("%s: status %q, want %q", got.Prompt, got.Status, want)
+		}
+	}
+}
+
+// TestCommitBatchRejectsNonWaiting verifies that a batch containing a task
+// that is not waiting is rejected before anything is merged.
+func TestCommitBatchRejectsNonWaiting(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	a := batchTask(t, s, r, repo, "task A", "a.txt")
+	b := batchTask(t, s, r, repo, "task B", "b.txt")
+	moveTask(t, s, b.ID, "in_progress")
+	head := gitRun(t, repo, "rev-parse", "HEAD")
+
+	if err := r.CommitBatch([]uuid.UUID{a.ID, b.ID}); err == nil {
+		t.Fatal("expected an error for a task that is not waiting")
+	}
+	if got := gitRun(t, repo, "rev-parse", "HEAD"); got != head {
+		t.Fatal("nothing should be merged when a task is not waiting")
+	}
+	if got, _ := s.GetTask(ctx, a.ID); got.Status != "waiting" {
+		t.Errorf("task A: status %q, want waiting", got.Status)
+	}
+}
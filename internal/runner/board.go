@@ -1,11 +1,14 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
+	"changkun.de/wallfacer/internal/gitutil"
 	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/store"
 	"github.com/google/uuid"
@@ -22,20 +25,82 @@ type BoardManifest struct {
 // BoardTask is a sanitized view of a single task exposed in board.json.
 // SessionID is deliberately absent to prevent session hijacking.
 type BoardTask struct {
-	ID            string          `json:"id"`
-	ShortID       string          `json:"short_id"`
-	Title         string          `json:"title,omitempty"`
-	Prompt        string          `json:"prompt"`
-	Status        string          `json:"status"`
-	IsSelf        bool            `json:"is_self"`
-	Turns         int             `json:"turns"`
-	Result        *string         `json:"result"`
-	StopReason    *string         `json:"stop_reason"`
-	Usage         store.TaskUsage `json:"usage"`
-	BranchName    string          `json:"branch_name,omitempty"`
-	WorktreeMount *string         `json:"worktree_mount"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
+	ID              string            `json:"id"`
+	ShortID         string            `json:"short_id"`
+	Title           string            `json:"title,omitempty"`
+	Prompt          string            `json:"prompt"`
+	Status          string            `json:"status"`
+	IsSelf          bool              `json:"is_self"`
+	Turns           int               `json:"turns"`
+	FailureCount    int               `json:"failure_count"`
+	Result          *string           `json:"result"`
+	StopReason      *string           `json:"stop_reason"`
+	Usage           store.TaskUsage   `json:"usage"`
+	BranchName      string            `json:"branch_name,omitempty"`
+	BaseCommits     map[string]string `json:"base_commits,omitempty"`
+	CommitHashes    map[string]string `json:"commit_hashes,omitempty"`
+	WorktreeMount   *string           `json:"worktree_mount"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	FinishedAt      *time.Time        `json:"finished_at,omitempty"`
+	DurationSeconds float64           `json:"duration_seconds,omitempty"`
+	PeakMemoryBytes int64             `json:"peak_memory_bytes,omitempty"`
+	CPUSeconds      float64           `json:"cpu_seconds,omitempty"`
+	// InstructionsHash is the SHA-256 of the CLAUDE.md the task's latest
+	// container launch was given, to tell instruction versions apart.
+	InstructionsHash string `json:"instructions_hash,omitempty"`
+	// ReviewRequested is set when the task's agent asked for a person's
+	// review before its changes merge.
+	ReviewRequested bool `json:"review_requested,omitempty"`
+	// CommitsBehind is how many commits the default branch has that the
+	// task's worktree lacks. Only set for the self task, from its first repo.
+	CommitsBehind int `json:"commits_behind,omitempty"`
+}
+
+// boardBehindTTL is how long a computed CommitsBehind is reused before git is
+// asked again, so a task taking many quick turns does not run rev-list on
+// every board refresh.
+const boardBehindTTL = 30 * time.Second
+
+// behindEntry is a cached CommitsBehind value of one worktree.
+type behindEntry struct {
+	n  int
+	at time.Time
+}
+
+// commitsBehind returns how far the worktree of the first (by path) repo in
+// worktreePaths is behind that repo's default branch. Results are cached per
+// worktree for boardBehindTTL; errors count as 0 and are not cached.
+func (r *Runner) commitsBehind(worktreePaths map[string]string) int {
+	if len(worktreePaths) == 0 {
+		return 0
+	}
+	repos := make([]string, 0, len(worktreePaths))
+	for repoPath := range worktreePaths {
+		repos = append(repos, repoPath)
+	}
+	sort.Strings(repos)
+	repoPath := repos[0]
+	wt := worktreePaths[repoPath]
+
+	if v, ok := r.behindCache.Load(wt); ok {
+		if e := v.(behindEntry); time.Since(e.at) < boardBehindTTL {
+			return e.n
+		}
+	}
+	defBranch, err := r.DefaultBranch(context.Background(), repoPath)
+	if err != nil {
+		logger.Runner.Warn("board commits behind", "repo", repoPath, "error", err)
+		return 0
+	}
+	n, err := gitutil.CommitsBehindBranch(context.Background(), wt, defBranch)
+	if err != nil {
+		logger.Runner.Warn("board commits behind", "worktree", wt, "error", err)
+		return 0
+	}
+	r.behindCache.Store(wt, behindEntry{n: n, at: time.Now()})
+	return n
 }
 
 // canMountWorktree reports whether a sibling task's worktrees are eligible
@@ -67,10 +132,11 @@ func (r *Runner) generateBoardContext(selfTaskID uuid.UUID, mountWorktrees bool)
 		return nil, err
 	}
 
+	shortIDs := r.taskShortIDs(tasks)
 	boardTasks := make([]BoardTask, 0, len(tasks))
 	for _, t := range tasks {
 		isSelf := t.ID == selfTaskID
-		shortID := t.ID.String()[:8]
+		shortID := shortIDs[t.ID]
 
 		var worktreeMount *string
 		if mountWorktrees && !isSelf && canMountWorktree(t.Status, t.WorktreePaths) && len(t.WorktreePaths) > 0 {
@@ -84,21 +150,37 @@ func (r *Runner) generateBoardContext(selfTaskID uuid.UUID, mountWorktrees bool)
 			}
 		}
 
+		var behind int
+		if isSelf {
+			behind = r.commitsBehind(t.WorktreePaths)
+		}
+
 		boardTasks = append(boardTasks, BoardTask{
-			ID:            t.ID.String(),
-			ShortID:       shortID,
-			Title:         t.Title,
-			Prompt:        t.Prompt,
-			Status:        t.Status,
-			IsSelf:        isSelf,
-			Turns:         t.Turns,
-			Result:        t.Result,
-			StopReason:    t.StopReason,
-			Usage:         t.Usage,
-			BranchName:    t.BranchName,
-			WorktreeMount: worktreeMount,
-			CreatedAt:     t.CreatedAt,
-			UpdatedAt:     t.UpdatedAt,
+			ID:               t.ID.String(),
+			ShortID:          shortID,
+			Title:            t.Title,
+			Prompt:           t.Prompt,
+			Status:           t.Status,
+			IsSelf:           isSelf,
+			Turns:            t.Turns,
+			FailureCount:     t.FailureCount,
+			Result:           t.Result,
+			StopReason:       t.StopReason,
+			Usage:            t.Usage,
+			BranchName:       t.BranchName,
+			BaseCommits:      t.BaseCommits,
+			CommitHashes:     t.CommitHashes,
+			WorktreeMount:    worktreeMount,
+			CreatedAt:        t.CreatedAt,
+			UpdatedAt:        t.UpdatedAt,
+			StartedAt:        t.StartedAt,
+			FinishedAt:       t.FinishedAt,
+			DurationSeconds:  t.DurationSeconds,
+			PeakMemoryBytes:  t.PeakMemoryBytes,
+			CPUSeconds:       t.CPUSeconds,
+			InstructionsHash: t.InstructionsHash,
+			ReviewRequested:  t.ReviewRequested,
+			CommitsBehind:    behind,
 		})
 	}
 
@@ -142,6 +224,7 @@ func (r *Runner) buildSiblingMounts(selfTaskID uuid.UUID) map[string]map[string]
 		return nil
 	}
 
+	shortIDs := r.taskShortIDs(tasks)
 	mounts := make(map[string]map[string]string)
 	for _, t := range tasks {
 		if t.ID == selfTaskID {
@@ -150,7 +233,7 @@ func (r *Runner) buildSiblingMounts(selfTaskID uuid.UUID) map[string]map[string]
 		if !canMountWorktree(t.Status, t.WorktreePaths) || len(t.WorktreePaths) == 0 {
 			continue
 		}
-		shortID := t.ID.String()[:8]
+		shortID := shortIDs[t.ID]
 		mounts[shortID] = make(map[string]string, len(t.WorktreePaths))
 		for repoPath, wtPath := range t.WorktreePaths {
 			mounts[shortID][repoPath] = wtPath
@@ -3,8 +3,10 @@ package runner
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -28,9 +30,9 @@ func TestGenerateBoardContext_Basic(t *testing.T) {
 	}
 
 	// Put tasks in different statuses.
-	s.UpdateTaskStatus(ctx, t1.ID, "in_progress")
+	moveTask(t, s, t1.ID, "in_progress")
 	s.UpdateTaskResult(ctx, t1.ID, "working", "sess-secret", "max_tokens", 2)
-	s.UpdateTaskStatus(ctx, t2.ID, "done")
+	moveTask(t, s, t2.ID, "done")
 	// t3 stays in backlog.
 
 	data, err := r.generateBoardContext(t2.ID, false)
@@ -177,9 +179,9 @@ func TestBuildSiblingMounts(t *testing.T) {
 	t3, _ := s.CreateTask(ctx, "backlog task", 5, false)
 
 	// Set t2 to waiting with worktree paths.
-	s.UpdateTaskStatus(ctx, t2.ID, "waiting")
+	moveTask(t, s, t2.ID, "waiting")
 	wtDir := t.TempDir()
-	s.UpdateTaskWorktrees(ctx, t2.ID, map[string]string{"/myrepo": wtDir}, "task/"+t2.ID.String()[:8])
+	s.UpdateTaskWorktrees(ctx, t2.ID, map[string]string{"/myrepo": wtDir}, r.taskBranchName(t2.ID))
 
 	// t3 stays in backlog (no worktrees).
 	_ = t3
@@ -222,3 +224,99 @@ func containsString(s, substr string) bool {
 func bg() context.Context {
 	return context.Background()
 }
+
+// TestRunWithBoardDisabled verifies that a runner with DisableBoard runs the
+// task normally without mounting a board context into the container.
+func TestRunWithBoardDisabled(t *testing.T) {
+	repo := setupTestRepo(t)
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args.txt")
+	script := filepath.Join(dir, "fake-cmd")
+	body := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\necho '%s'\n", argsFile, waitingOutput)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, []string{repo}, script)
+	r.disableBoard = true
+	ctx := bg()
+
+	task, _ := s.CreateTask(ctx, "task without board", 5, true)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "task without board", "", false)
+
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "waiting" {
+		t.Fatalf("status = %q, want waiting", got.Status)
+	}
+	recorded, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(recorded), "/workspace/.tasks") {
+		t.Fatalf("container args should not mount the board; got: %s", recorded)
+	}
+}
+
+// TestGenerateBoardContext_CommitsBehind verifies that the self task reports
+// how far its worktree has fallen behind the default branch, and that the
+// value is cached between board refreshes.
+func TestGenerateBoardContext_CommitsBehind(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, "echo")
+	ctx := bg()
+
+	self, _ := s.CreateTask(ctx, "self task", 5, false)
+	other, _ := s.CreateTask(ctx, "other task", 5, false)
+	moveTask(t, s, self.ID, "in_progress")
+	worktreePaths, branchName, err := r.setupWorktrees(self.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTaskWorktrees(ctx, self.ID, worktreePaths, branchName); err != nil {
+		t.Fatal(err)
+	}
+
+	// main advances twice after the worktree was created.
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("upstream%d.txt", i)
+		if err := os.WriteFile(filepath.Join(repo, name), []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitRun(t, repo, "add", name)
+		gitRun(t, repo, "commit", "-m", "upstream "+name)
+	}
+
+	behind := func() map[string]int {
+		data, err := r.generateBoardContext(self.ID, false)
+		if err != nil {
+			t.Fatalf("generateBoardContext: %v", err)
+		}
+		var manifest BoardManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		got := make(map[string]int)
+		for _, bt := range manifest.Tasks {
+			got[bt.ID] = bt.CommitsBehind
+		}
+		return got
+	}
+
+	got := behind()
+	if got[self.ID.String()] != 2 {
+		t.Errorf("self CommitsBehind = %d, want 2", got[self.ID.String()])
+	}
+	if got[other.ID.String()] != 0 {
+		t.Errorf("sibling CommitsBehind = %d, want 0 (only computed for self)", got[other.ID.String()])
+	}
+
+	// A further commit within the TTL is not seen: the cached value is reused.
+	if err := os.WriteFile(filepath.Join(repo, "upstream2.txt"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", "upstream2.txt")
+	gitRun(t, repo, "commit", "-m", "upstream 2")
+	if n := behind()[self.ID.String()]; n != 2 {
+		t.Errorf("cached CommitsBehind = %d, want 2", n)
+	}
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"slices"
+
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// workspaceCancelStatuses are the statuses CancelByWorkspace cancels: the
+// task has not finished yet. A committing task is only cancelled during its
+// pre-merge delay; once merging it is left to finish.
+var workspaceCancelStatuses = map[string]bool{
+	"backlog":     true,
+	"in_progress": true,
+	"waiting":     true,
+	"committing":  true,
+}
+
+// CancelByWorkspace cancels every unfinished task that runs against the
+// workspace ws (a host path, as in Workspaces), e.g. to take the repo
+// offline (see usesWorkspace). Each task is cancelled like a single cancel
+// from the API: its container is killed, its status set and its worktrees
+// removed. Returns the IDs of the tasks cancelled.
+func (r *Runner) CancelByWorkspace(ctx context.Context, ws string) ([]uuid.UUID, error) {
+	tasks, err := r.store.ListTasks(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	var cancelled []uuid.UUID
+	for _, t := range tasks {
+		if !workspaceCancelStatuses[t.Status] || !r.usesWorkspace(t, ws) {
+			continue
+		}
+		if t.Status == "committing" && !r.CancelPreMerge(t.ID) {
+			continue
+		}
+		if t.Status == "in_progress" {
+			r.KillContainer(t.ID)
+			r.CancelPreMerge(t.ID)
+		}
+		// Persist the cancelled status before removing the worktrees, so
+		// Run sees it and does not mark the task failed.
+		if err := r.store.UpdateTaskStatus(ctx, t.ID, "cancelled"); err != nil {
+			logger.Runner.Warn("cancel by workspace", "task", t.ID, "workspace", ws, "error", err)
+			continue
+		}
+		r.store.InsertEvent(ctx, t.ID, store.EventTypeStateChange, map[string]string{
+			"from": t.Status,
+			"to":   "cancelled",
+		})
+		r.CleanupWorktrees(t.ID, t.WorktreePaths, t.BranchName)
+		cancelled = append(cancelled, t.ID)
+	}
+	if len(cancelled) > 0 {
+		logger.Runner.Info("cancelled workspace tasks", "workspace", ws, "count", len(cancelled))
+	}
+	return cancelled, nil
+}
+
+// usesWorkspace reports whether task t runs against workspace ws: through a
+// worktree of it once started, or, for a backlog task that has none yet,
+// because ws is configured and every task starts with worktrees of all
+// configured workspaces.
+func (r *Runner) usesWorkspace(t store.Task, ws string) bool {
+	if t.Status == "backlog" {
+		return slices.Contains(r.Workspaces(), ws)
+	}
+	_, ok := t.WorktreePaths[ws]
+	return ok
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestCancelByWorkspace verifies that every unfinished task with a worktree
+// of the workspace, and every backlog task, which would start with one, is
+// cancelled and its worktrees removed, while tasks on other workspaces and
+// finished tasks are left alone.
+func TestCancelByWorkspace(t *testing.T) {
+	repoA := setupTestRepo(t)
+	repoB := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repoA, repoB}, "true")
+	ctx := context.Background()
+
+	// start creates a task in status with worktrees of the given repos.
+	start := func(status string, repos ...string) (id string, worktrees map[string]string) {
+		t.Helper()
+		task, _ := s.CreateTask(ctx, "task on "+status, 5, false)
+		all, branch, err := r.setupWorktrees(task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		worktrees = make(map[string]string)
+		for _, repo := range repos {
+			worktrees[repo] = all[repo]
+		}
+		if err := s.UpdateTaskWorktrees(ctx, task.ID, worktrees, branch); err != nil {
+			t.Fatal(err)
+		}
+		moveTask(t, s, task.ID, status)
+		return task.ID.String(), worktrees
+	}
+	running, runningWT := start("in_progress", repoA, repoB)
+	waiting, _ := start("waiting", repoA)
+	elsewhere, _ := start("waiting", repoB)
+	finished, _ := start("failed", repoA)
+	queued, _ := s.CreateTask(ctx, "task on backlog", 5, false)
+
+	cancelled, err := r.CancelByWorkspace(ctx, repoA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 3 {
+		t.Fatalf("cancelled %v, want the backlog task and the in_progress and waiting tasks on the workspace", cancelled)
+	}
+
+	want := map[string]string{
+		running: "cancelled", waiting: "cancelled", queued.ID.String(): "cancelled",
+		elsewhere: "waiting", finished: "failed",
+	}
+	tasks, _ := s.ListTasks(ctx, false)
+	for _, task := range tasks {
+		if got := task.Status; got != want[task.ID.String()] {
+			t.Errorf("task %q: status = %q, want %q", task.Prompt, got, want[task.ID.String()])
+		}
+	}
+	for repo, wt := range runningWT {
+		if _, err := os.Stat(wt); !os.IsNotExist(err) {
+			t.Errorf("worktree of %s not removed: %v", repo, err)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package runner
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -12,9 +14,45 @@ import (
 	"changkun.de/wallfacer/internal/gitutil"
 	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/store"
+	"changkun.de/wallfacer/internal/util"
 	"github.com/google/uuid"
 )
 
+// RunCommit runs Commit for a task that was moved to committing and settles
+// it (see settleCommit). It blocks until the pipeline finishes; callers run
+// it in a goroutine, like Run.
+func (r *Runner) RunCommit(taskID uuid.UUID, sessionID string) {
+	r.settleCommit(taskID, r.Commit(taskID, sessionID))
+}
+
+// settleCommit moves a committing task to done when its pipeline returned a
+// nil err and to failed with an error event otherwise (dead once it has
+// failed more than MaxRetries times). A cancelled merge is left alone: the
+// canceller has already moved the task to cancelled.
+func (r *Runner) settleCommit(taskID uuid.UUID, err error) {
+	bgCtx := context.Background()
+	if err != nil {
+		if errors.Is(err, ErrMergeCancelled) {
+			return
+		}
+		r.store.UpdateTaskStatus(bgCtx, taskID, "failed")
+		r.store.InsertEvent(bgCtx, taskID, store.EventTypeError, map[string]string{
+			"error": "commit failed: " + err.Error(),
+		})
+		r.store.InsertEvent(bgCtx, taskID, store.EventTypeStateChange, map[string]string{
+			"from": "committing",
+			"to":   "failed",
+		})
+		r.buryIfExhausted(bgCtx, taskID)
+		return
+	}
+	r.store.UpdateTaskStatus(bgCtx, taskID, "done")
+	r.store.InsertEvent(bgCtx, taskID, store.EventTypeStateChange, map[string]string{
+		"from": "committing",
+		"to":   "done",
+	})
+}
+
 // Commit creates its own timeout context and runs the full commit pipeline
 // (stage → rebase → merge → cleanup) for a task.
 // Returns an error if any phase of the pipeline fails.
@@ -47,28 +85,69 @@ func (r *Runner) commit(
 	bgCtx := context.Background()
 	logger.Runner.Info("auto-commit", "task", taskID, "session", sessionID)
 
+	task, _ := r.store.GetTask(bgCtx, taskID)
+	if task != nil && task.Scratch {
+		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+			"result": "Scratch task: nothing to commit. Download the output from /api/tasks/" + taskID.String() + "/artifact.",
+		})
+		return nil
+	}
+
+	// Read-only workspace mode: marking the task done is what promotes the
+	// writes held in the overlay into the worktree.
+	if r.readOnlyWorkspace {
+		for _, worktreePath := range worktreePaths {
+			if err := promoteOverlay(worktreePath); err != nil {
+				r.store.InsertEvent(bgCtx, taskID, store.EventTypeError, map[string]string{
+					"error": "promote overlay: " + err.Error(),
+				})
+				return fmt.Errorf("promote overlay for %s: %w", worktreePath, err)
+			}
+		}
+	}
+
 	// Phase 1: stage and commit all uncommitted changes on the host.
 	r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 		"result": "Phase 1/3: Staging and committing changes...",
 	})
-	task, _ := r.store.GetTask(bgCtx, taskID)
 	taskPrompt := ""
 	if task != nil {
 		taskPrompt = task.Prompt
 	}
-	if _, stageErr := r.hostStageAndCommit(taskID, worktreePaths, taskPrompt); stageErr != nil {
+	if _, stageErr := r.hostStageAndCommit(ctx, taskID, worktreePaths, taskPrompt); stageErr != nil {
 		logger.Runner.Error("host stage/commit failed", "task", taskID, "error", stageErr)
 		r.store.InsertEvent(bgCtx, taskID, store.EventTypeError, map[string]string{
 			"error": "stage/commit failed: " + stageErr.Error(),
 		})
 		return fmt.Errorf("stage and commit: %w", stageErr)
 	}
+	// Record each repo's own commit right away so per-workspace hashes are
+	// visible even if the merge phase fails. Repos still at their base commit
+	// have nothing of the task's to show and are left out.
+	worktreeHashes := make(map[string]string, len(worktreePaths))
+	for repoPath, worktreePath := range worktreePaths {
+		hash, err := gitutil.GetCommitHash(ctx, worktreePath)
+		if err != nil || (task != nil && task.BaseCommits[repoPath] == hash) {
+			continue
+		}
+		worktreeHashes[repoPath] = hash
+	}
+	if len(worktreeHashes) > 0 {
+		if err := r.store.UpdateTaskCommitHashes(bgCtx, taskID, worktreeHashes); err != nil {
+			logger.Runner.Warn("save worktree commit hashes", "task", taskID, "error", err)
+		}
+	}
+
+	// The last point at which cancelling leaves nothing merged.
+	if err := r.waitPreMerge(ctx, taskID); err != nil {
+		return err
+	}
 
 	// Phase 2: host-side rebase and merge for each git worktree.
 	r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 		"result": "Phase 2/3: Rebasing and merging into default branch...",
 	})
-	commitHashes, baseHashes, mergeErr := r.rebaseAndMerge(ctx, taskID, worktreePaths, branchName, sessionID)
+	mergedHashes, baseHashes, mergeErr := r.rebaseAndMerge(ctx, taskID, worktreePaths, branchName, sessionID)
 	if mergeErr != nil {
 		logger.Runner.Error("rebase/merge failed", "task", taskID, "error", mergeErr)
 		r.store.InsertEvent(bgCtx, taskID, store.EventTypeError, map[string]string{
@@ -77,10 +156,15 @@ func (r *Runner) commit(
 		return fmt.Errorf("rebase/merge: %w", mergeErr)
 	}
 
-	// Phase 3: persist commit hashes and clean up worktrees.
+	// Phase 3: persist commit hashes and clean up worktrees. Merged hashes
+	// replace the Phase 1 ones (rebasing rewrites them); repos that were not
+	// merged keep their worktree commit.
 	r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 		"result": "Phase 3/3: Cleaning up...",
 	})
+	commitHashes := make(map[string]string, len(worktreeHashes))
+	maps.Copy(commitHashes, worktreeHashes)
+	maps.Copy(commitHashes, mergedHashes)
 	if len(commitHashes) > 0 {
 		if err := r.store.UpdateTaskCommitHashes(bgCtx, taskID, commitHashes); err != nil {
 			logger.Runner.Warn("save commit hashes", "task", taskID, "error", err)
@@ -91,7 +175,12 @@ func (r *Runner) commit(
 			logger.Runner.Warn("save base commit hashes", "task", taskID, "error", err)
 		}
 	}
-	r.cleanupWorktrees(taskID, worktreePaths, branchName)
+	if r.keepBranch {
+		// An empty branch name removes the worktrees but keeps the ref.
+		r.cleanupWorktrees(taskID, worktreePaths, "")
+	} else {
+		r.cleanupWorktrees(taskID, worktreePaths, branchName)
+	}
 
 	r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 		"result": "Commit pipeline completed.",
@@ -103,7 +192,13 @@ func (r *Runner) commit(
 // hostStageAndCommit stages and commits all uncommitted changes in each
 // worktree directly on the host. Returns true if any new commits were created.
 // Returns an error if changes were present but could not be staged or committed.
-func (r *Runner) hostStageAndCommit(taskID uuid.UUID, worktreePaths map[string]string, prompt string) (bool, error) {
+// For a task with AllowedPaths, changes outside them fail the commit with
+// ErrOutOfScope, or are reverted when RunnerConfig.RevertOutOfScope is set.
+// Its git commands are bound to ctx, so the pipeline timeout also stops a
+// hung git process or hook.
+func (r *Runner) hostStageAndCommit(ctx context.Context, taskID uuid.UUID, worktreePaths map[string]string, prompt string) (bool, error) {
+	task, _ := r.store.GetTask(context.Background(), taskID)
+
 	// First pass: stage all changes and collect diff stats for each worktree
 	// that has pending changes.
 	type pendingCommit struct {
@@ -116,20 +211,26 @@ func (r *Runner) hostStageAndCommit(taskID uuid.UUID, worktreePaths map[string]s
 	var errs []string
 
 	for repoPath, worktreePath := range worktreePaths {
-		if out, err := exec.Command("git", "-C", worktreePath, "add", "-A").CombinedOutput(); err != nil {
+		if out, err := gitutil.Command(ctx, "-C", worktreePath, "add", "-A").CombinedOutput(); err != nil {
 			logger.Runner.Warn("host commit: git add -A", "repo", repoPath, "error", err, "output", string(out))
 			errs = append(errs, fmt.Sprintf("git add in %s: %v", repoPath, err))
 			continue
 		}
 
-		out, _ := exec.Command("git", "-C", worktreePath, "status", "--porcelain").Output()
+		if task != nil && len(task.AllowedPaths) > 0 {
+			if err := r.enforceAllowedPaths(ctx, taskID, task.AllowedPaths, repoPath, worktreePath, task.BaseCommits[repoPath]); err != nil {
+				return false, err
+			}
+		}
+
+		out, _ := gitutil.Command(ctx, "-C", worktreePath, "status", "--porcelain").Output()
 		if len(strings.TrimSpace(string(out))) == 0 {
 			logger.Runner.Info("host commit: nothing to commit", "repo", repoPath)
 			continue
 		}
 
-		statOut, _ := exec.Command("git", "-C", worktreePath, "diff", "--cached", "--stat").Output()
-		logOut, _ := exec.Command("git", "-C", worktreePath, "log", "--format=%s", "-5").Output()
+		statOut, _ := gitutil.Command(ctx, "-C", worktreePath, "diff", "--cached", "--stat").Output()
+		logOut, _ := gitutil.Command(ctx, "-C", worktreePath, "log", "--format=%s", "-5").Output()
 		pending = append(pending, pendingCommit{repoPath, worktreePath, strings.TrimSpace(string(statOut)), strings.TrimSpace(string(logOut))})
 	}
 
@@ -157,25 +258,13 @@ func (r *Runner) hostStageAndCommit(taskID uuid.UUID, worktreePaths map[string]s
 	msg := r.generateCommitMessage(taskID, prompt, allStats.String(), allLogs.String())
 
 	// Second pass: commit each worktree with the generated message.
-	// Use global git identity to prevent sandbox-set local configs from
-	// overriding the host user's author information.
-	var gitConfigOverrides []string
-	if out, err := exec.Command("git", "config", "--global", "user.name").Output(); err == nil {
-		if n := strings.TrimSpace(string(out)); n != "" {
-			gitConfigOverrides = append(gitConfigOverrides, "-c", "user.name="+n)
-		}
-	}
-	if out, err := exec.Command("git", "config", "--global", "user.email").Output(); err == nil {
-		if e := strings.TrimSpace(string(out)); e != "" {
-			gitConfigOverrides = append(gitConfigOverrides, "-c", "user.email="+e)
-		}
-	}
+	gitConfigOverrides := r.gitIdentityArgs()
 
 	committed := false
 	for _, p := range pending {
 		args := append([]string{"-C", p.worktreePath}, gitConfigOverrides...)
 		args = append(args, "commit", "-m", msg)
-		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		if out, err := gitutil.Command(ctx, args...).CombinedOutput(); err != nil {
 			logger.Runner.Warn("host commit: git commit", "repo", p.repoPath, "error", err, "output", string(out))
 			errs = append(errs, fmt.Sprintf("git commit in %s: %v", p.repoPath, err))
 			continue
@@ -190,6 +279,57 @@ func (r *Runner) hostStageAndCommit(taskID uuid.UUID, worktreePaths map[string]s
 	return committed, nil
 }
 
+// enforceAllowedPaths checks the staged worktree of repoPath against the
+// task's allowed globs, reverting the changes outside them when the runner
+// is configured to, and failing with ErrOutOfScope otherwise.
+func (r *Runner) enforceAllowedPaths(ctx context.Context, taskID uuid.UUID, globs []string, repoPath, worktreePath, base string) error {
+	files, err := outOfScopeFiles(ctx, worktreePath, base, globs)
+	if err != nil {
+		return fmt.Errorf("check allowed paths in %s: %w", repoPath, err)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if !r.revertOutOfScope {
+		return fmt.Errorf("%w in %s: %s", ErrOutOfScope, repoPath, strings.Join(files, ", "))
+	}
+	if err := revertFiles(ctx, worktreePath, base, files); err != nil {
+		return fmt.Errorf("revert out-of-scope changes in %s: %w", repoPath, err)
+	}
+	logger.Runner.Warn("reverted out-of-scope changes", "task", taskID, "repo", repoPath, "files", files)
+	r.store.InsertEvent(context.Background(), taskID, store.EventTypeSystem, map[string]string{
+		"result": fmt.Sprintf("Reverted changes outside the allowed paths in %s: %s", filepath.Base(repoPath), strings.Join(files, ", ")),
+	})
+	return nil
+}
+
+// gitIdentityArgs returns `-c user.name=… -c user.email=…` overrides for
+// wallfacer-initiated commits. The configured author wins; otherwise the
+// host's global git identity is used so that sandbox-set local configs
+// cannot override the host user's author information.
+func (r *Runner) gitIdentityArgs() []string {
+	var args []string
+	name := r.gitAuthorName
+	if name == "" {
+		if out, err := gitutil.Command(context.Background(), "config", "--global", "user.name").Output(); err == nil {
+			name = strings.TrimSpace(string(out))
+		}
+	}
+	if name != "" {
+		args = append(args, "-c", "user.name="+name)
+	}
+	email := r.gitAuthorEmail
+	if email == "" {
+		if out, err := gitutil.Command(context.Background(), "config", "--global", "user.email").Output(); err == nil {
+			email = strings.TrimSpace(string(out))
+		}
+	}
+	if email != "" {
+		args = append(args, "-c", "user.email="+email)
+	}
+	return args
+}
+
 // generateCommitMessage runs a lightweight container to produce a descriptive
 // git commit message from the task prompt, staged diff stats, and recent git
 // log history (used to match the project's commit style).
@@ -199,12 +339,12 @@ func (r *Runner) generateCommitMessage(taskID uuid.UUID, prompt, diffStat, recen
 	if idx := strings.IndexByte(firstLine, '\n'); idx >= 0 {
 		firstLine = firstLine[:idx]
 	}
-	fallback := "wallfacer: " + truncate(firstLine, 72)
+	fallback := "wallfacer: " + util.Truncate(firstLine, 72)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
 	defer cancel()
 
-	containerName := "wallfacer-commit-" + taskID.String()[:8]
+	containerName := r.containerPrefix() + "commit-" + r.ShortID(taskID)
 	exec.Command(r.command, "rm", "-f", containerName).Run()
 
 	args := []string{"run", "--rm", "--network=host", "--name", containerName}
@@ -240,7 +380,7 @@ func (r *Runner) generateCommitMessage(taskID uuid.UUID, prompt, diffStat, recen
 
 	if err := cmd.Run(); err != nil && ctx.Err() == nil {
 		logger.Runner.Warn("commit message generation failed", "task", taskID, "error", err,
-			"stderr", truncate(stderr.String(), 200))
+			"stderr", util.Truncate(stderr.String(), 200))
 		return fallback
 	}
 
@@ -252,7 +392,7 @@ func (r *Runner) generateCommitMessage(taskID uuid.UUID, prompt, diffStat, recen
 
 	output, err := parseOutput(raw)
 	if err != nil {
-		logger.Runner.Warn("commit message generation: parse failure", "task", taskID, "raw", truncate(raw, 200))
+		logger.Runner.Warn("commit message generation: parse failure", "task", taskID, "raw", util.Truncate(raw, 200))
 		return fallback
 	}
 
@@ -309,15 +449,30 @@ func (r *Runner) rebaseAndMergeOne(
 	bgCtx context.Context,
 	commitHashes, baseHashes map[string]string,
 ) error {
-	if !gitutil.IsGitRepo(repoPath) {
+	if !gitutil.IsGitRepo(ctx, repoPath) {
 		// Non-git workspace: copy snapshot changes back to the original directory.
 		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 			"result": fmt.Sprintf("Extracting changes from sandbox to %s...", filepath.Base(repoPath)),
 		})
-		if err := extractSnapshotToWorkspace(worktreePath, repoPath); err != nil {
+		var subpath, base string
+		if task, err := r.store.GetTask(bgCtx, taskID); err == nil {
+			subpath = task.SnapshotSubpath
+			base = task.BaseCommits[repoPath]
+		}
+		if r.secretScan {
+			// The snapshot's own history starts at the commit of the copied
+			// workspace; everything since is the task's.
+			if base == "" {
+				return fmt.Errorf("extract %s blocked: no base commit recorded for the secret scan", repoPath)
+			}
+			if err := checkSecrets(ctx, worktreePath, base, "HEAD"); err != nil {
+				return fmt.Errorf("extract %s blocked: %w", repoPath, err)
+			}
+		}
+		if err := extractSnapshotToWorkspace(worktreePath, repoPath, subpath); err != nil {
 			return fmt.Errorf("extract snapshot for %s: %w", repoPath, err)
 		}
-		if hash, err := gitutil.GetCommitHash(worktreePath); err == nil {
+		if hash, err := gitutil.GetCommitHash(ctx, worktreePath); err == nil {
 			commitHashes[repoPath] = hash
 		}
 		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
@@ -326,20 +481,33 @@ func (r *Runner) rebaseAndMergeOne(
 		return nil
 	}
 
-	defBranch, err := gitutil.DefaultBranch(repoPath)
+	defBranch, err := r.DefaultBranch(ctx, repoPath)
 	if err != nil {
 		return fmt.Errorf("defaultBranch for %s: %w", repoPath, err)
 	}
 
+	// The host repo is checked out on the task branch itself (wallfacer was
+	// started from a task/* branch, or the default is misconfigured). Rebasing
+	// or merging a branch onto itself is meaningless, so leave the commits on
+	// the branch for the user to integrate by hand.
+	if defBranch == branchName {
+		logger.Runner.Warn("default branch is the task branch, skipping rebase and merge",
+			"task", taskID, "repo", repoPath, "branch", branchName)
+		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+			"result": fmt.Sprintf("Skipping merge in %s — its current branch is the task branch %s. Commits remain on that branch.", repoPath, branchName),
+		})
+		return nil
+	}
+
 	// Always capture defBranch HEAD for diff reconstruction, even if there
 	// are no commits to merge. This ensures TaskDiff can show "genuinely no
 	// changes" rather than failing silently when the early return fires.
-	if base, err := gitutil.GetCommitHashForRef(repoPath, defBranch); err == nil {
+	if base, err := gitutil.GetCommitHashForRef(ctx, repoPath, defBranch); err == nil {
 		baseHashes[repoPath] = base
 	}
 
 	// Skip if there are no commits to merge.
-	ahead, err := gitutil.HasCommitsAheadOf(worktreePath, defBranch)
+	ahead, err := gitutil.HasCommitsAheadOf(ctx, worktreePath, defBranch)
 	if err != nil {
 		logger.Runner.Warn("rev-list check", "task", taskID, "repo", repoPath, "error", err)
 	}
@@ -351,48 +519,77 @@ func (r *Runner) rebaseAndMergeOne(
 		return nil
 	}
 
-	// Rebase with conflict-resolution retry loop.
-	var rebaseErr error
-	for attempt := 1; attempt <= maxRebaseRetries; attempt++ {
+	if r.shallowWorktree {
+		// A shallow clone has no shared history to rebase against; bring the
+		// task branch back into the host repo and fast-forward it as-is.
 		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
-			"result": fmt.Sprintf("Rebasing %s onto %s (attempt %d/%d)...", repoPath, defBranch, attempt, maxRebaseRetries),
+			"result": fmt.Sprintf("Fetching %s from shallow clone into %s...", branchName, repoPath),
 		})
-
-		rebaseErr = gitutil.RebaseOntoDefault(repoPath, worktreePath)
-		if rebaseErr == nil {
-			break
+		if err := gitutil.FetchBranch(ctx, repoPath, worktreePath, branchName); err != nil {
+			return fmt.Errorf("fetch shallow branch for %s: %w", repoPath, err)
 		}
-
-		if attempt == maxRebaseRetries {
-			return fmt.Errorf(
-				"rebase failed after %d attempts in %s: %w",
-				maxRebaseRetries, repoPath, rebaseErr,
-			)
-		}
-
-		if !isConflictError(rebaseErr) {
-			return fmt.Errorf("rebase %s: %w", repoPath, rebaseErr)
+	} else {
+		// Rebase with conflict-resolution retry loop.
+		var rebaseErr error
+		for attempt := 1; attempt <= maxRebaseRetries; attempt++ {
+			r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+				"result": fmt.Sprintf("Rebasing %s onto %s (attempt %d/%d)...", repoPath, defBranch, attempt, maxRebaseRetries),
+			})
+
+			rebaseErr = gitutil.RebaseOntoDefault(ctx, repoPath, worktreePath, r.rebaseOptions(defBranch))
+			r.recordConflict(taskID, repoPath, rebaseErr)
+			if rebaseErr == nil {
+				break
+			}
+
+			// Binary conflicts have no markers to resolve, so retrying with
+			// the resolver cannot succeed.
+			var ce *gitutil.ConflictError
+			if errors.As(rebaseErr, &ce) && len(ce.Binary) > 0 {
+				return fmt.Errorf("rebase %s: %w", repoPath, rebaseErr)
+			}
+
+			if attempt == maxRebaseRetries {
+				return fmt.Errorf(
+					"rebase failed after %d attempts in %s: %w",
+					maxRebaseRetries, repoPath, rebaseErr,
+				)
+			}
+
+			if !isConflictError(rebaseErr) {
+				return fmt.Errorf("rebase %s: %w", repoPath, rebaseErr)
+			}
+
+			logger.Runner.Warn("rebase conflict, invoking resolver",
+				"task", taskID, "repo", repoPath, "attempt", attempt)
+			r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+				"result": fmt.Sprintf("Conflict in %s — running resolver (attempt %d)...", repoPath, attempt),
+			})
+
+			if resolveErr := r.resolveConflicts(ctx, taskID, repoPath, worktreePath, sessionID); resolveErr != nil {
+				return fmt.Errorf("conflict resolution failed: %w", resolveErr)
+			}
 		}
+	}
 
-		logger.Runner.Warn("rebase conflict, invoking resolver",
-			"task", taskID, "repo", repoPath, "attempt", attempt)
-		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
-			"result": fmt.Sprintf("Conflict in %s — running resolver (attempt %d)...", repoPath, attempt),
-		})
-
-		if resolveErr := r.resolveConflicts(ctx, taskID, repoPath, worktreePath, sessionID); resolveErr != nil {
-			return fmt.Errorf("conflict resolution failed: %w", resolveErr)
+	if r.secretScan {
+		if err := checkSecrets(ctx, repoPath, defBranch, branchName); err != nil {
+			return fmt.Errorf("merge %s blocked: %w", repoPath, err)
 		}
 	}
 
 	r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 		"result": fmt.Sprintf("Fast-forward merging %s into %s...", branchName, defBranch),
 	})
-	if err := gitutil.FFMerge(repoPath, branchName); err != nil {
+	if err := gitutil.FFMergeInto(ctx, repoPath, defBranch, branchName); err != nil {
+		if r.shallowWorktree {
+			return fmt.Errorf("ff-merge %s (shallow worktrees cannot be rebased; %s moved since the task started): %w",
+				repoPath, defBranch, err)
+		}
 		return fmt.Errorf("ff-merge %s: %w", repoPath, err)
 	}
 
-	hash, err := gitutil.GetCommitHash(repoPath)
+	hash, err := gitutil.GetCommitHashForRef(ctx, repoPath, defBranch)
 	if err != nil {
 		logger.Runner.Warn("get commit hash", "task", taskID, "repo", repoPath, "error", err)
 	} else {
@@ -400,11 +597,45 @@ func (r *Runner) rebaseAndMergeOne(
 		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 			"result": fmt.Sprintf("Merged %s — commit %s", repoPath, hash[:8]),
 		})
+		if r.tagTasks {
+			tag := taskTagName(branchName)
+			if err := gitutil.CreateTag(ctx, repoPath, tag, hash); err != nil {
+				logger.Runner.Warn("tag merged commit", "task", taskID, "repo", repoPath, "error", err)
+			}
+		}
 	}
 
 	return nil
 }
 
+// rebaseOptions returns the configured extra rebase flags and rerere setting
+// for a rebase onto defBranch, resolved once by the caller.
+func (r *Runner) rebaseOptions(defBranch string) gitutil.RebaseOptions {
+	return gitutil.RebaseOptions{Args: r.rebaseArgs, Rerere: r.rerere, DefaultBranch: defBranch}
+}
+
+// taskTagName returns the lightweight tag name used for the merge commit of
+// the task on branchName: "wallfacer/" followed by the branch name without
+// its "task/" component. The tag thus carries the same unique short ID as
+// the branch, and a named instance's "<instance>/" prefix.
+func taskTagName(branchName string) string {
+	return "wallfacer/" + strings.Replace(branchName, "task/", "", 1)
+}
+
+// recordConflict stores the files listed by a *gitutil.ConflictError on the
+// task so the UI can show which paths conflicted. Any other err (including
+// nil, i.e. a successful rebase) clears the record for repoPath.
+func (r *Runner) recordConflict(taskID uuid.UUID, repoPath string, err error) {
+	var files []string
+	var ce *gitutil.ConflictError
+	if errors.As(err, &ce) {
+		files = ce.Files
+	}
+	if storeErr := r.store.SetTaskConflictFiles(context.Background(), taskID, repoPath, files); storeErr != nil {
+		logger.Runner.Warn("save conflict files", "task", taskID, "repo", repoPath, "error", storeErr)
+	}
+}
+
 // isConflictError reports whether err wraps ErrConflict.
 func isConflictError(err error) bool {
 	return err != nil && strings.Contains(err.Error(), gitutil.ErrConflict.Error())
@@ -432,10 +663,14 @@ func (r *Runner) resolveConflicts(
 		containerPath,
 	)
 
-	// Mount only the conflicted worktree for this targeted fix.
+	// Mount only the conflicted worktree for this targeted fix. The
+	// resolver edits the worktree directly, even in read-only workspace
+	// mode: by now the task's writes have been promoted.
 	override := map[string]string{repoPath: worktreePath}
+	rw := *r
+	rw.readOnlyWorkspace = false
 
-	output, rawStdout, rawStderr, err := r.runContainer(ctx, taskID, prompt, sessionID, override, "", nil)
+	output, rawStdout, rawStderr, err := rw.runContainer(ctx, taskID, prompt, sessionID, override, "", nil)
 
 	task, _ := r.store.GetTask(context.Background(), taskID)
 	turns := 0
@@ -448,11 +683,11 @@ func (r *Runner) resolveConflicts(
 		return fmt.Errorf("conflict resolver container: %w", err)
 	}
 	if output.IsError {
-		return fmt.Errorf("conflict resolver reported error: %s", truncate(output.Result, 300))
+		return fmt.Errorf("conflict resolver reported error: %s", util.Truncate(output.Result, 300))
 	}
 
 	r.store.InsertEvent(context.Background(), taskID, store.EventTypeSystem, map[string]string{
-		"result": "Conflict resolver: " + truncate(output.Result, 500),
+		"result": "Conflict resolver: " + util.Truncate(output.Result, 500),
 	})
 	return nil
 }
@@ -1,11 +1,15 @@
 package runner
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"changkun.de/wallfacer/internal/store"
 	"github.com/google/uuid"
@@ -136,8 +140,8 @@ func TestGenerateCommitMessageFallbackTruncatesLongPrompt(t *testing.T) {
 
 	msg := runner.generateCommitMessage(uuid.New(), longPrompt, "", "")
 
-	// "wallfacer: " (11 chars) + truncate(prompt, 72) → max 86 chars total
-	// because truncate appends "..." (3 chars) when the string is cut.
+	// "wallfacer: " (11 chars) + util.Truncate(prompt, 72) → max 86 chars total
+	// because Truncate appends "..." (3 chars) when the string is cut.
 	if len(msg) > 86 {
 		t.Fatalf("fallback message too long (%d chars): %q", len(msg), msg)
 	}
@@ -222,7 +226,7 @@ func TestHostStageAndCommitUsesGeneratedMessage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	committed, err := runner.hostStageAndCommit(taskID, worktreePaths, "Add authentication")
+	committed, err := runner.hostStageAndCommit(context.Background(), taskID, worktreePaths, "Add authentication")
 	if err != nil {
 		t.Fatalf("hostStageAndCommit error: %v", err)
 	}
@@ -274,7 +278,7 @@ func TestHostStageAndCommitFallsBackOnContainerFailure(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	committed, err := runner.hostStageAndCommit(taskID, worktreePaths, "Add new feature")
+	committed, err := runner.hostStageAndCommit(context.Background(), taskID, worktreePaths, "Add new feature")
 	if err != nil {
 		t.Fatalf("hostStageAndCommit error: %v", err)
 	}
@@ -290,3 +294,351 @@ func TestHostStageAndCommitFallsBackOnContainerFailure(t *testing.T) {
 		t.Fatalf("fallback commit message should contain prompt, got: %q", subject)
 	}
 }
+
+// TestHostStageAndCommitHonoursContext verifies that a git hook that hangs
+// is killed when the pipeline context ends instead of blocking the commit.
+func TestHostStageAndCommitHonoursContext(t *testing.T) {
+	repo := setupTestRepo(t)
+	hook := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, runner := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, "", 1))
+
+	taskID := uuid.New()
+	worktreePaths, branchName, err := runner.setupWorktrees(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(taskID, worktreePaths, branchName) })
+	if err := os.WriteFile(filepath.Join(worktreePaths[repo], "slow.txt"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	committed, err := runner.hostStageAndCommit(ctx, taskID, worktreePaths, "slow hook")
+	if err == nil || committed {
+		t.Fatalf("hostStageAndCommit = %v, %v; want an error from the killed commit", committed, err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("hostStageAndCommit took %s; the hook should have been killed", elapsed)
+	}
+}
+
+// TestHostStageAndCommitUsesConfiguredAuthor verifies that GitAuthorName and
+// GitAuthorEmail override the repo's own identity on host-side commits.
+func TestHostStageAndCommitUsesConfiguredAuthor(t *testing.T) {
+	repo := setupTestRepo(t)
+	_, runner := setupTestRunner(t, []string{repo})
+	runner.gitAuthorName = "Wallfacer Bot"
+	runner.gitAuthorEmail = "bot@wallfacer.test"
+
+	taskID := uuid.New()
+	worktreePaths, branchName, err := runner.setupWorktrees(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(taskID, worktreePaths, branchName) })
+
+	wt := worktreePaths[repo]
+	if err := os.WriteFile(filepath.Join(wt, "bot.txt"), []byte("bot\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.hostStageAndCommit(context.Background(), taskID, worktreePaths, "Add bot file"); err != nil {
+		t.Fatalf("hostStageAndCommit error: %v", err)
+	}
+
+	author := gitRun(t, wt, "log", "--format=%an <%ae>", "-1")
+	if author != "Wallfacer Bot <bot@wallfacer.test>" {
+		t.Fatalf("unexpected author: %q", author)
+	}
+	committer := gitRun(t, wt, "log", "--format=%cn <%ce>", "-1")
+	if committer != "Wallfacer Bot <bot@wallfacer.test>" {
+		t.Fatalf("unexpected committer: %q", committer)
+	}
+}
+
+// TestRebaseAndMergeSkipsWhenDefaultIsTaskBranch verifies that the pipeline
+// does not try to merge the task branch into itself when the host repo is
+// checked out on it, and records why the merge was skipped.
+func TestRebaseAndMergeSkipsWhenDefaultIsTaskBranch(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	task, err := s.CreateTask(ctx, "self merge", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+
+	wt := worktreePaths[repo]
+	if err := os.WriteFile(filepath.Join(wt, "task.txt"), []byte("task\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, wt, "add", ".")
+	gitRun(t, wt, "commit", "-m", "task change")
+	mainHead := gitRun(t, repo, "rev-parse", "main")
+
+	// Point the host repo's HEAD at the task branch, bypassing git's
+	// checked-out-elsewhere guard the way a misconfigured setup would.
+	gitRun(t, repo, "symbolic-ref", "HEAD", "refs/heads/"+branchName)
+
+	if _, _, err := runner.rebaseAndMerge(ctx, task.ID, worktreePaths, branchName, ""); err != nil {
+		t.Fatalf("rebaseAndMerge: %v", err)
+	}
+	if got := gitRun(t, repo, "rev-parse", "main"); got != mainHead {
+		t.Errorf("main moved from %s to %s", mainHead, got)
+	}
+
+	events, _ := s.GetEvents(ctx, task.ID)
+	found := false
+	for _, e := range events {
+		if e.EventType == store.EventTypeSystem && strings.Contains(string(e.Data), "current branch is the task branch") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected a system event explaining the skipped merge")
+	}
+}
+
+// TestRebaseAndMergeFailsFastOnBinaryConflict verifies that a conflict in a
+// binary file fails the merge on the first attempt with a clear error
+// instead of running the text-based conflict resolver.
+func TestRebaseAndMergeFailsFastOnBinaryConflict(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(repo, "logo.png"), []byte("base\x00png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "add logo")
+
+	task, err := s.CreateTask(ctx, "binary conflict", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+
+	wt := worktreePaths[repo]
+	if err := os.WriteFile(filepath.Join(wt, "logo.png"), []byte("task\x00png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, wt, "commit", "-am", "task logo")
+	if err := os.WriteFile(filepath.Join(repo, "logo.png"), []byte("main\x00png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "commit", "-am", "main logo")
+
+	_, _, err = runner.rebaseAndMerge(ctx, task.ID, worktreePaths, branchName, "")
+	if err == nil || !strings.Contains(err.Error(), "binary conflict in logo.png") {
+		t.Fatalf("expected binary conflict error, got %v", err)
+	}
+	events, _ := s.GetEvents(ctx, task.ID)
+	for _, e := range events {
+		if strings.Contains(string(e.Data), "running resolver") {
+			t.Fatal("resolver should not run for a binary conflict")
+		}
+	}
+}
+
+// scopedTaskWorktree creates a task restricted to allowed and a worktree
+// for it in which README.md and main.go were changed.
+func scopedTaskWorktree(t *testing.T, allowed ...string) (*store.Store, *Runner, uuid.UUID, string) {
+	t.Helper()
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	task, _ := s.CreateTask(context.Background(), "fix the README", 5, false)
+	if err := s.UpdateTaskAllowedPaths(context.Background(), task.ID, allowed); err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := r.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+	wt := worktreePaths[repo]
+	if err := os.WriteFile(filepath.Join(wt, "README.md"), []byte("# Fixed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wt, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return s, r, task.ID, wt
+}
+
+// TestHostStageAndCommitRejectsOutOfScopeChanges verifies that a change
+// outside the task's allowed paths fails the commit with ErrOutOfScope
+// naming the file.
+func TestHostStageAndCommitRejectsOutOfScopeChanges(t *testing.T) {
+	_, r, taskID, wt := scopedTaskWorktree(t, "*.md")
+	head := gitRun(t, wt, "rev-parse", "HEAD")
+
+	_, err := r.hostStageAndCommit(context.Background(), taskID, map[string]string{filepath.Dir(wt): wt}, "fix the README")
+	if !errors.Is(err, ErrOutOfScope) {
+		t.Fatalf("expected ErrOutOfScope, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "main.go") || strings.Contains(err.Error(), "README.md") {
+		t.Errorf("error should name only main.go: %v", err)
+	}
+	if gitRun(t, wt, "rev-parse", "HEAD") != head {
+		t.Error("nothing should be committed")
+	}
+}
+
+// TestHostStageAndCommitRevertsOutOfScopeChanges verifies that with
+// RevertOutOfScope the disallowed change is dropped and the rest committed.
+func TestHostStageAndCommitRevertsOutOfScopeChanges(t *testing.T) {
+	_, r, taskID, wt := scopedTaskWorktree(t, "README.md")
+	r.revertOutOfScope = true
+
+	committed, err := r.hostStageAndCommit(context.Background(), taskID, map[string]string{filepath.Dir(wt): wt}, "fix the README")
+	if err != nil || !committed {
+		t.Fatalf("hostStageAndCommit = %v, %v; want a commit", committed, err)
+	}
+	if files := gitRun(t, wt, "show", "--name-only", "--format=", "HEAD"); files != "README.md" {
+		t.Errorf("commit should contain only README.md, got %q", files)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "main.go")); !os.IsNotExist(err) {
+		t.Error("main.go should have been removed from the worktree")
+	}
+}
+
+// TestPathAllowed covers the glob forms accepted in Task.AllowedPaths.
+func TestPathAllowed(t *testing.T) {
+	globs := []string{"README.md", "docs/**", "cmd/*.go"}
+	for file, want := range map[string]bool{
+		"README.md":       true,
+		"docs/a/b.md":     true,
+		"cmd/main.go":     true,
+		"cmd/sub/main.go": false,
+		"internal/x.go":   false,
+		"docs":            false,
+		"sub/README.md":   false,
+	} {
+		if got := pathAllowed(globs, file); got != want {
+			t.Errorf("pathAllowed(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+// TestCommitResolvesDefaultBranchOnce verifies, by counting invocations of a
+// logging git wrapper, that the commit pipeline looks up a repo's default
+// branch once and reuses it for the rebase and the fast-forward merge.
+func TestCommitResolvesDefaultBranchOnce(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "add a file", 5, false)
+	worktreePaths, branchName, err := r.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePaths[repo], "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "committing")
+
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	logFile := filepath.Join(bin, "calls.log")
+	wrapper := fmt.Sprintf("#!/bin/sh\necho \"$*\" >> %s\nexec %s \"$@\"\n", logFile, realGit)
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(wrapper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := r.commit(ctx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	calls, _ := os.ReadFile(logFile)
+	if n := strings.Count(string(calls), "branch --show-current"); n != 1 {
+		t.Fatalf("default branch looked up %d times, want 1:\n%s", n, calls)
+	}
+}
+
+// TestCommitMergesIntoConfiguredBranches verifies that with per-repo
+// DefaultBranches each repo's task branch starts from and merges into its
+// own configured branch, regardless of what is checked out.
+func TestCommitMergesIntoConfiguredBranches(t *testing.T) {
+	repoA := setupTestRepo(t)
+	repoB := setupTestRepo(t)
+	gitRun(t, repoB, "checkout", "-q", "-b", "develop")
+	if err := os.WriteFile(filepath.Join(repoB, "DEVELOP.md"), []byte("develop only\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repoB, "add", ".")
+	gitRun(t, repoB, "commit", "-q", "-m", "develop commit")
+	gitRun(t, repoB, "checkout", "-q", "main")
+
+	s, r := setupTestRunner(t, []string{repoA, repoB})
+	r.defaultBranches = map[string]string{
+		filepath.Base(repoA): "main",
+		repoB:                "develop",
+	}
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "add a file", 5, false)
+	worktreePaths, branchName, err := r.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(worktreePaths[repoB], "DEVELOP.md")); err != nil {
+		t.Fatalf("repo B worktree should start from develop: %v", err)
+	}
+	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
+		t.Fatal(err)
+	}
+	for _, wt := range worktreePaths {
+		if err := os.WriteFile(filepath.Join(wt, "task.txt"), []byte("task\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moveTask(t, s, task.ID, "committing")
+	// An uncommitted edit in repo B's checkout (main) must survive the merge
+	// into develop.
+	if err := os.WriteFile(filepath.Join(repoB, "README.md"), []byte("uncommitted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.commit(ctx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	if got := gitRun(t, repoB, "branch", "--show-current"); got != "main" {
+		t.Errorf("repo B: checked-out branch = %q, want main", got)
+	}
+	if got := gitRun(t, repoB, "status", "--porcelain"); got != "M README.md" {
+		t.Errorf("repo B: uncommitted change lost; status:\n%s", got)
+	}
+	got, _ := s.GetTask(ctx, task.ID)
+	if want := gitRun(t, repoB, "rev-parse", "develop"); got.CommitHashes[repoB] != want {
+		t.Errorf("repo B: recorded commit %s, want develop tip %s", got.CommitHashes[repoB], want)
+	}
+	if _, err := gitRunMayFail(repoA, "cat-file", "-e", "main:task.txt"); err != nil {
+		t.Error("repo A: task.txt not merged into main")
+	}
+	if _, err := gitRunMayFail(repoB, "cat-file", "-e", "develop:task.txt"); err != nil {
+		t.Error("repo B: task.txt not merged into develop")
+	}
+	if _, err := gitRunMayFail(repoB, "cat-file", "-e", "main:task.txt"); err == nil {
+		t.Error("repo B: task.txt merged into main, want develop only")
+	}
+}
@@ -3,18 +3,33 @@ package runner
 import (
 	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"changkun.de/wallfacer/internal/envconfig"
+	"changkun.de/wallfacer/internal/gitutil"
 	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"changkun.de/wallfacer/internal/util"
 	"github.com/google/uuid"
 )
 
+// defaultSeccompProfile is applied in hardened mode when no SeccompProfile is
+// configured. Like the runtime's default profile it denies every syscall it
+// does not list; the allowlist is the runtime default's minus io_uring,
+// tracing, and anything gated on a capability the hardened container drops.
+//
+//go:embed seccomp.json
+var defaultSeccompProfile []byte
+
 // claudeUsage mirrors the token-usage object in Claude Code's JSON output.
 type claudeUsage struct {
 	InputTokens              int `json:"input_tokens"`
@@ -43,24 +58,46 @@ type claudeOutput struct {
 // will be mounted read-only at /workspace/.tasks/ inside the container.
 // siblingMounts maps shortID → (repoPath → worktreePath) for read-only
 // sibling worktree mounts under /workspace/.tasks/worktrees/.
+// env holds per-task variables passed as -e KEY=VALUE after --env-file so
+// they take precedence over the shared env file. instructionsPath is the
+// CLAUDE.md to mount: the shared workspace file, or a task-specific copy
+// carrying the task's extra instructions. inputsDir, when non-empty, holds
+// the files attached to the task and is mounted read-only at
+// /workspace/.tasks/inputs/.
 func (r *Runner) buildContainerArgs(
 	containerName, prompt, sessionID string,
 	worktreeOverrides map[string]string,
 	boardDir string,
 	siblingMounts map[string]map[string]string,
+	env map[string]string,
+	scratchDir string,
+	instructionsPath string,
+	inputsDir string,
 ) []string {
 	args := []string{"run", "--rm", "--network=host", "--name", containerName}
 
 	if r.envFile != "" {
 		args = append(args, "--env-file", r.envFile)
 	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-e", k+"="+env[k])
+	}
 
 	// Mount claude config volume.
 	args = append(args, "-v", "claude-config:/home/claude/.claude")
 
 	// Mount workspaces, substituting per-task worktree paths where available.
+	// Scratch tasks see only their empty scratch directory.
 	var basenames []string
-	if r.workspaces != "" {
+	if scratchDir != "" {
+		basenames = append(basenames, "scratch")
+		args = append(args, "-v", mountPath(scratchDir)+":/workspace/scratch:z")
+	} else if r.workspaces != "" {
 		for _, ws := range strings.Fields(r.workspaces) {
 			ws = strings.TrimSpace(ws)
 			if ws == "" {
@@ -70,22 +107,31 @@ func (r *Runner) buildContainerArgs(
 			if wt, ok := worktreeOverrides[ws]; ok {
 				hostPath = wt
 			}
-			parts := strings.Split(ws, "/")
-			basename := parts[len(parts)-1]
-			if basename == "" && len(parts) > 1 {
-				basename = parts[len(parts)-2]
-			}
+			basename := filepath.Base(ws)
 			basenames = append(basenames, basename)
-			args = append(args, "-v", hostPath+":/workspace/"+basename+":z")
+			_, isWorktree := worktreeOverrides[ws]
+			overlay := isWorktree && r.readOnlyWorkspace
+			if overlay {
+				args = append(args, "-v", overlayVolume(hostPath, "/workspace/"+basename, overlayDir(hostPath)))
+			} else {
+				args = append(args, "-v", mountPath(hostPath)+":/workspace/"+basename+":z")
+			}
 
 			// Git worktrees have a .git file (not directory) that references
 			// the main repo's .git/worktrees/<name>/ using an absolute host
 			// path. Mount the main repo's .git directory at the same host
 			// path inside the container so git operations work correctly.
-			if _, isWorktree := worktreeOverrides[ws]; isWorktree {
+			// Shallow clones carry their own .git directory and must not
+			// expose the host repository's history.
+			// In read-only workspace mode it gets an overlay of its own.
+			if isWorktree && !r.shallowWorktree {
 				gitDir := filepath.Join(ws, ".git")
 				if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
-					args = append(args, "-v", gitDir+":"+gitDir+":z")
+					if overlay {
+						args = append(args, "-v", overlayVolume(gitDir, mountPath(gitDir), filepath.Join(overlayDir(hostPath), "git")))
+					} else {
+						args = append(args, "-v", mountPath(gitDir)+":"+mountPath(gitDir)+":z")
+					}
 				}
 			}
 		}
@@ -96,20 +142,28 @@ func (r *Runner) buildContainerArgs(
 	// and at ~/.claude/, but NOT in parent directories above the project root.
 	// For single-workspace tasks, CWD is /workspace/<basename> which IS the
 	// project root, so /workspace/CLAUDE.md (the parent) would be invisible.
-	// Mount directly into the workspace root instead.
-	if r.instructionsPath != "" {
-		if _, err := os.Stat(r.instructionsPath); err == nil {
-			if len(basenames) == 1 {
-				args = append(args, "-v", r.instructionsPath+":/workspace/"+basenames[0]+"/CLAUDE.md:z,ro")
-			} else {
-				args = append(args, "-v", r.instructionsPath+":/workspace/CLAUDE.md:z,ro")
+	// Mount directly into the workspace root instead, unless the operator
+	// chose another target with InstructionsMountPath. Scratch tasks skip it:
+	// the instructions describe the workspaces, and the bind-mount target
+	// would otherwise leave a CLAUDE.md in the scratch output.
+	if instructionsPath != "" && scratchDir == "" {
+		if _, err := os.Stat(instructionsPath); err == nil {
+			target := "/workspace/CLAUDE.md"
+			if r.instructionsMount != "" {
+				target = r.instructionsMount
+			} else if len(basenames) == 1 && !r.separateInstructions {
+				target = "/workspace/" + basenames[0] + "/CLAUDE.md"
 			}
+			args = append(args, "-v", mountPath(instructionsPath)+":"+target+":z,ro")
+		}
+		if r.separateInstructions {
+			args = append(args, r.repoInstructionMounts(worktreeOverrides)...)
 		}
 	}
 
 	// Board context: mount board.json read-only at /workspace/.tasks/.
 	if boardDir != "" {
-		args = append(args, "-v", boardDir+":/workspace/.tasks:z,ro")
+		args = append(args, "-v", mountPath(boardDir)+":/workspace/.tasks:z,ro")
 	}
 
 	// Sibling worktrees: mount each eligible sibling's worktrees read-only.
@@ -117,10 +171,15 @@ func (r *Runner) buildContainerArgs(
 		for repoPath, wtPath := range repos {
 			basename := filepath.Base(repoPath)
 			containerPath := "/workspace/.tasks/worktrees/" + shortID + "/" + basename
-			args = append(args, "-v", wtPath+":"+containerPath+":z,ro")
+			args = append(args, "-v", mountPath(wtPath)+":"+containerPath+":z,ro")
 		}
 	}
 
+	// Attached input files: mounted whether or not the board is.
+	if inputsDir != "" {
+		args = append(args, "-v", mountPath(inputsDir)+":/workspace/.tasks/inputs:z,ro")
+	}
+
 	// When there is exactly one workspace, set CWD directly into it so
 	// Claude operates in the repo directory by default. For multiple
 	// workspaces keep CWD at /workspace so all repos are accessible.
@@ -128,6 +187,18 @@ func (r *Runner) buildContainerArgs(
 	if len(basenames) == 1 {
 		workdir = "/workspace/" + basenames[0]
 	}
+	// Hardened mode: Claude Code only needs the filesystem and the network,
+	// so drop every capability and forbid privilege escalation.
+	if r.hardenedSandbox {
+		args = append(args, "--cap-drop=ALL", "--security-opt=no-new-privileges")
+		if profile := r.seccompProfilePath(); profile != "" {
+			args = append(args, "--security-opt=seccomp="+profile)
+		}
+	}
+
+	// Operator-supplied runtime flags go last so they can tune or override
+	// anything above; everything after the image is passed to Claude Code.
+	args = append(args, r.extraRunArgs...)
 	args = append(args, "-w", workdir, r.sandboxImage)
 	args = append(args, "-p", prompt, "--verbose", "--output-format", "stream-json")
 	if model := r.modelFromEnv(); model != "" {
@@ -136,10 +207,55 @@ func (r *Runner) buildContainerArgs(
 	if sessionID != "" {
 		args = append(args, "--resume", sessionID)
 	}
+	args = append(args, r.agentArgs...)
 
 	return args
 }
 
+// repoInstructionMounts returns the -v flags mounting each workspace's own
+// CLAUDE.md read-only at /workspace/<name>/CLAUDE.md. A workspace is skipped
+// unless the directory mounted there (its task worktree, if any) already has
+// a CLAUDE.md: otherwise the runtime would create an empty one as the mount
+// point, which the commit pipeline would then pick up.
+func (r *Runner) repoInstructionMounts(worktreeOverrides map[string]string) []string {
+	var args []string
+	for _, ws := range strings.Fields(r.workspaces) {
+		src := filepath.Join(ws, "CLAUDE.md")
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		hostPath := ws
+		if wt, ok := worktreeOverrides[ws]; ok {
+			hostPath = wt
+		}
+		if _, err := os.Stat(filepath.Join(hostPath, "CLAUDE.md")); err != nil {
+			continue
+		}
+		args = append(args, "-v", mountPath(src)+":/workspace/"+filepath.Base(ws)+"/CLAUDE.md:z,ro")
+	}
+	return args
+}
+
+// redactEnvArgs returns a copy of args with the value of every -e KEY=VALUE
+// pair masked, so per-task secrets do not end up in logs, and with the
+// credentials util.RedactCredentials finds masked in the rest.
+func redactEnvArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = util.RedactCredentials(a)
+	}
+	for i := 0; i+1 < len(out); i++ {
+		if out[i] != "-e" {
+			continue
+		}
+		if k, _, ok := strings.Cut(out[i+1], "="); ok {
+			out[i+1] = k + "=" + util.Redacted
+		}
+		i++
+	}
+	return out
+}
+
 // modelFromEnv reads CLAUDE_CODE_MODEL from the env file (if configured).
 // Returns an empty string when the file cannot be read or the key is absent.
 func (r *Runner) modelFromEnv() string {
@@ -163,20 +279,74 @@ func (r *Runner) runContainer(
 	boardDir string,
 	siblingMounts map[string]map[string]string,
 ) (*claudeOutput, []byte, []byte, error) {
-	containerName := "wallfacer-" + taskID.String()
+	containerName := r.ContainerName(taskID)
 
 	// Remove any leftover container from a previous interrupted run.
 	exec.Command(r.command, "rm", "-f", containerName).Run()
 
-	args := r.buildContainerArgs(containerName, prompt, sessionID, worktreeOverrides, boardDir, siblingMounts)
+	var env map[string]string
+	var scratchDir, inputsDir string
+	var isTask bool
+	instructionsPath := r.instructionsPath
+	// A Runner without a store (see RunOnce) has no per-task settings.
+	if r.store != nil {
+		if t, err := r.store.GetTask(ctx, taskID); err == nil {
+			isTask = true
+			env = t.Env
+			if len(t.Inputs) > 0 {
+				inputsDir = r.store.InputsDir(taskID)
+			}
+			if t.Scratch {
+				scratchDir = r.store.ScratchDir(taskID)
+			}
+			if t.ExtraInstructions != "" && !t.Scratch {
+				path, err := r.writeTaskInstructions(t.ExtraInstructions)
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("task instructions: %w", err)
+				}
+				defer os.Remove(path)
+				instructionsPath = path
+			}
+		}
+	}
+	if r.readOnlyWorkspace && scratchDir == "" {
+		if err := prepareOverlays(worktreeOverrides); err != nil {
+			return nil, nil, nil, fmt.Errorf("prepare overlays: %w", err)
+		}
+	}
+	args := r.buildContainerArgs(containerName, prompt, sessionID, worktreeOverrides, boardDir, siblingMounts, env, scratchDir, instructionsPath, inputsDir)
+	if isTask {
+		r.recordLaunchContext(taskID, prompt, instructionsPath, boardDir, scratchDir != "")
+	}
 
 	cmd := exec.CommandContext(ctx, r.command, args...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	var live *liveWriter
+	if isTask {
+		live = &liveWriter{hub: r.live, taskID: taskID}
+		cmd.Stdout = io.MultiWriter(&stdout, live)
+	}
 
-	logger.Runner.Debug("exec", "cmd", r.command, "args", strings.Join(args, " "))
+	logger.Runner.Debug("exec", "cmd", r.command, "args", strings.Join(redactEnvArgs(args), " "))
+	if isTask {
+		r.containerStarted(taskID, containerName)
+	}
+	stopStats := r.watchStats(containerName)
 	runErr := cmd.Run()
+	if usage := stopStats(); usage.Samples > 0 && r.store != nil {
+		// Ignore the error: RunOnce runs containers for IDs the store does not know.
+		r.store.RecordTaskResources(context.Background(), taskID, usage.PeakMemoryBytes, usage.CPUSeconds)
+	}
+	if isTask {
+		r.containerStopped(taskID, containerName, runErr)
+		if len(live.toolCalls) > 0 {
+			if err := r.store.AppendTaskToolCalls(context.Background(), taskID, live.toolCalls); err != nil {
+				logger.Runner.Warn("record tool calls", "task", taskID, "error", err)
+			}
+		}
+	}
 
 	// If the context was cancelled or timed out, kill the container explicitly
 	// and return the context error rather than parsing potentially incomplete output.
@@ -198,7 +368,7 @@ func (r *Runner) runContainer(
 		stderrStr := strings.TrimSpace(stderr.String())
 		if stderrStr != "" {
 			return nil, stdout.Bytes(), stderr.Bytes(),
-				fmt.Errorf("empty output from container: stderr=%s", truncate(stderrStr, 500))
+				fmt.Errorf("empty output from container: stderr=%s", util.Truncate(stderrStr, 500))
 		}
 		return nil, stdout.Bytes(), stderr.Bytes(), fmt.Errorf("empty output from container")
 	}
@@ -209,12 +379,12 @@ func (r *Runner) runContainer(
 			if exitErr, ok := runErr.(*exec.ExitError); ok {
 				return nil, stdout.Bytes(), stderr.Bytes(),
 					fmt.Errorf("container exited with code %d: stderr=%s stdout=%s",
-						exitErr.ExitCode(), stderr.String(), truncate(raw, 500))
+						exitErr.ExitCode(), stderr.String(), util.Truncate(raw, 500))
 			}
 			return nil, stdout.Bytes(), stderr.Bytes(), fmt.Errorf("exec container: %w", runErr)
 		}
 		return nil, stdout.Bytes(), stderr.Bytes(),
-			fmt.Errorf("parse output: %w (raw: %s)", parseErr, truncate(raw, 200))
+			fmt.Errorf("parse output: %w (raw: %s)", parseErr, util.Truncate(raw, 200))
 	}
 
 	// Claude Code may exit non-zero even when it produces a valid result.
@@ -230,6 +400,43 @@ func (r *Runner) runContainer(
 	return output, stdout.Bytes(), stderr.Bytes(), nil
 }
 
+// containerStarted marks the task's container as up, records a container
+// start event, and opens its live output to subscribers.
+func (r *Runner) containerStarted(taskID uuid.UUID, containerName string) {
+	r.live.start(taskID)
+	bgCtx := context.Background()
+	if err := r.store.SetTaskContainer(bgCtx, taskID, containerName); err != nil {
+		logger.Runner.Warn("set task container", "task", taskID, "error", err)
+	}
+	r.store.InsertEvent(bgCtx, taskID, store.EventTypeContainer, map[string]string{
+		"action":    "start",
+		"container": containerName,
+	})
+}
+
+// containerStopped clears the task's running container and records a
+// container stop event carrying the exit code derived from runErr: -1 when
+// the container was killed by a signal or could not be run at all.
+func (r *Runner) containerStopped(taskID uuid.UUID, containerName string, runErr error) {
+	r.live.stop(taskID)
+	bgCtx := context.Background()
+	code := 0
+	if runErr != nil {
+		code = -1
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		}
+	}
+	if err := r.store.SetTaskContainer(bgCtx, taskID, ""); err != nil {
+		logger.Runner.Warn("clear task container", "task", taskID, "error", err)
+	}
+	r.store.InsertEvent(bgCtx, taskID, store.EventTypeContainer, map[string]string{
+		"action":    "stop",
+		"container": containerName,
+		"exit_code": strconv.Itoa(code),
+	})
+}
+
 // parseOutput tries to parse raw as a single JSON object first; if that fails
 // it scans backwards through NDJSON lines looking for the last valid object.
 func parseOutput(raw string) (*claudeOutput, error) {
@@ -271,5 +478,90 @@ func extractSessionID(raw []byte) string {
 
 // runGit is a helper to run a git command and discard output (best-effort).
 func runGit(dir string, args ...string) error {
-	return exec.Command("git", append([]string{"-C", dir}, args...)...).Run()
+	return gitutil.Command(context.Background(), append([]string{"-C", dir}, args...)...).Run()
+}
+
+// seccompProfilePath returns the seccomp profile for hardened containers: the
+// configured SeccompProfile, or else the built-in default, written to the
+// worktrees directory. It returns "" (the runtime's default profile) when the
+// built-in one cannot be written.
+func (r *Runner) seccompProfilePath() string {
+	if r.seccompProfile != "" {
+		return r.seccompProfile
+	}
+	path := filepath.Join(r.worktreesDir, ".seccomp.json")
+	if cur, err := os.ReadFile(path); err == nil && bytes.Equal(cur, defaultSeccompProfile) {
+		return path
+	}
+	// Write and rename so a container launching meanwhile never reads a
+	// partial profile.
+	tmp := fmt.Sprintf("%s.%s.tmp", path, uuid.NewString())
+	err := os.MkdirAll(r.worktreesDir, 0755)
+	if err == nil {
+		err = os.WriteFile(tmp, defaultSeccompProfile, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		logger.Runner.Warn("write default seccomp profile; using the runtime's default", "error", err)
+		return ""
+	}
+	return path
+}
+
+// mountPath renders a host path for a -v bind mount. Forward slashes are
+// used on every host OS: Docker Desktop on Windows accepts C:/Users/...
+// but would split a backslash path incorrectly.
+func mountPath(hostPath string) string {
+	return filepath.ToSlash(hostPath)
+}
+
+// checkInstructions reports a configured instructions file that cannot be
+// read. An empty InstructionsPath means no instructions were intended and is
+// not an error.
+func (r *Runner) checkInstructions() error {
+	if r.instructionsPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(r.instructionsPath); err != nil {
+		return fmt.Errorf("instructions file %s unavailable: %w", r.instructionsPath, err)
+	}
+	return nil
+}
+
+// writeTaskInstructions writes a temporary copy of the workspace instructions
+// with extra appended under its own heading, leaving the shared file
+// untouched. The caller removes the returned file when the container exits.
+func (r *Runner) writeTaskInstructions(extra string) (string, error) {
+	var base []byte
+	if r.instructionsPath != "" {
+		base, _ = os.ReadFile(r.instructionsPath)
+	}
+	f, err := os.CreateTemp("", "wallfacer-instructions-*.md")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.Write(base)
+	if len(base) > 0 {
+		if base[len(base)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+		b.WriteString("\n---\n\n")
+	}
+	b.WriteString("## Task-specific instructions\n\n")
+	b.WriteString(strings.TrimRight(extra, "\n"))
+	b.WriteByte('\n')
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
 }
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// buryIfExhausted moves a failed task to the terminal "dead" status once it
+// has failed more than MaxRetries times. A dead task cannot be resumed; it
+// stays put until a user explicitly retries it back to backlog. It is a
+// no-op when MaxRetries is zero.
+func (r *Runner) buryIfExhausted(ctx context.Context, taskID uuid.UUID) {
+	if r.maxRetries <= 0 {
+		return
+	}
+	task, err := r.store.GetTask(ctx, taskID)
+	if err != nil || task.Status != "failed" || task.FailureCount <= r.maxRetries {
+		return
+	}
+	if err := r.store.UpdateTaskStatus(ctx, taskID, "dead"); err != nil {
+		logger.Runner.Warn("move to dead", "task", taskID, "error", err)
+		return
+	}
+	r.store.InsertEvent(ctx, taskID, store.EventTypeSystem, map[string]string{
+		"result": fmt.Sprintf("Failed %d times (max retries %d); not retrying until the task is retried explicitly.", task.FailureCount, r.maxRetries),
+	})
+	r.store.InsertEvent(ctx, taskID, store.EventTypeStateChange, map[string]string{
+		"from": "failed",
+		"to":   "dead",
+	})
+	logger.Runner.Warn("task dead", "task", taskID, "failures", task.FailureCount)
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"changkun.de/wallfacer/internal/store"
+)
+
+// TestRunMovesTaskToDeadAfterMaxRetries verifies that a task failing more
+// than MaxRetries times ends in dead, where it can no longer be resumed, and
+// that an explicit retry revives it with a cleared failure count.
+func TestRunMovesTaskToDeadAfterMaxRetries(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, "", 1))
+	r.maxRetries = 1
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "always fails", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "prompt", "", false)
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "failed" || got.FailureCount != 1 {
+		t.Fatalf("after first run: status %q, failures %d; want failed, 1", got.Status, got.FailureCount)
+	}
+
+	// Resume the failed task; the second failure exceeds MaxRetries.
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "continue", "", false)
+	got, _ = s.GetTask(ctx, task.ID)
+	if got.Status != "dead" || got.FailureCount != 2 {
+		t.Fatalf("after second run: status %q, failures %d; want dead, 2", got.Status, got.FailureCount)
+	}
+
+	if err := s.UpdateTaskStatus(ctx, task.ID, "in_progress"); !errors.Is(err, store.ErrInvalidTransition) {
+		t.Errorf("resuming a dead task: err = %v, want ErrInvalidTransition", err)
+	}
+
+	if err := s.ResetTaskForRetry(ctx, task.ID, "try again", true); err != nil {
+		t.Fatalf("retrying a dead task: %v", err)
+	}
+	got, _ = s.GetTask(ctx, task.ID)
+	if got.Status != "backlog" || got.FailureCount != 0 {
+		t.Errorf("after retry: status %q, failures %d; want backlog, 0", got.Status, got.FailureCount)
+	}
+}
+
+// TestRunWithoutMaxRetriesStaysFailed verifies that with MaxRetries unset a
+// task keeps failing into failed however often it fails.
+func TestRunWithoutMaxRetriesStaysFailed(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, "", 1))
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "always fails", 5, false)
+	for i := 0; i < 3; i++ {
+		moveTask(t, s, task.ID, "in_progress")
+		r.Run(task.ID, "prompt", "", false)
+	}
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "failed" || got.FailureCount != 3 {
+		t.Errorf("status %q, failures %d; want failed, 3", got.Status, got.FailureCount)
+	}
+}
+
+// TestRunCommitMovesTaskToDeadAfterMaxRetries verifies that a failing commit
+// counts towards MaxRetries like a failing run.
+func TestRunCommitMovesTaskToDeadAfterMaxRetries(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupTestRunner(t, []string{repo})
+	r.maxRetries = 1
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "conflicts on commit", 5, false)
+	moveTask(t, s, task.ID, "failed")
+	moveTask(t, s, task.ID, "in_progress")
+	wtPaths, branchName, err := r.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTaskWorktrees(ctx, task.ID, wtPaths, branchName); err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "waiting")
+	moveTask(t, s, task.ID, "committing")
+
+	// Conflicting edits on both sides; the dummy runtime cannot resolve them.
+	if err := os.WriteFile(filepath.Join(wtPaths[repo], "README.md"), []byte("# Task\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("# Main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "commit", "-am", "conflicting change on main")
+
+	r.RunCommit(task.ID, "")
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "dead" || got.FailureCount != 2 {
+		t.Errorf("status %q, failures %d; want dead, 2", got.Status, got.FailureCount)
+	}
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+	"changkun.de/wallfacer/internal/logger"
+	"github.com/google/uuid"
+)
+
+// stagedDiffLines stages all changes in the worktree and returns the number
+// of lines added plus removed relative to base (its HEAD when base is
+// empty), so commits the agent made itself count too. Binary files count as
+// no lines.
+func stagedDiffLines(worktreePath, base string) (int, error) {
+	if base == "" {
+		base = "HEAD"
+	}
+	if out, err := gitutil.Command(context.Background(), "-C", worktreePath, "add", "-A").CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("git add in %s: %w\n%s", worktreePath, err, out)
+	}
+	out, err := gitutil.Command(context.Background(), "-C", worktreePath, "diff", "--cached", "--numstat", base).Output()
+	if err != nil {
+		return 0, fmt.Errorf("git diff in %s: %w", worktreePath, err)
+	}
+	lines := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0]) // "-" for binary files
+		removed, _ := strconv.Atoi(fields[1])
+		lines += added + removed
+	}
+	return lines, nil
+}
+
+// taskDiffLines sums stagedDiffLines over the task's worktrees. Worktrees
+// whose diff cannot be computed are logged and left out.
+func (r *Runner) taskDiffLines(taskID uuid.UUID, worktreePaths map[string]string) int {
+	var baseCommits map[string]string
+	if task, err := r.store.GetTask(context.Background(), taskID); err == nil {
+		baseCommits = task.BaseCommits
+	}
+	total := 0
+	for repoPath, worktreePath := range worktreePaths {
+		n, err := stagedDiffLines(worktreePath, baseCommits[repoPath])
+		if err != nil {
+			logger.Runner.Warn("diff size", "task", taskID, "repo", repoPath, "error", err)
+			continue
+		}
+		total += n
+	}
+	return total
+}
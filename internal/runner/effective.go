@@ -0,0 +1,116 @@
+package runner
+
+import "changkun.de/wallfacer/internal/util"
+
+// redacted replaces configuration values that may carry credentials.
+const redacted = util.Redacted
+
+// EffectiveConfig is the configuration a Runner actually uses, with defaults
+// filled in, for display when debugging. Values that may carry credentials
+// (the env file path, the notification URL, -e values in ExtraRunArgs) are
+// masked; an empty string still means the option is unset.
+type EffectiveConfig struct {
+	Command              string            `json:"command"`
+	SandboxImage         string            `json:"sandbox_image"`
+	EnvFile              string            `json:"env_file"`
+	Workspaces           []string          `json:"workspaces"`
+	WorktreesDir         string            `json:"worktrees_dir"`
+	InstructionsPath     string            `json:"instructions_path"`
+	InstructionsMount    string            `json:"instructions_mount_path"` // empty: inside the workspace root
+	SeparateInstructions bool              `json:"separate_instructions"`
+	RequireInstructions  bool              `json:"require_instructions"`
+	TaskTimeout          string            `json:"task_timeout"` // default for tasks without their own timeout
+	WaitingTimeout       string            `json:"waiting_timeout"`
+	WaitingTimeoutAction string            `json:"waiting_timeout_action"`
+	MaxRetries           int               `json:"max_retries"`
+	ShallowWorktree      bool              `json:"shallow_worktree"`
+	Unshallow            bool              `json:"unshallow"`
+	ReadOnlyWorkspace    bool              `json:"read_only_workspace"`
+	DisableBoard         bool              `json:"disable_board"`
+	ShortIDLength        int               `json:"short_id_length"`
+	Instance             string            `json:"instance"`
+	GitAuthorName        string            `json:"git_author_name"`
+	GitAuthorEmail       string            `json:"git_author_email"`
+	DefaultBranches      map[string]string `json:"default_branches"`
+	RebaseArgs           []string          `json:"rebase_args"`
+	Rerere               bool              `json:"rerere"`
+	MaxRebaseRetries     int               `json:"max_rebase_retries"`
+	KeepBranch           bool              `json:"keep_branch"`
+	TagTasks             bool              `json:"tag_tasks"`
+	MaxDiffLines         int               `json:"max_diff_lines"`
+	VerifyCommand        string            `json:"verify_command"`
+	PreMergeDelay        string            `json:"pre_merge_delay"`
+	RevertOutOfScope     bool              `json:"revert_out_of_scope"`
+	SecretScan           bool              `json:"secret_scan"`
+	NotifyURL            string            `json:"notify_url"`
+	NotifyFormat         string            `json:"notify_format"`
+	HardenedSandbox      bool              `json:"hardened_sandbox"`
+	SeccompProfile       string            `json:"seccomp_profile"`
+	ExtraRunArgs         []string          `json:"extra_run_args"`
+	AgentArgs            []string          `json:"agent_args"`
+	RsyncAvailable       bool              `json:"rsync_available"`
+}
+
+// EffectiveConfig returns the configuration r runs with, defaults applied
+// and credentials masked.
+func (r *Runner) EffectiveConfig() EffectiveConfig {
+	cfg := EffectiveConfig{
+		Command:              r.command,
+		SandboxImage:         r.sandboxImage,
+		Workspaces:           r.Workspaces(),
+		WorktreesDir:         r.worktreesDir,
+		InstructionsPath:     r.instructionsPath,
+		InstructionsMount:    r.instructionsMount,
+		SeparateInstructions: r.separateInstructions,
+		RequireInstructions:  r.requireInstructions,
+		TaskTimeout:          defaultTaskTimeout.String(),
+		WaitingTimeoutAction: r.waitingTimeoutAction,
+		MaxRetries:           r.maxRetries,
+		ShallowWorktree:      r.shallowWorktree,
+		Unshallow:            r.unshallow,
+		ReadOnlyWorkspace:    r.readOnlyWorkspace,
+		DisableBoard:         r.disableBoard,
+		ShortIDLength:        r.shortIDLength,
+		Instance:             r.instance,
+		GitAuthorName:        r.gitAuthorName,
+		GitAuthorEmail:       r.gitAuthorEmail,
+		DefaultBranches:      r.defaultBranches,
+		RebaseArgs:           r.rebaseArgs,
+		Rerere:               r.rerere,
+		MaxRebaseRetries:     maxRebaseRetries,
+		KeepBranch:           r.keepBranch,
+		TagTasks:             r.tagTasks,
+		MaxDiffLines:         r.maxDiffLines,
+		VerifyCommand:        r.verifyCommand,
+		RevertOutOfScope:     r.revertOutOfScope,
+		SecretScan:           r.secretScan,
+		NotifyFormat:         r.notifyFormat,
+		HardenedSandbox:      r.hardenedSandbox,
+		SeccompProfile:       r.seccompProfile,
+		ExtraRunArgs:         redactEnvArgs(r.extraRunArgs),
+		AgentArgs:            r.agentArgs,
+		RsyncAvailable:       r.rsyncAvailable,
+	}
+	if r.envFile != "" {
+		cfg.EnvFile = redacted
+	}
+	if r.notifyURL != "" {
+		cfg.NotifyURL = redacted
+	}
+	if r.preMergeDelay > 0 {
+		cfg.PreMergeDelay = r.preMergeDelay.String()
+	}
+	if r.waitingTimeout > 0 {
+		cfg.WaitingTimeout = r.waitingTimeout.String()
+	}
+	if cfg.WaitingTimeoutAction == "" {
+		cfg.WaitingTimeoutAction = WaitingTimeoutFail
+	}
+	if cfg.ShortIDLength <= 0 {
+		cfg.ShortIDLength = defaultShortIDLength
+	}
+	if cfg.NotifyFormat == "" {
+		cfg.NotifyFormat = NotifyFormatRaw
+	}
+	return cfg
+}
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"changkun.de/wallfacer/internal/gitutil"
+	"changkun.de/wallfacer/internal/instructions"
 	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/store"
 	"github.com/google/uuid"
@@ -20,6 +22,9 @@ import (
 func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWaiting bool) {
 	bgCtx := context.Background()
 
+	// Runs last, after the guard below has settled the final status.
+	defer r.buryIfExhausted(bgCtx, taskID)
+
 	// Guard: if this goroutine returns without explicitly setting the task
 	// status (panic, early error), move to "failed" so the task doesn't
 	// stay stuck in "in_progress" forever.
@@ -51,10 +56,39 @@ func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWait
 	ctx, cancel := context.WithTimeout(bgCtx, timeout)
 	defer cancel()
 
-	// Set up worktrees only if not already present.
+	// A configured instructions file that is missing means writing it failed;
+	// without it the container would silently run with no CLAUDE.md.
+	if !task.Scratch {
+		if err := r.checkInstructions(); err != nil {
+			if r.requireInstructions {
+				logger.Runner.Error("instructions missing", "task", taskID, "error", err)
+				statusSet = true
+				r.store.UpdateTaskStatus(bgCtx, taskID, "failed")
+				r.store.UpdateTaskResult(bgCtx, taskID, err.Error(), sessionID, "", task.Turns)
+				r.store.InsertEvent(bgCtx, taskID, store.EventTypeError, map[string]string{"error": err.Error()})
+				r.store.InsertEvent(bgCtx, taskID, store.EventTypeStateChange, map[string]string{
+					"from": "in_progress", "to": "failed",
+				})
+				return
+			}
+			logger.Runner.Warn("running WITHOUT workspace instructions", "task", taskID, "error", err)
+			r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+				"result": "Warning: " + err.Error() + " — running without workspace instructions.",
+			})
+		}
+	}
+
+	// Set up worktrees only if not already present. Scratch tasks get an
+	// empty directory instead and never touch the workspaces.
 	worktreePaths := task.WorktreePaths
 	branchName := task.BranchName
-	needSetup := len(worktreePaths) == 0
+	needSetup := len(worktreePaths) == 0 && !task.Scratch
+	if task.Scratch {
+		if err := os.MkdirAll(r.store.ScratchDir(taskID), 0755); err != nil {
+			logger.Runner.Error("create scratch dir", "task", taskID, "error", err)
+			return // defer moves to "failed"
+		}
+	}
 	if !needSetup {
 		// Verify stored paths still exist on disk.
 		for _, wt := range worktreePaths {
@@ -96,10 +130,16 @@ func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWait
 	prevCacheRead := task.Usage.LastReportedCacheReadInputTokens
 	prevCacheCreation := task.Usage.LastReportedCacheCreationTokens
 
-	// Prepare board context (board.json manifest of all tasks).
-	boardDir, boardErr := r.prepareBoardContext(taskID, task.MountWorktrees)
-	if boardErr != nil {
-		logger.Runner.Warn("board context failed", "task", taskID, "error", boardErr)
+	// Prepare board context (board.json manifest of all tasks) unless the
+	// board is disabled, in which case boardDir stays empty and nothing is
+	// mounted at /workspace/.tasks.
+	var boardDir string
+	if !r.disableBoard {
+		var boardErr error
+		boardDir, boardErr = r.prepareBoardContext(taskID, task.MountWorktrees)
+		if boardErr != nil {
+			logger.Runner.Warn("board context failed", "task", taskID, "error", boardErr)
+		}
 	}
 	defer func() {
 		if boardDir != "" {
@@ -109,10 +149,14 @@ func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWait
 
 	// Build sibling worktree mounts if opted in.
 	var siblingMounts map[string]map[string]string
-	if task.MountWorktrees {
+	if task.MountWorktrees && !r.disableBoard {
 		siblingMounts = r.buildSiblingMounts(taskID)
 	}
 
+	// Set once any turn of this run asks for review: an auto-continued
+	// final turn need not repeat the marker.
+	reviewRequested := false
+
 	for {
 		turns++
 		logger.Runner.Info("turn", "task", taskID, "turn", turns, "session", sessionID, "timeout", timeout)
@@ -128,6 +172,7 @@ func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWait
 		if saveErr := r.store.SaveTurnOutput(taskID, turns, rawStdout, rawStderr); saveErr != nil {
 			logger.Runner.Error("save turn output", "task", taskID, "turn", turns, "error", saveErr)
 		}
+		r.recordTranscript(task, turns, prompt, rawStdout)
 		if err != nil {
 			// Try to salvage session_id from partial output so the task
 			// can be resumed even when the container fails (e.g. timeout).
@@ -175,6 +220,9 @@ func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWait
 			sessionID = output.SessionID
 		}
 		r.store.UpdateTaskResult(bgCtx, taskID, output.Result, sessionID, output.StopReason, turns)
+		if requestsReview(output.Result) {
+			reviewRequested = true
+		}
 
 		// Compute per-turn deltas from session-cumulative values.
 		// If a value drops (e.g. new session after retry), use it as-is.
@@ -230,7 +278,40 @@ func (r *Runner) Run(taskID uuid.UUID, prompt, sessionID string, resumedFromWait
 		switch output.StopReason {
 		case "end_turn":
 			statusSet = true
-			if err := r.commit(ctx, taskID, sessionID, turns, worktreePaths, branchName); err != nil {
+			r.store.SetTaskReviewRequested(bgCtx, taskID, reviewRequested)
+			if r.readOnlyWorkspace && !task.Scratch {
+				// The writes stay in the overlay until the user promotes
+				// them by marking the task done.
+				r.holdForReview(bgCtx, taskID, store.HoldReadOnly,
+					"Read-only workspace: changes are held in an overlay. Mark the task done to apply and commit them.")
+				return
+			}
+			if reviewRequested && !task.Scratch {
+				r.holdForReview(bgCtx, taskID, store.HoldReviewRequested,
+					"The agent asked for review before merging. Review the changes and mark the task done to commit and merge them, or give feedback.")
+				return
+			}
+			if r.maxDiffLines > 0 && !task.Scratch {
+				if n := r.taskDiffLines(taskID, worktreePaths); n > r.maxDiffLines {
+					// Too large to merge unreviewed: the user commits it by
+					// marking the task done.
+					r.holdForReview(bgCtx, taskID, store.HoldLargeDiff,
+						fmt.Sprintf("Large diff needs review: %d changed lines exceed the limit of %d. Review the diff and mark the task done to commit and merge it.", n, r.maxDiffLines))
+					return
+				}
+			}
+			if r.verifyCommand != "" && !task.Scratch {
+				if out, err := r.verify(ctx, taskID, worktreePaths); err != nil {
+					// Held like a large diff: feedback lets the agent fix
+					// it, marking the task done merges it anyway.
+					r.holdForReview(bgCtx, taskID, store.HoldVerifyFailed,
+						fmt.Sprintf("Verification failed: %q: %v. Give feedback to fix it, or mark the task done to commit and merge anyway.\n\n%s", r.verifyCommand, err, verifyOutputTail(out)))
+					return
+				}
+			}
+			if err := r.commit(ctx, taskID, sessionID, turns, worktreePaths, branchName); errors.Is(err, ErrMergeCancelled) {
+				return // the canceller has moved the task to cancelled
+			} else if err != nil {
 				r.store.UpdateTaskStatus(bgCtx, taskID, "failed")
 				r.store.InsertEvent(bgCtx, taskID, store.EventTypeError, map[string]string{
 					"error": "commit failed: " + err.Error(),
@@ -305,14 +386,21 @@ func (r *Runner) SyncWorktrees(taskID uuid.UUID, sessionID, prevStatus string) {
 	})
 
 	for repoPath, worktreePath := range task.WorktreePaths {
-		if !gitutil.IsGitRepo(repoPath) {
+		if !gitutil.IsGitRepo(ctx, repoPath) {
 			r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 				"result": fmt.Sprintf("Skipping %s — not a git repository, cannot sync.", filepath.Base(repoPath)),
 			})
 			continue
 		}
 
-		defBranch, err := gitutil.DefaultBranch(repoPath)
+		if r.shallowWorktree {
+			r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+				"result": fmt.Sprintf("Skipping %s — shallow worktrees cannot be rebased.", filepath.Base(repoPath)),
+			})
+			continue
+		}
+
+		defBranch, err := r.DefaultBranch(ctx, repoPath)
 		if err != nil {
 			statusSet = true
 			r.failSync(bgCtx, taskID, sessionID, task.Turns,
@@ -320,7 +408,7 @@ func (r *Runner) SyncWorktrees(taskID uuid.UUID, sessionID, prevStatus string) {
 			return
 		}
 
-		n, _ := gitutil.CommitsBehind(repoPath, worktreePath)
+		n, _ := gitutil.CommitsBehindBranch(ctx, worktreePath, defBranch)
 		if n == 0 {
 			r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
 				"result": fmt.Sprintf("%s is already up to date with %s.", filepath.Base(repoPath), defBranch),
@@ -336,10 +424,17 @@ func (r *Runner) SyncWorktrees(taskID uuid.UUID, sessionID, prevStatus string) {
 
 		var rebaseErr error
 		for attempt := 1; attempt <= maxRebaseRetries; attempt++ {
-			rebaseErr = gitutil.RebaseOntoDefault(repoPath, worktreePath)
+			rebaseErr = gitutil.RebaseOntoDefault(ctx, repoPath, worktreePath, r.rebaseOptions(defBranch))
+			r.recordConflict(taskID, repoPath, rebaseErr)
 			if rebaseErr == nil {
 				break
 			}
+			// Binary conflicts have no markers to resolve, so retrying with
+			// the resolver cannot succeed.
+			var ce *gitutil.ConflictError
+			if errors.As(rebaseErr, &ce) && len(ce.Binary) > 0 {
+				break
+			}
 			if attempt == maxRebaseRetries || !isConflictError(rebaseErr) {
 				break
 			}
@@ -395,3 +490,13 @@ func (r *Runner) failSync(ctx context.Context, taskID uuid.UUID, sessionID strin
 	r.store.UpdateTaskResult(ctx, taskID, "Sync failed: "+msg, sessionID, "sync_failed", turns)
 }
 
+// requestsReview reports whether an agent result asks for a person's review
+// before merging: a line of its own reading instructions.ReviewRequestMarker.
+func requestsReview(result string) bool {
+	for _, line := range strings.Split(result, "\n") {
+		if strings.TrimSpace(line) == instructions.ReviewRequestMarker {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -107,6 +108,7 @@ func TestRunEndTurnTransitionsToDone(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "do the task", "", false)
 
 	updated, err := s.GetTask(ctx, task.ID)
@@ -118,6 +120,93 @@ func TestRunEndTurnTransitionsToDone(t *testing.T) {
 	}
 }
 
+// TestRunRecordsDuration verifies that a completed run carries ordered
+// start/finish timestamps and a positive duration.
+func TestRunRecordsDuration(t *testing.T) {
+	repo := setupTestRepo(t)
+	cmd := fakeCmdScript(t, endTurnOutput, 0)
+	s, r := setupRunnerWithCmd(t, []string{repo}, cmd)
+	ctx := context.Background()
+
+	task, err := s.CreateTask(ctx, "Test duration", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "do the task", "", false)
+
+	updated, _ := s.GetTask(ctx, task.ID)
+	if updated.Status != "done" {
+		t.Fatalf("expected status=done, got %q", updated.Status)
+	}
+	if updated.StartedAt == nil || updated.FinishedAt == nil {
+		t.Fatalf("expected both timestamps, got started=%v finished=%v", updated.StartedAt, updated.FinishedAt)
+	}
+	if !updated.StartedAt.Before(*updated.FinishedAt) {
+		t.Errorf("StartedAt %v not before FinishedAt %v", updated.StartedAt, updated.FinishedAt)
+	}
+	if updated.StartedAt.Before(updated.CreatedAt) {
+		t.Errorf("StartedAt %v before CreatedAt %v", updated.StartedAt, updated.CreatedAt)
+	}
+	if updated.DurationSeconds <= 0 {
+		t.Errorf("DurationSeconds = %v, want > 0", updated.DurationSeconds)
+	}
+}
+
+// TestRunWarnsOnMissingInstructions verifies that a configured but missing
+// instructions file is surfaced as a warning event and the task still runs.
+func TestRunWarnsOnMissingInstructions(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, endTurnOutput, 0))
+	r.instructionsPath = filepath.Join(t.TempDir(), "missing.md")
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "p", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "p", "", false)
+
+	updated, _ := s.GetTask(ctx, task.ID)
+	if updated.Status != "done" {
+		t.Fatalf("expected status=done, got %q", updated.Status)
+	}
+	events, _ := s.GetEvents(ctx, task.ID)
+	found := false
+	for _, e := range events {
+		if e.EventType == store.EventTypeSystem && strings.Contains(string(e.Data), "running without workspace instructions") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected a warning event about the missing instructions file")
+	}
+}
+
+// TestRunRequireInstructionsFails verifies that RequireInstructions fails the
+// task before any container runs when the instructions file is missing.
+func TestRunRequireInstructionsFails(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, endTurnOutput, 0))
+	r.instructionsPath = filepath.Join(t.TempDir(), "missing.md")
+	r.requireInstructions = true
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "p", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "p", "", false)
+
+	updated, _ := s.GetTask(ctx, task.ID)
+	if updated.Status != "failed" {
+		t.Fatalf("expected status=failed, got %q", updated.Status)
+	}
+	if updated.Turns != 0 {
+		t.Errorf("expected no turns to run, got %d", updated.Turns)
+	}
+	if updated.Result == nil || !strings.Contains(*updated.Result, "missing.md") {
+		t.Errorf("result should name the missing file, got %v", updated.Result)
+	}
+}
+
 // TestRunWaitingTransitionsToWaiting verifies that an empty stop_reason
 // moves the task to "waiting" (awaiting user feedback).
 func TestRunWaitingTransitionsToWaiting(t *testing.T) {
@@ -131,6 +220,7 @@ func TestRunWaitingTransitionsToWaiting(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "some prompt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -152,6 +242,7 @@ func TestRunIsErrorTransitionsToFailed(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "do something", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -173,6 +264,7 @@ func TestRunContainerErrorTransitionsToFailed(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "prompt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -195,6 +287,7 @@ func TestRunMaxTokensAutoContinues(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "prompt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -229,6 +322,7 @@ func TestRunWorktreeSetupFailureTransitionsToFailed(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "prompt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -250,6 +344,7 @@ func TestRunEndTurnRecordsResult(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "do the task", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -286,9 +381,7 @@ func TestSyncWorktreesAlreadyUpToDate(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// Worktree was just created from HEAD — 0 commits behind main.
 	runner.SyncWorktrees(task.ID, "", "waiting")
@@ -320,9 +413,7 @@ func TestSyncWorktreesBehindMain(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// Advance main with a new commit so the worktree is 1 commit behind.
 	if err := os.WriteFile(filepath.Join(repo, "advance.txt"), []byte("advance\n"), 0644); err != nil {
@@ -344,6 +435,59 @@ func TestSyncWorktreesBehindMain(t *testing.T) {
 	}
 }
 
+// TestSyncWorktreesFailsFastOnBinaryConflict verifies that a sync hitting
+// a conflict in a binary file fails without running the resolver.
+func TestSyncWorktreesFailsFastOnBinaryConflict(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(repo, "logo.png"), []byte("base\x00png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "add logo")
+
+	task, _ := s.CreateTask(ctx, "binary sync", 5, false)
+	wt, br, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, wt, br) })
+	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "waiting")
+
+	if err := os.WriteFile(filepath.Join(wt[repo], "logo.png"), []byte("task\x00png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, wt[repo], "commit", "-am", "task logo")
+	if err := os.WriteFile(filepath.Join(repo, "logo.png"), []byte("main\x00png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "commit", "-am", "main logo")
+
+	runner.SyncWorktrees(task.ID, "", "waiting")
+
+	if got, _ := s.GetTask(ctx, task.ID); got.Status != "failed" {
+		t.Fatalf("status = %q, want failed", got.Status)
+	}
+	events, _ := s.GetEvents(ctx, task.ID)
+	found := false
+	for _, e := range events {
+		if strings.Contains(string(e.Data), "running resolver") {
+			t.Fatal("resolver should not run for a binary conflict")
+		}
+		if strings.Contains(string(e.Data), "binary conflict in logo.png") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("no event names the binary conflict")
+	}
+}
+
 // TestSyncWorktreesNonGitWorkspaceSkipped verifies that non-git workspaces
 // are skipped during sync (logged as informational, not an error).
 func TestSyncWorktreesNonGitWorkspaceSkipped(t *testing.T) {
@@ -369,9 +513,7 @@ func TestSyncWorktreesNonGitWorkspaceSkipped(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// Non-git workspace is skipped, sync completes, status is restored.
 	runner.SyncWorktrees(task.ID, "", "waiting")
@@ -393,9 +535,7 @@ func TestSyncWorktreesNoWorktreePaths(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// No WorktreePaths set — the sync loop is a no-op.
 	runner.SyncWorktrees(task.ID, "", "waiting")
@@ -420,9 +560,7 @@ func TestFailSync(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "in_progress"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "in_progress")
 
 	runner.failSync(ctx, task.ID, "", 0, "simulated sync failure")
 
@@ -488,6 +626,7 @@ func TestRunWithPreexistingWorktrees(t *testing.T) {
 	}
 
 	// Run should detect existing worktrees and skip re-creation.
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "continue task", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -522,6 +661,7 @@ func TestRunUsageAccumulation(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "task prompt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -555,6 +695,7 @@ func TestRunCostMultiTurn(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "prompt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -592,6 +733,7 @@ func TestRunCostResumedFromWaiting(t *testing.T) {
 	}
 
 	// First Run: goes to waiting.
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "prompt", "", false)
 	waiting, _ := s.GetTask(ctx, task.ID)
 	if waiting.Status != "waiting" {
@@ -599,6 +741,7 @@ func TestRunCostResumedFromWaiting(t *testing.T) {
 	}
 
 	// Second Run (feedback resume): goes to done.
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "continue", *waiting.SessionID, false)
 	final, _ := s.GetTask(ctx, task.ID)
 	if final.Status != "done" {
@@ -639,9 +782,7 @@ func TestSyncWorktreesPrevStatusRestored(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "failed"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "failed")
 
 	// Restore to "failed" (a different prevStatus from "waiting").
 	runner.SyncWorktrees(task.ID, "", "failed")
@@ -669,6 +810,7 @@ func TestRunWaitingFeedbackDonePreservesChanges(t *testing.T) {
 	}
 
 	// First Run: produces waitingOutput → task goes to "waiting".
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "do the task", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -687,6 +829,7 @@ func TestRunWaitingFeedbackDonePreservesChanges(t *testing.T) {
 	}
 
 	// Second Run (feedback resume): produces endTurnOutput → commit pipeline → done.
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "continue", *updated.SessionID, false)
 
 	final, _ := s.GetTask(ctx, task.ID)
@@ -751,9 +894,7 @@ func TestSyncWorktreesBehindMainDirtyWorktree(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// Create an uncommitted change in the worktree (makes it "dirty").
 	dirtyFile := filepath.Join(wt[repo], "dirty.txt")
@@ -781,3 +922,331 @@ func TestSyncWorktreesBehindMainDirtyWorktree(t *testing.T) {
 		t.Fatal("advance2.txt should be in worktree after sync:", err)
 	}
 }
+
+// TestRunEmitsContainerStartAndStop verifies that subscribers see the task's
+// container come up and go away again, and that the audit trail records a
+// start event followed by a stop event with the exit code.
+func TestRunEmitsContainerStartAndStop(t *testing.T) {
+	repo := setupTestRepo(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-cmd")
+	body := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in run) ;; *) exit 0 ;; esac\nsleep 0.3\necho '%s'\n", waitingOutput)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, []string{repo}, script)
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "observe the container", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+
+	subID, ch := s.Subscribe()
+	defer s.Unsubscribe(subID)
+	seen := make(chan []string, 1)
+	done := make(chan struct{})
+	go func() {
+		var names []string
+		for {
+			select {
+			case <-ch:
+				if got, err := s.GetTask(ctx, task.ID); err == nil {
+					names = append(names, got.Container)
+				}
+			case <-done:
+				seen <- names
+				return
+			}
+		}
+	}()
+
+	r.Run(task.ID, "observe the container", "", false)
+	time.Sleep(200 * time.Millisecond) // let the debounced notifications drain
+	close(done)
+
+	names := <-seen
+	up := -1
+	for i, n := range names {
+		if n == "wallfacer-"+task.ID.String() {
+			up = i
+			break
+		}
+	}
+	if up < 0 || names[len(names)-1] != "" {
+		t.Fatalf("subscriber should see the container up, then gone; saw %q", names)
+	}
+
+	events, _ := s.GetEvents(ctx, task.ID)
+	var actions []string
+	for _, e := range events {
+		if e.EventType == store.EventTypeContainer {
+			actions = append(actions, string(e.Data))
+		}
+	}
+	if len(actions) != 2 || !strings.Contains(actions[0], `"action":"start"`) ||
+		!strings.Contains(actions[1], `"action":"stop"`) || !strings.Contains(actions[1], `"exit_code":"0"`) {
+		t.Fatalf("expected a start then a stop event with exit code 0, got %v", actions)
+	}
+}
+
+// runWritingLines runs a task whose container writes a file of n lines into
+// its worktree before ending its turn, under a runner capped at maxDiffLines.
+func runWritingLines(t *testing.T, n, maxDiffLines int) (*store.Store, uuid.UUID) {
+	t.Helper()
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, "")
+	r.maxDiffLines = maxDiffLines
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "write a big file", 5, false)
+
+	wt := filepath.Join(r.worktreesDir, task.ID.String(), filepath.Base(repo))
+	out := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(out, []byte(endTurnOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "fake-cmd")
+	body := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in run) ;; *) exit 0 ;; esac\n[ -d %q ] && seq 1 %d > %q\ncat %q\n",
+		wt, n, filepath.Join(wt, "big.txt"), out)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.command = script
+
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "write a big file", "", false)
+	return s, task.ID
+}
+
+// TestRunLargeDiffGoesToWaiting verifies that a task changing more lines
+// than MaxDiffLines is held in waiting for review instead of being merged.
+func TestRunLargeDiffGoesToWaiting(t *testing.T) {
+	s, id := runWritingLines(t, 50, 20)
+	task, _ := s.GetTask(context.Background(), id)
+	if task.Status != "waiting" {
+		t.Fatalf("status = %q, want waiting", task.Status)
+	}
+	if task.HoldReason != store.HoldLargeDiff {
+		t.Errorf("hold reason = %q, want %q", task.HoldReason, store.HoldLargeDiff)
+	}
+	if len(task.CommitHashes) != 0 {
+		t.Fatalf("large diff was committed: %v", task.CommitHashes)
+	}
+	events, _ := s.GetEvents(context.Background(), id)
+	found := false
+	for _, e := range events {
+		if e.EventType == store.EventTypeSystem && strings.Contains(string(e.Data), "Large diff needs review: 50 changed lines") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("missing large diff review event")
+	}
+}
+
+// TestRunDiffWithinLimitIsMerged verifies that the cap leaves smaller
+// tasks on the automatic path.
+func TestRunDiffWithinLimitIsMerged(t *testing.T) {
+	s, id := runWritingLines(t, 10, 20)
+	task, _ := s.GetTask(context.Background(), id)
+	if task.Status != "done" {
+		t.Fatalf("status = %q, want done", task.Status)
+	}
+}
+
+// runVerifying runs a task whose container adds a file to its worktree
+// before ending its turn, under a runner with a verify command. The fake
+// runtime's verify run writes coverage.out into each mounted workspace,
+// prints a test failure and exits with verifyExit.
+func runVerifying(t *testing.T, verifyExit int) (*store.Store, uuid.UUID, string) {
+	t.Helper()
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, "")
+	r.verifyCommand = "go test ./..."
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "change something", 5, false)
+
+	wt := filepath.Join(r.worktreesDir, task.ID.String(), filepath.Base(repo))
+	out := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(out, []byte(endTurnOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "fake-cmd")
+	body := fmt.Sprintf(`#!/bin/sh
+case "$1" in run) ;; *) exit 0 ;; esac
+case "$*" in *"--entrypoint sh"*"-c go test ./..."*)
+	for a in "$@"; do
+		case "$a" in *:/workspace/*) echo cover > "${a%%%%:/workspace/*}/coverage.out" || exit 3 ;; esac
+	done
+	echo "--- FAIL: TestThing"; exit %d ;;
+esac
+[ -d %q ] && echo change > %q
+cat %q
+`, verifyExit, wt, filepath.Join(wt, "change.txt"), out)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.command = script
+
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "change something", "", false)
+	return s, task.ID, repo
+}
+
+// TestRunVerifyFailureGoesToWaiting verifies that a failing verify command
+// blocks the merge and records the command's output on the task.
+func TestRunVerifyFailureGoesToWaiting(t *testing.T) {
+	s, id, repo := runVerifying(t, 1)
+	task, _ := s.GetTask(context.Background(), id)
+	if task.Status != "waiting" {
+		t.Fatalf("status = %q, want waiting", task.Status)
+	}
+	if task.HoldReason != store.HoldVerifyFailed {
+		t.Errorf("hold reason = %q, want %q", task.HoldReason, store.HoldVerifyFailed)
+	}
+	if len(task.CommitHashes) != 0 {
+		t.Fatalf("failed verification was committed: %v", task.CommitHashes)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "change.txt")); err == nil {
+		t.Fatal("failed verification was merged into the repo")
+	}
+	events, _ := s.GetEvents(context.Background(), id)
+	found := false
+	for _, e := range events {
+		if e.EventType == store.EventTypeSystem && strings.Contains(string(e.Data), "--- FAIL: TestThing") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("missing verify output event")
+	}
+}
+
+// TestRunVerifySuccessIsMerged verifies that a passing verify command
+// leaves the task on the automatic path.
+func TestRunVerifySuccessIsMerged(t *testing.T) {
+	s, id, repo := runVerifying(t, 0)
+	task, _ := s.GetTask(context.Background(), id)
+	if task.Status != "done" {
+		t.Fatalf("status = %q, want done", task.Status)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "change.txt")); err != nil {
+		t.Fatalf("verified change was not merged: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "coverage.out")); !os.IsNotExist(err) {
+		t.Errorf("file written by the verify command was merged: %v", err)
+	}
+}
+
+// TestRunReviewRequestGoesToWaiting verifies that an agent ending its turn
+// with the review request marker is held in waiting instead of merged, and
+// that the request shows in the task and in board.json.
+func TestRunReviewRequestGoesToWaiting(t *testing.T) {
+	repo := setupTestRepo(t)
+	stream := `{"type":"assistant","message":{"content":[{"type":"text","text":"Rewrote the auth flow."}]}}
+{"type":"result","result":"Rewrote the auth flow.\n\nWALLFACER: REVIEW REQUESTED","session_id":"sess1","stop_reason":"end_turn","is_error":false,"total_cost_usd":0.001}`
+	cmd := fakeCmdScript(t, stream, 0)
+	s, r := setupRunnerWithCmd(t, []string{repo}, cmd)
+	ctx := context.Background()
+
+	task, err := s.CreateTask(ctx, "Rewrite the auth flow", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "Rewrite the auth flow", "", false)
+
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "waiting" {
+		t.Fatalf("status = %q, want waiting", got.Status)
+	}
+	if !got.ReviewRequested || got.HoldReason != store.HoldReviewRequested {
+		t.Errorf("review_requested = %v, hold reason = %q; want true, %q", got.ReviewRequested, got.HoldReason, store.HoldReviewRequested)
+	}
+	if len(got.CommitHashes) != 0 {
+		t.Fatalf("task asking for review was committed: %v", got.CommitHashes)
+	}
+
+	data, err := r.generateBoardContext(task.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var board BoardManifest
+	if err := json.Unmarshal(data, &board); err != nil {
+		t.Fatal(err)
+	}
+	if len(board.Tasks) != 1 || !board.Tasks[0].ReviewRequested {
+		t.Errorf("board.json should flag the review request: %+v", board.Tasks)
+	}
+}
+
+// TestRequestsReview verifies that only a line of its own counts as the
+// review request marker.
+func TestRequestsReview(t *testing.T) {
+	tests := map[string]bool{
+		"done":                              false,
+		"done\nWALLFACER: REVIEW REQUESTED": true,
+		"done\n  WALLFACER: REVIEW REQUESTED  \n":    true,
+		"I did not need WALLFACER: REVIEW REQUESTED": false,
+	}
+	for result, want := range tests {
+		if got := requestsReview(result); got != want {
+			t.Errorf("requestsReview(%q) = %v, want %v", result, got, want)
+		}
+	}
+}
+
+// TestRunMountsTaskInputs verifies that files attached to a task are visible
+// in the container's /workspace/.tasks/inputs directory.
+func TestRunMountsTaskInputs(t *testing.T) {
+	repo := setupTestRepo(t)
+	dir := t.TempDir()
+	seen := filepath.Join(dir, "seen.txt")
+	out := filepath.Join(dir, "out.json")
+	if err := os.WriteFile(out, []byte(endTurnOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The fake runtime copies whatever is mounted at the inputs dir to seen.txt.
+	script := `#!/bin/sh
+[ "$1" = run ] || exit 0
+for a in "$@"; do
+  case "$a" in
+    *:/workspace/.tasks/inputs:z,ro) cat "${a%%:/workspace/.tasks/inputs:z,ro}/spec.md" > ` + seen + ` ;;
+  esac
+done
+cat ` + out + `
+`
+	cmd := filepath.Join(dir, "fake-runtime")
+	if err := os.WriteFile(cmd, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, []string{repo}, cmd)
+	ctx := context.Background()
+
+	task, err := s.CreateTask(ctx, "Implement the spec", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetTaskInputs(ctx, task.ID, map[string][]byte{"spec.md": []byte("# Spec\n")}); err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "Implement the spec", "", false)
+
+	data, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatalf("input dir was not mounted: %v", err)
+	}
+	if string(data) != "# Spec\n" {
+		t.Errorf("spec.md in container = %q, want %q", data, "# Spec\n")
+	}
+
+	// The attachments are cleaned up once the task is done.
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "done" {
+		t.Fatalf("status = %q, want done", got.Status)
+	}
+	if len(got.Inputs) != 0 {
+		t.Errorf("Inputs = %v after the task finished, want none", got.Inputs)
+	}
+	if _, err := os.Stat(s.InputsDir(task.ID)); !os.IsNotExist(err) {
+		t.Errorf("inputs dir still present after the task finished: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// instanceNameRe restricts instance names to what is safe in both a git
+// branch name component and a container name.
+var instanceNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateInstance reports whether name can be used as RunnerConfig.Instance.
+// The empty name (the default, unprefixed instance) is valid.
+func ValidateInstance(name string) error {
+	if name != "" && !instanceNameRe.MatchString(name) {
+		return fmt.Errorf("invalid instance name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// containerPrefix is the prefix of every container this runner starts:
+// "wallfacer-", or "wallfacer-<instance>-" for a named instance.
+func (r *Runner) containerPrefix() string {
+	if r.instance == "" {
+		return "wallfacer-"
+	}
+	return "wallfacer-" + r.instance + "-"
+}
+
+// ContainerName returns the name of the container that runs taskID.
+func (r *Runner) ContainerName(taskID uuid.UUID) string {
+	return r.containerPrefix() + taskID.String()
+}
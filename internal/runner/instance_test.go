@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestInstancesUseSeparateBranches verifies that two instances sharing a repo
+// create distinct task branches even for the same task ID, which would map
+// both to the same "task/<short-id>" branch without the instance prefix.
+func TestInstancesUseSeparateBranches(t *testing.T) {
+	repo := setupTestRepo(t)
+	_, a := setupRunnerWithCmd(t, []string{repo}, "echo")
+	_, b := setupRunnerWithCmd(t, []string{repo}, "echo")
+	a.instance, b.instance = "alpha", "beta"
+
+	taskID := uuid.New()
+	short := taskID.String()[:8]
+	pathsA, branchA, err := a.setupWorktrees(taskID)
+	if err != nil {
+		t.Fatal("alpha setupWorktrees:", err)
+	}
+	t.Cleanup(func() { a.cleanupWorktrees(taskID, pathsA, branchA) })
+	pathsB, branchB, err := b.setupWorktrees(taskID)
+	if err != nil {
+		t.Fatal("beta setupWorktrees:", err)
+	}
+	t.Cleanup(func() { b.cleanupWorktrees(taskID, pathsB, branchB) })
+
+	if branchA != "alpha/task/"+short || branchB != "beta/task/"+short {
+		t.Fatalf("branches = %q, %q; want alpha/task/%s, beta/task/%s", branchA, branchB, short, short)
+	}
+	for _, branch := range []string{branchA, branchB} {
+		if got := gitRun(t, repo, "branch", "--list", branch); !strings.Contains(got, branch) {
+			t.Errorf("branch %q missing from repo", branch)
+		}
+	}
+	if got := gitRun(t, pathsA[repo], "branch", "--show-current"); got != branchA {
+		t.Errorf("alpha worktree on %q, want %q", got, branchA)
+	}
+	if got := gitRun(t, pathsB[repo], "branch", "--show-current"); got != branchB {
+		t.Errorf("beta worktree on %q, want %q", got, branchB)
+	}
+
+	if a.ContainerName(taskID) == b.ContainerName(taskID) {
+		t.Errorf("both instances name the container %q", a.ContainerName(taskID))
+	}
+}
+
+// TestDefaultInstanceNamesUnchanged verifies that a runner without an
+// instance keeps the unprefixed branch and container names.
+func TestDefaultInstanceNamesUnchanged(t *testing.T) {
+	_, r := setupRunnerWithCmd(t, nil, "echo")
+	taskID := uuid.New()
+	if got, want := r.taskBranchName(taskID), "task/"+taskID.String()[:8]; got != want {
+		t.Errorf("branch = %q, want %q", got, want)
+	}
+	if got, want := r.ContainerName(taskID), "wallfacer-"+taskID.String(); got != want {
+		t.Errorf("container = %q, want %q", got, want)
+	}
+}
+
+func TestValidateInstance(t *testing.T) {
+	for _, name := range []string{"", "ci", "team-a", "bot_2"} {
+		if err := ValidateInstance(name); err != nil {
+			t.Errorf("ValidateInstance(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"-x", "a/b", "a..b", "a b", ".hidden"} {
+		if err := ValidateInstance(name); err == nil {
+			t.Errorf("ValidateInstance(%q) = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"changkun.de/wallfacer/internal/store"
+	"changkun.de/wallfacer/internal/util"
+	"github.com/google/uuid"
+)
+
+// LiveChunk is one piece of agent output relayed to live subscribers while a
+// task's container runs.
+type LiveChunk struct {
+	// Type is "text" for assistant text, "tool_use" for a tool call, or
+	// "snapshot" when Text carries the recent text output as a whole: sent
+	// on subscribe and to subscribers that fell behind.
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	Tool  string          `json:"tool,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+const (
+	// liveBuffer is how many chunks a subscriber may have pending before it
+	// counts as slow.
+	liveBuffer = 64
+	// liveSnapshotInterval is how often a slow subscriber is offered a
+	// snapshot in place of the chunks it missed.
+	liveSnapshotInterval = time.Second
+	// liveSnapshotMax bounds the text kept per task for snapshots.
+	liveSnapshotMax = 64 << 10
+)
+
+// liveHub fans the parsed output of running task containers out to
+// subscribers keyed by task ID. Publishing never blocks: a subscriber whose
+// buffer is full stops receiving chunks and instead gets a snapshot of the
+// recent text at most every liveSnapshotInterval until it catches up.
+type liveHub struct {
+	mu     sync.Mutex
+	tasks  map[uuid.UUID]*liveTask
+	nextID int
+}
+
+// liveTask is the live state of one task.
+type liveTask struct {
+	running bool
+	text    []byte // tail of the text output of the current container
+	subs    map[int]*liveSub
+}
+
+// liveSub is one subscriber of a task's live output.
+type liveSub struct {
+	ch      chan LiveChunk
+	lagged  bool
+	lastTry time.Time
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{tasks: make(map[uuid.UUID]*liveTask)}
+}
+
+// task returns the state of id, creating it. The caller holds h.mu.
+func (h *liveHub) task(id uuid.UUID) *liveTask {
+	t := h.tasks[id]
+	if t == nil {
+		t = &liveTask{subs: make(map[int]*liveSub)}
+		h.tasks[id] = t
+	}
+	return t
+}
+
+// start begins a container run of id, discarding the text of earlier runs.
+func (h *liveHub) start(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.task(id)
+	t.running = true
+	t.text = nil
+}
+
+// stop ends the container run of id, dropping its state once nobody is
+// subscribed.
+func (h *liveHub) stop(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t := h.tasks[id]; t != nil {
+		t.running = false
+		if len(t.subs) == 0 {
+			delete(h.tasks, id)
+		}
+	}
+}
+
+// publish delivers c to the subscribers of id. Output of tasks that are not
+// running is ignored.
+func (h *liveHub) publish(id uuid.UUID, c LiveChunk) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.tasks[id]
+	if t == nil || !t.running {
+		return
+	}
+	if c.Type == "text" {
+		t.text = append(t.text, c.Text...)
+		if over := len(t.text) - liveSnapshotMax; over > 0 {
+			t.text = t.text[over:]
+		}
+	}
+	now := time.Now()
+	for _, s := range t.subs {
+		if s.lagged {
+			if now.Sub(s.lastTry) < liveSnapshotInterval {
+				continue
+			}
+			s.lastTry = now
+			select {
+			case s.ch <- LiveChunk{Type: "snapshot", Text: string(t.text)}:
+				s.lagged = false
+			default:
+			}
+			continue
+		}
+		select {
+		case s.ch <- c:
+		default:
+			s.lagged = true
+			s.lastTry = now
+		}
+	}
+}
+
+// subscribe registers a subscriber for id. When the task already produced
+// text, the channel starts with a snapshot of it.
+func (h *liveHub) subscribe(id uuid.UUID) (int, <-chan LiveChunk) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.task(id)
+	h.nextID++
+	s := &liveSub{ch: make(chan LiveChunk, liveBuffer)}
+	if len(t.text) > 0 {
+		s.ch <- LiveChunk{Type: "snapshot", Text: string(t.text)}
+	}
+	t.subs[h.nextID] = s
+	return h.nextID, s.ch
+}
+
+// unsubscribe removes a subscriber registered by subscribe.
+func (h *liveHub) unsubscribe(id uuid.UUID, subID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t := h.tasks[id]; t != nil {
+		delete(t.subs, subID)
+		if len(t.subs) == 0 && !t.running {
+			delete(h.tasks, id)
+		}
+	}
+}
+
+// SubscribeLive returns a channel of the agent output of task id as its
+// container produces it, across all of the task's turns. The channel is
+// never closed; call UnsubscribeLive with the returned ID when done.
+func (r *Runner) SubscribeLive(id uuid.UUID) (int, <-chan LiveChunk) {
+	return r.live.subscribe(id)
+}
+
+// UnsubscribeLive removes a subscription made by SubscribeLive.
+func (r *Runner) UnsubscribeLive(id uuid.UUID, subID int) {
+	r.live.unsubscribe(id, subID)
+}
+
+// liveWriter is an io.Writer on container stdout that parses the NDJSON
+// stream-json lines as they arrive and publishes their text and tool calls.
+// It also collects the tool calls, stamped with their arrival time, for the
+// task's timeline.
+type liveWriter struct {
+	hub       *liveHub
+	taskID    uuid.UUID
+	buf       []byte
+	toolCalls []store.ToolCall
+}
+
+func (w *liveWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		for _, c := range parseLiveChunks(w.buf[:i]) {
+			if c.Type == "tool_use" {
+				w.toolCalls = append(w.toolCalls, store.ToolCall{
+					Tool:  c.Tool,
+					Input: toolInputSummary(c.Input),
+					At:    time.Now(),
+				})
+			}
+			w.hub.publish(w.taskID, c)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// parseLiveChunks extracts the text and tool_use blocks of an assistant
+// message line of Claude Code's stream-json output. Other lines yield none.
+func parseLiveChunks(line []byte) []LiveChunk {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return nil
+	}
+	var msg struct {
+		Type    string `json:"type"`
+		Message struct {
+			Content []struct {
+				Type  string          `json:"type"`
+				Text  string          `json:"text"`
+				Name  string          `json:"name"`
+				Input json.RawMessage `json:"input"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(line, &msg) != nil || msg.Type != "assistant" {
+		return nil
+	}
+	var chunks []LiveChunk
+	for _, b := range msg.Message.Content {
+		switch b.Type {
+		case "text":
+			if b.Text != "" {
+				chunks = append(chunks, LiveChunk{Type: "text", Text: b.Text})
+			}
+		case "tool_use":
+			chunks = append(chunks, LiveChunk{Type: "tool_use", Tool: b.Name, Input: b.Input})
+		}
+	}
+	return chunks
+}
+
+// toolInputKeys are the input fields that best describe a tool call, in order
+// of preference (Bash command, Read/Edit/Write file path, search pattern, …).
+var toolInputKeys = []string{"command", "file_path", "pattern", "query", "url", "path", "description"}
+
+// toolInputSummary returns the main argument of a tool call input, truncated
+// for storage, or "" when none of toolInputKeys is a non-empty string.
+func toolInputSummary(input json.RawMessage) string {
+	var fields map[string]any
+	if json.Unmarshal(input, &fields) != nil {
+		return ""
+	}
+	for _, k := range toolInputKeys {
+		if v, ok := fields[k].(string); ok && v != "" {
+			return util.Truncate(v, 200)
+		}
+	}
+	return ""
+}
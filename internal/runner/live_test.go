@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRunStreamsLiveOutput(t *testing.T) {
+	repo := setupTestRepo(t)
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"sess1"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Looking at the code."}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Done."}]}}`,
+		waitingOutput,
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-cmd")
+	body := "#!/bin/sh\ncase \"$1\" in run) ;; *) exit 0 ;; esac\n"
+	for _, l := range lines {
+		body += fmt.Sprintf("echo '%s'\nsleep 0.05\n", l)
+	}
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, []string{repo}, script)
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "narrate", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+
+	subID, ch := r.SubscribeLive(task.ID)
+	defer r.UnsubscribeLive(task.ID, subID)
+	r.Run(task.ID, "narrate", "", false)
+
+	var got []LiveChunk
+	for len(got) < 3 {
+		select {
+		case c := <-ch:
+			got = append(got, c)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d chunks, want 3: %+v", len(got), got)
+		}
+	}
+	if got[0].Type != "text" || got[0].Text != "Looking at the code." {
+		t.Errorf("chunk 0 = %+v", got[0])
+	}
+	if got[1].Type != "tool_use" || got[1].Tool != "Bash" || string(got[1].Input) != `{"command":"ls"}` {
+		t.Errorf("chunk 1 = %+v", got[1])
+	}
+	if got[2].Type != "text" || got[2].Text != "Done." {
+		t.Errorf("chunk 2 = %+v", got[2])
+	}
+}
+
+func TestLiveHubSlowSubscriberGetsSnapshot(t *testing.T) {
+	h := newLiveHub()
+	id := uuid.New()
+	h.start(id)
+	subID, ch := h.subscribe(id)
+	defer h.unsubscribe(id, subID)
+
+	// Overflow the buffer: publishing must not block.
+	for i := 0; i < liveBuffer+10; i++ {
+		h.publish(id, LiveChunk{Type: "text", Text: "x"})
+	}
+	for i := 0; i < liveBuffer; i++ {
+		if c := <-ch; c.Type != "text" {
+			t.Fatalf("chunk %d = %+v, want text", i, c)
+		}
+	}
+
+	// Within the snapshot interval nothing more is sent.
+	h.publish(id, LiveChunk{Type: "text", Text: "y"})
+	select {
+	case c := <-ch:
+		t.Fatalf("lagged subscriber got %+v before the snapshot interval", c)
+	default:
+	}
+
+	h.mu.Lock()
+	for _, s := range h.tasks[id].subs {
+		s.lastTry = time.Now().Add(-liveSnapshotInterval)
+	}
+	h.mu.Unlock()
+	h.publish(id, LiveChunk{Type: "text", Text: "z"})
+	c := <-ch
+	want := strings.Repeat("x", liveBuffer+10) + "yz"
+	if c.Type != "snapshot" || c.Text != want {
+		t.Fatalf("got %+v, want snapshot of all text", c)
+	}
+
+	// Caught up: deltas resume.
+	h.publish(id, LiveChunk{Type: "text", Text: "w"})
+	if c := <-ch; c.Type != "text" || c.Text != "w" {
+		t.Fatalf("got %+v, want text delta", c)
+	}
+}
+
+func TestRunRecordsToolCallTimeline(t *testing.T) {
+	repo := setupTestRepo(t)
+	lines := []string{
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Read","input":{"file_path":"/workspace/repo/main.go"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","content":"package main"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Fixing."},{"type":"tool_use","name":"Edit","input":{"file_path":"/workspace/repo/main.go","old_string":"a","new_string":"b"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}`,
+		waitingOutput,
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-cmd")
+	body := "#!/bin/sh\ncase \"$1\" in run) ;; *) exit 0 ;; esac\n"
+	for _, l := range lines {
+		body += fmt.Sprintf("echo '%s'\n", l)
+	}
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, []string{repo}, script)
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "use tools", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+
+	before := time.Now()
+	r.Run(task.ID, "use tools", "", false)
+
+	got, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ tool, input string }{
+		{"Read", "/workspace/repo/main.go"},
+		{"Edit", "/workspace/repo/main.go"},
+		{"Bash", "go test ./..."},
+	}
+	if len(got.ToolCalls) != len(want) {
+		t.Fatalf("ToolCalls = %+v, want %d calls", got.ToolCalls, len(want))
+	}
+	for i, w := range want {
+		c := got.ToolCalls[i]
+		if c.Tool != w.tool || c.Input != w.input {
+			t.Errorf("call %d = %s(%q), want %s(%q)", i, c.Tool, c.Input, w.tool, w.input)
+		}
+		if c.At.Before(before) || (i > 0 && c.At.Before(got.ToolCalls[i-1].At)) {
+			t.Errorf("call %d timestamp %v out of order", i, c.At)
+		}
+	}
+}
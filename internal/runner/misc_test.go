@@ -2,9 +2,11 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -26,6 +28,60 @@ func TestRunnerCommand(t *testing.T) {
 	}
 }
 
+// TestCheckRuntimeMissing verifies that a runtime binary absent from PATH
+// yields a descriptive error rather than the raw exec error.
+func TestCheckRuntimeMissing(t *testing.T) {
+	r := newTestRunnerWithInstructions(t, "")
+	r.command = "wallfacer-no-such-runtime"
+	err := r.CheckRuntime()
+	if err == nil {
+		t.Fatal("expected an error for a missing runtime")
+	}
+	want := "container runtime 'wallfacer-no-such-runtime' not found in PATH; install it or set command to docker"
+	if err.Error() != want {
+		t.Fatalf("CheckRuntime() = %q, want %q", err, want)
+	}
+
+	r.command = "echo"
+	if err := r.CheckRuntime(); err != nil {
+		t.Fatalf("CheckRuntime() with an existing binary: %v", err)
+	}
+}
+
+// TestNewRunnerDetectsRuntime verifies that an empty Command selects the
+// first runtime found on PATH, and that CheckRuntime reports when there is
+// none.
+func TestNewRunnerDetectsRuntime(t *testing.T) {
+	bin := t.TempDir()
+	docker := filepath.Join(bin, "docker")
+	if err := os.WriteFile(docker, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	r := NewRunner(s, RunnerConfig{})
+	if r.Command() != docker {
+		t.Fatalf("Command() = %q, want %q", r.Command(), docker)
+	}
+	if err := r.CheckRuntime(); err != nil {
+		t.Fatalf("CheckRuntime: %v", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	r = NewRunner(s, RunnerConfig{})
+	if r.Command() != "" {
+		t.Fatalf("Command() = %q with no runtime on PATH, want empty", r.Command())
+	}
+	if err := r.CheckRuntime(); err == nil || !strings.Contains(err.Error(), "install podman or docker") {
+		t.Fatalf("CheckRuntime() = %v, want a missing-runtime error", err)
+	}
+}
+
 // TestWorkspacesEmpty verifies that Workspaces() returns nil when no
 // workspaces are configured.
 func TestWorkspacesEmpty(t *testing.T) {
@@ -150,6 +206,121 @@ func TestSetupWorktreesIdempotent(t *testing.T) {
 	}
 }
 
+// TestSetupWorktreesRecoversStaleBranch verifies that a task branch left
+// behind without a worktree (e.g. by a crashed run) does not break setup: a
+// branch at the base commit is reused, one cut from an older base is
+// recreated at the current one.
+func TestSetupWorktreesRecoversStaleBranch(t *testing.T) {
+	for _, moved := range []bool{false, true} {
+		repo := setupTestRepo(t)
+		s, runner := setupTestRunner(t, []string{repo})
+		task, _ := s.CreateTask(context.Background(), "p", 5, false)
+
+		branch := runner.taskBranchName(task.ID)
+		gitRun(t, repo, "branch", branch)
+		if moved {
+			// The default branch moved on after the stale branch was cut.
+			gitRun(t, repo, "commit", "--allow-empty", "-m", "newer work")
+		}
+		base := gitRun(t, repo, "rev-parse", "HEAD")
+
+		wt, br, err := runner.setupWorktrees(task.ID)
+		if err != nil {
+			t.Fatalf("moved=%v: setupWorktrees with a stale branch: %v", moved, err)
+		}
+		t.Cleanup(func() { runner.cleanupWorktrees(task.ID, wt, br) })
+		if got := gitRun(t, wt[repo], "branch", "--show-current"); got != branch {
+			t.Errorf("moved=%v: worktree on %q, want %q", moved, got, branch)
+		}
+		if got := gitRun(t, wt[repo], "rev-parse", "HEAD"); got != base {
+			t.Errorf("moved=%v: worktree at %s, want base %s", moved, got, base)
+		}
+	}
+}
+
+// TestSetupWorktreesKeepsCrashedRunWork verifies that a crashed run's branch
+// is judged against the base commit recorded for the task, not the current
+// HEAD: its work is kept even though HEAD moved on, and the recorded base is
+// not replaced by the branch tip.
+func TestSetupWorktreesKeepsCrashedRunWork(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "p", 5, false)
+
+	base := gitRun(t, repo, "rev-parse", "HEAD")
+	branch := runner.taskBranchName(task.ID)
+	gitRun(t, repo, "checkout", "-q", "-b", branch)
+	gitRun(t, repo, "commit", "--allow-empty", "-m", "work from the crashed run")
+	tip := gitRun(t, repo, "rev-parse", "HEAD")
+	gitRun(t, repo, "checkout", "-q", "main")
+	gitRun(t, repo, "commit", "--allow-empty", "-m", "main moved on")
+	if err := s.UpdateTaskBaseCommits(ctx, task.ID, map[string]string{repo: base}); err != nil {
+		t.Fatal(err)
+	}
+
+	wt, br, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, wt, br) })
+	if got := gitRun(t, wt[repo], "rev-parse", "HEAD"); got != tip {
+		t.Errorf("worktree at %s, want the crashed run's tip %s", got, tip)
+	}
+	if got, _ := s.GetTask(ctx, task.ID); got.BaseCommits[repo] != base {
+		t.Errorf("BaseCommits = %s, want %s", got.BaseCommits[repo], base)
+	}
+}
+
+// TestSetupWorktreesBranchPrefixCollision verifies that two tasks whose UUIDs
+// share their first 8 characters get distinct branches and worktrees.
+func TestSetupWorktreesBranchPrefixCollision(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+
+	first, err := s.CreateTask(context.Background(), "first", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Force a collision: same first 8 characters, different remainder.
+	b := []byte(first.ID.String())
+	if b[9] == 'a' {
+		b[9] = 'b'
+	} else {
+		b[9] = 'a'
+	}
+	second := uuid.MustParse(string(b))
+
+	wt1, br1, err := runner.setupWorktrees(first.ID)
+	if err != nil {
+		t.Fatal("setupWorktrees first:", err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(first.ID, wt1, br1) })
+	wt2, br2, err := runner.setupWorktrees(second)
+	if err != nil {
+		t.Fatal("setupWorktrees second:", err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(second, wt2, br2) })
+
+	if br1 == br2 {
+		t.Fatalf("colliding tasks share branch %q", br1)
+	}
+	if got := gitRun(t, wt2[repo], "branch", "--show-current"); got != br2 {
+		t.Errorf("second worktree on %q, want %q", got, br2)
+	}
+}
+
+// TestTaskBranchNameReusesStoredBranch verifies that a task which already has
+// a branch keeps it.
+func TestTaskBranchNameReusesStoredBranch(t *testing.T) {
+	s, runner := setupTestRunner(t, nil)
+	task, _ := s.CreateTask(context.Background(), "p", 5, false)
+	s.UpdateTaskWorktrees(context.Background(), task.ID, map[string]string{}, "task/custom")
+	if got := runner.taskBranchName(task.ID); got != "task/custom" {
+		t.Errorf("taskBranchName = %q, want task/custom", got)
+	}
+}
+
 // TestResolveConflictsSuccess verifies that resolveConflicts returns nil when
 // the container exits successfully with a valid result.
 func TestResolveConflictsSuccess(t *testing.T) {
@@ -399,7 +570,7 @@ func TestRunContainerWithSessionID(t *testing.T) {
 // adds --resume <sessionID> to the container args.
 func TestBuildContainerArgsWithSessionID(t *testing.T) {
 	r := newTestRunnerWithInstructions(t, "")
-	args := r.buildContainerArgs("name", "prompt", "sess-abc", nil, "", nil)
+	args := r.buildContainerArgs("name", "prompt", "sess-abc", nil, "", nil, nil, "", r.instructionsPath, "")
 	if !containsConsecutive(args, "--resume", "sess-abc") {
 		t.Fatalf("expected --resume sess-abc in args; got: %v", args)
 	}
@@ -424,12 +595,94 @@ func TestBuildContainerArgsWithEnvFile(t *testing.T) {
 		SandboxImage: "test:latest",
 		EnvFile:      envFile,
 	})
-	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil)
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
 	if !containsConsecutive(args, "--env-file", envFile) {
 		t.Fatalf("expected --env-file %s in args; got: %v", envFile, args)
 	}
 }
 
+// TestBuildContainerArgsTaskEnv verifies that per-task env vars are passed as
+// -e KEY=VALUE after --env-file so they override the shared file.
+func TestBuildContainerArgsTaskEnv(t *testing.T) {
+	r := newTestRunnerWithInstructions(t, "")
+	r.envFile = "/tmp/.env"
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, map[string]string{"FOO": "bar", "A": "1"}, "", r.instructionsPath, "")
+	if !containsConsecutive(args, "-e", "FOO=bar") || !containsConsecutive(args, "-e", "A=1") {
+		t.Fatalf("expected -e FOO=bar and -e A=1 in args; got: %v", args)
+	}
+	envFileIdx, fooIdx := -1, -1
+	for i, a := range args {
+		switch a {
+		case "--env-file":
+			envFileIdx = i
+		case "FOO=bar":
+			fooIdx = i
+		}
+	}
+	if fooIdx < envFileIdx {
+		t.Fatalf("-e FOO=bar must follow --env-file; got: %v", args)
+	}
+}
+
+// TestBuildContainerArgsScratch verifies that a scratch task mounts only its
+// scratch directory, works inside it, and gets no workspace instructions.
+func TestBuildContainerArgsScratch(t *testing.T) {
+	instructions := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(instructions, []byte("# rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRunnerWithInstructions(t, instructions)
+	r.workspaces = "/repos/app"
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "/data/task/scratch", r.instructionsPath, "")
+
+	if !containsConsecutive(args, "-v", "/data/task/scratch:/workspace/scratch:z") {
+		t.Fatalf("expected scratch mount; got: %v", args)
+	}
+	if !containsConsecutive(args, "-w", "/workspace/scratch") {
+		t.Fatalf("expected workdir /workspace/scratch; got: %v", args)
+	}
+	for _, a := range args {
+		if strings.Contains(a, "/repos/app") || strings.Contains(a, "CLAUDE.md") {
+			t.Fatalf("scratch task should not mount %q; got: %v", a, args)
+		}
+	}
+}
+
+// TestRunContainerUsesTaskEnv verifies that runContainer picks up the env
+// stored on the task.
+func TestRunContainerUsesTaskEnv(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args.txt")
+	script := filepath.Join(dir, "fake-cmd")
+	body := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\necho '%s'\n", argsFile, validStreamJSON)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := runnerWithCmd(t, script)
+	task, _ := r.store.CreateTask(context.Background(), "p", 5, false)
+	r.store.UpdateTaskEnv(context.Background(), task.ID, map[string]string{"FOO": "bar"})
+
+	if _, _, _, err := r.runContainer(context.Background(), task.ID, "prompt", "", nil, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	recorded, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(recorded), "-e FOO=bar") {
+		t.Fatalf("expected -e FOO=bar in container args; got: %s", recorded)
+	}
+}
+
+// TestRedactEnvArgs verifies that env values are masked for logging.
+func TestRedactEnvArgs(t *testing.T) {
+	args := []string{"run", "-e", "TOKEN=secret", "-v", "a:b"}
+	got := strings.Join(redactEnvArgs(args), " ")
+	if strings.Contains(got, "secret") || !strings.Contains(got, "TOKEN=***") {
+		t.Fatalf("redactEnvArgs = %q", got)
+	}
+	if args[2] != "TOKEN=secret" {
+		t.Fatal("redactEnvArgs must not modify its input")
+	}
+}
+
 // TestBuildContainerArgsWorktreeOverride verifies that worktreeOverrides
 // replaces the workspace host path in the volume mount.
 func TestBuildContainerArgsWorktreeOverride(t *testing.T) {
@@ -448,7 +701,7 @@ func TestBuildContainerArgsWorktreeOverride(t *testing.T) {
 		SandboxImage: "test:latest",
 		Workspaces:   ws,
 	})
-	args := r.buildContainerArgs("name", "prompt", "", map[string]string{ws: wt}, "", nil)
+	args := r.buildContainerArgs("name", "prompt", "", map[string]string{ws: wt}, "", nil, nil, "", r.instructionsPath, "")
 	basename := filepath.Base(ws)
 	expectedMount := wt + ":/workspace/" + basename + ":z"
 	if !containsConsecutive(args, "-v", expectedMount) {
@@ -482,7 +735,7 @@ func TestBuildContainerArgsWorktreeGitDirMount(t *testing.T) {
 		SandboxImage: "test:latest",
 		Workspaces:   repo,
 	})
-	args := r.buildContainerArgs("name", "prompt", "", map[string]string{repo: wt}, "", nil)
+	args := r.buildContainerArgs("name", "prompt", "", map[string]string{repo: wt}, "", nil, nil, "", r.instructionsPath, "")
 
 	// The main repo's .git should be mounted at the same host path.
 	gitDir := filepath.Join(repo, ".git")
@@ -510,7 +763,7 @@ func TestBuildContainerArgsNoGitDirMountWithoutWorktree(t *testing.T) {
 		Workspaces:   repo,
 	})
 	// No worktree override — direct mount of workspace.
-	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil)
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
 
 	gitDir := filepath.Join(repo, ".git")
 	gitMount := gitDir + ":" + gitDir + ":z"
@@ -523,7 +776,7 @@ func TestBuildContainerArgsNoGitDirMountWithoutWorktree(t *testing.T) {
 // --resume is NOT added to the args.
 func TestBuildContainerArgsNoSessionID(t *testing.T) {
 	r := newTestRunnerWithInstructions(t, "")
-	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil)
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
 	for i, a := range args {
 		if a == "--resume" {
 			t.Fatalf("--resume should not appear when sessionID is empty (found at index %d)", i)
@@ -829,3 +1082,200 @@ func TestContainerJSONCreatedNil(t *testing.T) {
 		t.Fatalf("expected 0, got %d", c.createdUnix())
 	}
 }
+
+// TestListContainersIgnoresNonUUIDSuffix verifies that only containers whose
+// name suffix is a UUID are mapped to a task.
+func TestListContainersIgnoresNonUUIDSuffix(t *testing.T) {
+	id := uuid.New().String()
+	cmd := fakeCmdScript(t, `[
+		{"Id":"abc123","Names":["wallfacer-`+id+`"],"Image":"img","State":"running","Status":"Up"},
+		{"Id":"def456","Names":["wallfacer-notauuid"],"Image":"img","State":"exited","Status":"Exited"}
+	]`, 0)
+	r := runnerWithCmd(t, cmd)
+
+	containers, err := r.ListContainers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(containers))
+	}
+	if containers[0].TaskID != id {
+		t.Errorf("TaskID = %q, want %q", containers[0].TaskID, id)
+	}
+	if containers[1].TaskID != "" {
+		t.Errorf("TaskID for wallfacer-notauuid = %q, want empty", containers[1].TaskID)
+	}
+}
+
+// TestBuildContainerArgsExtraRunArgs verifies that operator-supplied runtime
+// flags are passed through in order, before the image name.
+func TestBuildContainerArgsExtraRunArgs(t *testing.T) {
+	r := newTestRunnerWithInstructions(t, "")
+	r.extraRunArgs = []string{"--cap-drop=ALL", "--tmpfs", "/tmp"}
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
+
+	image := slices.Index(args, r.sandboxImage)
+	if image < 0 {
+		t.Fatalf("image not found in args: %v", args)
+	}
+	start := slices.Index(args, "--cap-drop=ALL")
+	if start < 0 || start > image || !slices.Equal(args[start:start+3], r.extraRunArgs) {
+		t.Fatalf("expected %v in order before the image; got: %v", r.extraRunArgs, args)
+	}
+}
+
+// TestBuildContainerArgsAgentArgs verifies that agent flags follow the image
+// and wallfacer's own Claude Code flags, and are not mixed into the runtime
+// flags that precede the image.
+func TestBuildContainerArgsAgentArgs(t *testing.T) {
+	r := newTestRunnerWithInstructions(t, "")
+	r.extraRunArgs = []string{"--tmpfs", "/tmp"}
+	r.agentArgs = []string{"--debug", "--mcp-debug"}
+	args := r.buildContainerArgs("name", "prompt", "sess-abc", nil, "", nil, map[string]string{"A": "1"}, "", r.instructionsPath, "")
+
+	image := slices.Index(args, r.sandboxImage)
+	if image < 0 {
+		t.Fatalf("image not found in args: %v", args)
+	}
+	if got := args[len(args)-2:]; !slices.Equal(got, r.agentArgs) {
+		t.Fatalf("expected agent args %v at the end; got: %v", r.agentArgs, args)
+	}
+	if slices.Index(args, "--resume") > len(args)-2 || slices.Index(args, "--output-format") > len(args)-2 {
+		t.Errorf("agent args should follow wallfacer's flags; got: %v", args)
+	}
+	if slices.Contains(args[:image], "--debug") || slices.Contains(args[:image], "--mcp-debug") {
+		t.Errorf("agent args leaked into the runtime flags; got: %v", args)
+	}
+}
+
+// TestBuildContainerArgsHardenedSandbox verifies that hardened mode drops all
+// capabilities, forbids privilege escalation, and applies the seccomp profile.
+func TestBuildContainerArgsHardenedSandbox(t *testing.T) {
+	r := newTestRunnerWithInstructions(t, "")
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
+	if slices.Contains(args, "--cap-drop=ALL") {
+		t.Fatalf("hardening flags should be opt-in; got: %v", args)
+	}
+
+	r.hardenedSandbox = true
+	r.seccompProfile = "/etc/wallfacer/seccomp.json"
+	args = r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
+	image := slices.Index(args, r.sandboxImage)
+	for _, want := range []string{
+		"--cap-drop=ALL",
+		"--security-opt=no-new-privileges",
+		"--security-opt=seccomp=/etc/wallfacer/seccomp.json",
+	} {
+		if i := slices.Index(args, want); i < 0 || i > image {
+			t.Errorf("expected %q before the image; got: %v", want, args)
+		}
+	}
+}
+
+// TestBuildContainerArgsHardenedDefaultSeccomp verifies that hardened mode
+// without a configured profile applies the built-in one.
+func TestBuildContainerArgsHardenedDefaultSeccomp(t *testing.T) {
+	r := newTestRunnerWithInstructions(t, "")
+	r.worktreesDir = t.TempDir()
+	r.hardenedSandbox = true
+	args := r.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", r.instructionsPath, "")
+
+	want := "--security-opt=seccomp=" + filepath.Join(r.worktreesDir, ".seccomp.json")
+	if !slices.Contains(args, want) {
+		t.Fatalf("expected %q; got: %v", want, args)
+	}
+	data, err := os.ReadFile(filepath.Join(r.worktreesDir, ".seccomp.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profile struct {
+		DefaultAction string `json:"defaultAction"`
+		Syscalls      []struct {
+			Names  []string `json:"names"`
+			Action string   `json:"action"`
+		} `json:"syscalls"`
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		t.Fatalf("built-in profile is not valid JSON: %v", err)
+	}
+	if profile.DefaultAction != "SCMP_ACT_ERRNO" {
+		t.Errorf("built-in profile should deny by default; got %q", profile.DefaultAction)
+	}
+	allowed := map[string]bool{}
+	for _, rule := range profile.Syscalls {
+		if rule.Action == "SCMP_ACT_ALLOW" {
+			for _, name := range rule.Names {
+				allowed[name] = true
+			}
+		}
+	}
+	for _, name := range []string{"read", "execve", "clone", "futex", "socket", "epoll_wait"} {
+		if !allowed[name] {
+			t.Errorf("built-in profile should allow %s", name)
+		}
+	}
+	for _, name := range []string{
+		"io_uring_setup", "io_uring_enter", "io_uring_register",
+		"mount", "unshare", "setns", "ptrace", "bpf", "keyctl", "init_module",
+	} {
+		if allowed[name] {
+			t.Errorf("built-in profile should deny %s", name)
+		}
+	}
+}
+
+// TestListContainersWithTasksFlagsOrphans verifies that containers are joined
+// with their task's status and that a running container whose task is done
+// is flagged, while the in_progress task's container and helper containers
+// are not.
+func TestListContainersWithTasksFlagsOrphans(t *testing.T) {
+	dir := t.TempDir()
+	listing := filepath.Join(dir, "ps.json")
+	cmd := filepath.Join(dir, "fake-runtime")
+	if err := os.WriteFile(cmd, []byte("#!/bin/sh\ncat "+listing+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, nil, cmd)
+	ctx := context.Background()
+	done, _ := s.CreateTask(ctx, "finished", 5, false)
+	moveTask(t, s, done.ID, "done")
+	running, _ := s.CreateTask(ctx, "running", 5, false)
+	moveTask(t, s, running.ID, "in_progress")
+	gone := uuid.New()
+
+	ps := `[
+		{"Id":"a1","Names":["wallfacer-` + done.ID.String() + `"],"State":"running"},
+		{"Id":"b2","Names":["wallfacer-` + running.ID.String() + `"],"State":"running"},
+		{"Id":"c3","Names":["wallfacer-` + gone.String() + `"],"State":"running"},
+		{"Id":"d4","Names":["wallfacer-commit-12345678"],"State":"running"},
+		{"Id":"e5","Names":["wallfacer-` + done.ID.String() + `"],"State":"exited"}
+	]`
+	if err := os.WriteFile(listing, []byte(ps), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := r.ListContainersWithTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		status   string
+		orphaned bool
+	}{
+		"a1": {"done", true},
+		"b2": {"in_progress", false},
+		"c3": {TaskStatusUnknown, true},
+		"d4": {TaskStatusUnknown, false},
+		"e5": {"done", false},
+	}
+	if len(containers) != len(want) {
+		t.Fatalf("got %d containers, want %d", len(containers), len(want))
+	}
+	for _, c := range containers {
+		w := want[c.ID]
+		if c.TaskStatus != w.status || c.Orphaned != w.orphaned {
+			t.Errorf("%s (%s): status=%q orphaned=%v, want %q %v", c.ID, c.Name, c.TaskStatus, c.Orphaned, w.status, w.orphaned)
+		}
+	}
+}
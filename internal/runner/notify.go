@@ -0,0 +1,206 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"changkun.de/wallfacer/internal/gitutil"
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"changkun.de/wallfacer/internal/util"
+	"github.com/google/uuid"
+)
+
+// Notification formats accepted by RunnerConfig.NotifyFormat.
+const (
+	NotifyFormatRaw   = "raw"
+	NotifyFormatSlack = "slack"
+)
+
+// notifyStatuses are the statuses that trigger a webhook notification when a
+// task enters them.
+var notifyStatuses = map[string]bool{
+	"done":      true,
+	"failed":    true,
+	"waiting":   true,
+	"cancelled": true,
+}
+
+// notification is the JSON payload posted in the raw format.
+type notification struct {
+	TaskID       uuid.UUID         `json:"task_id"`
+	Title        string            `json:"title"`
+	Status       string            `json:"status"`
+	Result       string            `json:"result,omitempty"`
+	CommitHashes map[string]string `json:"commit_hashes,omitempty"`
+}
+
+// WatchNotifications posts a webhook to the configured NotifyURL every time a
+// task enters done, failed, waiting, or cancelled. It blocks until ctx is
+// cancelled and is a no-op when no URL is configured.
+func (r *Runner) WatchNotifications(ctx context.Context) {
+	if r.notifyURL == "" {
+		return
+	}
+	subID, ch := r.store.Subscribe()
+	defer r.store.Unsubscribe(subID)
+
+	// Seed with current statuses so existing tasks do not fire on startup.
+	last := make(map[uuid.UUID]string)
+	if tasks, err := r.store.ListTasks(ctx, true); err == nil {
+		for _, t := range tasks {
+			last[t.ID] = t.Status
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+		}
+		tasks, err := r.store.ListTasks(ctx, true)
+		if err != nil {
+			continue
+		}
+		for _, t := range tasks {
+			prev, seen := last[t.ID]
+			last[t.ID] = t.Status
+			if (!seen || prev != t.Status) && notifyStatuses[t.Status] {
+				go r.sendNotification(t)
+			}
+		}
+	}
+}
+
+// sendNotification posts a single task notification. Failures are logged.
+func (r *Runner) sendNotification(t store.Task) {
+	body, err := notificationBody(r.notifyFormat, t, commitURLs(t))
+	if err != nil {
+		logger.Runner.Warn("build notification", "task", t.ID, "error", err)
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(r.notifyURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Runner.Warn("send notification", "task", t.ID, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logger.Runner.Warn("send notification", "task", t.ID, "status", resp.StatusCode)
+	}
+}
+
+// commitURLs maps each repo in t.CommitHashes to a web link for its commit,
+// for repos whose origin remote is hosted on a forge that serves
+// <repo>/commit/<hash> (GitHub, GitLab, Gitea, ...).
+func commitURLs(t store.Task) map[string]string {
+	urls := make(map[string]string)
+	for repo, hash := range t.CommitHashes {
+		if u := commitURL(gitutil.RemoteURL(repo), hash); u != "" {
+			urls[repo] = u
+		}
+	}
+	return urls
+}
+
+// commitURL turns a remote URL (https, ssh:// or scp-like git@host:path) into
+// the web URL of hash, or "" for remotes without a web host such as local
+// paths.
+func commitURL(remote, hash string) string {
+	if remote == "" || hash == "" {
+		return ""
+	}
+	if !strings.Contains(remote, "://") {
+		// scp-like syntax: [user@]host:owner/repo.git
+		host, path, ok := strings.Cut(remote, ":")
+		if !ok || strings.Contains(host, "/") {
+			return ""
+		}
+		remote = "ssh://" + host + "/" + path
+	}
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" || u.Scheme == "file" {
+		return ""
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if path == "" {
+		return ""
+	}
+	return "https://" + u.Hostname() + "/" + path + "/commit/" + hash
+}
+
+// notificationBody renders t in the given format ("raw" or "slack"; empty
+// means raw). commitURLs, keyed like t.CommitHashes, links the commits in
+// the slack format.
+func notificationBody(format string, t store.Task, commitURLs map[string]string) ([]byte, error) {
+	switch format {
+	case "", NotifyFormatRaw:
+		n := notification{
+			TaskID:       t.ID,
+			Title:        taskDisplayTitle(t),
+			Status:       t.Status,
+			CommitHashes: t.CommitHashes,
+		}
+		if t.Result != nil {
+			n.Result = *t.Result
+		}
+		return json.Marshal(n)
+	case NotifyFormatSlack:
+		return json.Marshal(map[string]string{"text": slackText(t, commitURLs)})
+	default:
+		return nil, fmt.Errorf("unknown notification format %q", format)
+	}
+}
+
+// slackText summarises a task for a Slack incoming webhook, listing the
+// merged commit of each repo, linked where commitURLs has a URL for it.
+func slackText(t store.Task, commitURLs map[string]string) string {
+	emoji := map[string]string{
+		"done":      ":white_check_mark:",
+		"failed":    ":x:",
+		"waiting":   ":hourglass:",
+		"cancelled": ":no_entry_sign:",
+	}[t.Status]
+	var b strings.Builder
+	if emoji != "" {
+		b.WriteString(emoji + " ")
+	}
+	fmt.Fprintf(&b, "*%s* is %s", taskDisplayTitle(t), t.Status)
+
+	repos := make([]string, 0, len(t.CommitHashes))
+	for repo := range t.CommitHashes {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		hash := t.CommitHashes[repo]
+		short := hash
+		if len(short) > 8 {
+			short = short[:8]
+		}
+		if u := commitURLs[repo]; u != "" {
+			fmt.Fprintf(&b, "\n<%s|%s> %s", u, short, filepath.Base(repo))
+		} else {
+			fmt.Fprintf(&b, "\n`%s` %s", hash, filepath.Base(repo))
+		}
+	}
+	return b.String()
+}
+
+// taskDisplayTitle returns the task title, falling back to a truncated prompt.
+func taskDisplayTitle(t store.Task) string {
+	if t.Title != "" {
+		return t.Title
+	}
+	return util.Truncate(t.Prompt, 60)
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// TestNotificationBodySlack verifies that the slack format produces a single
+// text field that mentions the task title and status.
+func TestNotificationBodySlack(t *testing.T) {
+	task := store.Task{
+		ID:           uuid.New(),
+		Title:        "Add login page",
+		Status:       "done",
+		CommitHashes: map[string]string{"/repos/web": "0123456789abcdef"},
+	}
+	body, err := notificationBody(NotifyFormatSlack, task, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg map[string]string
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) != 1 {
+		t.Fatalf("expected only a text field, got %v", msg)
+	}
+	for _, want := range []string{"Add login page", "done", ":white_check_mark:", "`0123456789abcdef`", "web"} {
+		if !strings.Contains(msg["text"], want) {
+			t.Errorf("text %q should contain %q", msg["text"], want)
+		}
+	}
+
+	urls := map[string]string{"/repos/web": "https://github.com/acme/web/commit/0123456789abcdef"}
+	if text := slackText(task, urls); !strings.Contains(text, "<https://github.com/acme/web/commit/0123456789abcdef|01234567> web") {
+		t.Errorf("text %q should link the commit", text)
+	}
+}
+
+func TestCommitURL(t *testing.T) {
+	const hash = "0123abcd"
+	for _, tc := range []struct{ remote, want string }{
+		{"https://github.com/acme/web.git", "https://github.com/acme/web/commit/0123abcd"},
+		{"https://token@gitlab.example.com/group/sub/web", "https://gitlab.example.com/group/sub/web/commit/0123abcd"},
+		{"git@github.com:acme/web.git", "https://github.com/acme/web/commit/0123abcd"},
+		{"ssh://git@github.com:22/acme/web.git", "https://github.com/acme/web/commit/0123abcd"},
+		{"/srv/git/web.git", ""},
+		{"file:///srv/git/web.git", ""},
+		{"", ""},
+	} {
+		if got := commitURL(tc.remote, hash); got != tc.want {
+			t.Errorf("commitURL(%q) = %q, want %q", tc.remote, got, tc.want)
+		}
+	}
+}
+
+// TestNotificationBodyRaw verifies the default JSON task summary.
+func TestNotificationBodyRaw(t *testing.T) {
+	result := "all good"
+	task := store.Task{ID: uuid.New(), Prompt: "fix the bug", Status: "failed", Result: &result}
+	body, err := notificationBody("", task, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.TaskID != task.ID || n.Status != "failed" || n.Title != "fix the bug" || n.Result != "all good" {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+}
+
+func TestNotificationBodyUnknownFormat(t *testing.T) {
+	if _, err := notificationBody("xml", store.Task{}, nil); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+// TestWatchNotificationsPostsOnTerminalStatus verifies that a status change to
+// done delivers a webhook to NotifyURL.
+func TestWatchNotificationsPostsOnTerminalStatus(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		select {
+		case got <- string(b):
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	s, runner := setupTestRunner(t, nil)
+	runner.notifyURL = srv.URL
+	runner.notifyFormat = NotifyFormatSlack
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runner.WatchNotifications(ctx)
+
+	// A task finished before the watcher seeded its view is not reported, so
+	// finish one task after another until the server receives a webhook.
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(5 * time.Second)
+	for {
+		task, _ := s.CreateTask(ctx, "Ship it", 5, false)
+		moveTask(t, s, task.ID, "done")
+		select {
+		case body := <-got:
+			if !strings.Contains(body, "Ship it") || !strings.Contains(body, "done") {
+				t.Errorf("unexpected webhook body: %s", body)
+			}
+			return
+		case <-tick.C:
+		case <-timeout:
+			t.Fatal("no webhook received")
+		}
+	}
+}
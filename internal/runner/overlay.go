@@ -0,0 +1,226 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+)
+
+// Read-only workspace mode (RunnerConfig.ReadOnlyWorkspace) mounts each task
+// worktree as a Podman overlay: the worktree is the read-only lower layer and
+// everything the container writes lands in an upper layer on the host. The
+// worktree stays untouched until the writes are promoted, which happens when
+// the task is marked done and the commit pipeline runs.
+
+// overlayDir returns the directory holding the overlay layers of a worktree:
+// .overlay/<name> next to it inside the task's worktree directory, so
+// worktree cleanup removes the layers too. Podman needs both the upper
+// layer and its work directory to exist before the container starts.
+func overlayDir(worktreePath string) string {
+	return filepath.Join(filepath.Dir(worktreePath), ".overlay", filepath.Base(worktreePath))
+}
+
+// overlayVolume returns the -v value mounting hostPath at containerPath as a
+// Podman overlay whose upper layer lives under layerDir.
+func overlayVolume(hostPath, containerPath, layerDir string) string {
+	return mountPath(hostPath) + ":" + containerPath +
+		":O,upperdir=" + mountPath(filepath.Join(layerDir, "upper")) +
+		",workdir=" + mountPath(filepath.Join(layerDir, "work"))
+}
+
+// prepareOverlays creates the layer directories for every worktree in
+// worktreePaths: one pair for the worktree itself and one for the main
+// repository's .git directory, which git worktrees write their commits to.
+func prepareOverlays(worktreePaths map[string]string) error {
+	for _, wt := range worktreePaths {
+		dir := overlayDir(wt)
+		for _, sub := range []string{"upper", "work", "git/upper", "git/work"} {
+			if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isWhiteout reports whether the upper-layer entry name/info records a
+// deletion. Kernel overlayfs uses character devices; fuse-overlayfs, which
+// rootless Podman may use, uses .wh.<name> files. It returns the name of
+// the deleted entry.
+func isWhiteout(name string, info fs.FileInfo) (string, bool) {
+	if info.Mode()&fs.ModeCharDevice != 0 {
+		return name, true
+	}
+	if strings.HasPrefix(name, ".wh.") && name != opaqueMarker {
+		return strings.TrimPrefix(name, ".wh."), true
+	}
+	return "", false
+}
+
+// opaqueMarker is the fuse-overlayfs marker of a directory that replaces,
+// rather than merges with, its lower-layer counterpart.
+const opaqueMarker = ".wh..wh..opq"
+
+// walkUpper calls fn for every entry of the upper layer of worktreePath,
+// skipping the .git entry of shallow clones and snapshots: only file changes
+// are promoted, and the commit pipeline records them in a fresh commit.
+func walkUpper(worktreePath string, fn func(rel string, info fs.FileInfo) error) error {
+	upper := filepath.Join(overlayDir(worktreePath), "upper")
+	if _, err := os.Stat(upper); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(upper, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upper, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if rel == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(rel, info)
+	})
+}
+
+// promoteOverlay applies the writes a read-only-workspace task made in its
+// upper layer to the worktree, then discards the layers, including any git
+// history the container created in its .git layer. Directories made opaque
+// by kernel overlayfs (an xattr this code does not read) are merged with
+// their worktree counterpart instead of replacing it.
+func promoteOverlay(worktreePath string) error {
+	upper := filepath.Join(overlayDir(worktreePath), "upper")
+	err := walkUpper(worktreePath, func(rel string, info fs.FileInfo) error {
+		src := filepath.Join(upper, rel)
+		target := filepath.Join(worktreePath, rel)
+		name := filepath.Base(rel)
+		if name == opaqueMarker {
+			return clearOpaqueDir(filepath.Dir(src), filepath.Dir(target))
+		}
+		if deleted, ok := isWhiteout(name, info); ok {
+			return os.RemoveAll(filepath.Join(filepath.Dir(target), deleted))
+		}
+		// A path that changed type (file ↔ directory) must go first.
+		if cur, err := os.Lstat(target); err == nil && cur.Mode().Type() != info.Mode().Type() {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			return os.Chmod(target, info.Mode().Perm()|0200)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(src)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(src, target, info)
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(overlayDir(worktreePath))
+}
+
+// clearOpaqueDir removes the entries of target that the opaque upper-layer
+// directory src does not also contain.
+func clearOpaqueDir(src, target string) error {
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if _, err := os.Lstat(filepath.Join(src, e.Name())); os.IsNotExist(err) {
+			if err := os.RemoveAll(filepath.Join(target, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OverlayDiff returns a unified diff of the writes held in the overlay of
+// worktreePath that have not been promoted yet, or nil when there are none.
+// Paths in the diff are relative to the worktree.
+func OverlayDiff(ctx context.Context, worktreePath string) []byte {
+	upper := filepath.Join(overlayDir(worktreePath), "upper")
+	var out bytes.Buffer
+	diff := func(a, b string) {
+		// Exit status 1 just means the files differ.
+		d, _ := gitutil.Command(ctx, "diff", "--no-index", "--no-color", "--", a, b).Output()
+		out.Write(relabelDiff(d, worktreePath, upper))
+	}
+	walkUpper(worktreePath, func(rel string, info fs.FileInfo) error {
+		name := filepath.Base(rel)
+		if name == opaqueMarker {
+			return nil
+		}
+		if deleted, ok := isWhiteout(name, info); ok {
+			target := filepath.Join(worktreePath, filepath.Dir(rel), deleted)
+			if t, err := os.Lstat(target); err == nil && t.Mode().IsRegular() {
+				diff(target, "/dev/null")
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		a := filepath.Join(worktreePath, rel)
+		if _, err := os.Lstat(a); err != nil {
+			a = "/dev/null"
+		}
+		diff(a, filepath.Join(upper, rel))
+		return nil
+	})
+	if out.Len() == 0 {
+		return nil
+	}
+	return out.Bytes()
+}
+
+// relabelDiff strips the worktree and upper-layer directories from the file
+// names in the header lines of a `git diff --no-index` output, leaving the
+// content lines alone.
+func relabelDiff(d []byte, worktreePath, upper string) []byte {
+	wt := strings.TrimPrefix(filepath.ToSlash(worktreePath), "/") + "/"
+	up := strings.TrimPrefix(filepath.ToSlash(upper), "/") + "/"
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(d))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "diff --git ") || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") {
+			line = strings.ReplaceAll(line, up, "")
+			line = strings.ReplaceAll(line, wt, "")
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
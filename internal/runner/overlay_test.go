@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"changkun.de/wallfacer/internal/store"
+)
+
+// fakeOverlayRuntime returns a fake container runtime that behaves like a
+// Podman overlay mount for `run`: it writes file into the upper layer named
+// by the first upperdir= option (the workspace mount) and prints output.
+func fakeOverlayRuntime(t *testing.T, output, file string) string {
+	t.Helper()
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(dataPath, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in run) ;; *) exit 0 ;; esac
+for a in "$@"; do
+  case "$a" in
+  *upperdir=*)
+    upper=$(echo "$a" | sed 's/.*upperdir=\([^,]*\).*/\1/')
+    echo from-task > "$upper/%s"
+    break ;;
+  esac
+done
+cat %s
+`, file, dataPath)
+	path := filepath.Join(dir, "fake-runtime")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestReadOnlyWorkspaceKeepsWritesUntilPromoted verifies that in read-only
+// workspace mode a task's writes stay in the overlay — reaching neither the
+// worktree nor the workspace — until marking the task done promotes them.
+func TestReadOnlyWorkspaceKeepsWritesUntilPromoted(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeOverlayRuntime(t, endTurnOutput, "new.txt"))
+	r.readOnlyWorkspace = true
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "explore", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "explore", "", false)
+
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.Status != "waiting" {
+		t.Fatalf("status = %q, want waiting (no auto-commit in read-only mode)", got.Status)
+	}
+	if got.HoldReason != store.HoldReadOnly {
+		t.Errorf("hold reason = %q, want %q", got.HoldReason, store.HoldReadOnly)
+	}
+	wt := got.WorktreePaths[repo]
+	for _, dir := range []string{repo, wt} {
+		if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+			t.Fatalf("new.txt reached %s before promotion", dir)
+		}
+	}
+	if diff := string(OverlayDiff(ctx, wt)); !strings.Contains(diff, "+++ b/new.txt") || !strings.Contains(diff, "+from-task") {
+		t.Errorf("overlay diff missing the new file:\n%s", diff)
+	}
+
+	moveTask(t, s, task.ID, "committing")
+	if err := r.Commit(task.ID, "sess1"); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo, "new.txt"))
+	if err != nil {
+		t.Fatalf("new.txt not merged after promotion: %v", err)
+	}
+	if string(data) != "from-task\n" {
+		t.Errorf("new.txt = %q", data)
+	}
+}
+
+// TestContainerArgsReadOnlyWorkspace verifies that both the worktree and the
+// main repository's .git directory are mounted as overlays, so neither can be
+// written through.
+func TestContainerArgsReadOnlyWorkspace(t *testing.T) {
+	repo := setupTestRepo(t)
+	_, r := setupRunnerWithCmd(t, []string{repo}, "true")
+	r.readOnlyWorkspace = true
+	wt := filepath.Join(r.worktreesDir, "task", "repo")
+
+	args := r.buildContainerArgs("name", "prompt", "", map[string]string{repo: wt}, "", nil, nil, "", "", "")
+	var overlays int
+	for i, a := range args {
+		if a != "-v" || i+1 >= len(args) {
+			continue
+		}
+		mount := args[i+1]
+		if strings.HasPrefix(mount, wt+":") || strings.HasPrefix(mount, filepath.Join(repo, ".git")+":") {
+			if !strings.Contains(mount, ":O,upperdir="+overlayDir(wt)) {
+				t.Errorf("mount %q is not an overlay under %s", mount, overlayDir(wt))
+			}
+			overlays++
+		}
+	}
+	if overlays != 2 {
+		t.Errorf("found %d worktree/.git mounts, want 2: %v", overlays, args)
+	}
+}
+
+// TestPromoteOverlay verifies that modified files, new directories, and
+// both kinds of fuse-overlayfs whiteouts are applied to the worktree.
+func TestPromoteOverlay(t *testing.T) {
+	wt := filepath.Join(t.TempDir(), "repo")
+	for path, content := range map[string]string{
+		"keep.txt":         "old\n",
+		"gone.txt":         "bye\n",
+		"opaque/stale.txt": "stale\n",
+		"opaque/kept.txt":  "kept\n",
+		".git/HEAD":        "ref: refs/heads/main\n",
+	} {
+		writeTestFile(t, filepath.Join(wt, path), content)
+	}
+	upper := filepath.Join(overlayDir(wt), "upper")
+	for path, content := range map[string]string{
+		"keep.txt":            "new\n",
+		"dir/added.txt":       "added\n",
+		".wh.gone.txt":        "",
+		"opaque/.wh..wh..opq": "",
+		"opaque/kept.txt":     "rewritten\n",
+		".git/HEAD":           "ref: refs/heads/other\n",
+	} {
+		writeTestFile(t, filepath.Join(upper, path), content)
+	}
+
+	if err := promoteOverlay(wt); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"keep.txt":        "new\n",
+		"dir/added.txt":   "added\n",
+		"opaque/kept.txt": "rewritten\n",
+		".git/HEAD":       "ref: refs/heads/main\n",
+	}
+	for path, content := range want {
+		data, err := os.ReadFile(filepath.Join(wt, path))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v; want %q", path, data, err, content)
+		}
+	}
+	for _, path := range []string{"gone.txt", "opaque/stale.txt", ".wh.gone.txt"} {
+		if _, err := os.Lstat(filepath.Join(wt, path)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after promotion", path)
+		}
+	}
+	if _, err := os.Stat(overlayDir(wt)); !os.IsNotExist(err) {
+		t.Error("overlay layers not removed after promotion")
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// ErrMergeCancelled is returned by the commit pipeline when the task was
+// cancelled (CancelPreMerge) during the pre-merge delay. Nothing has been
+// merged; the canceller settles the task's status.
+var ErrMergeCancelled = errors.New("task cancelled before merge")
+
+// waitPreMerge blocks for the configured PreMergeDelay, during which
+// CancelPreMerge can still abort the task's merge. It returns
+// ErrMergeCancelled when that happens or the task was already cancelled, ctx's error when ctx ends first, and
+// nil once the delay has passed and the merge may go ahead.
+func (r *Runner) waitPreMerge(ctx context.Context, taskID uuid.UUID) error {
+	if r.preMergeDelay <= 0 {
+		return nil
+	}
+	cancelled := make(chan struct{})
+	r.preMerge.Store(taskID, cancelled)
+	// A cancel that came before the entry existed, e.g. during Phase 1,
+	// found nothing to abort; it must still stop the merge.
+	if task, err := r.store.GetTask(ctx, taskID); err == nil && task.Status == "cancelled" {
+		r.preMerge.Delete(taskID)
+		logger.Runner.Info("merge cancelled before pre-merge delay", "task", taskID)
+		return ErrMergeCancelled
+	}
+	r.store.InsertEvent(context.Background(), taskID, store.EventTypeSystem, map[string]string{
+		"result": fmt.Sprintf("Merging in %s. Cancel the task now to abort without merging.", r.preMergeDelay),
+	})
+
+	timer := time.NewTimer(r.preMergeDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-cancelled:
+	}
+	// Whoever removes the entry first decides: a cancel racing the end of
+	// the delay either finds it gone and is refused, or wins here.
+	if _, ok := r.preMerge.LoadAndDelete(taskID); !ok {
+		logger.Runner.Info("merge cancelled during pre-merge delay", "task", taskID)
+		return ErrMergeCancelled
+	}
+	return ctx.Err()
+}
+
+// CancelPreMerge aborts the merge of a task that is in its pre-merge delay
+// and reports whether it was. Once it returns true the commit pipeline stops
+// with ErrMergeCancelled without merging, and the caller is expected to move
+// the task to cancelled. It returns false when the task is not waiting to
+// merge, including when the merge has already started.
+func (r *Runner) CancelPreMerge(taskID uuid.UUID) bool {
+	v, ok := r.preMerge.LoadAndDelete(taskID)
+	if !ok {
+		return false
+	}
+	close(v.(chan struct{}))
+	return true
+}
@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// startDelayedMerge starts Run for a task whose container adds a file to its
+// worktree, under a runner with a pre-merge delay. It returns once the
+// pipeline is in the delay, with a channel closed when Run returns.
+func startDelayedMerge(t *testing.T, delay time.Duration) (*store.Store, *Runner, uuid.UUID, string, <-chan struct{}) {
+	t.Helper()
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, "")
+	r.preMergeDelay = delay
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "add a file", 5, false)
+
+	wt := filepath.Join(r.worktreesDir, task.ID.String(), filepath.Base(repo))
+	out := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(out, []byte(endTurnOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "fake-cmd")
+	body := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in run) ;; *) exit 0 ;; esac\n[ -d %q ] && echo new > %q\ncat %q\n",
+		wt, filepath.Join(wt, "new.txt"), out)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.command = script
+
+	moveTask(t, s, task.ID, "in_progress")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(task.ID, "add a file", "", false)
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, ok := r.preMerge.Load(task.ID); ok {
+			break
+		}
+		select {
+		case <-done:
+			t.Fatal("Run finished without entering the pre-merge delay")
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pre-merge delay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return s, r, task.ID, repo, done
+}
+
+// TestCancelDuringPreMergeDelaySkipsMerge verifies that cancelling a task
+// while its merge is delayed leaves the default branch untouched.
+func TestCancelDuringPreMergeDelaySkipsMerge(t *testing.T) {
+	s, r, id, repo, done := startDelayedMerge(t, time.Minute)
+	before := gitRun(t, repo, "rev-parse", "main")
+
+	if !r.CancelPreMerge(id) {
+		t.Fatal("CancelPreMerge = false during the delay")
+	}
+	if err := s.UpdateTaskStatus(context.Background(), id, "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not stop after the cancel")
+	}
+
+	if after := gitRun(t, repo, "rev-parse", "main"); after != before {
+		t.Errorf("main moved from %s to %s after a cancelled merge", strings.TrimSpace(before), strings.TrimSpace(after))
+	}
+	task, _ := s.GetTask(context.Background(), id)
+	if task.Status != "cancelled" {
+		t.Errorf("status = %q, want cancelled", task.Status)
+	}
+	if r.CancelPreMerge(id) {
+		t.Error("CancelPreMerge = true after the pipeline stopped")
+	}
+}
+
+// TestPreMergeDelayThenMerges verifies that without a cancel the task is
+// merged once the delay has passed.
+func TestPreMergeDelayThenMerges(t *testing.T) {
+	s, _, id, repo, done := startDelayedMerge(t, 100*time.Millisecond)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not finish after the delay")
+	}
+	task, _ := s.GetTask(context.Background(), id)
+	if task.Status != "done" {
+		t.Fatalf("status = %q, want done", task.Status)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "new.txt")); err != nil {
+		t.Errorf("change not merged after the delay: %v", err)
+	}
+}
+
+// TestCancelDuringPhase1SkipsMerge verifies that a task cancelled while its
+// Phase 1 commit runs, before the pre-merge delay has begun, is not merged
+// once the pipeline reaches the delay.
+func TestCancelDuringPhase1SkipsMerge(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, "")
+	r.preMergeDelay = time.Minute
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "add a file", 5, false)
+
+	wt := filepath.Join(r.worktreesDir, task.ID.String(), filepath.Base(repo))
+	out := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(out, []byte(endTurnOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The commit-message container marks that Phase 1 is running and holds
+	// it there for a moment.
+	inPhase1 := filepath.Join(t.TempDir(), "phase1")
+	script := filepath.Join(t.TempDir(), "fake-cmd")
+	body := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in run) ;; *) exit 0 ;; esac\n"+
+		"case \"$*\" in *commit-*) touch %q; sleep 1; exit 0 ;; esac\n"+
+		"[ -d %q ] && echo new > %q\ncat %q\n",
+		inPhase1, wt, filepath.Join(wt, "new.txt"), out)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.command = script
+	before := gitRun(t, repo, "rev-parse", "main")
+
+	moveTask(t, s, task.ID, "in_progress")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(task.ID, "add a file", "", false)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(inPhase1); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for Phase 1")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// What CancelTask does for an in_progress task.
+	if r.CancelPreMerge(task.ID) {
+		t.Fatal("CancelPreMerge = true before the pre-merge delay")
+	}
+	if err := s.UpdateTaskStatus(ctx, task.ID, "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not stop after the cancel")
+	}
+
+	if after := gitRun(t, repo, "rev-parse", "main"); after != before {
+		t.Errorf("main moved from %s to %s after a cancel during Phase 1", before, after)
+	}
+	if got, _ := s.GetTask(ctx, task.ID); got.Status != "cancelled" {
+		t.Errorf("status = %q, want cancelled", got.Status)
+	}
+}
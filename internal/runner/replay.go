@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+	"changkun.de/wallfacer/internal/logger"
+	"github.com/google/uuid"
+)
+
+// recordLaunchContext saves the prompt, CLAUDE.md, and board.json a container
+// launch of taskID is about to see, so Replay can later hand a run exactly
+// the same context, and records the SHA-256 of that CLAUDE.md on the task.
+func (r *Runner) recordLaunchContext(taskID uuid.UUID, prompt, instructionsPath, boardDir string, scratch bool) {
+	files := map[string][]byte{"prompt.txt": []byte(prompt)}
+	var instructionsHash string
+	if instructionsPath != "" && !scratch {
+		if data, err := os.ReadFile(instructionsPath); err == nil {
+			files["CLAUDE.md"] = data
+			sum := sha256.Sum256(data)
+			instructionsHash = hex.EncodeToString(sum[:])
+		}
+	}
+	if err := r.store.SetTaskInstructionsHash(context.Background(), taskID, instructionsHash); err != nil {
+		logger.Runner.Warn("record instructions hash", "task", taskID, "error", err)
+	}
+	if boardDir != "" {
+		if data, err := os.ReadFile(filepath.Join(boardDir, "board.json")); err == nil {
+			files["board.json"] = data
+		}
+	}
+	if _, err := r.store.SaveLaunchContext(taskID, files); err != nil {
+		logger.Runner.Warn("record launch context", "task", taskID, "error", err)
+	}
+}
+
+// Replay re-runs the first container launch of a task with the context
+// recorded for it: the same prompt, CLAUDE.md, and board.json, none of which
+// is regenerated. Each workspace is replaced by an ephemeral copy reset to
+// the commit the task's worktree started from, so the code matches too. As
+// with RunOnce, the run uses a fresh session, touches neither the workspaces
+// nor the task, and the result carries the diff of what it changed. Per-task
+// env vars are not part of the recorded context and are not applied.
+func (r *Runner) Replay(ctx context.Context, taskID uuid.UUID) (RunResult, error) {
+	task, err := r.store.GetTask(ctx, taskID)
+	if err != nil {
+		return RunResult{}, err
+	}
+	if task.Scratch {
+		return RunResult{}, fmt.Errorf("task %s is a scratch task; only workspace tasks can be replayed", taskID)
+	}
+	files, err := r.store.LaunchContext(taskID, 1)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	tmp, err := os.MkdirTemp("", "wallfacer-replay-")
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	once := *r
+	once.instructionsPath = ""
+	if data, ok := files["CLAUDE.md"]; ok {
+		once.instructionsPath = filepath.Join(tmp, "CLAUDE.md")
+		if err := os.WriteFile(once.instructionsPath, data, 0644); err != nil {
+			return RunResult{}, err
+		}
+	}
+	var boardDir string
+	if data, ok := files["board.json"]; ok {
+		boardDir = filepath.Join(tmp, "board")
+		if err := os.MkdirAll(boardDir, 0755); err != nil {
+			return RunResult{}, err
+		}
+		if err := os.WriteFile(filepath.Join(boardDir, "board.json"), data, 0644); err != nil {
+			return RunResult{}, err
+		}
+	}
+
+	id := uuid.New()
+	overrides := make(map[string]string)
+	bases := make(map[string]string)
+	for _, ws := range r.Workspaces() {
+		path := filepath.Join(tmp, "workspaces", filepath.Base(ws))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return RunResult{}, err
+		}
+		base, cleanup, err := r.ephemeralCopy(ws, path, id)
+		if err != nil {
+			return RunResult{}, err
+		}
+		defer cleanup()
+		if start := task.BaseCommits[ws]; start != "" && gitutil.IsGitRepo(ctx, ws) {
+			if out, err := gitutil.Command(ctx, "-C", path, "reset", "--hard", start).CombinedOutput(); err != nil {
+				return RunResult{}, fmt.Errorf("reset %s to %s: %w\n%s", ws, start, err, out)
+			}
+			base = start
+		}
+		overrides[ws] = path
+		bases[ws] = base
+	}
+
+	output, _, _, err := once.runContainer(ctx, id, string(files["prompt.txt"]), "", overrides, boardDir, nil)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	var diff strings.Builder
+	for _, ws := range r.Workspaces() {
+		d, err := stagedDiff(overrides[ws], bases[ws])
+		if err != nil {
+			return RunResult{}, err
+		}
+		if d != "" && len(overrides) > 1 {
+			fmt.Fprintf(&diff, "=== %s ===\n", filepath.Base(ws))
+		}
+		diff.WriteString(d)
+	}
+	return newRunResult(output, diff.String()), nil
+}
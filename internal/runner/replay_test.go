@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeBoardCapturingRuntime returns a fake container runtime whose `run`
+// copies the mounted board.json to capture/board-<n>.json, n counting runs
+// from 0, and prints output.
+func fakeBoardCapturingRuntime(t *testing.T, output string) (cmd, capture string) {
+	t.Helper()
+	dir := t.TempDir()
+	capture = filepath.Join(dir, "capture")
+	if err := os.MkdirAll(capture, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dataPath := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(dataPath, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in run) ;; *) exit 0 ;; esac
+n=$(ls %[1]s | wc -l | tr -d ' ')
+for a in "$@"; do
+  case "$a" in
+  *:/workspace/.tasks:z,ro) cp "${a%%%%:*}/board.json" %[1]s/board-$n.json ;;
+  esac
+done
+cat %[2]s
+`, capture, dataPath)
+	cmd = filepath.Join(dir, "fake-runtime")
+	if err := os.WriteFile(cmd, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return cmd, capture
+}
+
+// TestReplayReusesRecordedBoard verifies that Replay hands the container the
+// board.json recorded for the task's first launch rather than a freshly
+// generated one, even after the board changed.
+func TestReplayReusesRecordedBoard(t *testing.T) {
+	repo := setupTestRepo(t)
+	cmd, capture := fakeBoardCapturingRuntime(t, waitingOutput)
+	s, r := setupRunnerWithCmd(t, []string{repo}, cmd)
+	ctx := context.Background()
+
+	task, _ := s.CreateTask(ctx, "original task", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "original task", "", false)
+
+	recorded, err := s.LaunchContext(task.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(recorded["prompt.txt"]); got != "original task" {
+		t.Errorf("recorded prompt = %q", got)
+	}
+	if !bytes.Contains(recorded["board.json"], []byte("original task")) {
+		t.Fatalf("recorded board.json does not list the task:\n%s", recorded["board.json"])
+	}
+
+	// Change the board: a regenerated board.json would now list this task.
+	if _, err := s.CreateTask(ctx, "task added later", 5, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Replay(ctx, task.ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	replayed, err := os.ReadFile(filepath.Join(capture, "board-1.json"))
+	if err != nil {
+		t.Fatalf("replay did not mount a board: %v", err)
+	}
+	if !bytes.Equal(replayed, recorded["board.json"]) {
+		t.Errorf("replayed board.json differs from the recorded one:\n%s", replayed)
+	}
+	if strings.Contains(string(replayed), "task added later") {
+		t.Error("replay regenerated board.json")
+	}
+}
+
+// TestRunRecordsInstructionsHash verifies that each task records the hash of
+// the CLAUDE.md it was launched with, so tasks run under different
+// instructions can be told apart, also from board.json.
+func TestRunRecordsInstructionsHash(t *testing.T) {
+	repo := setupTestRepo(t)
+	cmd := fakeCmdScript(t, endTurnOutput, 0)
+	s, r := setupRunnerWithCmd(t, []string{repo}, cmd)
+	r.instructionsPath = filepath.Join(t.TempDir(), "CLAUDE.md")
+	ctx := context.Background()
+
+	var hashes []string
+	for _, content := range []string{"# Rules v1\n", "# Rules v2\n"} {
+		if err := os.WriteFile(r.instructionsPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		task, _ := s.CreateTask(ctx, "task", 5, false)
+		moveTask(t, s, task.ID, "in_progress")
+		r.Run(task.ID, "task", "", false)
+		got, _ := s.GetTask(ctx, task.ID)
+		if len(got.InstructionsHash) != 64 {
+			t.Fatalf("instructions hash = %q, want a hex SHA-256", got.InstructionsHash)
+		}
+		hashes = append(hashes, got.InstructionsHash)
+	}
+	if hashes[0] == hashes[1] {
+		t.Errorf("tasks run with different instructions recorded the same hash %s", hashes[0])
+	}
+
+	data, err := r.generateBoardContext(uuid.Nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest BoardManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for i, bt := range manifest.Tasks {
+		if bt.InstructionsHash != hashes[i] {
+			t.Errorf("board.json task %d instructions_hash = %q, want %q", i, bt.InstructionsHash, hashes[i])
+		}
+	}
+}
@@ -1,21 +1,26 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/store"
+	"changkun.de/wallfacer/internal/util"
 	"github.com/google/uuid"
 )
 
 // ContainerInfo represents a single sandbox container returned by ListContainers.
 type ContainerInfo struct {
 	ID        string `json:"id"`         // short container ID
-	Name      string `json:"name"`       // full container name (e.g. wallfacer-<uuid>)
+	Name      string `json:"name"`       // full container name (e.g. wallfacer-<uuid>, wallfacer-<instance>-<uuid>)
 	TaskID    string `json:"task_id"`    // task UUID extracted from name, empty if not a task container
 	Image     string `json:"image"`      // image name
 	State     string `json:"state"`      // running | exited | paused | …
@@ -111,11 +116,17 @@ func parseContainerList(out []byte) ([]containerJSON, error) {
 }
 
 // ListContainers runs `<runtime> ps -a --filter name=wallfacer --format json`
-// and returns structured info for each matching container.
+// and returns structured info for each matching container. A named instance
+// filters on its own container prefix instead, so it does not see the
+// containers of other instances.
 // Supports both Podman and Docker JSON output formats.
 func (r *Runner) ListContainers() ([]ContainerInfo, error) {
+	filter := "wallfacer"
+	if r.instance != "" {
+		filter = r.containerPrefix()
+	}
 	out, err := exec.Command(r.command, "ps", "-a",
-		"--filter", "name=wallfacer",
+		"--filter", "name="+filter,
 		"--format", "json",
 	).Output()
 	if err != nil {
@@ -130,9 +141,9 @@ func (r *Runner) ListContainers() ([]ContainerInfo, error) {
 	result := make([]ContainerInfo, 0, len(raw))
 	for _, c := range raw {
 		name := c.name()
-		taskID := strings.TrimPrefix(name, "wallfacer-")
-		if taskID == name {
-			taskID = "" // name didn't have the prefix → not a task container
+		taskID := strings.TrimPrefix(name, r.containerPrefix())
+		if taskID == name || !util.IsUUID(taskID) {
+			taskID = "" // no prefix or non-UUID suffix → not a task container
 		}
 		result = append(result, ContainerInfo{
 			ID:        c.ID,
@@ -147,57 +158,389 @@ func (r *Runner) ListContainers() ([]ContainerInfo, error) {
 	return result, nil
 }
 
+// TaskStatusUnknown is ContainerTaskInfo.TaskStatus for containers that do
+// not belong to a task in the store.
+const TaskStatusUnknown = "unknown"
+
+// ContainerTaskInfo is a container from ListContainers joined with the
+// current status of its task.
+type ContainerTaskInfo struct {
+	ContainerInfo
+	TaskStatus string `json:"task_status"` // status of TaskID in the store, or TaskStatusUnknown
+	// Orphaned marks a running task container whose task is not
+	// in_progress (or not in the store at all): nothing is waiting for its
+	// output, so it is a zombie that can be removed.
+	Orphaned bool `json:"orphaned"`
+}
+
+// ListContainersWithTasks is ListContainers with each container annotated
+// with its task's status, flagging running containers that outlived their
+// task. Helper containers without a task ID (commit messages, titles) get
+// TaskStatusUnknown and are never flagged.
+func (r *Runner) ListContainersWithTasks() ([]ContainerTaskInfo, error) {
+	containers, err := r.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := r.store.ListTasks(context.Background(), true)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		statuses[t.ID.String()] = t.Status
+	}
+
+	result := make([]ContainerTaskInfo, 0, len(containers))
+	for _, c := range containers {
+		info := ContainerTaskInfo{ContainerInfo: c, TaskStatus: TaskStatusUnknown}
+		if status, ok := statuses[c.TaskID]; ok {
+			info.TaskStatus = status
+		}
+		info.Orphaned = c.TaskID != "" && c.State == "running" && info.TaskStatus != "in_progress"
+		result = append(result, info)
+	}
+	return result, nil
+}
+
 const (
 	maxRebaseRetries   = 3
 	defaultTaskTimeout = 15 * time.Minute
+
+	// defaultGitAuthorName and defaultGitAuthorEmail identify commits in
+	// non-git snapshots when no author is configured.
+	defaultGitAuthorName  = "Wallfacer"
+	defaultGitAuthorEmail = "wallfacer@local"
 )
 
 // RunnerConfig holds all configuration needed to construct a Runner.
 type RunnerConfig struct {
-	Command          string
+	Command          string // container runtime; empty auto-detects podman, then docker
 	SandboxImage     string
 	EnvFile          string
 	Workspaces       string // space-separated workspace paths
 	WorktreesDir     string
 	InstructionsPath string
+
+	// InstructionsMountPath is where InstructionsPath is mounted (read-only)
+	// inside the container, for agents that look for instructions elsewhere
+	// (e.g. /home/claude/.claude/CLAUDE.md). Empty keeps the default:
+	// /workspace/<name>/CLAUDE.md for a single workspace, otherwise
+	// /workspace/CLAUDE.md.
+	InstructionsMountPath string
+
+	// SeparateInstructions mounts each workspace's own CLAUDE.md read-only
+	// at /workspace/<name>/CLAUDE.md, where agents discover it naturally,
+	// and InstructionsPath (then built without the repo files, see
+	// instructions.Options.OmitRepos) at /workspace/CLAUDE.md, instead of
+	// mounting one merged file.
+	SeparateInstructions bool
+
+	// ShallowWorktree creates a standalone depth-1 clone per task instead of
+	// a linked git worktree. The container then cannot reach the host
+	// repository's history, at the cost of rebase support: the task branch
+	// is fetched back and fast-forward merged as-is.
+	ShallowWorktree bool
+
+	// GitAuthorName and GitAuthorEmail, when set, are applied via
+	// `-c user.name=… -c user.email=…` to every commit wallfacer creates on
+	// the host. When empty, the host's global git identity is used.
+	GitAuthorName  string
+	GitAuthorEmail string
+
+	// KeepBranch leaves the task branch ref in each repository after a
+	// successful merge instead of deleting it, so the branch can serve as an
+	// audit record. Only the worktree is removed.
+	KeepBranch bool
+
+	// TagTasks creates a lightweight tag wallfacer/<short-id> on the merged
+	// commit in each git repository after a successful fast-forward merge.
+	TagTasks bool
+
+	// RebaseArgs are extra flags appended to every `git rebase` onto the
+	// default branch (e.g. --autosquash). Validate them with
+	// gitutil.ValidateRebaseArgs before constructing the Runner.
+	RebaseArgs []string
+
+	// Rerere enables git rerere for rebases so recorded conflict
+	// resolutions are reused automatically.
+	Rerere bool
+
+	// NotifyURL, when set, receives a POST every time a task enters done,
+	// failed, waiting, or cancelled. NotifyFormat selects the payload:
+	// "raw" (default) for a JSON task summary or "slack" for a Slack
+	// incoming-webhook {"text": …} message.
+	NotifyURL    string
+	NotifyFormat string
+
+	// ShortIDLength is the minimum length of the task short IDs used in
+	// board.json and sibling worktree mount paths (default 8). Short IDs
+	// that would collide within the board are extended until unique.
+	ShortIDLength int
+
+	// ExtraRunArgs are passed to every `<runtime> run` verbatim, just before
+	// the image name (e.g. --security-opt, --cap-drop, --tmpfs). They are
+	// trusted operator input and are not validated.
+	ExtraRunArgs []string
+
+	// AgentArgs are appended verbatim to the Claude Code command inside the
+	// container, after wallfacer's own flags (e.g. --debug), so debugging
+	// flags reach the agent rather than the runtime. Trusted operator input.
+	AgentArgs []string
+
+	// HardenedSandbox launches containers with --cap-drop=ALL,
+	// --security-opt=no-new-privileges, and a seccomp profile.
+	// SeccompProfile, when set, is the path of that profile; otherwise a
+	// built-in allowlist, the runtime default's without io_uring or
+	// tracing, is used.
+	HardenedSandbox bool
+	SeccompProfile  string
+
+	// WaitingTimeout, when positive, bounds how long a task may sit in
+	// waiting without a response. WatchWaitingTimeout then moves it on
+	// according to WaitingTimeoutAction: "commit" runs the commit pipeline
+	// as if the user had marked it done; "fail" (default) marks it failed.
+	WaitingTimeout       time.Duration
+	WaitingTimeoutAction string
+
+	// RequireInstructions fails a task at launch when InstructionsPath is
+	// set but the file is missing (e.g. it could not be written). By default
+	// the task runs without instructions and a warning is logged and
+	// recorded as an event.
+	RequireInstructions bool
+
+	// MaxRetries, when positive, is how many times a task may fail and be
+	// retried. A run that leaves the task failed for the (MaxRetries+1)th
+	// time moves it to the terminal "dead" status instead, where it cannot
+	// be resumed until a user retries it back to backlog.
+	MaxRetries int
+
+	// ReadOnlyWorkspace mounts task worktrees as Podman overlays: the
+	// worktree is a read-only lower layer and the container's writes go to
+	// an upper layer on the host. Nothing reaches the worktree, and so the
+	// workspace, until the task is marked done, which promotes the writes
+	// and runs the commit pipeline. Tasks ending their turn wait for that
+	// instead of committing automatically. Requires Podman.
+	ReadOnlyWorkspace bool
+
+	// DisableBoard runs tasks without the board context: no board.json is
+	// generated and nothing is mounted at /workspace/.tasks, not even the
+	// sibling worktrees of tasks that opted into them.
+	DisableBoard bool
+
+	// RevertOutOfScope makes the commit pipeline revert a task's changes
+	// outside its AllowedPaths and commit the rest, instead of failing the
+	// commit with ErrOutOfScope.
+	RevertOutOfScope bool
+
+	// SecretScan checks what a task is about to merge for likely secrets
+	// (AWS keys, private key headers, provider tokens, high-entropy values
+	// assigned to secret-sounding names) and blocks the merge with
+	// ErrSecretDetected when any are found.
+	SecretScan bool
+
+	// DefaultBranches maps a workspace, by path or basename, to the branch
+	// its tasks start from and merge into, for repos whose target is not
+	// the auto-detected default (see gitutil.DefaultBranch). A path entry
+	// wins over a basename entry.
+	DefaultBranches map[string]string
+
+	// MaxDiffLines caps the lines (added plus removed) a task may change
+	// and still be merged automatically when its turn ends. A larger task
+	// goes to waiting for review instead; marking it done commits it as
+	// usual. 0 disables the cap.
+	MaxDiffLines int
+
+	// VerifyCommand is a shell command run against a task's worktrees in a
+	// fresh sandbox container when its turn ends, before the commit
+	// pipeline. A non-zero exit holds the task in waiting with the
+	// command's output instead of merging it. Empty skips verification.
+	VerifyCommand string
+
+	// PreMergeDelay is how long the commit pipeline waits, after committing
+	// a task's changes in its worktrees and before rebasing and merging
+	// them, so that cancelling the task can still abort it without a merge
+	// (see CancelPreMerge). 0 merges right away.
+	PreMergeDelay time.Duration
+
+	// Unshallow fetches the full history of a workspace that is a shallow
+	// clone before creating a task worktree from it, so the commit
+	// pipeline's rebase can find the merge-base. Without it such workspaces
+	// are only warned about.
+	Unshallow bool
+
+	// Instance names this server when several share repositories. Task
+	// branches become "<instance>/task/<short-id>" and containers
+	// "wallfacer-<instance>-…", so the instances neither reuse nor remove
+	// each other's. Empty keeps the unprefixed names. See ValidateInstance.
+	Instance string
 }
 
 // Runner orchestrates Claude Code container execution for tasks.
 // It manages worktree isolation, container lifecycle, and the commit pipeline.
 type Runner struct {
-	store            *store.Store
-	command          string
-	sandboxImage     string
-	envFile          string
-	workspaces       string
-	worktreesDir     string
-	instructionsPath string
-	repoMu           sync.Map // per-repo *sync.Mutex for serializing rebase+merge
+	store                *store.Store
+	command              string
+	sandboxImage         string
+	envFile              string
+	workspaces           string
+	worktreesDir         string
+	instructionsPath     string
+	instructionsMount    string
+	separateInstructions bool
+	shallowWorktree      bool
+	gitAuthorName        string
+	gitAuthorEmail       string
+	keepBranch           bool
+	tagTasks             bool
+	rebaseArgs           []string
+	rerere               bool
+	notifyURL            string
+	notifyFormat         string
+	shortIDLength        int
+	extraRunArgs         []string
+	agentArgs            []string
+	hardenedSandbox      bool
+	seccompProfile       string
+	waitingTimeout       time.Duration
+	waitingTimeoutAction string
+	requireInstructions  bool
+	maxRetries           int
+	readOnlyWorkspace    bool
+	disableBoard         bool
+	rsyncAvailable       bool
+	revertOutOfScope     bool
+	secretScan           bool
+	maxDiffLines         int
+	verifyCommand        string
+	preMergeDelay        time.Duration
+	unshallow            bool
+	defaultBranches      map[string]string
+	instance             string
+	repoMu               *sync.Map    // per-repo *sync.Mutex for serializing rebase+merge
+	paused               *atomic.Bool // set by Pause: no new tasks are launched
+	live                 *liveHub     // live agent output of running containers
+	behindCache          *sync.Map    // worktree path -> behindEntry for board.json
+	preMerge             *sync.Map    // task ID -> chan struct{} closed by CancelPreMerge
 }
 
 // NewRunner constructs a Runner from the given store and config.
 func NewRunner(s *store.Store, cfg RunnerConfig) *Runner {
+	if cfg.Command == "" {
+		cfg.Command = DetectRuntime()
+		if cfg.Command != "" {
+			logger.Runner.Info("container runtime auto-detected", "command", cfg.Command)
+		}
+	}
 	return &Runner{
-		store:            s,
-		command:          cfg.Command,
-		sandboxImage:     cfg.SandboxImage,
-		envFile:          cfg.EnvFile,
-		workspaces:       cfg.Workspaces,
-		worktreesDir:     cfg.WorktreesDir,
-		instructionsPath: cfg.InstructionsPath,
+		store:                s,
+		command:              cfg.Command,
+		sandboxImage:         cfg.SandboxImage,
+		envFile:              cfg.EnvFile,
+		workspaces:           cfg.Workspaces,
+		worktreesDir:         cfg.WorktreesDir,
+		instructionsPath:     cfg.InstructionsPath,
+		instructionsMount:    cfg.InstructionsMountPath,
+		separateInstructions: cfg.SeparateInstructions,
+		shallowWorktree:      cfg.ShallowWorktree,
+		gitAuthorName:        cfg.GitAuthorName,
+		gitAuthorEmail:       cfg.GitAuthorEmail,
+		keepBranch:           cfg.KeepBranch,
+		tagTasks:             cfg.TagTasks,
+		rebaseArgs:           cfg.RebaseArgs,
+		rerere:               cfg.Rerere,
+		notifyURL:            cfg.NotifyURL,
+		notifyFormat:         cfg.NotifyFormat,
+		shortIDLength:        cfg.ShortIDLength,
+		extraRunArgs:         cfg.ExtraRunArgs,
+		agentArgs:            cfg.AgentArgs,
+		hardenedSandbox:      cfg.HardenedSandbox,
+		seccompProfile:       cfg.SeccompProfile,
+		waitingTimeout:       cfg.WaitingTimeout,
+		waitingTimeoutAction: cfg.WaitingTimeoutAction,
+		requireInstructions:  cfg.RequireInstructions,
+		maxRetries:           cfg.MaxRetries,
+		readOnlyWorkspace:    cfg.ReadOnlyWorkspace,
+		disableBoard:         cfg.DisableBoard,
+		rsyncAvailable:       rsyncOnPath(),
+		revertOutOfScope:     cfg.RevertOutOfScope,
+		secretScan:           cfg.SecretScan,
+		maxDiffLines:         cfg.MaxDiffLines,
+		verifyCommand:        cfg.VerifyCommand,
+		preMergeDelay:        cfg.PreMergeDelay,
+		unshallow:            cfg.Unshallow,
+		defaultBranches:      cfg.DefaultBranches,
+		instance:             cfg.Instance,
+		repoMu:               &sync.Map{},
+		paused:               &atomic.Bool{},
+		live:                 newLiveHub(),
+		behindCache:          &sync.Map{},
+		preMerge:             &sync.Map{},
 	}
 }
 
+// NewRunnerChecked is NewRunner for configuration supplied by a user: it
+// rejects cfg when cfg.Validate fails instead of letting the problem surface
+// when the first task runs.
+func NewRunnerChecked(s *store.Store, cfg RunnerConfig) (*Runner, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("runner config: %w", err)
+	}
+	return NewRunner(s, cfg), nil
+}
+
 // Command returns the container runtime binary path (podman/docker).
 func (r *Runner) Command() string {
 	return r.command
 }
 
+// runtimeCandidates are the container runtimes DetectRuntime probes, in order
+// of preference.
+var runtimeCandidates = []string{"podman", "docker"}
+
+// DetectRuntime returns the path of the first container runtime in
+// runtimeCandidates found on PATH, or "" when none is installed.
+func DetectRuntime() string {
+	for _, name := range runtimeCandidates {
+		if p, err := exec.LookPath(name); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// CheckRuntime reports a descriptive error when the container runtime binary
+// cannot be found, instead of the raw exec error every container launch
+// would otherwise fail with.
+func (r *Runner) CheckRuntime() error {
+	if r.command == "" {
+		return fmt.Errorf("no container runtime found in PATH; install podman or docker")
+	}
+	if _, err := exec.LookPath(r.command); err != nil {
+		alt := "docker"
+		if filepath.Base(r.command) == "docker" {
+			alt = "podman"
+		}
+		return fmt.Errorf("container runtime '%s' not found in PATH; install it or set command to %s", r.command, alt)
+	}
+	if r.readOnlyWorkspace && !strings.Contains(filepath.Base(r.command), "podman") {
+		return fmt.Errorf("read-only workspaces need Podman overlay mounts; '%s' is not podman", r.command)
+	}
+	return nil
+}
+
 // EnvFile returns the path to the env file used for containers.
 func (r *Runner) EnvFile() string {
 	return r.envFile
 }
 
+// RsyncAvailable reports whether rsync was on PATH when the runner was
+// created. Without it, changes of non-git workspaces are synced back by the
+// slower built-in copy.
+func (r *Runner) RsyncAvailable() bool {
+	return r.rsyncAvailable
+}
+
 // Workspaces returns the list of configured workspace paths.
 func (r *Runner) Workspaces() []string {
 	if r.workspaces == "" {
@@ -213,9 +556,28 @@ func (r *Runner) repoLock(repoPath string) *sync.Mutex {
 	return v.(*sync.Mutex)
 }
 
+// Pause stops new tasks from being launched until Resume is called. Tasks
+// that are already running are not affected.
+func (r *Runner) Pause() {
+	if !r.paused.Swap(true) {
+		logger.Runner.Info("task launching paused")
+	}
+}
+
+// Resume undoes Pause.
+func (r *Runner) Resume() {
+	if r.paused.Swap(false) {
+		logger.Runner.Info("task launching resumed")
+	}
+}
+
+// Paused reports whether task launching is paused.
+func (r *Runner) Paused() bool {
+	return r.paused.Load()
+}
+
 // KillContainer sends a kill signal to the running container for a task.
 // Safe to call when no container is running — errors are silently ignored.
 func (r *Runner) KillContainer(taskID uuid.UUID) {
-	containerName := "wallfacer-" + taskID.String()
-	exec.Command(r.command, "kill", containerName).Run()
+	exec.Command(r.command, "kill", r.ContainerName(taskID)).Run()
 }
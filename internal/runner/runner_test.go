@@ -1,7 +1,11 @@
 package runner
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/store"
 	"github.com/google/uuid"
 )
@@ -49,6 +54,29 @@ func setupTestRepo(t *testing.T) string {
 	return dir
 }
 
+// moveTask moves a task to status along legal state-machine edges, the way
+// the handlers do before the runner takes over: a backlog task is started
+// (in_progress) first.
+func moveTask(t *testing.T, s *store.Store, id uuid.UUID, status string) {
+	t.Helper()
+	ctx := context.Background()
+	task, err := s.GetTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status == status {
+		return
+	}
+	if task.Status == "backlog" && status != "in_progress" && status != "cancelled" {
+		if err := s.UpdateTaskStatus(ctx, id, "in_progress"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.UpdateTaskStatus(ctx, id, status); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // setupTestRunner creates a Store and Runner for testing.
 // The container command is a dummy since we're testing host-side operations.
 func setupTestRunner(t *testing.T, workspaces []string) (*store.Store, *Runner) {
@@ -117,7 +145,7 @@ func TestContainerArgsMountsCLAUDEMD(t *testing.T) {
 	}
 
 	runner := newTestRunnerWithInstructions(t, instructionsFile)
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	expectedMount := instructionsFile + ":/workspace/CLAUDE.md:z,ro"
 	if !containsConsecutive(args, "-v", expectedMount) {
@@ -125,11 +153,82 @@ func TestContainerArgsMountsCLAUDEMD(t *testing.T) {
 	}
 }
 
+// TestContainerArgsCustomInstructionsMount verifies that InstructionsMountPath
+// moves the CLAUDE.md mount target and that the mount stays read-only.
+func TestContainerArgsCustomInstructionsMount(t *testing.T) {
+	instructionsFile := filepath.Join(t.TempDir(), "instructions.md")
+	if err := os.WriteFile(instructionsFile, []byte("# test instructions\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := newTestRunnerWithInstructions(t, instructionsFile)
+	runner.instructionsMount = "/home/claude/.claude/CLAUDE.md"
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
+
+	expectedMount := instructionsFile + ":/home/claude/.claude/CLAUDE.md:z,ro"
+	if !containsConsecutive(args, "-v", expectedMount) {
+		t.Fatalf("args should contain -v %q; got: %v", expectedMount, args)
+	}
+	for _, a := range args {
+		if strings.HasSuffix(a, ":/workspace/CLAUDE.md:z,ro") {
+			t.Errorf("default mount target should not be used; got: %v", args)
+		}
+	}
+}
+
+// TestContainerArgsSeparateInstructions verifies that separate instructions
+// mode mounts each repo's own CLAUDE.md read-only at its repo path and the
+// wallfacer defaults at /workspace/CLAUDE.md, skipping repos without one.
+func TestContainerArgsSeparateInstructions(t *testing.T) {
+	instructionsFile := filepath.Join(t.TempDir(), "instructions.md")
+	if err := os.WriteFile(instructionsFile, []byte("# defaults\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	var workspaces []string
+	for _, name := range []string{"api", "web", "docs"} {
+		ws := filepath.Join(root, name)
+		if err := os.MkdirAll(ws, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if name != "docs" {
+			if err := os.WriteFile(filepath.Join(ws, "CLAUDE.md"), []byte("# "+name+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		workspaces = append(workspaces, ws)
+	}
+
+	runner := newTestRunnerWithInstructions(t, instructionsFile)
+	runner.workspaces = strings.Join(workspaces, " ")
+	runner.separateInstructions = true
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
+
+	for _, want := range []string{
+		instructionsFile + ":/workspace/CLAUDE.md:z,ro",
+		filepath.Join(workspaces[0], "CLAUDE.md") + ":/workspace/api/CLAUDE.md:z,ro",
+		filepath.Join(workspaces[1], "CLAUDE.md") + ":/workspace/web/CLAUDE.md:z,ro",
+	} {
+		if !containsConsecutive(args, "-v", want) {
+			t.Errorf("args should contain -v %q; got: %v", want, args)
+		}
+	}
+	var mounts int
+	for _, a := range args {
+		if strings.HasSuffix(a, "/CLAUDE.md:z,ro") {
+			mounts++
+		}
+	}
+	if mounts != 3 {
+		t.Errorf("expected 3 CLAUDE.md mounts (defaults + 2 repos), got %d: %v", mounts, args)
+	}
+}
+
 // TestContainerArgsNoInstructionsPath verifies that when InstructionsPath is
 // empty no CLAUDE.md mount is added to the container args.
 func TestContainerArgsNoInstructionsPath(t *testing.T) {
 	runner := newTestRunnerWithInstructions(t, "")
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	for _, a := range args {
 		if strings.Contains(a, "CLAUDE.md") {
@@ -144,7 +243,7 @@ func TestContainerArgsNoInstructionsPath(t *testing.T) {
 func TestContainerArgsMissingInstructionsFile(t *testing.T) {
 	missingPath := filepath.Join(t.TempDir(), "nonexistent.md")
 	runner := newTestRunnerWithInstructions(t, missingPath)
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	for _, a := range args {
 		if strings.Contains(a, "CLAUDE.md") {
@@ -153,6 +252,57 @@ func TestContainerArgsMissingInstructionsFile(t *testing.T) {
 	}
 }
 
+// TestRunContainerMountsTaskInstructions verifies that a task with extra
+// instructions gets a task-specific CLAUDE.md holding both the shared
+// content and the extra text, while the shared file stays untouched.
+func TestRunContainerMountsTaskInstructions(t *testing.T) {
+	repo := setupTestRepo(t)
+	shared := filepath.Join(t.TempDir(), "instructions.md")
+	if err := os.WriteFile(shared, []byte("# Shared rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake runtime copies whatever file is mounted as CLAUDE.md, since
+	// the runner deletes the task copy once the container exits.
+	dir := t.TempDir()
+	mounted := filepath.Join(dir, "mounted.md")
+	script := filepath.Join(dir, "fake-runtime")
+	body := fmt.Sprintf(`#!/bin/sh
+for a in "$@"; do
+  case "$a" in *CLAUDE.md:*) cp "${a%%%%:*}" %s ;; esac
+done
+echo '%s'
+`, mounted, endTurnOutput)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s, r := setupRunnerWithCmd(t, []string{repo}, script)
+	r.instructionsPath = shared
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "prompt", 5, false)
+	if err := s.UpdateTaskExtraInstructions(ctx, task.ID, "Only touch the docs/ directory."); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := r.runContainer(ctx, task.ID, "prompt", "", nil, "", nil); err != nil {
+		t.Fatalf("runContainer: %v", err)
+	}
+
+	got, err := os.ReadFile(mounted)
+	if err != nil {
+		t.Fatalf("no CLAUDE.md was mounted: %v", err)
+	}
+	for _, want := range []string{"# Shared rules", "## Task-specific instructions", "Only touch the docs/ directory."} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("mounted CLAUDE.md missing %q:\n%s", want, got)
+		}
+	}
+	if base, _ := os.ReadFile(shared); string(base) != "# Shared rules\n" {
+		t.Errorf("shared instructions were modified: %q", base)
+	}
+}
+
 // TestContainerArgsCLAUDEMDMountIsReadOnly verifies the mount is marked :ro
 // so the container cannot accidentally modify the shared instructions file.
 func TestContainerArgsCLAUDEMDMountIsReadOnly(t *testing.T) {
@@ -162,7 +312,7 @@ func TestContainerArgsCLAUDEMDMountIsReadOnly(t *testing.T) {
 	}
 
 	runner := newTestRunnerWithInstructions(t, instructionsFile)
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	for i, a := range args {
 		if a == "-v" && i+1 < len(args) && strings.Contains(args[i+1], "CLAUDE.md") {
@@ -202,7 +352,7 @@ func TestContainerArgsSingleWorkspaceMountsCLAUDEMDAtRoot(t *testing.T) {
 		InstructionsPath: instructionsFile,
 		Workspaces:       ws,
 	})
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	basename := filepath.Base(ws)
 	expectedMount := instructionsFile + ":/workspace/" + basename + "/CLAUDE.md:z,ro"
@@ -242,7 +392,7 @@ func TestContainerArgsMultiWorkspaceMountsCLAUDEMDAtWorkspace(t *testing.T) {
 		InstructionsPath: instructionsFile,
 		Workspaces:       ws1 + " " + ws2,
 	})
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	expectedMount := instructionsFile + ":/workspace/CLAUDE.md:z,ro"
 	if !containsConsecutive(args, "-v", expectedMount) {
@@ -273,7 +423,7 @@ func TestContainerArgsCLAUDEMDMountPosition(t *testing.T) {
 		InstructionsPath: instructionsFile,
 		Workspaces:       ws,
 	})
-	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil)
+	args := runner.buildContainerArgs("test-container", "do something", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 
 	claudeMDIdx := -1
 	imageIdx := -1
@@ -307,7 +457,7 @@ func TestContainerArgsCLAUDEMDMountPosition(t *testing.T) {
 func TestBuildContainerArgs_BoardMount(t *testing.T) {
 	runner := newTestRunnerWithInstructions(t, "")
 	boardDir := t.TempDir()
-	args := runner.buildContainerArgs("name", "prompt", "", nil, boardDir, nil)
+	args := runner.buildContainerArgs("name", "prompt", "", nil, boardDir, nil, nil, "", runner.instructionsPath, "")
 	expected := boardDir + ":/workspace/.tasks:z,ro"
 	if !containsConsecutive(args, "-v", expected) {
 		t.Fatalf("expected board mount %q in args; got: %v", expected, args)
@@ -318,7 +468,7 @@ func TestBuildContainerArgs_BoardMount(t *testing.T) {
 // not add a .tasks mount.
 func TestBuildContainerArgs_NoBoardMount(t *testing.T) {
 	runner := newTestRunnerWithInstructions(t, "")
-	args := runner.buildContainerArgs("name", "prompt", "", nil, "", nil)
+	args := runner.buildContainerArgs("name", "prompt", "", nil, "", nil, nil, "", runner.instructionsPath, "")
 	for _, a := range args {
 		if strings.Contains(a, ".tasks") {
 			t.Fatalf("should not have .tasks mount when boardDir is empty; found %q", a)
@@ -334,7 +484,7 @@ func TestBuildContainerArgs_SiblingMounts(t *testing.T) {
 	siblingMounts := map[string]map[string]string{
 		"abcd1234": {"/home/user/myrepo": siblingDir},
 	}
-	args := runner.buildContainerArgs("name", "prompt", "", nil, "", siblingMounts)
+	args := runner.buildContainerArgs("name", "prompt", "", nil, "", siblingMounts, nil, "", runner.instructionsPath, "")
 	expected := siblingDir + ":/workspace/.tasks/worktrees/abcd1234/myrepo:z,ro"
 	if !containsConsecutive(args, "-v", expected) {
 		t.Fatalf("expected sibling mount %q in args; got: %v", expected, args)
@@ -423,6 +573,162 @@ func TestWorktreeGitFilePointsToHost(t *testing.T) {
 	}
 }
 
+// TestWorktreeSetupShallow verifies that ShallowWorktree creates a standalone
+// depth-1 clone on the task branch instead of a linked worktree.
+func TestWorktreeSetupShallow(t *testing.T) {
+	repo := setupTestRepo(t)
+	// Add a second commit so a full clone would have more than one.
+	if err := os.WriteFile(filepath.Join(repo, "second.txt"), []byte("second\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "second commit")
+
+	_, runner := setupTestRunner(t, []string{repo})
+	runner.shallowWorktree = true
+
+	taskID := uuid.New()
+	worktreePaths, branchName, err := runner.setupWorktrees(taskID)
+	if err != nil {
+		t.Fatal("setupWorktrees:", err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(taskID, worktreePaths, branchName) })
+
+	wt := worktreePaths[repo]
+	if info, err := os.Stat(filepath.Join(wt, ".git")); err != nil || !info.IsDir() {
+		t.Fatalf("shallow clone should have its own .git directory: %v", err)
+	}
+	if got := gitRun(t, wt, "rev-parse", "--is-shallow-repository"); got != "true" {
+		t.Fatalf("expected shallow repository, got %q", got)
+	}
+	if got := gitRun(t, wt, "rev-list", "--count", "HEAD"); got != "1" {
+		t.Fatalf("expected 1 reachable commit, got %s", got)
+	}
+	if branch := gitRun(t, wt, "branch", "--show-current"); branch != branchName {
+		t.Fatalf("expected branch %q, got %q", branchName, branch)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "second.txt")); err != nil {
+		t.Fatal("second.txt should exist in shallow clone:", err)
+	}
+}
+
+// shallowWorkspace returns a depth-1 clone of a fresh two-commit repo, the
+// kind of checkout CI systems hand out.
+func shallowWorkspace(t *testing.T) string {
+	t.Helper()
+	origin := setupTestRepo(t)
+	if err := os.WriteFile(filepath.Join(origin, "second.txt"), []byte("second\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, origin, "add", ".")
+	gitRun(t, origin, "commit", "-m", "second commit")
+	ws := filepath.Join(t.TempDir(), "shallow")
+	gitRun(t, origin, "clone", "--depth", "1", "file://"+origin, ws)
+	gitRun(t, ws, "config", "user.email", "test@test.com")
+	gitRun(t, ws, "config", "user.name", "Test")
+	if got := gitRun(t, ws, "rev-parse", "--is-shallow-repository"); got != "true" {
+		t.Fatalf("fixture is not shallow: %q", got)
+	}
+	return ws
+}
+
+// TestWorktreeSetupWarnsOnShallowWorkspace verifies that creating a worktree
+// from a shallow clone records a warning event on the task and leaves the
+// workspace shallow.
+func TestWorktreeSetupWarnsOnShallowWorkspace(t *testing.T) {
+	ws := shallowWorkspace(t)
+	s, runner := setupTestRunner(t, []string{ws})
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "shallow", 5, false)
+
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal("setupWorktrees:", err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+
+	events, _ := s.GetEvents(ctx, task.ID)
+	found := false
+	for _, e := range events {
+		if strings.Contains(string(e.Data), "is a shallow clone") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected a shallow clone warning event")
+	}
+	if got := gitRun(t, ws, "rev-parse", "--is-shallow-repository"); got != "true" {
+		t.Errorf("workspace unshallowed without -unshallow")
+	}
+}
+
+// TestWorktreeSetupUnshallowsWorkspace verifies that with Unshallow the
+// workspace's full history is fetched before the worktree is created.
+func TestWorktreeSetupUnshallowsWorkspace(t *testing.T) {
+	ws := shallowWorkspace(t)
+	s, runner := setupTestRunner(t, []string{ws})
+	runner.unshallow = true
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "unshallow", 5, false)
+
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal("setupWorktrees:", err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+
+	if got := gitRun(t, ws, "rev-parse", "--is-shallow-repository"); got != "false" {
+		t.Fatalf("workspace still shallow: %q", got)
+	}
+	if got := gitRun(t, worktreePaths[ws], "rev-list", "--count", "HEAD"); got != "2" {
+		t.Errorf("worktree history has %s commits, want 2", got)
+	}
+	events, _ := s.GetEvents(ctx, task.ID)
+	for _, e := range events {
+		if strings.Contains(string(e.Data), "is a shallow clone") {
+			t.Errorf("unexpected shallow warning after unshallow: %s", e.Data)
+		}
+	}
+}
+
+// TestCommitPipelineShallow verifies that a task run in a shallow clone is
+// fetched back into the host repo and fast-forward merged.
+func TestCommitPipelineShallow(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	runner.shallowWorktree = true
+
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Add a shallow file", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := worktreePaths[repo]
+	// A clone does not inherit the test repo's local identity config.
+	gitRun(t, wt, "config", "user.email", "test@test.com")
+	gitRun(t, wt, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(wt, "shallow.txt"), []byte("shallow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := runner.commit(commitCtx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatal("commit:", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repo, "shallow.txt")); err != nil {
+		t.Fatal("shallow.txt should exist on main after merge:", err)
+	}
+	if _, err := os.Stat(wt); !os.IsNotExist(err) {
+		t.Fatal("shallow clone should have been cleaned up")
+	}
+}
+
 // TestHostStageAndCommit verifies that host-side staging and committing works
 // correctly in a worktree.
 func TestHostStageAndCommit(t *testing.T) {
@@ -444,7 +750,7 @@ func TestHostStageAndCommit(t *testing.T) {
 	}
 
 	// Run host-side commit.
-	committed, err := runner.hostStageAndCommit(taskID, worktreePaths, "Add hello world file")
+	committed, err := runner.hostStageAndCommit(context.Background(), taskID, worktreePaths, "Add hello world file")
 	if err != nil {
 		t.Fatalf("hostStageAndCommit error: %v", err)
 	}
@@ -479,7 +785,7 @@ func TestHostStageAndCommitNoChanges(t *testing.T) {
 	t.Cleanup(func() { runner.cleanupWorktrees(taskID, worktreePaths, branchName) })
 
 	// No changes made — commit should be a no-op.
-	committed, err := runner.hostStageAndCommit(taskID, worktreePaths, "Nothing to do")
+	committed, err := runner.hostStageAndCommit(context.Background(), taskID, worktreePaths, "Nothing to do")
 	if err != nil {
 		t.Fatalf("hostStageAndCommit error: %v", err)
 	}
@@ -515,9 +821,7 @@ func TestCommitPipelineBasic(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	wt := worktreePaths[repo]
 
@@ -558,6 +862,268 @@ func TestCommitPipelineBasic(t *testing.T) {
 	}
 }
 
+// TestWorktreeSetupRecordsBaseCommits verifies that setupWorktrees stores the
+// repo HEAD at creation time as the task's base commit and that it appears
+// in board.json.
+func TestWorktreeSetupRecordsBaseCommits(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Record base", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := gitRun(t, repo, "rev-parse", "HEAD")
+
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+
+	updated, _ := s.GetTask(ctx, task.ID)
+	if got := updated.BaseCommits[repo]; got != head {
+		t.Fatalf("BaseCommits[repo] = %q, want %q", got, head)
+	}
+
+	data, err := runner.generateBoardContext(task.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), head) {
+		t.Errorf("board.json should contain base commit %s:\n%s", head, data)
+	}
+}
+
+// TestResetWorktree verifies that ResetWorktree restores the worktree to the
+// base commit recorded at setup, discarding commits and untracked files.
+func TestResetWorktree(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Produce garbage", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { runner.cleanupWorktrees(task.ID, worktreePaths, branchName) })
+	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
+		t.Fatal(err)
+	}
+
+	wt := worktreePaths[repo]
+	base := gitRun(t, wt, "rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(wt, "garbage.txt"), []byte("bad\n"), 0644)
+	gitRun(t, wt, "add", ".")
+	gitRun(t, wt, "commit", "-m", "garbage")
+	os.WriteFile(filepath.Join(wt, "scratch.txt"), []byte("tmp\n"), 0644)
+
+	if err := runner.ResetWorktree(task.ID); err != nil {
+		t.Fatal("ResetWorktree:", err)
+	}
+	if got := gitRun(t, wt, "rev-parse", "HEAD"); got != base {
+		t.Fatalf("HEAD = %s, want base %s", got, base)
+	}
+	for _, f := range []string{"garbage.txt", "scratch.txt"} {
+		if _, err := os.Stat(filepath.Join(wt, f)); !os.IsNotExist(err) {
+			t.Errorf("%s should be gone after reset", f)
+		}
+	}
+}
+
+// TestResetWorktreeNoWorktrees verifies that a task without worktrees cannot
+// be reset.
+func TestResetWorktreeNoWorktrees(t *testing.T) {
+	s, runner := setupTestRunner(t, nil)
+	task, _ := s.CreateTask(context.Background(), "p", 5, false)
+	if err := runner.ResetWorktree(task.ID); err == nil {
+		t.Fatal("expected error for task without worktrees")
+	}
+}
+
+// TestCommitPipelineKeepBranch verifies that with KeepBranch the task branch
+// survives the commit pipeline while the worktree is removed.
+func TestCommitPipelineKeepBranch(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	runner.keepBranch = true
+
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Keep my branch", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := worktreePaths[repo]
+	if err := os.WriteFile(filepath.Join(wt, "kept.txt"), []byte("kept\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := runner.commit(commitCtx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatal("commit:", err)
+	}
+
+	if _, err := os.Stat(wt); !os.IsNotExist(err) {
+		t.Fatal("worktree should have been cleaned up")
+	}
+	if out := gitRun(t, repo, "branch", "--list", branchName); !strings.Contains(out, branchName) {
+		t.Fatalf("branch %s should still exist after commit, got %q", branchName, out)
+	}
+}
+
+// TestCommitPipelineMultiRepoCommitHashes verifies that a task spanning
+// several repos records each repo's own merged commit, and that a repo the
+// task did not touch gets no entry.
+func TestCommitPipelineMultiRepoCommitHashes(t *testing.T) {
+	repoA := setupTestRepo(t)
+	repoB := setupTestRepo(t)
+	untouched := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repoA, repoB, untouched})
+
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Change two repos", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePaths[repoA], "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePaths[repoB], "b.txt"), []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := runner.commit(commitCtx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatal("commit:", err)
+	}
+
+	got, _ := s.GetTask(ctx, task.ID)
+	if len(got.CommitHashes) != 2 {
+		t.Fatalf("CommitHashes = %v, want entries for exactly two repos", got.CommitHashes)
+	}
+	for _, repo := range []string{repoA, repoB} {
+		if want := gitRun(t, repo, "rev-parse", "HEAD"); got.CommitHashes[repo] != want {
+			t.Errorf("CommitHashes[%s] = %q, want %q", repo, got.CommitHashes[repo], want)
+		}
+	}
+	if got.CommitHashes[repoA] == got.CommitHashes[repoB] {
+		t.Error("expected distinct hashes per repo")
+	}
+	if _, ok := got.CommitHashes[untouched]; ok {
+		t.Error("untouched repo should have no commit hash")
+	}
+
+	data, err := runner.generateBoardContext(uuid.Nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest BoardManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for _, bt := range manifest.Tasks {
+		if bt.ID == task.ID.String() && len(bt.CommitHashes) != 2 {
+			t.Errorf("board.json commit_hashes = %v, want two entries", bt.CommitHashes)
+		}
+	}
+}
+
+// TestCommitPipelineTagTasks verifies that TagTasks creates a
+// wallfacer/<short-id> tag pointing at the task's merge commit.
+func TestCommitPipelineTagTasks(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	runner.tagTasks = true
+
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Tag me", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePaths[repo], "tagged.txt"), []byte("tagged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := runner.commit(commitCtx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatal("commit:", err)
+	}
+
+	updated, _ := s.GetTask(ctx, task.ID)
+	want := updated.CommitHashes[repo]
+	if want == "" {
+		t.Fatal("expected a commit hash to be recorded")
+	}
+	tag := taskTagName(branchName)
+	if tag != "wallfacer/"+strings.TrimPrefix(branchName, "task/") {
+		t.Errorf("tag %s does not carry the branch's short ID (%s)", tag, branchName)
+	}
+	if got := gitRun(t, repo, "rev-parse", tag); got != want {
+		t.Fatalf("tag %s resolves to %q, want %q", tag, got, want)
+	}
+}
+
+// TestCommitPipelineLogsGitCommands verifies that every host-side git call
+// of the commit pipeline is logged at debug level, including the rebase and
+// the fast-forward merge.
+func TestCommitPipelineLogsGitCommands(t *testing.T) {
+	var buf bytes.Buffer
+	orig := logger.Git
+	logger.Git = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() { logger.Git = orig })
+
+	repo := setupTestRepo(t)
+	s, runner := setupTestRunner(t, []string{repo})
+	ctx := context.Background()
+	task, err := s.CreateTask(ctx, "Log git", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktreePaths, branchName, err := runner.setupWorktrees(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePaths[repo], "logged.txt"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := runner.commit(commitCtx, task.ID, "", 1, worktreePaths, branchName); err != nil {
+		t.Fatal("commit:", err)
+	}
+
+	logged := buf.String()
+	for _, want := range []string{" rebase ", "merge --ff-only " + branchName} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected a git log line containing %q; got:\n%s", want, logged)
+		}
+	}
+	if !strings.Contains(logged, "exit=0") || !strings.Contains(logged, "dur=") {
+		t.Errorf("expected exit status and duration in git log lines; got:\n%s", logged)
+	}
+}
+
 // TestCommitPipelineDivergedBranch tests the pipeline when the default branch
 // has advanced since the worktree was created. The task's changes must be
 // rebased on top of the latest default branch.
@@ -579,9 +1145,7 @@ func TestCommitPipelineDivergedBranch(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	wt := worktreePaths[repo]
 
@@ -637,9 +1201,7 @@ func TestCommitPipelineNoChanges(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	initialHash := gitRun(t, repo, "rev-parse", "HEAD")
 
@@ -681,9 +1243,7 @@ func TestCompleteTaskE2E(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "in_progress"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "in_progress")
 	sessionID := "test-session-123"
 	result := "I created the greeting feature"
 	if err := s.UpdateTaskResult(ctx, task.ID, result, sessionID, "", 1); err != nil {
@@ -697,14 +1257,10 @@ func TestCompleteTaskE2E(t *testing.T) {
 	}
 
 	// Step 4: Task goes to waiting (Claude needs feedback).
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// Step 5: User clicks "Mark as Done" — this triggers Commit.
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	// Run the exact same code path as CompleteTask handler.
 	runner.Commit(task.ID, sessionID)
@@ -751,9 +1307,7 @@ func TestCommitOnTopOfLatestMain(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	wt := worktreePaths[repo]
 
@@ -821,9 +1375,7 @@ func TestParallelTasksSameRepo(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, taskA.ID, wtA, brA); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, taskA.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, taskA.ID, "committing")
 
 	wtB, brB, err := runner.setupWorktrees(taskB.ID)
 	if err != nil {
@@ -832,9 +1384,7 @@ func TestParallelTasksSameRepo(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, taskB.ID, wtB, brB); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, taskB.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, taskB.ID, "committing")
 
 	// Both worktrees should exist and be on different branches.
 	pathA := wtA[repo]
@@ -907,9 +1457,7 @@ func TestParallelTasksTwoRepos(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wtPaths, brName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	if len(wtPaths) != 2 {
 		t.Fatalf("expected 2 worktrees (one per repo), got %d", len(wtPaths))
@@ -967,9 +1515,7 @@ func TestParallelTasksConflictingChanges(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, taskA.ID, wtA, brA); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, taskA.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, taskA.ID, "committing")
 
 	wtB, brB, err := runner.setupWorktrees(taskB.ID)
 	if err != nil {
@@ -978,9 +1524,7 @@ func TestParallelTasksConflictingChanges(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, taskB.ID, wtB, brB); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, taskB.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, taskB.ID, "committing")
 
 	pathA := wtA[repo]
 	pathB := wtB[repo]
@@ -1137,9 +1681,7 @@ func TestRunDetectsMissingWorktreePaths(t *testing.T) {
 	commitOnBranch := gitRun(t, wt, "rev-parse", "HEAD")
 
 	// Task goes to waiting; worktree directory is still on disk at this point.
-	if err := s.UpdateTaskStatus(ctx, task.ID, "waiting"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "waiting")
 
 	// ---- Server restart simulation ----
 	// The worktree directory disappears but task.json retains WorktreePaths.
@@ -1282,9 +1824,7 @@ func TestConcurrentCompleteTaskSameRepo(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, taskA.ID, wtA, brA); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, taskA.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, taskA.ID, "committing")
 
 	wtB, brB, err := runner.setupWorktrees(taskB.ID)
 	if err != nil {
@@ -1293,9 +1833,7 @@ func TestConcurrentCompleteTaskSameRepo(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, taskB.ID, wtB, brB); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, taskB.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, taskB.ID, "committing")
 
 	pathA := wtA[repo]
 	pathB := wtB[repo]
@@ -1366,9 +1904,7 @@ func TestConcurrentCompleteTaskCommitErrorPropagated(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wtPaths, brName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	wt := wtPaths[repo]
 
@@ -1429,9 +1965,7 @@ func TestCommitPipelineBaseHashUsesDefBranch(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	wt := worktreePaths[repo]
 	if err := os.WriteFile(filepath.Join(wt, "task.txt"), []byte("task work\n"), 0644); err != nil {
@@ -1476,9 +2010,7 @@ func TestCommitPipelineNoChangesStoresBaseHash(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, worktreePaths, branchName); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	mainHash := gitRun(t, repo, "rev-parse", "main")
 
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"changkun.de/wallfacer/internal/gitutil"
+	"github.com/google/uuid"
+)
+
+// RunResult is the outcome of a RunOnce call.
+type RunResult struct {
+	Result     string  // final result text from Claude
+	SessionID  string  // Claude Code session ID of the run
+	StopReason string  // stop_reason of the last turn
+	IsError    bool    // Claude reported an error result
+	CostUSD    float64 // total cost reported by Claude Code
+	Diff       string  // unified diff of everything the run changed in the workspace copy
+}
+
+// RunOnce runs prompt against an ephemeral copy of workspace and returns the
+// result together with the diff of what the run changed. The copy is a git
+// worktree on a throwaway branch for git repositories and a snapshot
+// otherwise; it is removed before RunOnce returns, so the workspace itself is
+// never modified. Nothing is written to the store — no task is created — so a
+// Runner built with a nil store can be used, which makes this the entry point
+// for embedding wallfacer in tests and scripts. Only a single container turn
+// is run.
+func (r *Runner) RunOnce(ctx context.Context, prompt, workspace string) (RunResult, error) {
+	ws, err := filepath.Abs(workspace)
+	if err != nil {
+		return RunResult{}, err
+	}
+	tmp, err := os.MkdirTemp("", "wallfacer-once-")
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	id := uuid.New()
+	worktreePath := filepath.Join(tmp, filepath.Base(ws))
+	base, cleanup, err := r.ephemeralCopy(ws, worktreePath, id)
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer cleanup()
+
+	// Mount only this workspace, with its ephemeral copy in place of the
+	// original. The copy shares repoMu and all other configuration.
+	once := *r
+	once.workspaces = ws
+	output, _, _, err := once.runContainer(ctx, id, prompt, "", map[string]string{ws: worktreePath}, "", nil)
+	if err != nil {
+		return RunResult{}, err
+	}
+	diff, err := stagedDiff(worktreePath, base)
+	if err != nil {
+		return RunResult{}, err
+	}
+	return newRunResult(output, diff), nil
+}
+
+// ephemeralCopy creates a throwaway copy of workspace ws at path: a git
+// worktree on a wallfacer-once/<id> branch for git repositories and a
+// snapshot otherwise. It returns the commit the copy starts from and a
+// function removing the worktree again.
+func (r *Runner) ephemeralCopy(ws, path string, id uuid.UUID) (string, func(), error) {
+	cleanup := func() {}
+	if gitutil.IsGitRepo(context.Background(), ws) {
+		branch := "wallfacer-once/" + r.ShortID(id)
+		if err := gitutil.CreateWorktree(ws, path, branch); err != nil {
+			return "", nil, err
+		}
+		cleanup = func() { gitutil.RemoveWorktree(ws, path, branch) }
+	} else if err := setupNonGitSnapshot(ws, path, "", r.gitAuthorName, r.gitAuthorEmail); err != nil {
+		return "", nil, err
+	}
+	base, err := gitutil.GetCommitHash(context.Background(), path)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return base, cleanup, nil
+}
+
+// stagedDiff returns the diff of everything that changed in the workspace
+// copy at path since base. Everything is staged first, including untracked
+// files, so the diff covers both commits made by the agent and uncommitted
+// work.
+func stagedDiff(path, base string) (string, error) {
+	if out, err := gitutil.Command(context.Background(), "-C", path, "add", "-A").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git add in %s: %w\n%s", path, err, out)
+	}
+	diff, err := gitutil.Command(context.Background(), "-C", path, "diff", "--cached", base).Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff in %s: %w", path, err)
+	}
+	return string(diff), nil
+}
+
+// newRunResult builds a RunResult from the container output and the diff.
+func newRunResult(output *claudeOutput, diff string) RunResult {
+	return RunResult{
+		Result:     output.Result,
+		SessionID:  output.SessionID,
+		StopReason: output.StopReason,
+		IsError:    output.IsError,
+		CostUSD:    output.TotalCostUSD,
+		Diff:       diff,
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEditingRuntime returns a fake container runtime that writes
+// once.txt into every workspace mounted read-write and prints output.
+func fakeEditingRuntime(t *testing.T, output string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "fake-runtime")
+	body := fmt.Sprintf(`#!/bin/sh
+for a in "$@"; do
+  case "$a" in *:/workspace/*:z) echo "from the sandbox" > "${a%%%%:*}/once.txt" ;; esac
+done
+echo '%s'
+`, output)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+// TestRunOnceGitWorkspace verifies that RunOnce works without a store,
+// returns the result and the diff of the run, and leaves the workspace and
+// its branches untouched.
+func TestRunOnceGitWorkspace(t *testing.T) {
+	repo := setupTestRepo(t)
+	r := NewRunner(nil, RunnerConfig{
+		Command:      fakeEditingRuntime(t, endTurnOutput),
+		SandboxImage: "test:latest",
+	})
+
+	res, err := r.RunOnce(context.Background(), "add a file", repo)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if res.Result != "task complete" || res.SessionID != "sess1" {
+		t.Errorf("result = %+v", res)
+	}
+	if !strings.Contains(res.Diff, "+++ b/once.txt") || !strings.Contains(res.Diff, "+from the sandbox") {
+		t.Errorf("diff does not show the new file:\n%s", res.Diff)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "once.txt")); !os.IsNotExist(err) {
+		t.Error("the workspace itself must not be modified")
+	}
+	if branches := gitRun(t, repo, "branch", "--list", "wallfacer-once/*"); branches != "" {
+		t.Errorf("throwaway branch left behind: %s", branches)
+	}
+}
+
+// TestRunOnceNonGitWorkspace verifies the snapshot path for a plain directory.
+func TestRunOnceNonGitWorkspace(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ws, "existing.txt"), []byte("hi\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(nil, RunnerConfig{
+		Command:      fakeEditingRuntime(t, endTurnOutput),
+		SandboxImage: "test:latest",
+	})
+
+	res, err := r.RunOnce(context.Background(), "add a file", ws)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !strings.Contains(res.Diff, "once.txt") || strings.Contains(res.Diff, "existing.txt") {
+		t.Errorf("diff should list only the new file:\n%s", res.Diff)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "once.txt")); !os.IsNotExist(err) {
+		t.Error("the workspace itself must not be modified")
+	}
+}
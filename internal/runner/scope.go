@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+)
+
+// ErrOutOfScope is returned by the commit pipeline when a task restricted to
+// Task.AllowedPaths changed files outside them.
+var ErrOutOfScope = errors.New("changes outside the task's allowed paths")
+
+// pathAllowed reports whether the slash-separated repo-relative file matches
+// one of globs. Globs use path.Match syntax; a trailing "/**" matches
+// everything below a directory.
+func pathAllowed(globs []string, file string) bool {
+	for _, g := range globs {
+		g = strings.TrimPrefix(path.Clean(g), "./")
+		if dir, ok := strings.CutSuffix(g, "/**"); ok && strings.HasPrefix(file, dir+"/") {
+			return true
+		}
+		if ok, _ := path.Match(g, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// outOfScopeFiles lists the files changed in the staged worktree relative to
+// base (its HEAD when base is empty) that no glob allows. Changes the agent
+// committed itself count as well as uncommitted ones.
+func outOfScopeFiles(ctx context.Context, worktreePath, base string, globs []string) ([]string, error) {
+	if base == "" {
+		base = "HEAD"
+	}
+	out, err := gitutil.Command(ctx, "-C", worktreePath, "diff", "--cached", "--name-only", "--no-renames", "-z", base).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list changed files: %w", err)
+	}
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" && !pathAllowed(globs, f) {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// revertFiles restores files in the staged worktree to their state at base,
+// deleting those that did not exist there, and stages the result.
+func revertFiles(ctx context.Context, worktreePath, base string, files []string) error {
+	if base == "" {
+		base = "HEAD"
+	}
+	for _, f := range files {
+		if gitutil.Command(ctx, "-C", worktreePath, "cat-file", "-e", base+":"+f).Run() == nil {
+			if out, err := gitutil.Command(ctx, "-C", worktreePath, "checkout", base, "--", f).CombinedOutput(); err != nil {
+				return fmt.Errorf("restore %s: %w\n%s", f, err, out)
+			}
+			continue
+		}
+		if out, err := gitutil.Command(ctx, "-C", worktreePath, "rm", "-q", "--cached", "--ignore-unmatch", "--", f).CombinedOutput(); err != nil {
+			return fmt.Errorf("unstage %s: %w\n%s", f, err, out)
+		}
+		if err := os.Remove(filepath.Join(worktreePath, filepath.FromSlash(f))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+)
+
+// ErrSecretDetected is returned by the commit pipeline when secret scanning
+// (RunnerConfig.SecretScan) finds a likely credential in a task's changes.
+var ErrSecretDetected = errors.New("possible secret in task changes")
+
+// secretRule is one pattern of the built-in secret ruleset. When minEntropy
+// is set, the first submatch must also look random enough.
+type secretRule struct {
+	name       string
+	re         *regexp.Regexp
+	minEntropy float64
+}
+
+// secretRules is deliberately small: it targets credentials that are
+// unambiguous (provider key formats, PEM headers) plus long random-looking
+// values assigned to secret-sounding names.
+var secretRules = []secretRule{
+	{name: "AWS access key ID", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "private key", re: regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----`)},
+	{name: "GitHub token", re: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{name: "Slack token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{
+		name:       "high-entropy token",
+		re:         regexp.MustCompile(`(?i)(?:secret|token|passw(?:or)?d|api_?key|access_?key)[a-z_]*["']?\s*(?::=|[:=])\s*["']?([A-Za-z0-9+/_=\-]{20,})`),
+		minEntropy: 3.5,
+	},
+}
+
+// secretFinding locates one rule match in a diff.
+type secretFinding struct {
+	File string
+	Rule string
+}
+
+// scanDiffForSecrets checks the lines a unified diff adds against
+// secretRules. The matched values are not returned, so reporting a finding
+// does not leak the secret further.
+func scanDiffForSecrets(diff []byte) []secretFinding {
+	var findings []secretFinding
+	var file string
+	sc := bufio.NewScanner(bytes.NewReader(diff))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if name, ok := strings.CutPrefix(line, "+++ "); ok {
+			file = strings.TrimPrefix(name, "b/")
+			continue
+		}
+		if !strings.HasPrefix(line, "+") {
+			continue
+		}
+		for _, rule := range secretRules {
+			m := rule.re.FindStringSubmatch(line[1:])
+			if m == nil {
+				continue
+			}
+			if rule.minEntropy > 0 && shannonEntropy(m[1]) < rule.minEntropy {
+				continue
+			}
+			findings = append(findings, secretFinding{File: file, Rule: rule.name})
+		}
+	}
+	return findings
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// checkSecrets scans what merging branchName into defBranch would add to
+// repoPath and returns ErrSecretDetected, naming the files and rules, when
+// anything matches. Non-git snapshots pass their base commit and HEAD.
+func checkSecrets(ctx context.Context, repoPath, defBranch, branchName string) error {
+	out, err := gitutil.Command(ctx, "-C", repoPath, "diff", "--no-color", "--no-ext-diff", "-U0", defBranch, branchName).Output()
+	if err != nil {
+		return fmt.Errorf("diff for secret scan: %w", err)
+	}
+	findings := scanDiffForSecrets(out)
+	if len(findings) == 0 {
+		return nil
+	}
+	var reasons []string
+	seen := make(map[secretFinding]bool)
+	for _, f := range findings {
+		if !seen[f] {
+			seen[f] = true
+			reasons = append(reasons, fmt.Sprintf("%s in %s", f.Rule, f.File))
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrSecretDetected, strings.Join(reasons, ", "))
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"sort"
+
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// defaultShortIDLength is the minimum length of a task short ID when
+// RunnerConfig.ShortIDLength is not set.
+const defaultShortIDLength = 8
+
+// shortIDs returns a short ID for every id in ids: the shortest prefix of its
+// canonical string form that is at least n characters long and is not a
+// prefix of any other id in the set. Prefixes that would collide are
+// extended one character at a time until they are unique.
+func shortIDs(ids []uuid.UUID, n int) map[uuid.UUID]string {
+	if n <= 0 {
+		n = defaultShortIDLength
+	}
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	sort.Strings(strs)
+
+	out := make(map[uuid.UUID]string, len(ids))
+	for i, s := range strs {
+		length := n
+		// In sorted order the longest shared prefix is always with a neighbour.
+		if i > 0 {
+			length = max(length, commonPrefixLen(s, strs[i-1])+1)
+		}
+		if i+1 < len(strs) {
+			length = max(length, commonPrefixLen(s, strs[i+1])+1)
+		}
+		length = min(length, len(s))
+		out[uuid.MustParse(s)] = s[:length]
+	}
+	return out
+}
+
+// taskShortIDs computes short IDs across tasks using the runner's configured
+// minimum length. Callers pass the same task set (the active board) so that
+// board.json and sibling mount paths agree.
+func (r *Runner) taskShortIDs(tasks []store.Task) map[uuid.UUID]string {
+	ids := make([]uuid.UUID, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return shortIDs(ids, r.shortIDLength)
+}
+
+// ShortID returns the short ID of taskID computed across every stored task,
+// as used in its branch name. Helper containers, archives and throwaway
+// branches are named with it, so two tasks sharing their first characters
+// never collide. A Runner without a store uses the minimum length.
+func (r *Runner) ShortID(taskID uuid.UUID) string {
+	ids := []uuid.UUID{taskID}
+	if r.store != nil {
+		if tasks, err := r.store.ListTasks(context.Background(), true); err == nil {
+			for _, t := range tasks {
+				if t.ID != taskID {
+					ids = append(ids, t.ID)
+				}
+			}
+		}
+	}
+	return shortIDs(ids, r.shortIDLength)[taskID]
+}
+
+// commonPrefixLen returns the length of the longest common prefix of a and b.
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
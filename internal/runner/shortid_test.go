@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestShortIDsDefaultLength verifies that non-colliding IDs use the minimum
+// length.
+func TestShortIDsDefaultLength(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	got := shortIDs([]uuid.UUID{a, b}, 0)
+	if got[a] != a.String()[:8] || got[b] != b.String()[:8] {
+		t.Fatalf("shortIDs = %v, want 8-char prefixes", got)
+	}
+}
+
+// TestShortIDsConfiguredLength verifies that the minimum length is honoured.
+func TestShortIDsConfiguredLength(t *testing.T) {
+	a := uuid.New()
+	if got := shortIDs([]uuid.UUID{a}, 12)[a]; got != a.String()[:12] {
+		t.Fatalf("short ID = %q, want %q", got, a.String()[:12])
+	}
+}
+
+// TestShortIDsCollision verifies that IDs sharing an 8-char prefix are
+// extended until they are distinct, while unrelated IDs keep 8 chars.
+func TestShortIDsCollision(t *testing.T) {
+	a := uuid.MustParse("aaaaaaaa-1111-4000-8000-000000000001")
+	b := uuid.MustParse("aaaaaaaa-1122-4000-8000-000000000002")
+	c := uuid.MustParse("bbbbbbbb-0000-4000-8000-000000000003")
+
+	got := shortIDs([]uuid.UUID{a, b, c}, 8)
+	if got[a] == got[b] {
+		t.Fatalf("colliding IDs got the same short ID %q", got[a])
+	}
+	if got[a] != "aaaaaaaa-111" || got[b] != "aaaaaaaa-112" {
+		t.Errorf("short IDs = %q, %q; want aaaaaaaa-111, aaaaaaaa-112", got[a], got[b])
+	}
+	if got[c] != "bbbbbbbb" {
+		t.Errorf("short ID for c = %q, want bbbbbbbb", got[c])
+	}
+}
+
+// TestRunnerShortIDCollision verifies that Runner.ShortID extends the prefix
+// of a task whose first characters collide with a stored task, so helper
+// containers and archives named after it stay distinct.
+func TestRunnerShortIDCollision(t *testing.T) {
+	s, r := setupRunnerWithCmd(t, nil, "true")
+	first, err := s.CreateTask(context.Background(), "first", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(first.ID.String())
+	if b[9] == 'a' {
+		b[9] = 'b'
+	} else {
+		b[9] = 'a'
+	}
+	second := uuid.MustParse(string(b))
+
+	got := r.ShortID(second)
+	if got == first.ID.String()[:8] || !strings.HasPrefix(second.String(), got) {
+		t.Fatalf("ShortID(%s) = %q, want a longer prefix distinct from %s", second, got, first.ID)
+	}
+	if r.ShortID(first.ID) == got {
+		t.Fatalf("colliding tasks share short ID %q", got)
+	}
+}
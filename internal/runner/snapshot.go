@@ -1,67 +1,217 @@
 package runner
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 
-	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/gitutil"
 )
 
 // setupNonGitSnapshot copies ws into snapshotPath and initialises a local git
 // repo there for change tracking. This lets the standard commit pipeline work
 // on non-git workspaces: Phase 1 commits changes in the snapshot, Phase 2
 // copies the snapshot back to ws (instead of rebasing into a remote branch).
-func setupNonGitSnapshot(ws, snapshotPath string) error {
-	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
+// authorName and authorEmail set the snapshot's commit identity; empty values
+// fall back to "Wallfacer" <wallfacer@local>. A non-empty subpath copies only
+// that subdirectory of ws, placed at the same relative path in the snapshot so
+// the container still sees it where it lives in the workspace.
+func setupNonGitSnapshot(ws, snapshotPath, subpath, authorName, authorEmail string) error {
+	if authorName == "" {
+		authorName = defaultGitAuthorName
+	}
+	if authorEmail == "" {
+		authorEmail = defaultGitAuthorEmail
+	}
+	src, dst := filepath.Join(ws, subpath), filepath.Join(snapshotPath, subpath)
+	if info, err := os.Stat(src); err != nil || !info.IsDir() {
+		return fmt.Errorf("snapshot subpath %q is not a directory in %s", subpath, ws)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
 		return fmt.Errorf("mkdir: %w", err)
 	}
-	// Copy all files (including hidden) from ws into the snapshot.
-	// The trailing "/." on the source ensures hidden files are included.
-	if out, err := exec.Command("cp", "-a", ws+"/.", snapshotPath).CombinedOutput(); err != nil {
+	// Copy all files (including hidden) from ws into the snapshot. The copy
+	// is done in Go rather than with cp so it works on every host OS.
+	if err := copyTree(src, dst); err != nil {
 		os.RemoveAll(snapshotPath)
-		return fmt.Errorf("cp workspace to snapshot: %w\n%s", err, out)
+		return fmt.Errorf("copy workspace to snapshot: %w", err)
 	}
 	// Initialise a git repo so Phase 1 (hostStageAndCommit) can commit changes.
-	if out, err := exec.Command("git", "-C", snapshotPath, "init").CombinedOutput(); err != nil {
+	if out, err := gitutil.Command(context.Background(), "-C", snapshotPath, "init").CombinedOutput(); err != nil {
 		os.RemoveAll(snapshotPath)
 		return fmt.Errorf("git init snapshot: %w\n%s", err, out)
 	}
-	exec.Command("git", "-C", snapshotPath, "config", "user.email", "wallfacer@local").Run()
-	exec.Command("git", "-C", snapshotPath, "config", "user.name", "Wallfacer").Run()
-	exec.Command("git", "-C", snapshotPath, "add", "-A").Run()
+	runGit(snapshotPath, "config", "user.email", authorEmail)
+	runGit(snapshotPath, "config", "user.name", authorName)
+	runGit(snapshotPath, "add", "-A")
 	// --allow-empty handles the edge case of an empty workspace.
-	exec.Command("git", "-C", snapshotPath, "commit", "--allow-empty", "-m", "wallfacer: initial snapshot").Run()
+	runGit(snapshotPath,
+		"-c", "user.name="+authorName, "-c", "user.email="+authorEmail,
+		"commit", "--allow-empty", "-m", "wallfacer: initial snapshot")
 	return nil
 }
 
 // extractSnapshotToWorkspace copies all changes from snapshotPath back to
-// the original workspace at targetPath, excluding the .git directory that was
-// added for change tracking. Uses rsync when available (handles deletions);
-// falls back to cp which covers new/modified files only.
-func extractSnapshotToWorkspace(snapshotPath, targetPath string) error {
-	// rsync handles new, modified, AND deleted files correctly.
+// the original workspace at targetPath, excluding the top-level .git directory
+// that was added for change tracking. That exclusion also keeps a .git already
+// present in targetPath (e.g. the user ran git init mid-task) from being
+// deleted. New, modified, and deleted files all propagate. A non-empty
+// subpath, matching the one given to setupNonGitSnapshot, restricts the sync
+// to that subdirectory so the rest of the workspace is left untouched.
+// rsync is used as a fast path when available; otherwise syncTree does the
+// same work in Go.
+func extractSnapshotToWorkspace(snapshotPath, targetPath, subpath string) error {
+	var exclude []string
+	if subpath == "" {
+		exclude = []string{".git"}
+	}
+	src, dst := filepath.Join(snapshotPath, subpath), filepath.Join(targetPath, subpath)
 	// --checksum is needed because files may have the same size and mtime
 	// but different content (e.g. macOS openrsync skips them otherwise).
-	if _, err := exec.LookPath("rsync"); err == nil {
-		out, err := exec.Command(
-			"rsync", "-a", "--checksum", "--delete", "--exclude=.git",
-			snapshotPath+"/", targetPath+"/",
-		).CombinedOutput()
+	if rsyncOnPath() {
+		args := []string{"-a", "--checksum", "--delete"}
+		for _, e := range exclude {
+			args = append(args, "--exclude=/"+e)
+		}
+		out, err := exec.Command("rsync", append(args, src+"/", dst+"/")...).CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("rsync snapshot to workspace: %w\n%s", err, out)
 		}
 		return nil
 	}
-	// Fallback: cp covers new/modified files; files deleted inside the sandbox
-	// are not removed from the original workspace.
-	logger.Runner.Warn("rsync not found; falling back to cp (deletions will not propagate to workspace)",
-		"snapshot", snapshotPath, "target", targetPath)
-	if out, err := exec.Command("cp", "-a", snapshotPath+"/.", targetPath).CombinedOutput(); err != nil {
-		return fmt.Errorf("cp snapshot to workspace: %w\n%s", err, out)
+	if err := syncTree(src, dst, exclude...); err != nil {
+		return fmt.Errorf("sync snapshot to workspace: %w", err)
 	}
-	// Remove the .git directory that cp may have brought over from the snapshot.
-	os.RemoveAll(filepath.Join(targetPath, ".git"))
 	return nil
 }
+
+// rsyncOnPath reports whether the rsync fast path of
+// extractSnapshotToWorkspace is available.
+func rsyncOnPath() bool {
+	_, err := exec.LookPath("rsync")
+	return err == nil
+}
+
+// syncTree makes dst mirror src: entries missing from src (or whose type
+// changed, e.g. a file that became a directory) are removed from dst, then
+// src is copied over with copyTree. Paths in exclude, relative to the tree
+// roots, are neither copied nor deleted.
+func syncTree(src, dst string, exclude ...string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	err := filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dst {
+			return nil
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		srcInfo, err := os.Lstat(filepath.Join(src, rel))
+		if err == nil && srcInfo.Mode().Type() == d.Type() {
+			return nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return copyTree(src, dst, exclude...)
+}
+
+// copyTree recursively copies the contents of src into dst, creating dst if
+// needed. Regular files keep their permission bits and modification time,
+// directories keep their permission bits, and symlinks are recreated as
+// symlinks with the same target. Existing files in dst are overwritten.
+// Paths in exclude, relative to src, are skipped.
+func copyTree(src, dst string, exclude ...string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			// Keep the owner write bit so the directory's contents can
+			// still be copied into it.
+			return os.Chmod(target, info.Mode().Perm()|0200)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info)
+		default:
+			// Sockets, devices and pipes have no meaningful copy.
+			return nil
+		}
+	})
+}
+
+// copyFile copies the regular file src to dst with info's mode and mtime.
+func copyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Remove first so a symlink at dst is replaced rather than followed and
+	// a read-only destination does not block the write.
+	os.Remove(dst)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
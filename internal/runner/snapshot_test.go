@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -30,7 +31,7 @@ func TestSetupNonGitSnapshotCopiesFiles(t *testing.T) {
 	}
 
 	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
-	if err := setupNonGitSnapshot(ws, snapshotPath); err != nil {
+	if err := setupNonGitSnapshot(ws, snapshotPath, "", "", ""); err != nil {
 		t.Fatal("setupNonGitSnapshot:", err)
 	}
 
@@ -58,7 +59,7 @@ func TestSetupNonGitSnapshotHasInitialCommit(t *testing.T) {
 	}
 
 	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
-	if err := setupNonGitSnapshot(ws, snapshotPath); err != nil {
+	if err := setupNonGitSnapshot(ws, snapshotPath, "", "", ""); err != nil {
 		t.Fatal(err)
 	}
 
@@ -71,12 +72,35 @@ func TestSetupNonGitSnapshotHasInitialCommit(t *testing.T) {
 	}
 }
 
+// TestSetupNonGitSnapshotUsesAuthor verifies that the initial snapshot commit
+// uses the given identity, and the default identity when none is given.
+func TestSetupNonGitSnapshotUsesAuthor(t *testing.T) {
+	for _, tc := range []struct {
+		name, email, want string
+	}{
+		{"", "", "Wallfacer <wallfacer@local>"},
+		{"Bot", "bot@example.com", "Bot <bot@example.com>"},
+	} {
+		snapshotPath := filepath.Join(t.TempDir(), "snapshot")
+		if err := setupNonGitSnapshot(t.TempDir(), snapshotPath, "", tc.name, tc.email); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("git", "-C", snapshotPath, "log", "--format=%an <%ae>", "-1").Output()
+		if err != nil {
+			t.Fatal("git log in snapshot:", err)
+		}
+		if got := strings.TrimSpace(string(out)); got != tc.want {
+			t.Errorf("author = %q, want %q", got, tc.want)
+		}
+	}
+}
+
 // TestSetupNonGitSnapshotEmptyWorkspace verifies that setupNonGitSnapshot
 // handles an empty workspace without error (uses --allow-empty commit).
 func TestSetupNonGitSnapshotEmptyWorkspace(t *testing.T) {
 	ws := t.TempDir() // deliberately empty
 	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
-	if err := setupNonGitSnapshot(ws, snapshotPath); err != nil {
+	if err := setupNonGitSnapshot(ws, snapshotPath, "", "", ""); err != nil {
 		t.Fatal("setupNonGitSnapshot on empty workspace should not fail:", err)
 	}
 	if _, err := os.Stat(filepath.Join(snapshotPath, ".git")); err != nil {
@@ -84,6 +108,71 @@ func TestSetupNonGitSnapshotEmptyWorkspace(t *testing.T) {
 	}
 }
 
+// TestNonGitSnapshotSubpath verifies that a snapshot limited to src/ skips
+// sibling directories, and that extraction writes back only under src/,
+// leaving the rest of the workspace untouched.
+func TestNonGitSnapshotSubpath(t *testing.T) {
+	ws := t.TempDir()
+	for path, content := range map[string]string{
+		"src/main.go":        "package main",
+		"src/old.go":         "package main",
+		"assets/big.bin":     strings.Repeat("x", 1<<20),
+		"assets/nested/data": "data",
+	} {
+		full := filepath.Join(ws, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshotPath := filepath.Join(t.TempDir(), "snap")
+
+	if err := setupNonGitSnapshot(ws, snapshotPath, "src", "", ""); err != nil {
+		t.Fatal("setupNonGitSnapshot:", err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshotPath, "src", "main.go")); err != nil {
+		t.Fatal("src/main.go should be in the snapshot:", err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshotPath, "assets")); !os.IsNotExist(err) {
+		t.Fatal("sibling assets/ should not be snapshotted")
+	}
+
+	// Simulate the task: edit one file, delete another.
+	if err := os.WriteFile(filepath.Join(snapshotPath, "src", "main.go"), []byte("package changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(snapshotPath, "src", "old.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extractSnapshotToWorkspace(snapshotPath, ws, "src"); err != nil {
+		t.Fatal("extractSnapshotToWorkspace:", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "src", "main.go")); string(data) != "package changed" {
+		t.Errorf("src/main.go = %q, want the task's edit", data)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "src", "old.go")); !os.IsNotExist(err) {
+		t.Error("src/old.go should be deleted from the workspace")
+	}
+	if _, err := os.Stat(filepath.Join(ws, "assets", "nested", "data")); err != nil {
+		t.Error("files outside the subpath should be untouched:", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, ".git")); !os.IsNotExist(err) {
+		t.Error("the snapshot's .git should not be extracted")
+	}
+}
+
+// TestSetupNonGitSnapshotMissingSubpath verifies that a subpath that does
+// not exist in the workspace is reported as an error.
+func TestSetupNonGitSnapshotMissingSubpath(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "snap")
+	if err := setupNonGitSnapshot(t.TempDir(), snapshotPath, "missing", "", ""); err == nil {
+		t.Fatal("expected an error for a missing subpath")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // extractSnapshotToWorkspace
 // ---------------------------------------------------------------------------
@@ -104,7 +193,7 @@ func TestExtractSnapshotToWorkspace(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := extractSnapshotToWorkspace(snapshot, target); err != nil {
+	if err := extractSnapshotToWorkspace(snapshot, target, ""); err != nil {
 		t.Fatal("extractSnapshotToWorkspace:", err)
 	}
 
@@ -121,8 +210,7 @@ func TestExtractSnapshotToWorkspace(t *testing.T) {
 }
 
 // TestExtractSnapshotDoesNotLeakGitDir verifies that the .git directory from
-// the snapshot is not extracted to the target workspace. rsync excludes it;
-// the cp fallback removes it afterward.
+// the snapshot is not extracted to the target workspace.
 func TestExtractSnapshotDoesNotLeakGitDir(t *testing.T) {
 	snapshot := t.TempDir()
 	target := t.TempDir()
@@ -138,7 +226,7 @@ func TestExtractSnapshotDoesNotLeakGitDir(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := extractSnapshotToWorkspace(snapshot, target); err != nil {
+	if err := extractSnapshotToWorkspace(snapshot, target, ""); err != nil {
 		t.Fatal("extractSnapshotToWorkspace:", err)
 	}
 
@@ -148,6 +236,228 @@ func TestExtractSnapshotDoesNotLeakGitDir(t *testing.T) {
 	}
 }
 
+// TestExtractSnapshotWithoutRsync verifies that extraction propagates
+// deletions and excludes .git when rsync is not on PATH.
+func TestExtractSnapshotWithoutRsync(t *testing.T) {
+	snapshot := t.TempDir()
+	target := t.TempDir()
+	t.Setenv("PATH", "")
+
+	if err := os.MkdirAll(filepath.Join(snapshot, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(snapshot, "app.txt"), []byte("app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "removed.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extractSnapshotToWorkspace(snapshot, target, ""); err != nil {
+		t.Fatal("extractSnapshotToWorkspace:", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "app.txt")); err != nil {
+		t.Fatal("app.txt should be in target:", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "removed.txt")); !os.IsNotExist(err) {
+		t.Error("removed.txt should be deleted from target")
+	}
+	if _, err := os.Stat(filepath.Join(target, ".git")); !os.IsNotExist(err) {
+		t.Error(".git should not be extracted to target")
+	}
+}
+
+// TestExtractSnapshotPreservesWorkspaceGitDir verifies that a .git directory
+// the workspace gained during the task survives extraction, with and without
+// rsync, rather than being removed along with the snapshot's tracking repo.
+func TestExtractSnapshotPreservesWorkspaceGitDir(t *testing.T) {
+	for _, name := range []string{"default", "no-rsync"} {
+		t.Run(name, func(t *testing.T) {
+			if name == "no-rsync" {
+				t.Setenv("PATH", "")
+			}
+			snapshot := t.TempDir()
+			target := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(snapshot, ".git"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(snapshot, ".git", "HEAD"), []byte("snapshot"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(filepath.Join(target, ".git"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(target, ".git", "HEAD"), []byte("workspace"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := extractSnapshotToWorkspace(snapshot, target, ""); err != nil {
+				t.Fatal("extractSnapshotToWorkspace:", err)
+			}
+			data, err := os.ReadFile(filepath.Join(target, ".git", "HEAD"))
+			if err != nil {
+				t.Fatal("workspace .git should be preserved:", err)
+			}
+			if string(data) != "workspace" {
+				t.Errorf(".git/HEAD = %q, want the workspace's own", data)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// copyTree
+// ---------------------------------------------------------------------------
+
+// TestCopyTreeCopiesNestedFiles verifies that copyTree reproduces nested
+// directories, hidden files, file modes and modification times.
+func TestCopyTreeCopiesNestedFiles(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(filepath.Join(src, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, ".hidden"), []byte("h"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(src, "a", "b", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(script, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dst, ".hidden")); err != nil || string(data) != "h" {
+		t.Errorf(".hidden = %q, %v; want %q", data, err, "h")
+	}
+	info, err := os.Stat(filepath.Join(dst, "a", "b", "run.sh"))
+	if err != nil {
+		t.Fatal("nested file should be copied:", err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+}
+
+// TestCopyTreeOverwritesExisting verifies that copyTree replaces files that
+// already exist in the destination and leaves unrelated files alone.
+func TestCopyTreeOverwritesExisting(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "f.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "f.txt"), []byte("old content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "f.txt")); string(data) != "new" {
+		t.Errorf("f.txt = %q, want %q", data, "new")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "keep.txt")); err != nil {
+		t.Error("unrelated destination file should be left alone:", err)
+	}
+}
+
+// TestCopyTreePreservesSymlinks verifies that symlinks are recreated as links
+// rather than followed.
+func TestCopyTreePreservesSymlinks(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "target.txt"), []byte("t"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target.txt", filepath.Join(src, "link")); err != nil {
+		t.Skip("symlinks not supported on this host:", err)
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree: %v", err)
+	}
+	link, err := os.Readlink(filepath.Join(dst, "link"))
+	if err != nil {
+		t.Fatal("link should be copied as a symlink:", err)
+	}
+	if link != "target.txt" {
+		t.Errorf("link target = %q, want %q", link, "target.txt")
+	}
+}
+
+// TestSyncTreePropagatesDeletions verifies that syncTree removes files and
+// directories deleted from the source, replaces entries whose type changed,
+// and leaves the excluded top-level entry untouched on both sides.
+func TestSyncTreePropagatesDeletions(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	for path, content := range map[string]string{
+		"keep.txt":        "new",
+		"became-dir/f":    "f",
+		".git/HEAD":       "snapshot",
+		"sub/.git/config": "nested",
+	} {
+		full := filepath.Join(src, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{
+		"keep.txt":        "old",
+		"deleted.txt":     "gone",
+		"gone/nested.txt": "gone",
+		"became-dir":      "was a file",
+		".git/HEAD":       "workspace",
+	} {
+		full := filepath.Join(dst, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := syncTree(src, dst, ".git"); err != nil {
+		t.Fatalf("syncTree: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dst, "keep.txt")); string(data) != "new" {
+		t.Errorf("keep.txt = %q, want %q", data, "new")
+	}
+	for _, path := range []string{"deleted.txt", "gone"} {
+		if _, err := os.Lstat(filepath.Join(dst, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist in destination (err=%v)", path, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "became-dir", "f")); string(data) != "f" {
+		t.Errorf("became-dir/f = %q, want %q", data, "f")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, ".git", "HEAD")); string(data) != "workspace" {
+		t.Errorf("excluded .git/HEAD = %q, want it untouched", data)
+	}
+	// Only the top-level entry is excluded; nested .git dirs are content.
+	if data, _ := os.ReadFile(filepath.Join(dst, "sub", ".git", "config")); string(data) != "nested" {
+		t.Errorf("sub/.git/config = %q, want %q", data, "nested")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Non-git commit pipeline integration
 // ---------------------------------------------------------------------------
@@ -179,9 +489,7 @@ func TestCommitPipelineNonGitWorkspace(t *testing.T) {
 	if err := s.UpdateTaskWorktrees(ctx, task.ID, wt, br); err != nil {
 		t.Fatal(err)
 	}
-	if err := s.UpdateTaskStatus(ctx, task.ID, "committing"); err != nil {
-		t.Fatal(err)
-	}
+	moveTask(t, s, task.ID, "committing")
 
 	// Simulate Claude modifying files inside the snapshot (the "container").
 	snapshotPath := wt[ws]
@@ -227,6 +535,7 @@ func TestRunEndToEndNonGitWorkspace(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	moveTask(t, s, task.ID, "in_progress")
 	r.Run(task.ID, "modify init.txt", "", false)
 
 	updated, _ := s.GetTask(ctx, task.ID)
@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsInterval is how often a running container's resource usage is
+// sampled. It is a variable so tests can shorten it.
+var statsInterval = 2 * time.Second
+
+// statsFormat is the `<runtime> stats` template understood by both Podman
+// and Docker: memory usage ("123.4MiB / 7.6GiB") and CPU percentage.
+const statsFormat = "{{.MemUsage}}|{{.CPUPerc}}"
+
+// resourceUsage is what sampling a container's stats yielded.
+type resourceUsage struct {
+	Samples         int
+	PeakMemoryBytes int64
+	CPUSeconds      float64 // CPU percentage integrated over the sampling intervals
+}
+
+// watchStats samples `<runtime> stats --no-stream` for containerName every
+// statsInterval until the returned stop function is called, which returns
+// the accumulated usage. Sampling is best-effort: a runtime without stats
+// support, or a container that has not started or already exited, simply
+// yields no samples. Containers run with --rm, so their stats are gone once
+// they exit; sampling while they run is the only way to observe them.
+func (r *Runner) watchStats(containerName string) (stop func() resourceUsage) {
+	var (
+		mu    sync.Mutex
+		usage resourceUsage
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			out, err := exec.CommandContext(ctx, r.command, "stats", "--no-stream", "--format", statsFormat, containerName).Output()
+			now := time.Now()
+			elapsed := now.Sub(last).Seconds()
+			last = now
+			if err != nil {
+				continue
+			}
+			mem, cpu, err := parseStats(string(out))
+			if err != nil {
+				continue
+			}
+			mu.Lock()
+			usage.Samples++
+			usage.PeakMemoryBytes = max(usage.PeakMemoryBytes, mem)
+			usage.CPUSeconds += cpu / 100 * elapsed
+			mu.Unlock()
+		}
+	}()
+
+	return func() resourceUsage {
+		cancel() // also kills a stats call still in flight
+		<-finished
+		mu.Lock()
+		defer mu.Unlock()
+		return usage
+	}
+}
+
+// parseStats parses one line of statsFormat output into the memory usage in
+// bytes and the CPU percentage.
+func parseStats(out string) (memBytes int64, cpuPercent float64, err error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	memField, cpuField, ok := strings.Cut(line, "|")
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected stats output %q", line)
+	}
+	used, _, _ := strings.Cut(memField, "/")
+	memBytes, err = parseByteSize(strings.TrimSpace(used))
+	if err != nil {
+		return 0, 0, err
+	}
+	cpuPercent, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(cpuField), "%"), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse cpu %q: %w", cpuField, err)
+	}
+	return memBytes, cpuPercent, nil
+}
+
+// byteUnits maps the size suffixes printed by Podman and Docker (decimal
+// and binary) to their multipliers.
+var byteUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// parseByteSize parses a human-readable size such as "123.4MiB" or "2GB".
+func parseByteSize(s string) (int64, error) {
+	i := strings.IndexFunc(s, func(c rune) bool {
+		return (c < '0' || c > '9') && c != '.'
+	})
+	if i <= 0 {
+		return 0, fmt.Errorf("parse size %q", s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size %q: %w", s, err)
+	}
+	mult, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("parse size %q: unknown unit", s)
+	}
+	return int64(n * mult), nil
+}
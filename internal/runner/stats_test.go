@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseStats(t *testing.T) {
+	tests := []struct {
+		in   string
+		mem  int64
+		cpu  float64
+		fail bool
+	}{
+		{in: "123.5MiB / 7.6GiB|12.34%\n", mem: 129499136, cpu: 12.34},
+		{in: "2GB / 8GB|100%", mem: 2e9, cpu: 100},
+		{in: "0B / 0B|0.00%", mem: 0, cpu: 0},
+		{in: "512kB / 1GB|--", fail: true},
+		{in: "no stats here", fail: true},
+		{in: "12XB / 1GB|1%", fail: true},
+	}
+	for _, tc := range tests {
+		mem, cpu, err := parseStats(tc.in)
+		if tc.fail {
+			if err == nil {
+				t.Errorf("parseStats(%q): expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseStats(%q): %v", tc.in, err)
+			continue
+		}
+		if mem != tc.mem || cpu != tc.cpu {
+			t.Errorf("parseStats(%q) = %d, %v; want %d, %v", tc.in, mem, cpu, tc.mem, tc.cpu)
+		}
+	}
+}
+
+// fakeStatsRuntime returns a fake container runtime whose `run` takes a
+// moment and prints output, and whose `stats` prints statsLine.
+func fakeStatsRuntime(t *testing.T, output, statsLine string) string {
+	t.Helper()
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(dataPath, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+stats) echo '%s' ;;
+run) sleep 0.3; cat %s ;;
+esac
+`, statsLine, dataPath)
+	path := filepath.Join(dir, "fake-runtime")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestRunContainerRecordsResourceUsage verifies that stats sampled while
+// the container runs end up on the task.
+func TestRunContainerRecordsResourceUsage(t *testing.T) {
+	old := statsInterval
+	statsInterval = 20 * time.Millisecond
+	defer func() { statsInterval = old }()
+
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeStatsRuntime(t, endTurnOutput, "256MiB / 2GiB|50.00%"))
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "p", 5, false)
+
+	if _, _, _, err := r.runContainer(ctx, task.ID, "p", "", nil, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.PeakMemoryBytes != 256<<20 {
+		t.Errorf("PeakMemoryBytes = %d, want %d", got.PeakMemoryBytes, 256<<20)
+	}
+	if got.CPUSeconds <= 0 {
+		t.Errorf("CPUSeconds = %v, want > 0", got.CPUSeconds)
+	}
+}
+
+// TestRunContainerWithoutStats verifies that a runtime whose stats command
+// fails leaves the resource fields empty without failing the run.
+func TestRunContainerWithoutStats(t *testing.T) {
+	old := statsInterval
+	statsInterval = 20 * time.Millisecond
+	defer func() { statsInterval = old }()
+
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeStatsRuntime(t, endTurnOutput, "unsupported"))
+	ctx := context.Background()
+	task, _ := s.CreateTask(ctx, "p", 5, false)
+
+	if _, _, _, err := r.runContainer(ctx, task.ID, "p", "", nil, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetTask(ctx, task.ID)
+	if got.PeakMemoryBytes != 0 || got.CPUSeconds != 0 {
+		t.Errorf("resources = %d, %v; want zero", got.PeakMemoryBytes, got.CPUSeconds)
+	}
+}
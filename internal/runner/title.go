@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/util"
 	"github.com/google/uuid"
 )
 
@@ -23,7 +24,7 @@ func (r *Runner) GenerateTitle(taskID uuid.UUID, prompt string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	containerName := "wallfacer-title-" + taskID.String()[:8]
+	containerName := r.containerPrefix() + "title-" + r.ShortID(taskID)
 	exec.Command(r.command, "rm", "-f", containerName).Run()
 
 	args := []string{"run", "--rm", "--network=host", "--name", containerName}
@@ -47,7 +48,7 @@ func (r *Runner) GenerateTitle(taskID uuid.UUID, prompt string) {
 
 	if err := cmd.Run(); err != nil && ctx.Err() == nil {
 		logger.Runner.Warn("title generation failed", "task", taskID, "error", err,
-			"stderr", truncate(stderr.String(), 200))
+			"stderr", util.Truncate(stderr.String(), 200))
 		return
 	}
 
@@ -59,7 +60,7 @@ func (r *Runner) GenerateTitle(taskID uuid.UUID, prompt string) {
 
 	output, err := parseOutput(raw)
 	if err != nil {
-		logger.Runner.Warn("title generation: parse failure", "task", taskID, "raw", truncate(raw, 200))
+		logger.Runner.Warn("title generation: parse failure", "task", taskID, "raw", util.Truncate(raw, 200))
 		return
 	}
 
@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"changkun.de/wallfacer/internal/envconfig"
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"changkun.de/wallfacer/internal/util"
+)
+
+// minRedactedValue is the shortest env value masked literally in a
+// transcript: shorter ones (flags such as "1" or "true") would mangle
+// unrelated text without protecting anything.
+const minRedactedValue = 8
+
+// transcriptPrompt is the line recorded before a turn's output that started
+// from a prompt, so the transcript carries the human side of the
+// conversation too. Auto-continued turns have none.
+type transcriptPrompt struct {
+	Type   string `json:"type"` // always "wallfacer_prompt"
+	Turn   int    `json:"turn"`
+	Prompt string `json:"prompt"`
+}
+
+// recordTranscript appends one turn to the task's transcript: its prompt,
+// if any, then the agent's stream-json output, redacted.
+func (r *Runner) recordTranscript(task *store.Task, turn int, prompt string, stdout []byte) {
+	var buf bytes.Buffer
+	if prompt != "" {
+		line, _ := json.Marshal(transcriptPrompt{Type: "wallfacer_prompt", Turn: turn, Prompt: prompt})
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if out := bytes.TrimSpace(stdout); len(out) > 0 {
+		buf.Write(out)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return
+	}
+	data := redactTranscript(buf.Bytes(), r.transcriptSecrets(task))
+	if err := r.store.AppendTranscript(task.ID, data); err != nil {
+		logger.Runner.Warn("transcript", "task", task.ID, "error", err)
+	}
+}
+
+// transcriptSecrets returns the values masked literally in the task's
+// transcript: the credentials in the env file and the task's own env
+// values, which logging masks in container arguments too.
+func (r *Runner) transcriptSecrets(task *store.Task) []string {
+	var secrets []string
+	if r.envFile != "" {
+		if cfg, err := envconfig.Parse(r.envFile); err == nil {
+			secrets = append(secrets, cfg.OAuthToken, cfg.APIKey)
+		}
+	}
+	for _, v := range task.Env {
+		secrets = append(secrets, v)
+	}
+	return secrets
+}
+
+// redactTranscript masks secrets (literal values, plain and JSON-escaped),
+// the matches of the secret scan's rules, and the URL passwords and
+// authorization headers that logging masks too (util.RedactCredentials).
+func redactTranscript(data []byte, secrets []string) []byte {
+	mask := []byte(redacted)
+	for _, v := range secrets {
+		if len(v) < minRedactedValue {
+			continue
+		}
+		data = bytes.ReplaceAll(data, []byte(v), mask)
+		if escaped, err := json.Marshal(v); err == nil {
+			data = bytes.ReplaceAll(data, escaped[1:len(escaped)-1], mask)
+		}
+	}
+	for _, rule := range secretRules {
+		data = rule.re.ReplaceAllFunc(data, func(m []byte) []byte {
+			if rule.minEntropy == 0 {
+				return mask
+			}
+			sub := rule.re.FindSubmatchIndex(m)
+			if sub == nil || shannonEntropy(string(m[sub[2]:sub[3]])) < rule.minEntropy {
+				return m
+			}
+			return append(append(append([]byte{}, m[:sub[2]]...), mask...), m[sub[3]:]...)
+		})
+	}
+	return []byte(util.RedactCredentials(string(data)))
+}
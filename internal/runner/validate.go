@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"changkun.de/wallfacer/internal/gitutil"
+)
+
+// isContainerRuntime reports whether command names podman or docker (or is
+// empty, which NewRunner resolves to one of them), as opposed to a stand-in
+// such as the scripts used by tests.
+func isContainerRuntime(command string) bool {
+	switch filepath.Base(command) {
+	case ".", "podman", "docker": // filepath.Base("") is "."
+		return true
+	}
+	return false
+}
+
+// Validate checks cfg for values that would otherwise only fail once a task
+// runs: a missing sandbox image, relative or repeated workspaces, negative
+// limits, unknown enum values, and options that cannot be combined. It
+// returns the first problem found.
+func (cfg RunnerConfig) Validate() error {
+	if cfg.SandboxImage == "" && isContainerRuntime(cfg.Command) {
+		command := cfg.Command
+		if command == "" {
+			command = "the auto-detected runtime"
+		}
+		return fmt.Errorf("sandbox image is required to run tasks with %s", command)
+	}
+
+	seen := make(map[string]bool)
+	for _, ws := range strings.Fields(cfg.Workspaces) {
+		if !filepath.IsAbs(ws) {
+			return fmt.Errorf("workspace %q is not an absolute path", ws)
+		}
+		if seen[ws] {
+			return fmt.Errorf("workspace %q is listed twice", ws)
+		}
+		seen[ws] = true
+	}
+
+	if cfg.InstructionsMountPath != "" && !path.IsAbs(cfg.InstructionsMountPath) {
+		return fmt.Errorf("instructions mount path %q is not an absolute path", cfg.InstructionsMountPath)
+	}
+
+	if cfg.WaitingTimeout < 0 {
+		return fmt.Errorf("waiting timeout %s is negative", cfg.WaitingTimeout)
+	}
+	if cfg.PreMergeDelay < 0 {
+		return fmt.Errorf("pre-merge delay %s is negative", cfg.PreMergeDelay)
+	}
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("max retries %d is negative", cfg.MaxRetries)
+	}
+	if cfg.MaxDiffLines < 0 {
+		return fmt.Errorf("max diff lines %d is negative", cfg.MaxDiffLines)
+	}
+	if cfg.ShortIDLength < 0 || cfg.ShortIDLength > 36 {
+		return fmt.Errorf("short ID length %d is not between 0 and 36", cfg.ShortIDLength)
+	}
+
+	switch cfg.WaitingTimeoutAction {
+	case "", WaitingTimeoutCommit, WaitingTimeoutFail:
+	default:
+		return fmt.Errorf("unknown waiting timeout action %q", cfg.WaitingTimeoutAction)
+	}
+	switch cfg.NotifyFormat {
+	case "", NotifyFormatRaw, NotifyFormatSlack:
+	default:
+		return fmt.Errorf("unknown notify format %q", cfg.NotifyFormat)
+	}
+	if err := gitutil.ValidateRebaseArgs(cfg.RebaseArgs); err != nil {
+		return err
+	}
+	if err := ValidateInstance(cfg.Instance); err != nil {
+		return err
+	}
+
+	if cfg.ShallowWorktree && (len(cfg.RebaseArgs) > 0 || cfg.Rerere) {
+		return errors.New("rebase options have no effect with shallow worktrees, which are never rebased")
+	}
+	if cfg.ReadOnlyWorkspace && filepath.Base(cfg.Command) == "docker" {
+		return errors.New("read-only workspaces need Podman overlay mounts; docker does not support them")
+	}
+	if cfg.SeccompProfile != "" && !cfg.HardenedSandbox {
+		return errors.New("a seccomp profile is only applied to hardened sandboxes")
+	}
+	return nil
+}
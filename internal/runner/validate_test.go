@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/wallfacer/internal/store"
+)
+
+// TestNewRunnerCheckedRequiresImage verifies that a real runtime without a
+// sandbox image is rejected with an error naming the problem.
+func TestNewRunnerCheckedRequiresImage(t *testing.T) {
+	s, err := store.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	r, err := NewRunnerChecked(s, RunnerConfig{Command: "podman"})
+	if err == nil {
+		t.Fatal("expected an error for an empty sandbox image")
+	}
+	if r != nil {
+		t.Error("no runner should be returned on error")
+	}
+	if !strings.Contains(err.Error(), "sandbox image is required") || !strings.Contains(err.Error(), "podman") {
+		t.Errorf("error %q does not describe the missing image", err)
+	}
+}
+
+func TestRunnerConfigValidate(t *testing.T) {
+	valid := []RunnerConfig{
+		{Command: "podman", SandboxImage: "wallfacer:latest", Workspaces: "/a /b"},
+		{Command: "/usr/bin/docker", SandboxImage: "wallfacer:latest", WaitingTimeout: time.Hour, WaitingTimeoutAction: WaitingTimeoutCommit},
+		{Command: "echo"}, // test stand-ins need no image
+		{Command: "podman", SandboxImage: "img", HardenedSandbox: true, SeccompProfile: "/etc/seccomp.json"},
+	}
+	for i, cfg := range valid {
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("valid[%d]: Validate() = %v, want nil", i, err)
+		}
+	}
+
+	invalid := map[string]RunnerConfig{
+		"auto-detected runtime without image": {},
+		"relative workspace":                  {Command: "echo", Workspaces: "/a rel"},
+		"duplicate workspace":                 {Command: "echo", Workspaces: "/a /a"},
+		"negative waiting timeout":            {Command: "echo", WaitingTimeout: -time.Second},
+		"negative max retries":                {Command: "echo", MaxRetries: -1},
+		"negative pre-merge delay":            {Command: "echo", PreMergeDelay: -time.Second},
+		"negative max diff lines":             {Command: "echo", MaxDiffLines: -1},
+		"short ID too long":                   {Command: "echo", ShortIDLength: 37},
+		"unknown waiting action":              {Command: "echo", WaitingTimeoutAction: "ignore"},
+		"unknown notify format":               {Command: "echo", NotifyFormat: "xml"},
+		"forbidden rebase arg":                {Command: "echo", RebaseArgs: []string{"--exec=true"}},
+		"invalid instance":                    {Command: "echo", Instance: "a/b"},
+		"shallow with rerere":                 {Command: "echo", ShallowWorktree: true, Rerere: true},
+		"read-only on docker":                 {Command: "docker", SandboxImage: "img", ReadOnlyWorkspace: true},
+		"seccomp without hardening":           {Command: "echo", SeccompProfile: "/etc/seccomp.json"},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", name)
+		}
+	}
+}
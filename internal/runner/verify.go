@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxVerifyOutput caps how much of a failed verify run's output is kept in
+// the task's event log.
+const maxVerifyOutput = 4000
+
+// verifyArgs returns the container run arguments for the verify command: a
+// fresh container with copies of the task's worktrees (see verifyCopies)
+// mounted where the agent saw them, running the command with sh instead of
+// the image's agent entrypoint.
+func (r *Runner) verifyArgs(containerName string, worktreePaths map[string]string) []string {
+	args := []string{"run", "--rm", "--network=host", "--name", containerName}
+	if r.envFile != "" {
+		args = append(args, "--env-file", r.envFile)
+	}
+
+	var basenames []string
+	for _, ws := range strings.Fields(r.workspaces) {
+		hostPath, ok := worktreePaths[ws]
+		if !ok {
+			continue
+		}
+		basename := filepath.Base(ws)
+		basenames = append(basenames, basename)
+		args = append(args, "-v", mountPath(hostPath)+":/workspace/"+basename+":z")
+		// As for the agent: a linked worktree needs the main repo's .git
+		// directory at its host path. Read-only, so git commands of the
+		// verify command cannot touch the task's index or branch.
+		if !r.shallowWorktree {
+			gitDir := filepath.Join(ws, ".git")
+			if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+				args = append(args, "-v", mountPath(gitDir)+":"+mountPath(gitDir)+":z,ro")
+			}
+		}
+	}
+
+	workdir := "/workspace"
+	if len(basenames) == 1 {
+		workdir = "/workspace/" + basenames[0]
+	}
+	if r.hardenedSandbox {
+		args = append(args, "--cap-drop=ALL", "--security-opt=no-new-privileges")
+		if profile := r.seccompProfilePath(); profile != "" {
+			args = append(args, "--security-opt=seccomp="+profile)
+		}
+	}
+	args = append(args, r.extraRunArgs...)
+	args = append(args, "-w", workdir, "--entrypoint", "sh", r.sandboxImage, "-c", r.verifyCommand)
+	return args
+}
+
+// verify runs the configured verify command against copies of the task's
+// worktrees and returns its combined output. The error is non-nil when the
+// command exits non-zero or the container cannot be started.
+func (r *Runner) verify(ctx context.Context, taskID uuid.UUID, worktreePaths map[string]string) (string, error) {
+	copies, cleanup, err := verifyCopies(worktreePaths)
+	if err != nil {
+		return "", fmt.Errorf("copy worktrees for verification: %w", err)
+	}
+	defer cleanup()
+
+	containerName := r.containerPrefix() + "verify-" + r.ShortID(taskID)
+	exec.Command(r.command, "rm", "-f", containerName).Run()
+
+	cmd := exec.CommandContext(ctx, r.command, r.verifyArgs(containerName, copies)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+	return out.String(), err
+}
+
+// verifyCopies copies each worktree into a throwaway directory and returns
+// the copies, keyed like worktreePaths, with a function removing them. The
+// verify command runs against the copies, so whatever it writes (build
+// output, coverage files, caches) never reaches the worktrees and is not
+// swept into the task's commit.
+func verifyCopies(worktreePaths map[string]string) (map[string]string, func(), error) {
+	tmp, err := os.MkdirTemp("", "wallfacer-verify-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	copies := make(map[string]string, len(worktreePaths))
+	i := 0
+	for ws, wt := range worktreePaths {
+		// Numbered parents keep workspaces with the same basename apart.
+		dst := filepath.Join(tmp, strconv.Itoa(i), filepath.Base(wt))
+		i++
+		if err := copyTree(wt, dst); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		copies[ws] = dst
+	}
+	return copies, cleanup, nil
+}
+
+// verifyOutputTail returns the last maxVerifyOutput bytes of out, where
+// test runners print their failures and summary.
+func verifyOutputTail(out string) string {
+	out = strings.TrimSpace(out)
+	if len(out) <= maxVerifyOutput {
+		return out
+	}
+	return "…" + strings.ToValidUTF8(out[len(out)-maxVerifyOutput:], "")
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// Actions accepted by RunnerConfig.WaitingTimeoutAction.
+const (
+	WaitingTimeoutCommit = "commit"
+	WaitingTimeoutFail   = "fail"
+)
+
+// maxWaitingSweepInterval caps how often WatchWaitingTimeout scans the board
+// for long timeouts.
+const maxWaitingSweepInterval = time.Minute
+
+// WatchWaitingTimeout periodically moves tasks that have sat in waiting for
+// longer than the configured WaitingTimeout to committing (action "commit",
+// which runs the commit pipeline) or failed (action "fail"). A task's
+// WaitingSince marks the start of its wait, so unrelated updates such as a
+// title change do not extend it. Tasks with a HoldReason are never committed
+// this way; the "commit" action leaves them waiting. It blocks until ctx is
+// cancelled and is a no-op when no timeout is configured.
+func (r *Runner) WatchWaitingTimeout(ctx context.Context) {
+	if r.waitingTimeout <= 0 {
+		return
+	}
+	interval := min(r.waitingTimeout/2, maxWaitingSweepInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		tasks, err := r.store.ListTasks(ctx, false)
+		if err != nil {
+			continue
+		}
+		for _, t := range tasks {
+			if t.Status == "waiting" && time.Since(waitingSince(t)) > r.waitingTimeout {
+				r.expireWaiting(ctx, t.ID)
+			}
+		}
+	}
+}
+
+// expireWaiting applies the waiting-timeout action to a single task. The task
+// is re-read first so one that a user resumed since the scan is left alone,
+// and every transition is a compare-and-swap against that read so a user
+// completing or resuming the task at the same moment wins.
+func (r *Runner) expireWaiting(ctx context.Context, taskID uuid.UUID) {
+	task, err := r.store.GetTask(ctx, taskID)
+	if err != nil || task.Status != "waiting" {
+		return
+	}
+	msg := fmt.Sprintf("No response within waiting timeout (%s).", r.waitingTimeout)
+
+	if r.waitingTimeoutAction == WaitingTimeoutCommit && task.HoldReason != "" {
+		// The runner held the task back from merging; only a person may
+		// commit it.
+		logger.Runner.Debug("waiting timeout: task held, not committing", "task", taskID, "hold", task.HoldReason)
+		return
+	}
+	if r.waitingTimeoutAction != WaitingTimeoutCommit {
+		if err := r.store.UpdateTaskStatusIfVersion(ctx, taskID, "waiting", "failed", task.Version); err != nil {
+			logger.Runner.Warn("waiting timeout", "task", taskID, "error", err)
+			return
+		}
+		r.store.InsertEvent(ctx, taskID, store.EventTypeError, map[string]string{"error": msg})
+		r.store.InsertEvent(ctx, taskID, store.EventTypeStateChange, map[string]string{
+			"from": "waiting",
+			"to":   "failed",
+		})
+		return
+	}
+
+	if task.SessionID == nil || *task.SessionID == "" {
+		// Nothing to commit: accept the task as done.
+		if err := r.store.UpdateTaskStatusIfVersion(ctx, taskID, "waiting", "done", task.Version); err != nil {
+			logger.Runner.Warn("waiting timeout", "task", taskID, "error", err)
+			return
+		}
+		r.store.InsertEvent(ctx, taskID, store.EventTypeSystem, map[string]string{"result": msg})
+		r.store.InsertEvent(ctx, taskID, store.EventTypeStateChange, map[string]string{
+			"from": "waiting",
+			"to":   "done",
+		})
+		return
+	}
+
+	if err := r.store.UpdateTaskStatusIfVersion(ctx, taskID, "waiting", "committing", task.Version); err != nil {
+		logger.Runner.Warn("waiting timeout", "task", taskID, "error", err)
+		return
+	}
+	r.store.InsertEvent(ctx, taskID, store.EventTypeSystem, map[string]string{"result": msg + " Committing."})
+	r.store.InsertEvent(ctx, taskID, store.EventTypeStateChange, map[string]string{
+		"from": "waiting",
+		"to":   "committing",
+	})
+	go r.RunCommit(taskID, *task.SessionID)
+}
+
+// holdForReview moves a task that finished its turn to waiting instead of
+// committing it, recording reason (one of the store.Hold* constants) so the
+// waiting timeout leaves the commit to a person. msg explains the hold in the
+// task's event trail.
+func (r *Runner) holdForReview(ctx context.Context, taskID uuid.UUID, reason, msg string) {
+	r.store.SetTaskHoldReason(ctx, taskID, reason)
+	r.store.UpdateTaskStatus(ctx, taskID, "waiting")
+	r.store.InsertEvent(ctx, taskID, store.EventTypeSystem, map[string]string{"result": msg})
+	r.store.InsertEvent(ctx, taskID, store.EventTypeStateChange, map[string]string{
+		"from": "in_progress",
+		"to":   "waiting",
+	})
+}
+
+// waitingSince returns when t entered waiting. Tasks saved before
+// WaitingSince was recorded fall back to their last update.
+func waitingSince(t store.Task) time.Time {
+	if t.WaitingSince != nil {
+		return *t.WaitingSince
+	}
+	return t.UpdatedAt
+}
@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// waitForStatus polls until the task reaches want or the deadline passes.
+func waitForStatus(t *testing.T, s *store.Store, id uuid.UUID, want string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		task, err := s.GetTask(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Status == want {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	task, _ := s.GetTask(context.Background(), id)
+	t.Fatalf("task status = %q, want %q", task.Status, want)
+}
+
+// TestWaitingTimeoutFails verifies that the default action moves a task left
+// in waiting past the timeout to failed.
+func TestWaitingTimeoutFails(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, waitingOutput, 0))
+	r.waitingTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	task, _ := s.CreateTask(ctx, "wait forever", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "prompt", "", false)
+	if got, _ := s.GetTask(ctx, task.ID); got.Status != "waiting" {
+		t.Fatalf("expected waiting after run, got %q", got.Status)
+	}
+
+	go r.WatchWaitingTimeout(ctx)
+	waitForStatus(t, s, task.ID, "failed")
+}
+
+// TestWaitingTimeoutCommits verifies that the "commit" action runs the commit
+// pipeline for a timed-out waiting task and ends in done.
+func TestWaitingTimeoutCommits(t *testing.T) {
+	repo := setupTestRepo(t)
+	s, r := setupRunnerWithCmd(t, []string{repo}, fakeCmdScript(t, waitingOutput, 0))
+	r.waitingTimeout = 100 * time.Millisecond
+	r.waitingTimeoutAction = WaitingTimeoutCommit
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	task, _ := s.CreateTask(ctx, "wait forever", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	r.Run(task.ID, "prompt", "", false)
+
+	go r.WatchWaitingTimeout(ctx)
+	waitForStatus(t, s, task.ID, "done")
+}
+
+// TestWaitingTimeoutIgnoresUnrelatedUpdates verifies that the wait is
+// measured from the entry into waiting, not from the last write to the task.
+func TestWaitingTimeoutIgnoresUnrelatedUpdates(t *testing.T) {
+	s, r := setupRunnerWithCmd(t, nil, "true")
+	r.waitingTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	task, _ := s.CreateTaskWithStatus(ctx, "p", 5, false, "waiting")
+	go r.WatchWaitingTimeout(ctx)
+	// Keep touching the task well within the timeout. The writer is stopped
+	// before the test returns so it cannot race the temp dir cleanup.
+	touched := make(chan struct{})
+	go func() {
+		defer close(touched)
+		for ctx.Err() == nil {
+			s.UpdateTaskTitle(ctx, task.ID, "still here")
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	waitForStatus(t, s, task.ID, "failed")
+	cancel()
+	<-touched
+}
+
+// TestWaitingTimeoutHeldTasks verifies that the commit action never settles
+// a task the runner held in waiting for a person, while the fail action still
+// fails it.
+func TestWaitingTimeoutHeldTasks(t *testing.T) {
+	tests := []struct {
+		name   string
+		hold   string
+		action string
+		want   string
+	}{
+		{"unheld commit", "", WaitingTimeoutCommit, "done"},
+		{"read-only commit", store.HoldReadOnly, WaitingTimeoutCommit, "waiting"},
+		{"read-only fail", store.HoldReadOnly, WaitingTimeoutFail, "failed"},
+		{"large diff commit", store.HoldLargeDiff, WaitingTimeoutCommit, "waiting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, r := setupRunnerWithCmd(t, nil, "true")
+			r.waitingTimeout = time.Minute
+			r.waitingTimeoutAction = tt.action
+			ctx := context.Background()
+
+			task, _ := s.CreateTaskWithStatus(ctx, "p", 5, false, "waiting")
+			if err := s.SetTaskHoldReason(ctx, task.ID, tt.hold); err != nil {
+				t.Fatal(err)
+			}
+			r.expireWaiting(ctx, task.ID)
+			if got, _ := s.GetTask(ctx, task.ID); got.Status != tt.want {
+				t.Errorf("status = %q, want %q", got.Status, tt.want)
+			}
+		})
+	}
+}
+
+// TestWaitingTimeoutDisabled verifies that a zero timeout leaves waiting
+// tasks alone.
+func TestWaitingTimeoutDisabled(t *testing.T) {
+	s, r := setupRunnerWithCmd(t, nil, "true")
+	ctx := context.Background()
+
+	task, _ := s.CreateTaskWithStatus(ctx, "p", 5, false, "waiting")
+	done := make(chan struct{})
+	go func() {
+		r.WatchWaitingTimeout(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WatchWaitingTimeout did not return with no timeout configured")
+	}
+	if got, _ := s.GetTask(ctx, task.ID); got.Status != "waiting" {
+		t.Errorf("status = %q, want waiting", got.Status)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"changkun.de/wallfacer/internal/gitutil"
 	"changkun.de/wallfacer/internal/logger"
@@ -13,14 +14,25 @@ import (
 )
 
 // setupWorktrees creates an isolated working directory for each workspace.
-// For git-backed workspaces a proper git worktree is created.
+// The HEAD of every newly created worktree is recorded as the task's base
+// commit for that repo so ResetWorktree can return to it later.
+// For git-backed workspaces a proper git worktree is created, or a shallow
+// clone when ShallowWorktree is configured.
 // For non-git workspaces a snapshot copy is created and tracked with a local
-// git repo so that the same commit pipeline can be used for both cases.
+// git repo so that the same commit pipeline can be used for both cases; a
+// task's SnapshotSubpath limits that copy to one subdirectory.
 // Returns (worktreePaths, branchName, error).
 // Idempotent: if the worktree/snapshot directory already exists it is reused.
 func (r *Runner) setupWorktrees(taskID uuid.UUID) (map[string]string, string, error) {
-	branchName := "task/" + taskID.String()[:8]
+	branchName := r.taskBranchName(taskID)
+	var subpath string
+	var recorded map[string]string
+	if task, err := r.store.GetTask(context.Background(), taskID); err == nil {
+		subpath = task.SnapshotSubpath
+		recorded = task.BaseCommits
+	}
 	worktreePaths := make(map[string]string)
+	baseCommits := make(map[string]string)
 
 	for _, ws := range r.Workspaces() {
 		basename := filepath.Base(ws)
@@ -37,24 +49,181 @@ func (r *Runner) setupWorktrees(taskID uuid.UUID) (map[string]string, string, er
 			return nil, "", fmt.Errorf("mkdir worktree parent: %w", err)
 		}
 
-		if gitutil.IsGitRepo(ws) {
-			if err := gitutil.CreateWorktree(ws, worktreePath, branchName); err != nil {
+		// A repo with a configured default branch starts its tasks there
+		// rather than at whatever is checked out.
+		start := gitutil.BranchOverride(ws, r.defaultBranches)
+		base := ""
+		if gitutil.IsGitRepo(context.Background(), ws) && r.shallowWorktree {
+			var err error
+			if start != "" {
+				err = gitutil.CreateShallowCloneFrom(ws, worktreePath, branchName, start)
+			} else {
+				err = gitutil.CreateShallowClone(ws, worktreePath, branchName)
+			}
+			if err != nil {
+				r.cleanupWorktrees(taskID, worktreePaths, branchName)
+				return nil, "", fmt.Errorf("shallow clone for %s: %w", ws, err)
+			}
+		} else if gitutil.IsGitRepo(context.Background(), ws) {
+			if gitutil.IsShallow(ws) {
+				r.handleShallowWorkspace(taskID, ws)
+			}
+			if start == "" {
+				start = "HEAD"
+			}
+			// A crashed run's branch is judged against the base it was cut
+			// from, recorded before the crash, rather than against wherever
+			// start has moved since.
+			startHash, err := gitutil.GetCommitHashForRef(context.Background(), ws, start+"^{commit}")
+			if err == nil {
+				base = recorded[ws]
+				if base == "" {
+					base = startHash
+				}
+				var reused bool
+				reused, err = r.recoverStaleBranch(taskID, ws, worktreePath, branchName, base)
+				if err == nil && !reused {
+					base = startHash
+					err = gitutil.CreateWorktreeFrom(ws, worktreePath, branchName, startHash)
+				}
+			}
+			if err != nil {
 				r.cleanupWorktrees(taskID, worktreePaths, branchName)
 				return nil, "", fmt.Errorf("createWorktree for %s: %w", ws, err)
 			}
 		} else {
-			if err := setupNonGitSnapshot(ws, worktreePath); err != nil {
+			if err := setupNonGitSnapshot(ws, worktreePath, subpath, r.gitAuthorName, r.gitAuthorEmail); err != nil {
 				r.cleanupWorktrees(taskID, worktreePaths, branchName)
 				return nil, "", fmt.Errorf("snapshot for %s: %w", ws, err)
 			}
 		}
 
 		worktreePaths[ws] = worktreePath
+		if base != "" {
+			baseCommits[ws] = base
+		} else if hash, err := gitutil.GetCommitHash(context.Background(), worktreePath); err == nil {
+			baseCommits[ws] = hash
+		}
+	}
+
+	if len(baseCommits) > 0 {
+		if err := r.store.UpdateTaskBaseCommits(context.Background(), taskID, baseCommits); err != nil {
+			logger.Runner.Warn("save base commits", "task", taskID, "error", err)
+		}
 	}
 
 	return worktreePaths, branchName, nil
 }
 
+// recoverStaleBranch handles a task branch that already exists in repo ws
+// before its worktree is created, typically left behind by a crashed run.
+// A branch that builds on base, the commit the task started from (it points
+// at it, or at work committed on top of it), is checked out at worktreePath
+// as is and reused is true; any other branch was cut from a different base
+// and is deleted so the caller can create it afresh. A branch checked out in another worktree is an error:
+// something else is using it.
+func (r *Runner) recoverStaleBranch(taskID uuid.UUID, ws, worktreePath, branchName, base string) (reused bool, err error) {
+	exists, inUse, err := gitutil.BranchWorktree(ws, branchName)
+	if err != nil || !exists {
+		return false, err
+	}
+	if inUse != "" {
+		return false, fmt.Errorf("branch %s is already checked out at %s", branchName, inUse)
+	}
+	tip, _ := gitutil.GetCommitHashForRef(context.Background(), ws, "refs/heads/"+branchName)
+	if gitutil.IsAncestor(ws, base, "refs/heads/"+branchName) {
+		if err := gitutil.AddWorktreeForBranch(ws, worktreePath, branchName); err != nil {
+			return false, err
+		}
+		logger.Runner.Info("reusing stale task branch", "task", taskID, "repo", ws, "branch", branchName)
+		return true, nil
+	}
+	if err := gitutil.DeleteBranch(ws, branchName); err != nil {
+		return false, err
+	}
+	logger.Runner.Warn("deleted stale task branch", "task", taskID, "repo", ws, "branch", branchName, "tip", tip)
+	r.store.InsertEvent(context.Background(), taskID, store.EventTypeSystem, map[string]string{
+		"result": fmt.Sprintf("Deleted leftover branch %s in %s (it was not based on the task's base commit) and recreated it.", branchName, filepath.Base(ws)),
+	})
+	return false, nil
+}
+
+// unshallowTimeout bounds the history fetch of handleShallowWorkspace.
+const unshallowTimeout = 10 * time.Minute
+
+// handleShallowWorkspace deals with a workspace that is a shallow clone
+// before a task worktree is created from it. Rebasing the task branch later
+// needs the merge-base with the default branch, which the truncated history
+// may lack. With Unshallow configured the full history is fetched; otherwise,
+// or if the fetch fails, the task gets a warning event up front instead of a
+// confusing rebase failure at commit time.
+func (r *Runner) handleShallowWorkspace(taskID uuid.UUID, ws string) {
+	var msg string
+	if r.unshallow {
+		ctx, cancel := context.WithTimeout(context.Background(), unshallowTimeout)
+		err := gitutil.Unshallow(ctx, ws)
+		cancel()
+		if err == nil {
+			logger.Runner.Info("unshallowed workspace", "task", taskID, "repo", ws)
+			return
+		}
+		logger.Runner.Warn("unshallow workspace", "task", taskID, "repo", ws, "error", err)
+		msg = fmt.Sprintf("Workspace %s is a shallow clone and fetching its full history failed: %v. Rebasing the task branch may fail.", filepath.Base(ws), err)
+	} else {
+		logger.Runner.Warn("workspace is a shallow clone; rebase may fail", "task", taskID, "repo", ws)
+		msg = fmt.Sprintf("Workspace %s is a shallow clone; rebasing the task branch may fail if its history lacks the merge-base. Run git fetch --unshallow in it, or start the server with -unshallow.", filepath.Base(ws))
+	}
+	r.store.InsertEvent(context.Background(), taskID, store.EventTypeSystem, map[string]string{
+		"result": msg,
+	})
+}
+
+// taskBranchName returns the git branch used for taskID's worktrees. A task
+// that already has a branch keeps it so resumed tasks reuse their worktrees.
+// Otherwise the branch is "task/" followed by the task's short ID computed
+// across every stored task, so two UUIDs sharing their first characters
+// never map to the same branch. A named instance prefixes it with
+// "<instance>/", keeping it apart from the branches of other instances
+// sharing the repository, whose short IDs this store does not know about.
+func (r *Runner) taskBranchName(taskID uuid.UUID) string {
+	if task, err := r.store.GetTask(context.Background(), taskID); err == nil && task.BranchName != "" {
+		return task.BranchName
+	}
+	branch := "task/" + r.ShortID(taskID)
+	if r.instance != "" {
+		branch = r.instance + "/" + branch
+	}
+	return branch
+}
+
+// ResetWorktree discards everything the task did by hard-resetting each of
+// its worktrees to the base commit recorded when the worktree was created.
+func (r *Runner) ResetWorktree(taskID uuid.UUID) error {
+	bgCtx := context.Background()
+	task, err := r.store.GetTask(bgCtx, taskID)
+	if err != nil {
+		return err
+	}
+	if len(task.WorktreePaths) == 0 {
+		return fmt.Errorf("task %s has no worktrees", taskID)
+	}
+	for repoPath := range task.WorktreePaths {
+		if task.BaseCommits[repoPath] == "" {
+			return fmt.Errorf("no base commit recorded for %s", repoPath)
+		}
+	}
+	for repoPath, wt := range task.WorktreePaths {
+		base := task.BaseCommits[repoPath]
+		if err := gitutil.ResetHard(bgCtx, wt, base); err != nil {
+			return err
+		}
+		r.store.InsertEvent(bgCtx, taskID, store.EventTypeSystem, map[string]string{
+			"result": fmt.Sprintf("Reset %s to base commit %s.", filepath.Base(repoPath), base[:8]),
+		})
+	}
+	return nil
+}
+
 // CleanupWorktrees is the exported variant of cleanupWorktrees for handler use.
 func (r *Runner) CleanupWorktrees(taskID uuid.UUID, worktreePaths map[string]string, branchName string) {
 	r.cleanupWorktrees(taskID, worktreePaths, branchName)
@@ -64,7 +233,7 @@ func (r *Runner) CleanupWorktrees(taskID uuid.UUID, worktreePaths map[string]str
 // directory. Safe to call multiple times — errors are logged as warnings.
 func (r *Runner) cleanupWorktrees(taskID uuid.UUID, worktreePaths map[string]string, branchName string) {
 	for repoPath, wt := range worktreePaths {
-		if !gitutil.IsGitRepo(repoPath) {
+		if !gitutil.IsGitRepo(context.Background(), repoPath) {
 			// Non-git snapshots are cleaned by os.RemoveAll below.
 			continue
 		}
@@ -111,7 +280,7 @@ func (r *Runner) PruneOrphanedWorktrees(s *store.Store) {
 
 	// Run `git worktree prune` on all workspaces to clean stale references.
 	for _, ws := range r.Workspaces() {
-		if gitutil.IsGitRepo(ws) {
+		if gitutil.IsGitRepo(ctx, ws) {
 			gitPrune(ws)
 		}
 	}
@@ -121,3 +290,9 @@ func gitPrune(repoPath string) {
 	// best-effort; errors are silently ignored
 	_ = runGit(repoPath, "worktree", "prune")
 }
+
+// DefaultBranch returns the branch tasks in repoPath merge into: the one
+// configured in RunnerConfig.DefaultBranches, or the auto-detected default.
+func (r *Runner) DefaultBranch(ctx context.Context, repoPath string) (string, error) {
+	return gitutil.DefaultBranchWithOverride(ctx, repoPath, r.defaultBranches)
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/store"
+	"github.com/google/uuid"
+)
+
+// zombieSweepInterval is how often WatchZombieContainers looks for leaked
+// task containers.
+const zombieSweepInterval = time.Minute
+
+// WatchZombieContainers periodically kills and removes running task
+// containers whose task has already finished. Such containers leak when Run
+// crashes or the server dies before cleaning up after itself. It blocks until
+// ctx is cancelled.
+func (r *Runner) WatchZombieContainers(ctx context.Context) {
+	ticker := time.NewTicker(zombieSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.reapZombieContainers(ctx)
+	}
+}
+
+// reapZombieContainers removes every running task container whose task is in
+// a terminal status and returns how many it removed. Containers of tasks that
+// are not in the store are left alone: they may belong to another server
+// sharing the runtime.
+func (r *Runner) reapZombieContainers(ctx context.Context) int {
+	containers, err := r.ListContainersWithTasks()
+	if err != nil {
+		logger.Recovery.Warn("zombie sweep: list containers", "error", err)
+		return 0
+	}
+	reaped := 0
+	for _, c := range containers {
+		if !c.Orphaned || !store.IsTerminal(c.TaskStatus) {
+			continue
+		}
+		taskID, err := uuid.Parse(c.TaskID)
+		if err != nil {
+			continue
+		}
+		// Re-read the task so one that was retried since the listing keeps
+		// its fresh container.
+		task, err := r.store.GetTask(ctx, taskID)
+		if err != nil || !store.IsTerminal(task.Status) {
+			continue
+		}
+		exec.Command(r.command, "kill", c.Name).Run()
+		exec.Command(r.command, "rm", "-f", c.Name).Run()
+		logger.Recovery.Warn("removed zombie container", "task", taskID, "container", c.Name, "status", task.Status)
+		reaped++
+	}
+	return reaped
+}
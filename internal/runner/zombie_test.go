@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReapZombieContainersRemovesFinishedTaskContainers verifies that a
+// running container whose task is done is killed and removed, while the
+// container of an in_progress task is left running.
+func TestReapZombieContainersRemovesFinishedTaskContainers(t *testing.T) {
+	dir := t.TempDir()
+	listing := filepath.Join(dir, "ps.json")
+	calls := filepath.Join(dir, "calls.log")
+	cmd := filepath.Join(dir, "fake-runtime")
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = ps ]; then cat " + listing + "; exit 0; fi\n" +
+		"echo \"$@\" >> " + calls + "\n"
+	if err := os.WriteFile(cmd, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, r := setupRunnerWithCmd(t, nil, cmd)
+	ctx := context.Background()
+	done, _ := s.CreateTask(ctx, "finished", 5, false)
+	moveTask(t, s, done.ID, "done")
+	running, _ := s.CreateTask(ctx, "running", 5, false)
+	moveTask(t, s, running.ID, "in_progress")
+
+	ps := `[
+		{"Id":"a1","Names":["wallfacer-` + done.ID.String() + `"],"State":"running"},
+		{"Id":"b2","Names":["wallfacer-` + running.ID.String() + `"],"State":"running"}
+	]`
+	if err := os.WriteFile(listing, []byte(ps), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := r.reapZombieContainers(ctx); n != 1 {
+		t.Fatalf("reaped %d containers, want 1", n)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	zombie := "wallfacer-" + done.ID.String()
+	if !strings.Contains(got, "kill "+zombie) || !strings.Contains(got, "rm -f "+zombie) {
+		t.Errorf("zombie container not killed and removed; runtime calls:\n%s", got)
+	}
+	if strings.Contains(got, running.ID.String()) {
+		t.Errorf("in_progress task's container was touched; runtime calls:\n%s", got)
+	}
+}
@@ -57,6 +57,9 @@ func (s *Store) GetEvents(_ context.Context, taskID uuid.UUID) ([]TaskEvent, err
 // saveEvent writes a single event to the task's traces directory.
 // Must be called with s.mu held for writing.
 func (s *Store) saveEvent(taskID uuid.UUID, seq int, event TaskEvent) error {
+	if s.inMemory() {
+		return nil
+	}
 	tracesDir := filepath.Join(s.dir, taskID.String(), "traces")
 	if err := os.MkdirAll(tracesDir, 0755); err != nil {
 		return err
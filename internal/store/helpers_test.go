@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 // bg returns a background context for use in tests.
@@ -19,3 +21,24 @@ func newTestStore(t *testing.T) *Store {
 	}
 	return s
 }
+
+// moveTask moves a task to status along legal state-machine edges: a backlog
+// task is started (in_progress) first.
+func moveTask(t *testing.T, s *Store, id uuid.UUID, status string) {
+	t.Helper()
+	task, err := s.GetTask(bg(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status == status {
+		return
+	}
+	if task.Status == "backlog" && status != "in_progress" && status != "cancelled" {
+		if err := s.UpdateTaskStatus(bg(), id, "in_progress"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.UpdateTaskStatus(bg(), id, status); err != nil {
+		t.Fatal(err)
+	}
+}
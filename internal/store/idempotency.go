@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// idempotencyFile holds the Idempotency-Key → task mapping at the root of the
+// data directory.
+const idempotencyFile = "idempotency.json"
+
+// idempotencyEntry records the task created for an idempotency key.
+type idempotencyEntry struct {
+	TaskID    uuid.UUID `json:"task_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// loadIdempotencyKeys reads the idempotency key file, if present.
+func (s *Store) loadIdempotencyKeys() error {
+	raw, err := os.ReadFile(filepath.Join(s.dir, idempotencyFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return jsonUnmarshal(raw, &s.idemKeys)
+}
+
+// LookupIdempotencyKey returns the task created for key, provided the key was
+// recorded within window and the task still exists.
+func (s *Store) LookupIdempotencyKey(_ context.Context, key string, window time.Duration) (uuid.UUID, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.idemKeys[key]
+	if !ok || time.Since(e.CreatedAt) > window {
+		return uuid.Nil, false
+	}
+	if _, exists := s.tasks[e.TaskID]; !exists {
+		return uuid.Nil, false
+	}
+	return e.TaskID, true
+}
+
+// RecordIdempotencyKey maps key to taskID and drops keys older than window.
+func (s *Store) RecordIdempotencyKey(_ context.Context, key string, taskID uuid.UUID, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := make(map[string]idempotencyEntry, len(s.idemKeys)+1)
+	for k, e := range s.idemKeys {
+		if now.Sub(e.CreatedAt) <= window {
+			keys[k] = e
+		}
+	}
+	keys[key] = idempotencyEntry{TaskID: taskID, CreatedAt: now}
+
+	if !s.inMemory() {
+		if err := atomicWriteJSON(filepath.Join(s.dir, idempotencyFile), keys); err != nil {
+			return err
+		}
+	}
+	s.idemKeys = keys
+	return nil
+}
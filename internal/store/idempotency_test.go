@@ -0,0 +1,61 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+
+	if _, ok := s.LookupIdempotencyKey(bg(), "k1", time.Hour); ok {
+		t.Fatal("unexpected hit for unknown key")
+	}
+	if err := s.RecordIdempotencyKey(bg(), "k1", task.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := s.LookupIdempotencyKey(bg(), "k1", time.Hour); !ok || id != task.ID {
+		t.Fatalf("lookup = %v, %v; want %v", id, ok, task.ID)
+	}
+
+	// Keys survive a reload.
+	s2, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := s2.LookupIdempotencyKey(bg(), "k1", time.Hour); !ok || id != task.ID {
+		t.Fatalf("after reload lookup = %v, %v; want %v", id, ok, task.ID)
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	s.RecordIdempotencyKey(bg(), "k1", task.ID, time.Hour)
+
+	s.idemKeys["k1"] = idempotencyEntry{TaskID: task.ID, CreatedAt: time.Now().Add(-2 * time.Hour)}
+	if _, ok := s.LookupIdempotencyKey(bg(), "k1", time.Hour); ok {
+		t.Fatal("expired key should not match")
+	}
+
+	// Recording another key prunes the expired one.
+	s.RecordIdempotencyKey(bg(), "k2", task.ID, time.Hour)
+	if _, ok := s.idemKeys["k1"]; ok {
+		t.Error("expired key was not pruned")
+	}
+}
+
+func TestIdempotencyKeyDeletedTask(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	s.RecordIdempotencyKey(bg(), "k1", task.ID, time.Hour)
+	s.DeleteTask(bg(), task.ID)
+	if _, ok := s.LookupIdempotencyKey(bg(), "k1", time.Hour); ok {
+		t.Fatal("key for a deleted task should not match")
+	}
+}
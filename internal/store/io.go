@@ -5,19 +5,28 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-// saveTask atomically writes a task's metadata to its task.json file.
-// Must be called with s.mu held for writing.
+// saveTask atomically writes a task's metadata to its task.json file and
+// bumps its Version. Must be called with s.mu held for writing.
 func (s *Store) saveTask(id uuid.UUID, task *Task) error {
+	task.Version++
+	if s.inMemory() {
+		return nil
+	}
 	path := filepath.Join(s.dir, id.String(), "task.json")
-	return atomicWriteJSON(path, task)
+	return atomicWriteJSON(path, (*taskRecord)(task))
 }
 
 // SaveTurnOutput persists raw stdout/stderr for a given turn to the outputs directory.
+// A memory-only store discards the output.
 func (s *Store) SaveTurnOutput(taskID uuid.UUID, turn int, stdout, stderr []byte) error {
+	if s.inMemory() {
+		return nil
+	}
 	outputsDir := filepath.Join(s.dir, taskID.String(), "outputs")
 	if err := os.MkdirAll(outputsDir, 0755); err != nil {
 		return fmt.Errorf("create outputs dir: %w", err)
@@ -38,6 +47,102 @@ func (s *Store) SaveTurnOutput(taskID uuid.UUID, turn int, stdout, stderr []byte
 	return nil
 }
 
+// transcriptFile is the name of a task's transcript in its data directory.
+const transcriptFile = "transcript.jsonl"
+
+// TranscriptPath returns the file AppendTranscript writes the task's
+// conversation to.
+func (s *Store) TranscriptPath(taskID uuid.UUID) string {
+	return filepath.Join(s.dir, taskID.String(), transcriptFile)
+}
+
+// AppendTranscript appends NDJSON lines to the task's transcript and records
+// the file on the task (Task.Transcript). Callers redact data first: it is
+// stored as given. A memory-only store discards it.
+func (s *Store) AppendTranscript(taskID uuid.UUID, data []byte) error {
+	if s.inMemory() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+	f, err := os.OpenFile(s.TranscriptPath(taskID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open transcript: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	if t.Transcript == transcriptFile {
+		return nil
+	}
+	t.Transcript = transcriptFile
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(taskID, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// SaveLaunchContext records the inputs handed to one container launch of a
+// task — files maps names such as "prompt.txt", "CLAUDE.md", and
+// "board.json" to their content — under data/<uuid>/context/NNNN/, numbered
+// from 1 in launch order, and returns the launch number. Launches of a task
+// are sequential, so numbering by the existing directories is safe. A
+// memory-only store discards the context and returns 0.
+func (s *Store) SaveLaunchContext(taskID uuid.UUID, files map[string][]byte) (int, error) {
+	if s.inMemory() {
+		return 0, nil
+	}
+	contextDir := filepath.Join(s.dir, taskID.String(), "context")
+	entries, err := os.ReadDir(contextDir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("read context dir: %w", err)
+	}
+	n := len(entries) + 1
+	dir := filepath.Join(contextDir, fmt.Sprintf("%04d", n))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("create context dir: %w", err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return 0, fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return n, nil
+}
+
+// LaunchContext returns the files recorded by SaveLaunchContext for launch
+// n of a task.
+func (s *Store) LaunchContext(taskID uuid.UUID, n int) (map[string][]byte, error) {
+	if s.inMemory() {
+		return nil, fmt.Errorf("launch context %d of task %s: not recorded by a memory-only store", n, taskID)
+	}
+	dir := filepath.Join(s.dir, taskID.String(), "context", fmt.Sprintf("%04d", n))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("launch context %d of task %s: %w", n, taskID, err)
+	}
+	files := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = data
+	}
+	return files, nil
+}
+
 // atomicWriteJSON marshals v to JSON and writes it atomically via temp+rename.
 func atomicWriteJSON(path string, v any) error {
 	raw, err := json.MarshalIndent(v, "", "  ")
@@ -2,8 +2,10 @@
 package store
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -66,3 +68,41 @@ func TestSaveTurnOutput_TurnNumberFormatted(t *testing.T) {
 		t.Errorf("expected file turn-0042.json: %v", err)
 	}
 }
+
+// TestSaveTaskKeepsEnvButJSONMasksIt verifies that task.json keeps the
+// per-task env values across a reload while JSON output masks them.
+func TestSaveTaskKeepsEnvButJSONMasksIt(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewStore(dir)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	if err := s.UpdateTaskEnv(bg(), task.ID, map[string]string{"TOKEN": "s3cret-value"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s2.GetTask(bg(), task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Env["TOKEN"] != "s3cret-value" {
+		t.Errorf("Env after reload = %v, want TOKEN=s3cret-value", loaded.Env)
+	}
+
+	data, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret-value") {
+		t.Errorf("JSON output leaks the env value: %s", data)
+	}
+	var out Task
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Env["TOKEN"] != maskedEnvValue {
+		t.Errorf("Env in JSON = %v, want TOKEN masked", out.Env)
+	}
+}
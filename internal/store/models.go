@@ -45,15 +45,84 @@ type Task struct {
 	Position      int       `json:"position"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+	Version       int       `json:"version"`                 // bumped on every write; see UpdateTaskStatusIfVersion
+	FailureCount  int       `json:"failure_count,omitempty"` // entries into failed; reset when a dead task is retried
+
+	// Run timing (maintained by UpdateTaskStatus, cleared by ResetTaskForRetry).
+	StartedAt       *time.Time `json:"started_at,omitempty"`       // first entry into in_progress
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`      // entry into done, failed, cancelled, or dead
+	DurationSeconds float64    `json:"duration_seconds,omitempty"` // FinishedAt - StartedAt
+	WaitingSince    *time.Time `json:"waiting_since,omitempty"`    // latest entry into waiting; nil in other statuses
+
+	// Container resource usage sampled while the task's containers ran
+	// (maintained by RecordTaskResources; zero when the runtime has no stats).
+	PeakMemoryBytes int64   `json:"peak_memory_bytes,omitempty"` // highest memory usage of any container
+	CPUSeconds      float64 `json:"cpu_seconds,omitempty"`       // approximate CPU time summed over all containers
+	Container       string  `json:"container,omitempty"`         // name of the task's container while it runs; cleared on load
 
 	// Worktree isolation fields (populated when task moves to in_progress).
-	WorktreePaths    map[string]string `json:"worktree_paths,omitempty"`     // host repoPath → worktree path
-	BranchName       string            `json:"branch_name,omitempty"`        // "task/<uuid8>"
-	CommitHashes     map[string]string `json:"commit_hashes,omitempty"`      // host repoPath → commit hash after merge
-	BaseCommitHashes map[string]string `json:"base_commit_hashes,omitempty"` // host repoPath → defBranch HEAD before merge
-	MountWorktrees   bool              `json:"mount_worktrees,omitempty"`
+	WorktreePaths    map[string]string   `json:"worktree_paths,omitempty"`     // host repoPath → worktree path
+	BranchName       string              `json:"branch_name,omitempty"`        // "task/<short-id>"
+	CommitHashes     map[string]string   `json:"commit_hashes,omitempty"`      // host repoPath → per-repo task commit (merged hash once merged)
+	BaseCommitHashes map[string]string   `json:"base_commit_hashes,omitempty"` // host repoPath → defBranch HEAD before merge
+	ConflictFiles    map[string][]string `json:"conflict_files,omitempty"`     // host repoPath → paths that conflicted on the last rebase
+	BaseCommits      map[string]string   `json:"base_commits,omitempty"`       // host repoPath → worktree HEAD when it was created
+	MountWorktrees   bool                `json:"mount_worktrees,omitempty"`
+	Scratch          bool                `json:"scratch,omitempty"` // run in an empty scratch dir; output downloaded, never committed
+	Env              map[string]string   `json:"env,omitempty"`     // extra container env vars, applied over the env file; values masked in JSON output
+	Inputs           []string            `json:"inputs,omitempty"`  // names of the files attached at creation; see SetTaskInputs
+
+	ExtraInstructions string `json:"extra_instructions,omitempty"` // appended to this task's copy of the workspace CLAUDE.md
+	InstructionsHash  string `json:"instructions_hash,omitempty"`  // SHA-256 of the CLAUDE.md mounted for the latest launch
+	Transcript        string `json:"transcript,omitempty"`         // redacted conversation file in the task's data directory; see AppendTranscript
+	SnapshotSubpath   string `json:"snapshot_subpath,omitempty"`   // non-git workspaces: only this relative subtree is snapshotted
+
+	HoldReason      string `json:"hold_reason,omitempty"`      // why the runner holds the task in waiting for a person (Hold* constants); cleared when it leaves waiting
+	ReviewRequested bool   `json:"review_requested,omitempty"` // the agent's latest completed run asked for a person's review before merging
+
+	DependsOn    []uuid.UUID `json:"depends_on,omitempty"`    // tasks whose changes this one builds on; merged first by CommitBatch
+	AllowedPaths []string    `json:"allowed_paths,omitempty"` // repo-relative globs the task may change; empty allows everything
+
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // tool calls of all runs, in order (appended by AppendTaskToolCalls)
 }
 
+// maskedEnvValue replaces the values of Task.Env in JSON output.
+const maskedEnvValue = "***"
+
+// taskRecord is Task without its MarshalJSON: the form saved to task.json,
+// which keeps the Env values the runner passes to the container.
+type taskRecord Task
+
+// MarshalJSON masks the Env values, which often hold per-task secrets, so
+// they never leave the server through the API, the task stream or the
+// client package. Only the variable names are shown.
+func (t Task) MarshalJSON() ([]byte, error) {
+	rec := taskRecord(t)
+	if len(t.Env) > 0 {
+		rec.Env = make(map[string]string, len(t.Env))
+		for k := range t.Env {
+			rec.Env[k] = maskedEnvValue
+		}
+	}
+	return json.Marshal(rec)
+}
+
+// ToolCall is one tool invocation parsed from the agent's output stream.
+type ToolCall struct {
+	Tool  string    `json:"tool"`            // tool name, e.g. Bash, Edit, Read
+	Input string    `json:"input,omitempty"` // main argument (command, file path, pattern), truncated
+	At    time.Time `json:"at"`              // when the call appeared in the stream
+}
+
+// Reasons for Task.HoldReason. A held task only leaves waiting by a person's
+// action: the waiting timeout never commits it.
+const (
+	HoldLargeDiff       = "large_diff"       // the diff exceeds -max-diff-lines
+	HoldReadOnly        = "read_only"        // the changes wait in a read-only workspace overlay
+	HoldVerifyFailed    = "verify_failed"    // the -verify-command exited non-zero
+	HoldReviewRequested = "review_requested" // the agent asked for review before merging (Task.ReviewRequested)
+)
+
 // EventType identifies the kind of event stored in a task's audit trail.
 type EventType string
 
@@ -63,6 +132,7 @@ const (
 	EventTypeFeedback    EventType = "feedback"
 	EventTypeError       EventType = "error"
 	EventTypeSystem      EventType = "system"
+	EventTypeContainer   EventType = "container" // container start/stop: {action, container, exit_code}
 )
 
 // TaskEvent is a single event in a task's audit trail (event sourcing).
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"changkun.de/wallfacer/internal/logger"
 	"github.com/google/uuid"
@@ -17,14 +18,18 @@ import (
 // All mutations are atomic (temp-file + rename) and guarded by a RWMutex.
 type Store struct {
 	mu      sync.RWMutex
-	dir     string
+	dir     string // empty for a memory-only store (see NewMemoryStore)
 	tasks   map[uuid.UUID]*Task
 	events  map[uuid.UUID][]TaskEvent
 	nextSeq map[uuid.UUID]int
 
-	subMu       sync.Mutex
-	subscribers map[int]chan struct{}
-	nextSubID   int
+	idemKeys map[string]idempotencyEntry
+
+	subMu         sync.Mutex
+	subscribers   map[int]chan struct{}
+	nextSubID     int
+	notifyTimer   *time.Timer // open debounce window, nil when idle
+	notifyPending bool        // a notify arrived during the open window
 }
 
 // NewStore loads (or creates) a Store rooted at dir.
@@ -34,6 +39,7 @@ func NewStore(dir string) (*Store, error) {
 		tasks:       make(map[uuid.UUID]*Task),
 		events:      make(map[uuid.UUID][]TaskEvent),
 		nextSeq:     make(map[uuid.UUID]int),
+		idemKeys:    make(map[string]idempotencyEntry),
 		subscribers: make(map[int]chan struct{}),
 	}
 
@@ -44,10 +50,33 @@ func NewStore(dir string) (*Store, error) {
 	if err := s.loadAll(); err != nil {
 		return nil, fmt.Errorf("load store: %w", err)
 	}
+	if err := s.loadIdempotencyKeys(); err != nil {
+		return nil, fmt.Errorf("load idempotency keys: %w", err)
+	}
 
 	return s, nil
 }
 
+// NewMemoryStore returns an empty Store that keeps everything in memory and
+// never touches the filesystem. Subscriptions, events, and idempotency keys
+// behave as in a disk-backed store, but turn outputs are discarded and
+// OutputsDir/ScratchDir have nothing behind them, so scratch tasks cannot
+// run against it. Intended for tests and embedding.
+func NewMemoryStore() *Store {
+	return &Store{
+		tasks:       make(map[uuid.UUID]*Task),
+		events:      make(map[uuid.UUID][]TaskEvent),
+		nextSeq:     make(map[uuid.UUID]int),
+		idemKeys:    make(map[string]idempotencyEntry),
+		subscribers: make(map[int]chan struct{}),
+	}
+}
+
+// inMemory reports whether s was created by NewMemoryStore.
+func (s *Store) inMemory() bool {
+	return s.dir == ""
+}
+
 // Close is a no-op placeholder for future resource cleanup.
 func (s *Store) Close() {}
 
@@ -57,6 +86,18 @@ func (s *Store) OutputsDir(taskID uuid.UUID) string {
 	return filepath.Join(s.dir, taskID.String(), "outputs")
 }
 
+// ScratchDir returns the directory mounted as /workspace/scratch for a
+// scratch task. It lives with the task data so it is removed with the task.
+func (s *Store) ScratchDir(taskID uuid.UUID) string {
+	return filepath.Join(s.dir, taskID.String(), "scratch")
+}
+
+// InputsDir returns the directory holding the files attached to a task with
+// SetTaskInputs.
+func (s *Store) InputsDir(taskID uuid.UUID) string {
+	return filepath.Join(s.dir, taskID.String(), "inputs")
+}
+
 // loadAll scans the data directory and populates in-memory maps.
 func (s *Store) loadAll() error {
 	entries, err := os.ReadDir(s.dir)
@@ -84,6 +125,8 @@ func (s *Store) loadAll() error {
 			logger.Store.Warn("skipping task", "name", entry.Name(), "error", err)
 			continue
 		}
+		// Nothing of a previous process's containers is being watched.
+		task.Container = ""
 		s.tasks[id] = &task
 
 		if err := s.loadEvents(id, entry.Name()); err != nil {
@@ -1,4 +1,4 @@
-// Tests for store.go: NewStore, loadAll, loadEvents, OutputsDir, Close,
+// Tests for store.go: NewStore, NewMemoryStore, loadAll, loadEvents, OutputsDir, Close,
 // and full persistence round-trip integration tests.
 package store
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -116,6 +117,54 @@ func TestOutputsDir(t *testing.T) {
 	}
 }
 
+func TestNewMemoryStore_RoundTrip(t *testing.T) {
+	s := NewMemoryStore()
+	subID, ch := s.Subscribe()
+	defer s.Unsubscribe(subID)
+
+	task, err := s.CreateTask(bg(), "in memory", 5, false)
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	select {
+	case <-ch:
+	default:
+		t.Error("CreateTask should notify subscribers")
+	}
+	if err := s.InsertEvent(bg(), task.ID, EventTypeOutput, "hi"); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+	if err := s.UpdateTaskStatus(bg(), task.ID, "in_progress"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	if err := s.SaveTurnOutput(task.ID, 1, []byte("{}"), nil); err != nil {
+		t.Fatalf("SaveTurnOutput: %v", err)
+	}
+	if err := s.RecordIdempotencyKey(bg(), "k", task.ID, time.Hour); err != nil {
+		t.Fatalf("RecordIdempotencyKey: %v", err)
+	}
+
+	got, err := s.GetTask(bg(), task.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.Prompt != "in memory" || got.Status != "in_progress" {
+		t.Errorf("task = %+v", got)
+	}
+	if events, _ := s.GetEvents(bg(), task.ID); len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+	if id, ok := s.LookupIdempotencyKey(bg(), "k", time.Hour); !ok || id != task.ID {
+		t.Error("idempotency key should be recorded")
+	}
+	if err := s.DeleteTask(bg(), task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := os.Stat(task.ID.String()); !os.IsNotExist(err) {
+		t.Error("memory store must not create task directories")
+	}
+}
+
 // ─────────────────────────────────────────────────────────────────────────────
 // Full persistence round-trip integration tests
 // ─────────────────────────────────────────────────────────────────────────────
@@ -125,7 +174,7 @@ func TestPersistence_FullRoundTrip(t *testing.T) {
 	s, _ := NewStore(dir)
 
 	task, _ := s.CreateTask(bg(), "round trip prompt", 15, false)
-	s.UpdateTaskStatus(bg(), task.ID, "in_progress")
+	moveTask(t, s, task.ID, "in_progress")
 	s.UpdateTaskTitle(bg(), task.ID, "Round Trip Title")
 	s.AccumulateTaskUsage(bg(), task.ID, TaskUsage{InputTokens: 100, CostUSD: 0.5})
 	s.UpdateTaskWorktrees(bg(), task.ID, map[string]string{"/repo": "/wt"}, "task/rt")
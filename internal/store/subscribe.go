@@ -1,5 +1,10 @@
 package store
 
+import "time"
+
+// notifyDebounce is the window within which notify calls are coalesced.
+const notifyDebounce = 50 * time.Millisecond
+
 // subscribe registers a channel that receives a signal whenever task state changes.
 // The caller must call unsubscribe with the returned ID when done.
 func (s *Store) subscribe() (int, <-chan struct{}) {
@@ -23,11 +28,38 @@ func (s *Store) Unsubscribe(id int) {
 	delete(s.subscribers, id)
 }
 
-// notify wakes all SSE subscribers. Non-blocking: if a subscriber's buffer is
-// already full it already has a pending signal, so no additional send is needed.
+// notify wakes all SSE subscribers. Bursts are coalesced: the first call
+// signals immediately and opens a notifyDebounce window; further calls within
+// the window collapse into a single signal sent when it closes, so the last
+// change is always followed by a signal.
 func (s *Store) notify() {
 	s.subMu.Lock()
 	defer s.subMu.Unlock()
+	if s.notifyTimer != nil {
+		s.notifyPending = true
+		return
+	}
+	s.broadcast()
+	s.notifyTimer = time.AfterFunc(notifyDebounce, s.flushNotify)
+}
+
+// flushNotify closes a debounce window, sending the signal deferred during
+// it (which opens a new window) if any.
+func (s *Store) flushNotify() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.notifyTimer = nil
+	if s.notifyPending {
+		s.notifyPending = false
+		s.broadcast()
+		s.notifyTimer = time.AfterFunc(notifyDebounce, s.flushNotify)
+	}
+}
+
+// broadcast signals every subscriber. Non-blocking: if a subscriber's buffer
+// is already full it already has a pending signal, so no additional send is
+// needed. Must be called with s.subMu held.
+func (s *Store) broadcast() {
 	for _, ch := range s.subscribers {
 		select {
 		case ch <- struct{}{}:
@@ -1,7 +1,8 @@
-// Tests for subscribe.go: Subscribe, Unsubscribe, and notify.
+// Tests for subscribe.go: Subscribe, Unsubscribe, and notify debouncing.
 package store
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -27,7 +28,7 @@ func TestSubscribe_ReceivesNotificationOnStatusUpdate(t *testing.T) {
 	id, ch := s.Subscribe()
 	defer s.Unsubscribe(id)
 
-	s.UpdateTaskStatus(bg(), task.ID, "in_progress")
+	moveTask(t, s, task.ID, "in_progress")
 
 	select {
 	case <-ch:
@@ -126,3 +127,45 @@ func TestSubscribe_IDsAreUnique(t *testing.T) {
 		seen[id] = true
 	}
 }
+
+func TestNotify_DebouncesBursts(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	time.Sleep(2 * notifyDebounce) // let the create's window close
+
+	id, ch := s.Subscribe()
+	defer s.Unsubscribe(id)
+
+	// Drain signals as a client would, reading the title each time.
+	var signals int
+	var lastTitle string
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ch:
+				signals++
+				got, _ := s.GetTask(bg(), task.ID)
+				lastTitle = got.Title
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		s.UpdateTaskTitle(bg(), task.ID, fmt.Sprintf("title %d", i))
+	}
+	time.Sleep(3 * notifyDebounce)
+	close(stop)
+	<-done
+
+	if signals == 0 || signals > 10 {
+		t.Errorf("delivered %d signals for 100 updates; want a few", signals)
+	}
+	if lastTitle != "title 99" {
+		t.Errorf("last observed title = %q, want the final state", lastTitle)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"changkun.de/wallfacer/internal/logger"
 	"github.com/google/uuid"
 )
 
@@ -46,14 +48,71 @@ func (s *Store) GetTask(_ context.Context, id uuid.UUID) (*Task, error) {
 	return &cp, nil
 }
 
+// GetTaskByShortID returns a copy of the task whose UUID starts with short
+// (case-insensitive), such as the 8-character short IDs shown on the board.
+// It fails with ErrAmbiguousID when more than one task matches.
+func (s *Store) GetTaskByShortID(_ context.Context, short string) (*Task, error) {
+	prefix := strings.ToLower(short)
+	if prefix == "" {
+		return nil, fmt.Errorf("task not found: %q", short)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var match *Task
+	n := 0
+	for id, t := range s.tasks {
+		if strings.HasPrefix(id.String(), prefix) {
+			match = t
+			n++
+		}
+	}
+	switch n {
+	case 0:
+		return nil, fmt.Errorf("task not found: %s", short)
+	case 1:
+		cp := *match
+		return &cp, nil
+	default:
+		return nil, fmt.Errorf("%w: %s matches %d tasks", ErrAmbiguousID, short, n)
+	}
+}
+
+// initialStatuses are the statuses a task may be created in. in_progress and
+// committing are excluded: they stand for a running container or commit
+// pipeline, which only the runner starts.
+var initialStatuses = map[string]bool{
+	"backlog":   true,
+	"waiting":   true,
+	"done":      true,
+	"failed":    true,
+	"cancelled": true,
+}
+
+// IsInitialStatus reports whether a task may be created in status.
+func IsInitialStatus(status string) bool {
+	return initialStatuses[status]
+}
+
 // CreateTask creates a new task in backlog status and persists it.
-func (s *Store) CreateTask(_ context.Context, prompt string, timeout int, mountWorktrees bool) (*Task, error) {
+func (s *Store) CreateTask(ctx context.Context, prompt string, timeout int, mountWorktrees bool) (*Task, error) {
+	return s.CreateTaskWithStatus(ctx, prompt, timeout, mountWorktrees, "backlog")
+}
+
+// CreateTaskWithStatus creates a new task directly in status, e.g. done for a
+// historical record or waiting for an imported task, and persists it. The
+// task is placed at the end of that status column. Nothing is started.
+func (s *Store) CreateTaskWithStatus(_ context.Context, prompt string, timeout int, mountWorktrees bool, status string) (*Task, error) {
+	if !IsInitialStatus(status) {
+		return nil, fmt.Errorf("invalid initial status: %q", status)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	maxPos := -1
 	for _, t := range s.tasks {
-		if t.Status == "backlog" && t.Position > maxPos {
+		if t.Status == status && t.Position > maxPos {
 			maxPos = t.Position
 		}
 	}
@@ -64,7 +123,7 @@ func (s *Store) CreateTask(_ context.Context, prompt string, timeout int, mountW
 	task := &Task{
 		ID:             uuid.New(),
 		Prompt:         prompt,
-		Status:         "backlog",
+		Status:         status,
 		Turns:          0,
 		Timeout:        timeout,
 		MountWorktrees: mountWorktrees,
@@ -72,11 +131,15 @@ func (s *Store) CreateTask(_ context.Context, prompt string, timeout int, mountW
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
+	if status == "waiting" {
+		task.WaitingSince = &now
+	}
 
-	taskDir := filepath.Join(s.dir, task.ID.String())
-	tracesDir := filepath.Join(taskDir, "traces")
-	if err := os.MkdirAll(tracesDir, 0755); err != nil {
-		return nil, err
+	if !s.inMemory() {
+		tracesDir := filepath.Join(s.dir, task.ID.String(), "traces")
+		if err := os.MkdirAll(tracesDir, 0755); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.saveTask(task.ID, task); err != nil {
@@ -101,9 +164,11 @@ func (s *Store) DeleteTask(_ context.Context, id uuid.UUID) error {
 		return fmt.Errorf("task not found: %s", id)
 	}
 
-	taskDir := filepath.Join(s.dir, id.String())
-	if err := os.RemoveAll(taskDir); err != nil {
-		return fmt.Errorf("remove task dir: %w", err)
+	if !s.inMemory() {
+		taskDir := filepath.Join(s.dir, id.String())
+		if err := os.RemoveAll(taskDir); err != nil {
+			return fmt.Errorf("remove task dir: %w", err)
+		}
 	}
 
 	delete(s.tasks, id)
@@ -113,7 +178,8 @@ func (s *Store) DeleteTask(_ context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// UpdateTaskStatus sets a task's status field.
+// UpdateTaskStatus sets a task's status field. Changes that are not an edge
+// of the state machine (see statusTransitions) fail with ErrInvalidTransition.
 func (s *Store) UpdateTaskStatus(_ context.Context, id uuid.UUID, status string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -122,8 +188,20 @@ func (s *Store) UpdateTaskStatus(_ context.Context, id uuid.UUID, status string)
 	if !ok {
 		return fmt.Errorf("task not found: %s", id)
 	}
+	if err := checkTransition(t.Status, status); err != nil {
+		return err
+	}
+	now := time.Now()
 	t.Status = status
-	t.UpdatedAt = time.Now()
+	t.UpdatedAt = now
+	recordTiming(t, now)
+	s.dropInputs(t)
+	if status != "waiting" {
+		t.HoldReason = ""
+	}
+	if status == "failed" {
+		t.FailureCount++
+	}
 	if err := s.saveTask(id, t); err != nil {
 		return err
 	}
@@ -131,6 +209,69 @@ func (s *Store) UpdateTaskStatus(_ context.Context, id uuid.UUID, status string)
 	return nil
 }
 
+// UpdateTaskStatusIfVersion is the compare-and-swap variant of
+// UpdateTaskStatus: it moves the task from expected to status only if the
+// task is still in expected at version, i.e. nothing wrote it since the
+// caller read it. Otherwise it fails with ErrVersionConflict and leaves the
+// task alone, so of several racing callers exactly one wins.
+func (s *Store) UpdateTaskStatusIfVersion(_ context.Context, id uuid.UUID, expected, status string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	if t.Version != version || t.Status != expected {
+		return fmt.Errorf("%w: task %s is %s at version %d, expected %s at version %d",
+			ErrVersionConflict, id, t.Status, t.Version, expected, version)
+	}
+	if err := checkTransition(t.Status, status); err != nil {
+		return err
+	}
+	now := time.Now()
+	t.Status = status
+	t.UpdatedAt = now
+	recordTiming(t, now)
+	s.dropInputs(t)
+	if status != "waiting" {
+		t.HoldReason = ""
+	}
+	if status == "failed" {
+		t.FailureCount++
+	}
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// recordTiming updates the run timestamps of t after it entered t.Status at
+// now. StartedAt keeps the first start across resumes; re-entering
+// in_progress clears the previous finish. WaitingSince is set only while the
+// task is waiting.
+func recordTiming(t *Task, now time.Time) {
+	if t.Status == "waiting" {
+		t.WaitingSince = &now
+	} else {
+		t.WaitingSince = nil
+	}
+	switch {
+	case t.Status == "in_progress":
+		if t.StartedAt == nil {
+			t.StartedAt = &now
+		}
+		t.FinishedAt = nil
+		t.DurationSeconds = 0
+	case terminalStatuses[t.Status]:
+		t.FinishedAt = &now
+		if t.StartedAt != nil {
+			t.DurationSeconds = now.Sub(*t.StartedAt).Seconds()
+		}
+	}
+}
+
 // UpdateTaskTitle sets a task's display title.
 func (s *Store) UpdateTaskTitle(_ context.Context, id uuid.UUID, title string) error {
 	s.mu.Lock()
@@ -149,6 +290,272 @@ func (s *Store) UpdateTaskTitle(_ context.Context, id uuid.UUID, title string) e
 	return nil
 }
 
+// UpdateTaskEnv replaces the task's per-task container environment.
+func (s *Store) UpdateTaskEnv(_ context.Context, id uuid.UUID, env map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.Env = env
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// UpdateTaskExtraInstructions sets the task-specific guidance appended to the
+// workspace instructions mounted into the task's container.
+func (s *Store) UpdateTaskExtraInstructions(_ context.Context, id uuid.UUID, extra string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.ExtraInstructions = extra
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// SetTaskHoldReason records why the runner holds the task in waiting (one
+// of the Hold* constants). Set it before moving the task to waiting: any
+// status change away from waiting clears it.
+func (s *Store) SetTaskHoldReason(_ context.Context, id uuid.UUID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.HoldReason = reason
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// UpdateTaskDependsOn records the tasks whose changes the task builds on.
+func (s *Store) UpdateTaskDependsOn(_ context.Context, id uuid.UUID, deps []uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.DependsOn = deps
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// UpdateTaskAllowedPaths restricts the files the task may change to the
+// given repo-relative globs. An empty list lifts the restriction.
+func (s *Store) UpdateTaskAllowedPaths(_ context.Context, id uuid.UUID, globs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.AllowedPaths = globs
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// SetTaskContainer records the name of the task's running container, or
+// clears it when name is empty, so subscribers can tell whether a container
+// is up independently of the task status.
+func (s *Store) SetTaskContainer(_ context.Context, id uuid.UUID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.Container = name
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// SetTaskReviewRequested records whether the agent's latest completed run
+// asked for a person's review before its changes merge.
+func (s *Store) SetTaskReviewRequested(_ context.Context, id uuid.UUID, requested bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	if t.ReviewRequested == requested {
+		return nil
+	}
+	t.ReviewRequested = requested
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// SetTaskInstructionsHash records the SHA-256 (hex) of the CLAUDE.md mounted
+// for the task's latest container launch, or clears it when hash is empty.
+func (s *Store) SetTaskInstructionsHash(_ context.Context, id uuid.UUID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	if t.InstructionsHash == hash {
+		return nil
+	}
+	t.InstructionsHash = hash
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// UpdateTaskSnapshotSubpath limits the snapshots of the task's non-git
+// workspaces to the given relative subdirectory.
+func (s *Store) UpdateTaskSnapshotSubpath(_ context.Context, id uuid.UUID, subpath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.SnapshotSubpath = subpath
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// ValidateInputName reports whether name can be used for a file attached
+// with SetTaskInputs: a plain file name, with no directory components.
+func ValidateInputName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("invalid input file name %q", name)
+	}
+	return nil
+}
+
+// SetTaskInputs writes files (name to content) under data/<uuid>/inputs/,
+// which the runner mounts read-only at /workspace/.tasks/inputs, and records
+// their names on the task. The files are removed once the task is done or
+// cancelled (see dropInputs), or with the task. It fails for memory-only
+// stores, which have nowhere to keep them.
+func (s *Store) SetTaskInputs(_ context.Context, id uuid.UUID, files map[string][]byte) error {
+	if s.inMemory() {
+		return fmt.Errorf("task inputs need a disk-backed store")
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if err := ValidateInputName(name); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	dir := s.InputsDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create inputs dir: %w", err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), files[name], 0644); err != nil {
+			return fmt.Errorf("write input %s: %w", name, err)
+		}
+	}
+	t.Inputs = names
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// dropInputs removes the files attached with SetTaskInputs once t is done or
+// cancelled, when no further container of the task needs them. Failed and
+// waiting tasks keep them for a resume. Must be called with s.mu held for
+// writing.
+func (s *Store) dropInputs(t *Task) {
+	if len(t.Inputs) == 0 || (t.Status != "done" && t.Status != "cancelled") {
+		return
+	}
+	t.Inputs = nil
+	if err := os.RemoveAll(s.InputsDir(t.ID)); err != nil {
+		logger.Store.Warn("remove task inputs", "task", t.ID, "error", err)
+	}
+}
+
+// SetTaskScratch marks a task as a scratch task: it runs against an empty
+// directory instead of the workspaces and is never committed anywhere. It
+// fails for memory-only stores, which have nowhere to keep that directory.
+func (s *Store) SetTaskScratch(_ context.Context, id uuid.UUID, scratch bool) error {
+	if scratch && s.inMemory() {
+		return fmt.Errorf("scratch tasks need a disk-backed store")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.Scratch = scratch
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
 // UpdateTaskResult stores the final output, session ID, stop reason, and turn count.
 func (s *Store) UpdateTaskResult(_ context.Context, id uuid.UUID, result, sessionID, stopReason string, turns int) error {
 	s.mu.Lock()
@@ -170,6 +577,45 @@ func (s *Store) UpdateTaskResult(_ context.Context, id uuid.UUID, result, sessio
 	return nil
 }
 
+// RecordTaskResources folds one container's resource usage into the task:
+// PeakMemoryBytes keeps the highest peak seen and CPUSeconds accumulates.
+func (s *Store) RecordTaskResources(_ context.Context, id uuid.UUID, peakMemoryBytes int64, cpuSeconds float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.PeakMemoryBytes = max(t.PeakMemoryBytes, peakMemoryBytes)
+	t.CPUSeconds += cpuSeconds
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// AppendTaskToolCalls adds the tool calls of a container run to the task's
+// timeline.
+func (s *Store) AppendTaskToolCalls(_ context.Context, id uuid.UUID, calls []ToolCall) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	t.ToolCalls = append(t.ToolCalls, calls...)
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
 // AccumulateTaskUsage adds token/cost deltas to the task's running totals.
 func (s *Store) AccumulateTaskUsage(_ context.Context, id uuid.UUID, delta TaskUsage) error {
 	s.mu.Lock()
@@ -225,6 +671,62 @@ func (s *Store) UpdateTaskPosition(_ context.Context, id uuid.UUID, position int
 	return nil
 }
 
+// ReorderBacklog puts the given backlog tasks at the front of the backlog
+// in the order listed. Backlog tasks not listed follow in their previous
+// order. Positions of all backlog tasks are renumbered from 0. Every id must
+// name a distinct backlog task; otherwise nothing is changed.
+func (s *Store) ReorderBacklog(_ context.Context, ids []uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listed := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		t, ok := s.tasks[id]
+		if !ok {
+			return fmt.Errorf("task not found: %s", id)
+		}
+		if t.Status != "backlog" {
+			return fmt.Errorf("task %s is %s, not backlog", id, t.Status)
+		}
+		if listed[id] {
+			return fmt.Errorf("task %s listed more than once", id)
+		}
+		listed[id] = true
+	}
+
+	var rest []*Task
+	for id, t := range s.tasks {
+		if t.Status == "backlog" && !listed[id] {
+			rest = append(rest, t)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		if rest[i].Position != rest[j].Position {
+			return rest[i].Position < rest[j].Position
+		}
+		return rest[i].CreatedAt.Before(rest[j].CreatedAt)
+	})
+	order := make([]*Task, 0, len(ids)+len(rest))
+	for _, id := range ids {
+		order = append(order, s.tasks[id])
+	}
+	order = append(order, rest...)
+
+	now := time.Now()
+	for pos, t := range order {
+		if t.Position == pos {
+			continue
+		}
+		t.Position = pos
+		t.UpdatedAt = now
+		if err := s.saveTask(t.ID, t); err != nil {
+			return err
+		}
+	}
+	s.notify()
+	return nil
+}
+
 // UpdateTaskBacklog edits prompt, timeout, fresh_start, and mount_worktrees for backlog tasks.
 func (s *Store) UpdateTaskBacklog(_ context.Context, id uuid.UUID, prompt *string, timeout *int, freshStart *bool, mountWorktrees *bool) error {
 	s.mu.Lock()
@@ -254,7 +756,7 @@ func (s *Store) UpdateTaskBacklog(_ context.Context, id uuid.UUID, prompt *strin
 	return nil
 }
 
-// ResetTaskForRetry moves a done/failed/cancelled task back to backlog with a fresh state.
+// ResetTaskForRetry moves a done/failed/waiting/cancelled/dead task back to backlog with a fresh state.
 // freshStart controls whether the task will start a new Claude session (true) or resume the
 // previous one (false, the default) when moved to in_progress.
 func (s *Store) ResetTaskForRetry(_ context.Context, id uuid.UUID, newPrompt string, freshStart bool) error {
@@ -265,7 +767,14 @@ func (s *Store) ResetTaskForRetry(_ context.Context, id uuid.UUID, newPrompt str
 	if !ok {
 		return fmt.Errorf("task not found: %s", id)
 	}
+	if !retryableStatuses[t.Status] {
+		return fmt.Errorf("%w: %s → backlog", ErrInvalidTransition, t.Status)
+	}
 
+	if t.Status == "dead" {
+		// Retrying a dead task is the explicit user action that revives it.
+		t.FailureCount = 0
+	}
 	t.PromptHistory = append(t.PromptHistory, t.Prompt)
 	t.Prompt = newPrompt
 	t.FreshStart = freshStart
@@ -277,6 +786,14 @@ func (s *Store) ResetTaskForRetry(_ context.Context, id uuid.UUID, newPrompt str
 	t.BranchName = ""
 	t.CommitHashes = nil
 	t.BaseCommitHashes = nil
+	t.ConflictFiles = nil
+	t.BaseCommits = nil
+	t.StartedAt = nil
+	t.FinishedAt = nil
+	t.DurationSeconds = 0
+	t.WaitingSince = nil
+	t.HoldReason = ""
+	t.ReviewRequested = false
 	t.UpdatedAt = time.Now()
 	if err := s.saveTask(id, t); err != nil {
 		return err
@@ -312,12 +829,17 @@ func (s *Store) ResumeTask(_ context.Context, id uuid.UUID, timeout *int) error
 	if !ok {
 		return fmt.Errorf("task not found: %s", id)
 	}
+	if err := checkTransition(t.Status, "in_progress"); err != nil {
+		return err
+	}
 
+	now := time.Now()
 	t.Status = "in_progress"
 	if timeout != nil {
 		t.Timeout = clampTimeout(*timeout)
 	}
-	t.UpdatedAt = time.Now()
+	t.UpdatedAt = now
+	recordTiming(t, now)
 	if err := s.saveTask(id, t); err != nil {
 		return err
 	}
@@ -344,7 +866,9 @@ func (s *Store) UpdateTaskWorktrees(_ context.Context, id uuid.UUID, worktreePat
 	return nil
 }
 
-// UpdateTaskCommitHashes stores the post-merge commit hash per repo path.
+// UpdateTaskCommitHashes stores the task's commit per repo path: the worktree
+// (pre-rebase) commit after Phase 1, replaced by the merged commit once the
+// repo is merged. Only merged repos also have a BaseCommitHashes entry.
 func (s *Store) UpdateTaskCommitHashes(_ context.Context, id uuid.UUID, hashes map[string]string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -372,6 +896,68 @@ func (s *Store) UpdateTaskBaseCommitHashes(_ context.Context, id uuid.UUID, hash
 	return s.saveTask(id, t)
 }
 
+// UpdateTaskBaseCommits records the commit each newly created worktree
+// started from. Entries for repos not in commits are kept.
+func (s *Store) UpdateTaskBaseCommits(_ context.Context, id uuid.UUID, commits map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	merged := make(map[string]string, len(t.BaseCommits)+len(commits))
+	for k, v := range t.BaseCommits {
+		merged[k] = v
+	}
+	for k, v := range commits {
+		merged[k] = v
+	}
+	t.BaseCommits = merged
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// SetTaskConflictFiles records the files that conflicted while rebasing the
+// task's worktree for repoPath. An empty files slice clears the entry.
+func (s *Store) SetTaskConflictFiles(_ context.Context, id uuid.UUID, repoPath string, files []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	if _, had := t.ConflictFiles[repoPath]; !had && len(files) == 0 {
+		return nil
+	}
+	// Copy rather than mutate: GetTask hands out shallow copies that share
+	// the map with the stored task.
+	conflicts := make(map[string][]string, len(t.ConflictFiles)+1)
+	for k, v := range t.ConflictFiles {
+		if k != repoPath {
+			conflicts[k] = v
+		}
+	}
+	if len(files) > 0 {
+		conflicts[repoPath] = files
+	}
+	if len(conflicts) == 0 {
+		conflicts = nil
+	}
+	t.ConflictFiles = conflicts
+	t.UpdatedAt = time.Now()
+	if err := s.saveTask(id, t); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
 // clampTimeout ensures timeout stays in [1, 1440] minutes with a default of 5.
 func clampTimeout(v int) int {
 	if v <= 0 {
@@ -2,7 +2,9 @@
 package store
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -111,7 +113,7 @@ func TestCreateTask_PersistsToDisk(t *testing.T) {
 func TestCreateTask_PositionOnlyCountsBacklog(t *testing.T) {
 	s := newTestStore(t)
 	t1, _ := s.CreateTask(bg(), "a", 5, false)
-	s.UpdateTaskStatus(bg(), t1.ID, "done")
+	moveTask(t, s, t1.ID, "done")
 	t2, _ := s.CreateTask(bg(), "b", 5, false)
 	// No backlog tasks exist, so maxPos = -1 and t2 gets position 0.
 	if t2.Position != 0 {
@@ -119,6 +121,39 @@ func TestCreateTask_PositionOnlyCountsBacklog(t *testing.T) {
 	}
 }
 
+func TestCreateTaskWithStatus_Done(t *testing.T) {
+	s := newTestStore(t)
+	backlog, _ := s.CreateTask(bg(), "queued", 5, false)
+	task, err := s.CreateTaskWithStatus(bg(), "historical", 5, false, "done")
+	if err != nil {
+		t.Fatalf("CreateTaskWithStatus: %v", err)
+	}
+	if task.Status != "done" {
+		t.Errorf("Status = %q, want done", task.Status)
+	}
+	// Seeding a record starts nothing: no turns, session, or worktrees.
+	if task.Turns != 0 || task.SessionID != nil || task.WorktreePaths != nil {
+		t.Errorf("seeded task should be untouched, got %+v", task)
+	}
+	// Positions are per status column, so the backlog is unaffected.
+	if task.Position != 0 || backlog.Position != 0 {
+		t.Errorf("positions = %d/%d, want 0/0", task.Position, backlog.Position)
+	}
+}
+
+func TestCreateTaskWithStatus_Invalid(t *testing.T) {
+	s := newTestStore(t)
+	for _, status := range []string{"", "in_progress", "committing", "Done", "bogus"} {
+		if _, err := s.CreateTaskWithStatus(bg(), "p", 5, false, status); err == nil {
+			t.Errorf("expected error for initial status %q", status)
+		}
+	}
+	tasks, _ := s.ListTasks(bg(), true)
+	if len(tasks) != 0 {
+		t.Errorf("rejected creations should not persist tasks, got %d", len(tasks))
+	}
+}
+
 // ─────────────────────────────────────────────────────────────────────────────
 // GetTask
 // ─────────────────────────────────────────────────────────────────────────────
@@ -269,6 +304,188 @@ func TestUpdateTaskStatus(t *testing.T) {
 	}
 }
 
+func TestUpdateTaskStatus_RecordsTiming(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+
+	moveTask(t, s, task.ID, "in_progress")
+	started, _ := s.GetTask(bg(), task.ID)
+	if started.StartedAt == nil || started.FinishedAt != nil {
+		t.Fatalf("in_progress: started=%v finished=%v", started.StartedAt, started.FinishedAt)
+	}
+
+	moveTask(t, s, task.ID, "failed")
+	failed, _ := s.GetTask(bg(), task.ID)
+	if failed.FinishedAt == nil || failed.FinishedAt.Before(*failed.StartedAt) {
+		t.Fatalf("failed: started=%v finished=%v", failed.StartedAt, failed.FinishedAt)
+	}
+	if want := failed.FinishedAt.Sub(*failed.StartedAt).Seconds(); failed.DurationSeconds != want {
+		t.Errorf("DurationSeconds = %v, want %v", failed.DurationSeconds, want)
+	}
+
+	// Resuming keeps the original start and clears the finish.
+	moveTask(t, s, task.ID, "in_progress")
+	resumed, _ := s.GetTask(bg(), task.ID)
+	if !resumed.StartedAt.Equal(*started.StartedAt) {
+		t.Errorf("StartedAt changed on resume: %v → %v", started.StartedAt, resumed.StartedAt)
+	}
+	if resumed.FinishedAt != nil || resumed.DurationSeconds != 0 {
+		t.Errorf("resume did not clear finish: %v, %v", resumed.FinishedAt, resumed.DurationSeconds)
+	}
+
+	moveTask(t, s, task.ID, "cancelled")
+	s.ResetTaskForRetry(bg(), task.ID, "p", false)
+	reset, _ := s.GetTask(bg(), task.ID)
+	if reset.StartedAt != nil || reset.FinishedAt != nil || reset.DurationSeconds != 0 {
+		t.Errorf("retry did not clear timing: %+v", reset)
+	}
+}
+
+// TestUpdateTaskStatus_RecordsWaitingSince verifies that WaitingSince marks
+// the entry into waiting, survives unrelated updates, and is cleared on
+// leaving waiting.
+func TestUpdateTaskStatus_RecordsWaitingSince(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	moveTask(t, s, task.ID, "waiting")
+	waiting, _ := s.GetTask(bg(), task.ID)
+	if waiting.WaitingSince == nil {
+		t.Fatal("WaitingSince not set on entering waiting")
+	}
+
+	s.UpdateTaskTitle(bg(), task.ID, "renamed")
+	renamed, _ := s.GetTask(bg(), task.ID)
+	if !renamed.WaitingSince.Equal(*waiting.WaitingSince) {
+		t.Errorf("title update moved WaitingSince: %v → %v", waiting.WaitingSince, renamed.WaitingSince)
+	}
+
+	moveTask(t, s, task.ID, "in_progress")
+	if resumed, _ := s.GetTask(bg(), task.ID); resumed.WaitingSince != nil {
+		t.Errorf("WaitingSince = %v after leaving waiting, want nil", resumed.WaitingSince)
+	}
+
+	imported, _ := s.CreateTaskWithStatus(bg(), "p", 5, false, "waiting")
+	if imported.WaitingSince == nil {
+		t.Error("WaitingSince not set on a task created in waiting")
+	}
+}
+
+// TestUpdateTaskStatus_ClearsHoldReason verifies that a hold reason only
+// lasts while the task is waiting.
+func TestUpdateTaskStatus_ClearsHoldReason(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	if err := s.SetTaskHoldReason(bg(), task.ID, HoldReadOnly); err != nil {
+		t.Fatal(err)
+	}
+	moveTask(t, s, task.ID, "waiting")
+	if held, _ := s.GetTask(bg(), task.ID); held.HoldReason != HoldReadOnly {
+		t.Fatalf("HoldReason = %q in waiting, want %q", held.HoldReason, HoldReadOnly)
+	}
+
+	moveTask(t, s, task.ID, "in_progress")
+	if resumed, _ := s.GetTask(bg(), task.ID); resumed.HoldReason != "" {
+		t.Errorf("HoldReason = %q after leaving waiting, want empty", resumed.HoldReason)
+	}
+}
+
+func TestUpdateTaskStatus_IllegalTransition(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	moveTask(t, s, task.ID, "done")
+
+	err := s.UpdateTaskStatus(bg(), task.ID, "backlog")
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("done → backlog: got %v, want ErrInvalidTransition", err)
+	}
+	if err := s.UpdateTaskStatus(bg(), task.ID, "in_progress"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("done → in_progress: got %v, want ErrInvalidTransition", err)
+	}
+	got, _ := s.GetTask(bg(), task.ID)
+	if got.Status != "done" {
+		t.Errorf("Status = %q after rejected transitions, want done", got.Status)
+	}
+}
+
+func TestUpdateTaskStatus_UnknownStatus(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	if err := s.UpdateTaskStatus(bg(), task.ID, "in-progress"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("got %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	legal := [][2]string{
+		{"backlog", "in_progress"}, {"in_progress", "waiting"}, {"waiting", "in_progress"},
+		{"waiting", "committing"}, {"committing", "done"}, {"committing", "failed"},
+		{"in_progress", "failed"}, {"failed", "in_progress"}, {"in_progress", "cancelled"},
+		{"failed", "dead"}, {"dead", "cancelled"}, {"committing", "cancelled"},
+	}
+	for _, e := range legal {
+		if !CanTransition(e[0], e[1]) {
+			t.Errorf("%s → %s should be legal", e[0], e[1])
+		}
+	}
+	illegal := [][2]string{
+		{"done", "backlog"}, {"done", "in_progress"}, {"backlog", "done"},
+		{"committing", "in_progress"}, {"cancelled", "in_progress"}, {"backlog", "bogus"},
+		{"dead", "in_progress"}, {"in_progress", "dead"},
+	}
+	for _, e := range illegal {
+		if CanTransition(e[0], e[1]) {
+			t.Errorf("%s → %s should be illegal", e[0], e[1])
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	for status, want := range map[string]bool{
+		"done": true, "failed": true, "cancelled": true, "dead": true,
+		"backlog": false, "in_progress": false, "waiting": false, "committing": false,
+	} {
+		if got := IsTerminal(status); got != want {
+			t.Errorf("IsTerminal(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestResetTaskForRetry_RejectsActiveTask(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	moveTask(t, s, task.ID, "in_progress")
+	if err := s.ResetTaskForRetry(bg(), task.ID, "p", false); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("got %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestGetTaskByShortID(t *testing.T) {
+	s := newTestStore(t)
+	for _, id := range []string{
+		"abcd1234-0000-0000-0000-000000000001",
+		"abcd1234-0000-0000-0000-000000000002",
+		"ef012345-0000-0000-0000-000000000003",
+	} {
+		uid := uuid.MustParse(id)
+		s.tasks[uid] = &Task{ID: uid, Prompt: id}
+	}
+
+	got, err := s.GetTaskByShortID(bg(), "EF01")
+	if err != nil {
+		t.Fatalf("unique prefix: %v", err)
+	}
+	if got.ID.String() != "ef012345-0000-0000-0000-000000000003" {
+		t.Errorf("resolved %s", got.ID)
+	}
+	if _, err := s.GetTaskByShortID(bg(), "abcd1234"); !errors.Is(err, ErrAmbiguousID) {
+		t.Errorf("ambiguous prefix: got %v, want ErrAmbiguousID", err)
+	}
+	if _, err := s.GetTaskByShortID(bg(), "99"); err == nil || errors.Is(err, ErrAmbiguousID) {
+		t.Errorf("unknown prefix: got %v, want not found", err)
+	}
+}
+
 func TestUpdateTaskStatus_NotFound(t *testing.T) {
 	s := newTestStore(t)
 	if err := s.UpdateTaskStatus(bg(), uuid.New(), "done"); err == nil {
@@ -276,6 +493,119 @@ func TestUpdateTaskStatus_NotFound(t *testing.T) {
 	}
 }
 
+func TestTaskVersion_BumpedOnWrite(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	if task.Version != 1 {
+		t.Fatalf("new task Version = %d, want 1", task.Version)
+	}
+	s.UpdateTaskTitle(bg(), task.ID, "title")
+	got, _ := s.GetTask(bg(), task.ID)
+	if got.Version != 2 {
+		t.Errorf("Version after update = %d, want 2", got.Version)
+	}
+}
+
+func TestUpdateTaskStatusIfVersion_StaleVersion(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	stale := task.Version
+	s.UpdateTaskTitle(bg(), task.ID, "title")
+
+	err := s.UpdateTaskStatusIfVersion(bg(), task.ID, "backlog", "in_progress", stale)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got %v, want ErrVersionConflict", err)
+	}
+	if got, _ := s.GetTask(bg(), task.ID); got.Status != "backlog" {
+		t.Errorf("status = %q, want backlog", got.Status)
+	}
+}
+
+func TestUpdateTaskStatusIfVersion_RaceHasOneWinner(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTaskWithStatus(bg(), "p", 5, false, "waiting")
+
+	var wg sync.WaitGroup
+	results := make(chan error, 2)
+	for _, to := range []string{"committing", "cancelled"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- s.UpdateTaskStatusIfVersion(bg(), task.ID, "waiting", to, task.Version)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	wins, conflicts := 0, 0
+	for err := range results {
+		switch {
+		case err == nil:
+			wins++
+		case errors.Is(err, ErrVersionConflict):
+			conflicts++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if wins != 1 || conflicts != 1 {
+		t.Errorf("wins = %d, conflicts = %d; want exactly one of each", wins, conflicts)
+	}
+}
+
+func backlogOrder(t *testing.T, s *Store) []uuid.UUID {
+	t.Helper()
+	tasks, err := s.ListTasks(bg(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uuid.UUID
+	for _, task := range tasks {
+		if task.Status == "backlog" {
+			ids = append(ids, task.ID)
+		}
+	}
+	return ids
+}
+
+func TestReorderBacklog(t *testing.T) {
+	s := newTestStore(t)
+	a, _ := s.CreateTask(bg(), "a", 5, false)
+	b, _ := s.CreateTask(bg(), "b", 5, false)
+	c, _ := s.CreateTask(bg(), "c", 5, false)
+	if got := backlogOrder(t, s); got[0] != a.ID || got[1] != b.ID || got[2] != c.ID {
+		t.Fatalf("initial order = %v", got)
+	}
+
+	if err := s.ReorderBacklog(bg(), []uuid.UUID{c.ID, a.ID}); err != nil {
+		t.Fatalf("ReorderBacklog: %v", err)
+	}
+	got := backlogOrder(t, s)
+	want := []uuid.UUID{c.ID, a.ID, b.ID}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order after reorder = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReorderBacklog_RejectsNonBacklog(t *testing.T) {
+	s := newTestStore(t)
+	a, _ := s.CreateTask(bg(), "a", 5, false)
+	b, _ := s.CreateTask(bg(), "b", 5, false)
+	moveTask(t, s, b.ID, "in_progress")
+
+	if err := s.ReorderBacklog(bg(), []uuid.UUID{b.ID, a.ID}); err == nil {
+		t.Error("expected error for a task not in backlog")
+	}
+	if err := s.ReorderBacklog(bg(), []uuid.UUID{a.ID, a.ID}); err == nil {
+		t.Error("expected error for a repeated id")
+	}
+	if err := s.ReorderBacklog(bg(), []uuid.UUID{uuid.New()}); err == nil {
+		t.Error("expected error for an unknown id")
+	}
+}
+
 // ─────────────────────────────────────────────────────────────────────────────
 // UpdateTaskTitle
 // ─────────────────────────────────────────────────────────────────────────────
@@ -534,7 +864,7 @@ func TestUpdateTaskBacklog_MountWorktrees(t *testing.T) {
 func TestResetTaskForRetry_PreservesMountWorktrees(t *testing.T) {
 	s := newTestStore(t)
 	task, _ := s.CreateTask(bg(), "mount retry", 5, true)
-	s.UpdateTaskStatus(bg(), task.ID, "done")
+	moveTask(t, s, task.ID, "done")
 
 	if err := s.ResetTaskForRetry(bg(), task.ID, "retry prompt", true); err != nil {
 		t.Fatalf("ResetTaskForRetry: %v", err)
@@ -553,7 +883,7 @@ func TestResetTaskForRetry_PreservesMountWorktrees(t *testing.T) {
 func TestResetTaskForRetry(t *testing.T) {
 	s := newTestStore(t)
 	task, _ := s.CreateTask(bg(), "original prompt", 5, false)
-	s.UpdateTaskStatus(bg(), task.ID, "done")
+	moveTask(t, s, task.ID, "done")
 	s.UpdateTaskResult(bg(), task.ID, "some result", "sess", "end_turn", 2)
 
 	if err := s.ResetTaskForRetry(bg(), task.ID, "new prompt", true); err != nil {
@@ -596,7 +926,9 @@ func TestResetTaskForRetry(t *testing.T) {
 func TestResetTaskForRetry_AccumulatesHistory(t *testing.T) {
 	s := newTestStore(t)
 	task, _ := s.CreateTask(bg(), "prompt1", 5, false)
+	moveTask(t, s, task.ID, "failed")
 	s.ResetTaskForRetry(bg(), task.ID, "prompt2", false)
+	moveTask(t, s, task.ID, "failed")
 	s.ResetTaskForRetry(bg(), task.ID, "prompt3", false)
 
 	got, _ := s.GetTask(bg(), task.ID)
@@ -613,6 +945,7 @@ func TestResetTaskForRetry_ClearsBaseCommitHashes(t *testing.T) {
 	task, _ := s.CreateTask(bg(), "original", 5, false)
 	s.UpdateTaskCommitHashes(bg(), task.ID, map[string]string{"/repo": "abc"})
 	s.UpdateTaskBaseCommitHashes(bg(), task.ID, map[string]string{"/repo": "def"})
+	moveTask(t, s, task.ID, "done")
 
 	s.ResetTaskForRetry(bg(), task.ID, "retry prompt", true)
 
@@ -667,7 +1000,7 @@ func TestSetTaskArchived_NotFound(t *testing.T) {
 func TestResumeTask_SetsInProgress(t *testing.T) {
 	s := newTestStore(t)
 	task, _ := s.CreateTask(bg(), "p", 5, false)
-	s.UpdateTaskStatus(bg(), task.ID, "failed")
+	moveTask(t, s, task.ID, "failed")
 
 	if err := s.ResumeTask(bg(), task.ID, nil); err != nil {
 		t.Fatalf("ResumeTask: %v", err)
@@ -788,6 +1121,40 @@ func TestUpdateTaskBaseCommitHashes_NotFound(t *testing.T) {
 	}
 }
 
+func TestSetTaskConflictFiles(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+
+	if err := s.SetTaskConflictFiles(bg(), task.ID, "/repo/a", []string{"a.go", "b.go"}); err != nil {
+		t.Fatalf("SetTaskConflictFiles: %v", err)
+	}
+	s.SetTaskConflictFiles(bg(), task.ID, "/repo/b", []string{"c.go"})
+
+	got, _ := s.GetTask(bg(), task.ID)
+	if len(got.ConflictFiles["/repo/a"]) != 2 || len(got.ConflictFiles["/repo/b"]) != 1 {
+		t.Fatalf("ConflictFiles = %v", got.ConflictFiles)
+	}
+
+	// Clearing one repo leaves the other intact; clearing all yields nil.
+	s.SetTaskConflictFiles(bg(), task.ID, "/repo/a", nil)
+	got, _ = s.GetTask(bg(), task.ID)
+	if _, ok := got.ConflictFiles["/repo/a"]; ok {
+		t.Errorf("/repo/a should be cleared, got %v", got.ConflictFiles)
+	}
+	s.SetTaskConflictFiles(bg(), task.ID, "/repo/b", nil)
+	got, _ = s.GetTask(bg(), task.ID)
+	if got.ConflictFiles != nil {
+		t.Errorf("ConflictFiles should be nil, got %v", got.ConflictFiles)
+	}
+}
+
+func TestSetTaskConflictFiles_NotFound(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetTaskConflictFiles(bg(), uuid.New(), "/repo", []string{"a"}); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
 // ─────────────────────────────────────────────────────────────────────────────
 // Concurrency
 // ─────────────────────────────────────────────────────────────────────────────
@@ -833,3 +1200,57 @@ func TestConcurrentUpdateStatus(t *testing.T) {
 		t.Error("task ID changed unexpectedly")
 	}
 }
+
+func TestSetTaskScratch(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	if err := s.SetTaskScratch(bg(), task.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetTask(bg(), task.ID)
+	if !got.Scratch {
+		t.Error("expected Scratch to be set")
+	}
+}
+
+func TestSetTaskScratch_MemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	if err := s.SetTaskScratch(bg(), task.ID, true); err == nil {
+		t.Fatal("expected error for a memory-only store")
+	}
+	if got, _ := s.GetTask(bg(), task.ID); got.Scratch {
+		t.Error("Scratch should not be set")
+	}
+}
+
+func TestSetTaskScratch_NotFound(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetTaskScratch(bg(), uuid.New(), true); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
+// TestTaskInputsRemovedWhenCancelled verifies that attached files survive a
+// failure, which may be resumed, and are removed once the task is cancelled.
+func TestTaskInputsRemovedWhenCancelled(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(bg(), "p", 5, false)
+	if err := s.SetTaskInputs(bg(), task.ID, map[string][]byte{"spec.md": []byte("# Spec\n")}); err != nil {
+		t.Fatal(err)
+	}
+
+	moveTask(t, s, task.ID, "failed")
+	if _, err := os.Stat(filepath.Join(s.InputsDir(task.ID), "spec.md")); err != nil {
+		t.Fatalf("inputs removed from a failed task: %v", err)
+	}
+
+	moveTask(t, s, task.ID, "cancelled")
+	got, _ := s.GetTask(bg(), task.ID)
+	if len(got.Inputs) != 0 {
+		t.Errorf("Inputs = %v after cancel, want none", got.Inputs)
+	}
+	if _, err := os.Stat(s.InputsDir(task.ID)); !os.IsNotExist(err) {
+		t.Errorf("inputs dir still present after cancel: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition is returned when a status change is not an edge of the
+// task state machine or names an unknown status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrAmbiguousID is returned by GetTaskByShortID when the prefix matches
+// more than one task.
+var ErrAmbiguousID = errors.New("ambiguous task id")
+
+// ErrVersionConflict is returned by UpdateTaskStatusIfVersion when the task
+// was modified since the caller read it.
+var ErrVersionConflict = errors.New("task version conflict")
+
+// statusTransitions is the task state machine: the statuses each status may
+// move to via UpdateTaskStatus. Returning to backlog is not listed; it goes
+// through ResetTaskForRetry, which also clears the previous run's state.
+// Archiving is a separate flag and does not change the status. A dead task
+// failed too often to be retried automatically; only an explicit retry back
+// to backlog revives it.
+var statusTransitions = map[string]map[string]bool{
+	"backlog":     {"in_progress": true, "cancelled": true},
+	"in_progress": {"waiting": true, "committing": true, "done": true, "failed": true, "cancelled": true},
+	"waiting":     {"in_progress": true, "committing": true, "done": true, "failed": true, "cancelled": true},
+	"committing":  {"done": true, "failed": true, "cancelled": true}, // cancelled only during the runner's pre-merge delay
+	"failed":      {"in_progress": true, "cancelled": true, "dead": true},
+	"done":        {},
+	"cancelled":   {},
+	"dead":        {"cancelled": true},
+}
+
+// retryableStatuses are the statuses ResetTaskForRetry moves back to backlog.
+var retryableStatuses = map[string]bool{
+	"done":      true,
+	"failed":    true,
+	"waiting":   true,
+	"cancelled": true,
+	"dead":      true,
+}
+
+// terminalStatuses end a run; entering one records FinishedAt.
+var terminalStatuses = map[string]bool{
+	"done":      true,
+	"failed":    true,
+	"cancelled": true,
+	"dead":      true,
+}
+
+// IsRetryable reports whether ResetTaskForRetry can move a task in status
+// back to backlog.
+func IsRetryable(status string) bool {
+	return retryableStatuses[status]
+}
+
+// IsTerminal reports whether status ends a run, after which no container
+// should be running for the task.
+func IsTerminal(status string) bool {
+	return terminalStatuses[status]
+}
+
+// CanTransition reports whether a task may move from one status to another.
+func CanTransition(from, to string) bool {
+	return statusTransitions[from][to]
+}
+
+// checkTransition returns an ErrInvalidTransition error unless from → to is a
+// legal edge.
+func checkTransition(from, to string) error {
+	if _, known := statusTransitions[to]; !known {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, to)
+	}
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,7 +9,10 @@ import (
 	"runtime"
 	"strings"
 
+	"changkun.de/wallfacer/internal/instructions"
+	"changkun.de/wallfacer/internal/layout"
 	"changkun.de/wallfacer/internal/logger"
+	"changkun.de/wallfacer/internal/runner"
 )
 
 // defaultSandboxImage is the published container image pulled automatically
@@ -23,6 +27,7 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "Commands:\n")
 	fmt.Fprintf(os.Stderr, "  run          start the Kanban server\n")
 	fmt.Fprintf(os.Stderr, "  env          show configuration and env file status\n")
+	fmt.Fprintf(os.Stderr, "  reinit       rebuild every workspace instructions file from the current template\n")
 	fmt.Fprintf(os.Stderr, "\nRun 'wallfacer <command> -help' for more information on a command.\n")
 }
 
@@ -31,7 +36,7 @@ func main() {
 	if err != nil {
 		logger.Fatal(logger.Main, "home dir", "error", err)
 	}
-	configDir := filepath.Join(home, ".wallfacer")
+	configDir := envOrDefault("WALLFACER_HOME", filepath.Join(home, ".wallfacer"))
 
 	if len(os.Args) < 2 {
 		printUsage()
@@ -43,6 +48,8 @@ func main() {
 		runEnvCheck(configDir)
 	case "run":
 		runServer(configDir, os.Args[2:])
+	case "reinit":
+		runReinitInstructions(configDir, os.Args[2:])
 	case "-help", "--help", "-h":
 		printUsage()
 	default:
@@ -52,11 +59,47 @@ func main() {
 	}
 }
 
+// runReinitInstructions rebuilds all recorded workspace instructions files,
+// e.g. after an upgrade changed the default template. Each file is rebuilt
+// with the instructions options its server recorded, so the result matches
+// what "wallfacer run" would generate. User edits to those files are
+// overwritten.
+func runReinitInstructions(configDir string, args []string) {
+	fs := flag.NewFlagSet("reinit", flag.ExitOnError)
+	instance := fs.String("instance", envOrDefault("WALLFACER_INSTANCE", ""), "rebuild the instructions of this named instance instead of the default one")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: wallfacer reinit [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Rebuild every workspace instructions file in %s from the\n", layout.New(configDir).InstructionsDir)
+		fmt.Fprintf(os.Stderr, "current default template and its workspaces' CLAUDE.md files, using the\n")
+		fmt.Fprintf(os.Stderr, "instructions flags the server last ran with for those workspaces.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if err := runner.ValidateInstance(*instance); err != nil {
+		logger.Fatal(logger.Main, "instance", "error", err)
+	}
+	paths, err := instructions.ReinitAll(layout.New(configDir).ForInstance(*instance).InstructionsBase())
+	for _, p := range paths {
+		fmt.Printf("rebuilt %s\n", p)
+	}
+	if err != nil {
+		logger.Fatal(logger.Main, "reinit instructions", "error", err)
+	}
+	if len(paths) == 0 {
+		fmt.Println("no recorded instructions files; run 'wallfacer run' to create them")
+	}
+}
+
 func runEnvCheck(configDir string) {
-	envFile := envOrDefault("ENV_FILE", filepath.Join(configDir, ".env"))
+	paths := layout.New(configDir)
+	envFile := envOrDefault("ENV_FILE", paths.EnvFile)
 
 	fmt.Printf("Config directory:  %s\n", configDir)
-	fmt.Printf("Data directory:    %s\n", envOrDefault("DATA_DIR", filepath.Join(configDir, "data")))
+	fmt.Printf("Data directory:    %s\n", envOrDefault("DATA_DIR", paths.DataDir))
+	fmt.Printf("Instructions:      %s\n", paths.InstructionsDir)
+	fmt.Printf("Worktrees:         %s\n", paths.WorktreesDir)
 	fmt.Printf("Env file:          %s\n", envFile)
 	fmt.Printf("Container command: %s\n", envOrDefault("CONTAINER_CMD", detectContainerRuntime()))
 	fmt.Printf("Sandbox image:     %s\n", envOrDefault("SANDBOX_IMAGE", defaultSandboxImage))
@@ -123,7 +166,9 @@ func runEnvCheck(configDir string) {
 	}
 
 	containerCmd := envOrDefault("CONTAINER_CMD", detectContainerRuntime())
-	if _, err := exec.LookPath(containerCmd); err != nil {
+	if containerCmd == "" {
+		fmt.Printf("[!] Container runtime not found: install podman or docker\n")
+	} else if _, err := exec.LookPath(containerCmd); err != nil {
 		fmt.Printf("[!] Container runtime not found: %s\n", containerCmd)
 	} else {
 		fmt.Printf("[ok] Container runtime found: %s\n", containerCmd)
@@ -180,22 +225,14 @@ func envOrDefault(key, fallback string) string {
 
 // detectContainerRuntime returns the path to the container runtime binary.
 // It prefers /opt/podman/bin/podman, then falls back to "podman" and "docker"
-// on $PATH. Returns the hardcoded default if nothing is found.
+// on $PATH. Returns "" if nothing is found; Runner.CheckRuntime then reports
+// the missing runtime.
 func detectContainerRuntime() string {
 	// Preferred: explicit podman installation.
 	if _, err := os.Stat("/opt/podman/bin/podman"); err == nil {
 		return "/opt/podman/bin/podman"
 	}
-	// Fallback: podman on $PATH.
-	if p, err := exec.LookPath("podman"); err == nil {
-		return p
-	}
-	// Fallback: docker on $PATH.
-	if p, err := exec.LookPath("docker"); err == nil {
-		return p
-	}
-	// Nothing found; return the traditional default so the error message is clear.
-	return "/opt/podman/bin/podman"
+	return runner.DetectRuntime()
 }
 
 func openBrowser(url string) {
@@ -14,8 +14,10 @@ import (
 	"strings"
 	"time"
 
+	"changkun.de/wallfacer/internal/gitutil"
 	"changkun.de/wallfacer/internal/handler"
 	"changkun.de/wallfacer/internal/instructions"
+	"changkun.de/wallfacer/internal/layout"
 	"changkun.de/wallfacer/internal/logger"
 	"changkun.de/wallfacer/internal/runner"
 	"changkun.de/wallfacer/internal/store"
@@ -29,14 +31,54 @@ var uiFiles embed.FS
 
 func runServer(configDir string, args []string) {
 	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	paths := layout.New(configDir)
 
 	logFormat := fs.String("log-format", envOrDefault("LOG_FORMAT", "text"), `log output format: "text" or "json"`)
 	addr := fs.String("addr", envOrDefault("ADDR", ":8080"), "listen address")
-	dataDir := fs.String("data", envOrDefault("DATA_DIR", filepath.Join(configDir, "data")), "data directory")
-	containerCmd := fs.String("container", envOrDefault("CONTAINER_CMD", detectContainerRuntime()), "container runtime command (podman or docker)")
+	dataDir := fs.String("data", envOrDefault("DATA_DIR", paths.DataDir), "data directory")
+	containerCmd := fs.String("container", envOrDefault("CONTAINER_CMD", detectContainerRuntime()), "container runtime command (podman or docker; default: auto-detect)")
 	sandboxImage := fs.String("image", envOrDefault("SANDBOX_IMAGE", defaultSandboxImage), "sandbox container image")
-	envFile := fs.String("env-file", envOrDefault("ENV_FILE", filepath.Join(configDir, ".env")), "env file for container (Claude token)")
+	envFile := fs.String("env-file", envOrDefault("ENV_FILE", paths.EnvFile), "env file for container (Claude token)")
 	noBrowser := fs.Bool("no-browser", false, "do not open browser on start")
+	gitAuthorName := fs.String("git-author-name", envOrDefault("WALLFACER_GIT_AUTHOR_NAME", ""), "author name for wallfacer commits (default: global git user.name)")
+	gitAuthorEmail := fs.String("git-author-email", envOrDefault("WALLFACER_GIT_AUTHOR_EMAIL", ""), "author email for wallfacer commits (default: global git user.email)")
+	keepBranch := fs.Bool("keep-branch", false, "keep task branches after merge instead of deleting them")
+	tagTasks := fs.Bool("tag-tasks", false, "tag each merged task commit as wallfacer/<short-id>")
+	rebaseArgs := fs.String("rebase-args", envOrDefault("WALLFACER_REBASE_ARGS", ""), "extra space-separated flags for git rebase (e.g. --autosquash)")
+	rerere := fs.Bool("rerere", false, "enable git rerere when rebasing task branches")
+	notifyURL := fs.String("notify-url", envOrDefault("WALLFACER_NOTIFY_URL", ""), "webhook URL notified when a task finishes, fails, waits, or is cancelled")
+	notifyFormat := fs.String("notify-format", envOrDefault("WALLFACER_NOTIFY_FORMAT", runner.NotifyFormatRaw), "webhook payload format: raw or slack")
+	agentArgs := fs.String("agent-args", envOrDefault("WALLFACER_AGENT_ARGS", ""), "extra space-separated flags passed to Claude Code inside the container, after wallfacer's own (trusted; e.g. --debug)")
+	containerArgs := fs.String("container-args", envOrDefault("WALLFACER_CONTAINER_ARGS", ""), "extra space-separated flags passed to the container runtime before the image (trusted; e.g. --cap-drop=ALL)")
+	hardened := fs.Bool("hardened", envOrDefault("WALLFACER_HARDENED", "false") == "true", "run containers with --cap-drop=ALL, no-new-privileges, and a seccomp profile")
+	seccompProfile := fs.String("seccomp-profile", envOrDefault("WALLFACER_SECCOMP_PROFILE", ""), "seccomp profile applied to containers in -hardened mode (default: a built-in profile)")
+	waitingTimeout := fs.Duration("waiting-timeout", 0, "move tasks left in waiting this long to -waiting-timeout-action (0 = wait forever)")
+	waitingTimeoutAction := fs.String("waiting-timeout-action", envOrDefault("WALLFACER_WAITING_TIMEOUT_ACTION", runner.WaitingTimeoutFail), "what to do with timed-out waiting tasks: commit or fail")
+	noWorkspaceLayout := fs.Bool("no-workspace-layout", false, "omit the Workspace Layout section from generated instructions")
+	noBoard := fs.Bool("no-board", false, "run tasks without the board context (board.json, sibling worktrees) and omit it from generated instructions")
+	instructionsMount := fs.String("instructions-mount", envOrDefault("WALLFACER_INSTRUCTIONS_MOUNT", ""), "absolute container path the workspace instructions are mounted at (default: the workspace root, or /workspace/CLAUDE.md for several workspaces)")
+	separateInstructions := fs.Bool("separate-instructions", false, "mount each workspace's own CLAUDE.md at its repo path and only wallfacer's defaults at /workspace/CLAUDE.md, instead of one merged file")
+	instructionsOrder := fs.String("instructions-order", envOrDefault("WALLFACER_INSTRUCTIONS_ORDER", instructions.OrderAppend), "where repo CLAUDE.md files go in generated instructions: append or prepend")
+	requireInstructions := fs.Bool("require-instructions", false, "fail tasks instead of running them without instructions when the instructions file is missing")
+	readOnlyWorkspace := fs.Bool("read-only-workspace", false, "mount task worktrees as Podman overlays; writes reach the worktree only when the task is marked done")
+	revertOutOfScope := fs.Bool("revert-out-of-scope", false, "revert task changes outside the task's allowed_paths instead of failing the commit")
+	secretScan := fs.Bool("secret-scan", false, "block merging task changes that contain likely secrets (AWS keys, private keys, tokens)")
+	defaultBranches := fs.String("default-branches", envOrDefault("WALLFACER_DEFAULT_BRANCHES", ""), "comma-separated workspace=branch pairs (workspace path or basename) overriding the auto-detected branch tasks merge into")
+	maxDiffLines := fs.Int("max-diff-lines", 0, "send tasks whose changes exceed this many lines to waiting for review instead of merging them automatically (0 = no limit)")
+	verifyCommand := fs.String("verify-command", envOrDefault("WALLFACER_VERIFY_COMMAND", ""), "shell command run in a fresh sandbox against a finished task's worktrees; a non-zero exit holds the task in waiting instead of merging it (e.g. \"go test ./...\")")
+	preMergeDelay := fs.Duration("pre-merge-delay", 0, "wait this long before merging a task's changes, so cancelling the task can still abort the merge (0 = merge right away)")
+	unshallow := fs.Bool("unshallow", false, "fetch the full history of workspaces that are shallow clones before creating task worktrees, so rebasing task branches works")
+	maxRetries := fs.Int("max-retries", 0, "move a task that has failed more than this many times to dead instead of failed (0 = unlimited)")
+	shortIDLength := fs.Int("short-id-length", 8, "minimum length of task short IDs in board.json and sibling mounts")
+	createRate := fs.Float64("create-rate", 0, "maximum task creations per second through the API (0 = unlimited)")
+	createBurst := fs.Int("create-burst", 10, "task creations allowed in a burst before -create-rate applies")
+	idempotencyWindow := fs.Duration("idempotency-window", 24*time.Hour, "how long Idempotency-Key headers on POST /api/tasks are remembered")
+	apiToken := fs.String("api-token", envOrDefault("WALLFACER_API_TOKEN", ""), "require \"Authorization: Bearer <token>\" on /api/ routes (default: no auth)")
+	corsOrigins := fs.String("cors-origins", envOrDefault("WALLFACER_CORS_ORIGINS", ""), "comma-separated origins allowed to call the API cross-origin (\"*\" for any; default: same-origin only)")
+	corsMethods := fs.String("cors-methods", envOrDefault("WALLFACER_CORS_METHODS", ""), "comma-separated methods allowed for cross-origin requests (default: GET,POST,PUT,PATCH,DELETE)")
+	corsHeaders := fs.String("cors-headers", envOrDefault("WALLFACER_CORS_HEADERS", ""), "comma-separated request headers allowed for cross-origin requests (default: Content-Type)")
+	instance := fs.String("instance", envOrDefault("WALLFACER_INSTANCE", ""), "name of this server when several share repositories: prefixes task branches and container names and gives it its own data, instructions and worktrees (default: unprefixed)")
+	shallow := fs.Bool("shallow", false, "use isolated shallow clones instead of linked git worktrees (disables rebase)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: wallfacer run [flags] [workspace ...]\n\n")
@@ -54,6 +96,17 @@ func runServer(configDir string, args []string) {
 	// Auto-initialize config directory and .env template.
 	initConfigDir(configDir, *envFile)
 
+	// A named instance keeps its files under its own directory. An explicit
+	// -data (or DATA_DIR) still wins over the instance's data directory.
+	if err := runner.ValidateInstance(*instance); err != nil {
+		logger.Fatal(logger.Main, "instance", "error", err)
+	}
+	instancePaths := paths.ForInstance(*instance)
+	if *dataDir == paths.DataDir {
+		*dataDir = instancePaths.DataDir
+	}
+	paths = instancePaths
+
 	// Positional args are workspace directories.
 	workspaces := fs.Args()
 	if len(workspaces) == 0 {
@@ -80,8 +133,15 @@ func runServer(configDir string, args []string) {
 		workspaces[i] = abs
 	}
 
-	// Scope the data directory to the specific workspace combination.
-	scopedDataDir := filepath.Join(*dataDir, instructions.Key(workspaces))
+	// Scope the data directory to the specific workspace combination,
+	// adopting tasks an older release kept directly in the data directory.
+	paths.DataDir, paths.EnvFile = *dataDir, *envFile
+	if moved, err := paths.MigrateLegacyStore(workspaces); err != nil {
+		logger.Fatal(logger.Main, "migrate legacy data", "error", err)
+	} else if len(moved) > 0 {
+		logger.Main.Info("migrated legacy tasks", "count", len(moved), "to", paths.StoreDir(workspaces))
+	}
+	scopedDataDir := paths.StoreDir(workspaces)
 
 	s, err := store.NewStore(scopedDataDir)
 	if err != nil {
@@ -90,35 +150,100 @@ func runServer(configDir string, args []string) {
 	defer s.Close()
 	logger.Main.Info("store loaded", "path", scopedDataDir)
 
-	worktreesDir := filepath.Join(configDir, "worktrees")
+	worktreesDir := paths.WorktreesDir
 	if err := os.MkdirAll(worktreesDir, 0755); err != nil {
 		logger.Fatal(logger.Main, "create worktrees dir", "error", err)
 	}
 
-	instructionsPath, err := instructions.Ensure(configDir, workspaces)
+	if *instructionsOrder != instructions.OrderAppend && *instructionsOrder != instructions.OrderPrepend {
+		logger.Fatal(logger.Main, "instructions order", "order", *instructionsOrder)
+	}
+	instructionsOpts := instructions.Options{OmitLayout: *noWorkspaceLayout, OmitBoard: *noBoard, Order: *instructionsOrder, OmitRepos: *separateInstructions}
+	instructionsPath, err := instructions.Ensure(paths.InstructionsBase(), workspaces, instructionsOpts)
 	if err != nil {
-		logger.Main.Warn("init workspace instructions", "error", err)
+		// Keep the expected path so the runner can tell "write failed" from
+		// "no instructions" and warn (or fail, with -require-instructions).
+		instructionsPath = instructions.FilePath(paths.InstructionsBase(), workspaces)
+		logger.Main.Error("init workspace instructions", "path", instructionsPath, "error", err)
 	} else {
 		logger.Main.Info("workspace instructions", "path", instructionsPath)
 	}
 
+	branchOverrides, err := parseBranchMap(*defaultBranches)
+	if err != nil {
+		logger.Fatal(logger.Main, "default branches", "error", err)
+	}
+
 	resolvedImage := ensureImage(*containerCmd, *sandboxImage)
 
-	r := runner.NewRunner(s, runner.RunnerConfig{
-		Command:          *containerCmd,
-		SandboxImage:     resolvedImage,
-		EnvFile:          *envFile,
-		Workspaces:       strings.Join(workspaces, " "),
-		WorktreesDir:     worktreesDir,
-		InstructionsPath: instructionsPath,
+	r, err := runner.NewRunnerChecked(s, runner.RunnerConfig{
+		Command:               *containerCmd,
+		SandboxImage:          resolvedImage,
+		EnvFile:               *envFile,
+		Workspaces:            strings.Join(workspaces, " "),
+		WorktreesDir:          worktreesDir,
+		InstructionsPath:      instructionsPath,
+		InstructionsMountPath: *instructionsMount,
+		SeparateInstructions:  *separateInstructions,
+		ShallowWorktree:       *shallow,
+		KeepBranch:            *keepBranch,
+		TagTasks:              *tagTasks,
+		RebaseArgs:            strings.Fields(*rebaseArgs),
+		Rerere:                *rerere,
+		NotifyURL:             *notifyURL,
+		NotifyFormat:          *notifyFormat,
+		ShortIDLength:         *shortIDLength,
+		ExtraRunArgs:          strings.Fields(*containerArgs),
+		AgentArgs:             strings.Fields(*agentArgs),
+		HardenedSandbox:       *hardened,
+		SeccompProfile:        *seccompProfile,
+		GitAuthorName:         *gitAuthorName,
+		GitAuthorEmail:        *gitAuthorEmail,
+		WaitingTimeout:        *waitingTimeout,
+		WaitingTimeoutAction:  *waitingTimeoutAction,
+		RequireInstructions:   *requireInstructions,
+		MaxRetries:            *maxRetries,
+		ReadOnlyWorkspace:     *readOnlyWorkspace,
+		DisableBoard:          *noBoard,
+		RevertOutOfScope:      *revertOutOfScope,
+		SecretScan:            *secretScan,
+		MaxDiffLines:          *maxDiffLines,
+		VerifyCommand:         *verifyCommand,
+		PreMergeDelay:         *preMergeDelay,
+		Unshallow:             *unshallow,
+		DefaultBranches:       branchOverrides,
+		Instance:              *instance,
 	})
+	if err != nil {
+		logger.Fatal(logger.Main, "runner", "error", err)
+	}
+	if err := r.CheckRuntime(); err != nil {
+		logger.Fatal(logger.Main, "container runtime", "error", err)
+	}
 
 	r.PruneOrphanedWorktrees(s)
 	recoverOrphanedTasks(s, r)
+	go r.WatchNotifications(context.Background())
+	go r.WatchWaitingTimeout(context.Background())
+	go r.WatchZombieContainers(context.Background())
 
 	logger.Main.Info("workspaces", "paths", strings.Join(workspaces, ", "))
+	if !r.RsyncAvailable() {
+		var nonGit []string
+		for _, ws := range workspaces {
+			if !gitutil.IsGitRepo(context.Background(), ws) {
+				nonGit = append(nonGit, ws)
+			}
+		}
+		if len(nonGit) > 0 {
+			logger.Main.Warn("rsync not found: changes to non-git workspaces are synced back with the slower built-in copy", "workspaces", strings.Join(nonGit, ", "))
+		}
+	}
 
-	h := handler.NewHandler(s, r, configDir, workspaces)
+	h := handler.NewHandler(s, r, paths.InstructionsBase(), workspaces)
+	h.SetCreateRateLimit(*createRate, *createBurst)
+	h.SetIdempotencyWindow(*idempotencyWindow)
+	h.SetInstructionsOptions(instructionsOpts)
 
 	mux := buildMux(h, r)
 
@@ -142,7 +267,12 @@ func runServer(configDir string, args []string) {
 	}
 
 	logger.Main.Info("listening", "addr", ln.Addr().String())
-	if err := http.Serve(ln, loggingMiddleware(mux)); err != nil {
+	cors := handler.CORSConfig{
+		AllowedOrigins: splitList(*corsOrigins),
+		AllowedMethods: splitList(*corsMethods),
+		AllowedHeaders: splitList(*corsHeaders),
+	}
+	if err := http.Serve(ln, loggingMiddleware(handler.CORS(cors, handler.BearerAuth(*apiToken, mux)))); err != nil {
 		logger.Fatal(logger.Main, "server", "error", err)
 	}
 }
@@ -155,6 +285,12 @@ func buildMux(h *handler.Handler, _ *runner.Runner) *http.ServeMux {
 	uiFS, _ := fsLib.Sub(uiFiles, "ui")
 	mux.Handle("GET /", http.FileServer(http.FS(uiFS)))
 
+	// Liveness probe (never requires the API token).
+	mux.HandleFunc("GET /healthz", h.Healthz)
+
+	// API description.
+	mux.HandleFunc("GET /api/openapi.json", h.OpenAPI)
+
 	// Container monitoring.
 	mux.HandleFunc("GET /api/containers", h.GetContainers)
 
@@ -181,6 +317,14 @@ func buildMux(h *handler.Handler, _ *runner.Runner) *http.ServeMux {
 	mux.HandleFunc("GET /api/tasks/stream", h.StreamTasks)
 	mux.HandleFunc("POST /api/tasks", h.CreateTask)
 	mux.HandleFunc("POST /api/tasks/generate-titles", h.GenerateMissingTitles)
+	mux.HandleFunc("POST /api/tasks/run-sync", h.RunTaskSync)
+	mux.HandleFunc("POST /api/backlog/reorder", h.ReorderBacklog)
+	mux.HandleFunc("POST /api/workspaces/{name}/cancel-all", h.CancelWorkspaceTasks)
+
+	// Task launching.
+	mux.HandleFunc("GET /api/scheduler", h.GetScheduler)
+	mux.HandleFunc("POST /api/scheduler/pause", h.PauseScheduler)
+	mux.HandleFunc("POST /api/scheduler/resume", h.ResumeScheduler)
 
 	// Task instance routes (require UUID parsing).
 	withID := func(fn func(http.ResponseWriter, *http.Request, uuid.UUID)) http.HandlerFunc {
@@ -194,6 +338,7 @@ func buildMux(h *handler.Handler, _ *runner.Runner) *http.ServeMux {
 		}
 	}
 
+	mux.HandleFunc("GET /api/tasks/{id}", h.GetTask) // accepts a UUID or a unique prefix
 	mux.HandleFunc("PATCH /api/tasks/{id}", withID(h.UpdateTask))
 	mux.HandleFunc("DELETE /api/tasks/{id}", withID(h.DeleteTask))
 	mux.HandleFunc("GET /api/tasks/{id}/events", withID(h.GetEvents))
@@ -204,8 +349,12 @@ func buildMux(h *handler.Handler, _ *runner.Runner) *http.ServeMux {
 	mux.HandleFunc("POST /api/tasks/{id}/archive", withID(h.ArchiveTask))
 	mux.HandleFunc("POST /api/tasks/{id}/unarchive", withID(h.UnarchiveTask))
 	mux.HandleFunc("POST /api/tasks/{id}/sync", withID(h.SyncTask))
+	mux.HandleFunc("POST /api/tasks/{id}/reset", withID(h.ResetTask))
 	mux.HandleFunc("GET /api/tasks/{id}/diff", withID(h.TaskDiff))
+	mux.HandleFunc("GET /api/tasks/{id}/artifact", withID(h.TaskArtifact))
 	mux.HandleFunc("GET /api/tasks/{id}/logs", withID(h.StreamLogs))
+	mux.HandleFunc("GET /api/tasks/{id}/stream", withID(h.StreamTaskOutput))
+	mux.HandleFunc("GET /api/tasks/{id}/transcript", withID(h.GetTranscript))
 	mux.HandleFunc("GET /api/tasks/{id}/outputs/{filename}", func(w http.ResponseWriter, r *http.Request) {
 		id, err := uuid.Parse(r.PathValue("id"))
 		if err != nil {
@@ -250,11 +399,48 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseBranchMap parses the -default-branches value, "workspace=branch"
+// pairs separated by commas. A workspace containing a path separator is
+// resolved to an absolute path; anything else is matched as a basename.
+func parseBranchMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range splitList(s) {
+		ws, branch, ok := strings.Cut(pair, "=")
+		ws, branch = strings.TrimSpace(ws), strings.TrimSpace(branch)
+		if !ok || ws == "" || branch == "" {
+			return nil, fmt.Errorf("invalid entry %q, want workspace=branch", pair)
+		}
+		if strings.ContainsRune(ws, filepath.Separator) {
+			abs, err := filepath.Abs(ws)
+			if err != nil {
+				return nil, err
+			}
+			ws = abs
+		}
+		m[ws] = branch
+	}
+	return m, nil
+}
+
 // ensureImage checks whether the sandbox image is present locally and pulls it
 // from the registry if it is not.  When the pull fails and a local fallback
 // image (wallfacer:latest) is available, that image is used instead.
 // Returns the image reference that should actually be used.
 func ensureImage(containerCmd, image string) string {
+	if _, err := exec.LookPath(containerCmd); err != nil {
+		return image // reported by Runner.CheckRuntime
+	}
 	out, err := exec.Command(containerCmd, "images", "-q", image).Output()
 	if err == nil && strings.TrimSpace(string(out)) != "" {
 		return image // already present
@@ -355,7 +541,7 @@ func recoverOrphanedTasks(s *store.Store, r *runner.Runner) {
 // to waiting so the user can decide what to do next.
 func monitorContainerUntilStopped(s *store.Store, r *runner.Runner, taskID uuid.UUID) {
 	ctx := context.Background()
-	containerName := "wallfacer-" + taskID.String()
+	containerName := r.ContainerName(taskID)
 	ticker := time.NewTicker(containerPollInterval)
 	defer ticker.Stop()
 
@@ -48,3 +48,28 @@ function toggleShowArchived() {
   localStorage.setItem('wallfacer-show-archived', showArchived ? 'true' : 'false');
   startTasksStream();
 }
+
+// --- Task launching ---
+
+function renderSchedulerState(state) {
+  document.getElementById('paused-badge').classList.toggle('hidden', !state.paused);
+  document.getElementById('scheduler-paused-toggle').checked = state.paused;
+}
+
+async function fetchSchedulerState() {
+  try {
+    renderSchedulerState(await api('/api/scheduler'));
+  } catch (e) {
+    console.error('scheduler state:', e);
+  }
+}
+
+async function toggleSchedulerPaused() {
+  const paused = document.getElementById('scheduler-paused-toggle').checked;
+  try {
+    renderSchedulerState(await api(paused ? '/api/scheduler/pause' : '/api/scheduler/resume', { method: 'POST' }));
+  } catch (e) {
+    showAlert('Error updating task launching: ' + e.message);
+    fetchSchedulerState();
+  }
+}
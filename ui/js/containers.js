@@ -88,9 +88,14 @@ function renderContainers(containers) {
 
   containers.forEach(function(c) {
     var tr = document.createElement('tr');
+    var rowBg = c.orphaned ? 'rgba(212,104,104,0.12)' : '';
     tr.style.cssText = 'border-bottom: 1px solid var(--border); transition: background 0.1s;';
+    tr.style.background = rowBg;
+    if (c.orphaned) {
+      tr.title = 'Container is running but its task is ' + (c.task_status || 'unknown');
+    }
     tr.addEventListener('mouseenter', function() { tr.style.background = 'var(--bg-raised)'; });
-    tr.addEventListener('mouseleave', function() { tr.style.background = ''; });
+    tr.addEventListener('mouseleave', function() { tr.style.background = rowBg; });
 
     var shortID = c.id ? c.id.substring(0, 12) : '—';
     var stateColor = containerStateColor(c.state);
@@ -101,10 +106,12 @@ function renderContainers(containers) {
     if (c.task_id) {
       var task = taskMap[c.task_id];
       if (task) {
+        // Prefer the server-joined status: it is current as of this listing.
+        var status = c.task_status && c.task_status !== 'unknown' ? c.task_status : task.status;
         var taskTitle = escapeHtml(task.title || task.prompt || c.task_id);
-        var badgeClass = 'badge badge-' + (task.status || 'backlog');
+        var badgeClass = 'badge badge-' + (status || 'backlog');
         taskCell = '<span class="' + badgeClass + '" style="margin-right:6px;">' +
-          escapeHtml(task.status) + '</span>' +
+          escapeHtml(status) + '</span>' +
           '<span style="color:var(--text-primary);">' + taskTitle + '</span>';
       } else {
         taskCell = '<span style="font-family:monospace;color:var(--text-muted);">' +
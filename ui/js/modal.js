@@ -157,6 +157,29 @@ function renderDiffFiles(container, diff) {
 
 // --- Modal ---
 
+// Summarize a task's tool call timeline, e.g.
+// "ran 14 bash commands, edited 3 files, read 5 files".
+function summarizeToolCalls(calls) {
+  if (!calls || calls.length === 0) return '';
+  let bash = 0, other = 0;
+  const edited = new Set(), read = new Set();
+  for (const c of calls) {
+    switch (c.tool) {
+      case 'Bash': bash++; break;
+      case 'Edit': case 'MultiEdit': case 'Write': case 'NotebookEdit': edited.add(c.input || c.at); break;
+      case 'Read': read.add(c.input || c.at); break;
+      default: other++;
+    }
+  }
+  const plural = (n, word) => `${n} ${word}${n === 1 ? '' : 's'}`;
+  const parts = [];
+  if (bash) parts.push('ran ' + plural(bash, 'bash command'));
+  if (edited.size) parts.push('edited ' + plural(edited.size, 'file'));
+  if (read.size) parts.push('read ' + plural(read.size, 'file'));
+  if (other) parts.push(plural(other, 'other call'));
+  return parts.join(', ');
+}
+
 async function openModal(id) {
   currentTaskId = id;
   const task = tasks.find(t => t.id === id);
@@ -211,6 +234,29 @@ async function openModal(id) {
     document.getElementById('modal-usage-cache-read').textContent = u.cache_read_input_tokens.toLocaleString();
     document.getElementById('modal-usage-cache-creation').textContent = u.cache_creation_input_tokens.toLocaleString();
     document.getElementById('modal-usage-cost').textContent = '$' + u.cost_usd.toFixed(4);
+    const durationRow = document.getElementById('modal-usage-duration-row');
+    if (task.duration_seconds) {
+      document.getElementById('modal-usage-duration').textContent = formatDuration(task.duration_seconds);
+      durationRow.classList.remove('hidden');
+    } else {
+      durationRow.classList.add('hidden');
+    }
+    const resourcesRow = document.getElementById('modal-usage-resources-row');
+    if (task.peak_memory_bytes || task.cpu_seconds) {
+      document.getElementById('modal-usage-resources').textContent =
+        formatBytes(task.peak_memory_bytes || 0) + ' / ' + formatDuration(task.cpu_seconds || 0);
+      resourcesRow.classList.remove('hidden');
+    } else {
+      resourcesRow.classList.add('hidden');
+    }
+    const toolsRow = document.getElementById('modal-usage-tools-row');
+    const toolSummary = summarizeToolCalls(task.tool_calls);
+    if (toolSummary) {
+      document.getElementById('modal-usage-tools').textContent = toolSummary;
+      toolsRow.classList.remove('hidden');
+    } else {
+      toolsRow.classList.add('hidden');
+    }
     usageSection.classList.remove('hidden');
   } else {
     usageSection.classList.add('hidden');
@@ -284,15 +330,16 @@ async function openModal(id) {
     resumeSection.classList.add('hidden');
   }
 
-  // Cancel section (backlog / in_progress / waiting / failed)
+  // Cancel section (backlog / in_progress / waiting / committing / failed);
+  // a committing task can only be cancelled during its pre-merge delay.
   const cancelSection = document.getElementById('modal-cancel-section');
-  const cancellable = ['backlog', 'in_progress', 'waiting', 'failed'];
+  const cancellable = ['backlog', 'in_progress', 'waiting', 'committing', 'failed', 'dead'];
   cancelSection.classList.toggle('hidden', !cancellable.includes(task.status));
 
-  // Retry section (done / failed / waiting / cancelled)
+  // Retry section (done / failed / waiting / cancelled / dead)
   const retrySection = document.getElementById('modal-retry-section');
   const retryResumeRow = document.getElementById('modal-retry-resume-row');
-  if (task.status === 'done' || task.status === 'failed' || task.status === 'waiting' || task.status === 'cancelled') {
+  if (task.status === 'done' || task.status === 'failed' || task.status === 'waiting' || task.status === 'cancelled' || task.status === 'dead') {
     retrySection.classList.remove('hidden');
     document.getElementById('modal-retry-prompt').value = task.prompt;
     if (task.session_id) {
@@ -360,6 +407,10 @@ async function openModal(id) {
         detail = escapeHtml(data.result || '');
       } else if (e.event_type === 'error') {
         detail = escapeHtml(data.error);
+      } else if (e.event_type === 'container') {
+        detail = data.action === 'stop'
+          ? `${escapeHtml(data.container)} exited (code ${escapeHtml(data.exit_code)})`
+          : `${escapeHtml(data.container)} started`;
       }
       const typeClasses = {
         state_change: 'ev-state',
@@ -367,6 +418,7 @@ async function openModal(id) {
         system: 'ev-system',
         feedback: 'ev-feedback',
         error: 'ev-error',
+        container: 'ev-container',
       };
       return `<div class="flex items-start gap-2 text-xs">
         <span class="text-v-muted shrink-0">${time}</span>
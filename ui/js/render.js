@@ -62,17 +62,18 @@ function applyDiffToCard(el, diff, behindCounts, taskId) {
 }
 
 function render() {
-  const columns = { backlog: [], in_progress: [], waiting: [], committing: [], done: [], failed: [], cancelled: [] };
+  const columns = { backlog: [], in_progress: [], waiting: [], committing: [], done: [], failed: [], dead: [], cancelled: [] };
   for (const t of tasks) {
     const col = columns[t.status];
     if (col) col.push(t);
   }
 
-  // Failed and committing tasks show in the Waiting column.
-  // Failed tasks are visually distinguished by a red left border on the card.
-  columns.waiting = columns.waiting.concat(columns.failed).concat(columns.committing);
+  // Failed, dead, and committing tasks show in the Waiting column.
+  // Failed and dead tasks are visually distinguished by a red left border on the card.
+  columns.waiting = columns.waiting.concat(columns.failed).concat(columns.dead).concat(columns.committing);
   delete columns.committing;
   delete columns.failed;
+  delete columns.dead;
 
   // Cancelled tasks show in the Done column.
   // Cancelled tasks are visually distinguished by a purple left border on the card.
@@ -156,7 +157,7 @@ function buildCardActions(t) {
       parts.push(`<button class="card-action-btn card-action-resume" onclick="event.stopPropagation();quickResumeTask('${t.id}',${t.timeout || 15})" title="Resume in existing session">&#8635; Resume</button>`);
     }
     parts.push(`<button class="card-action-btn card-action-retry" onclick="event.stopPropagation();quickRetryTask('${t.id}')" title="Move back to Backlog">&#8617; Retry</button>`);
-  } else if (t.status === 'cancelled') {
+  } else if (t.status === 'cancelled' || t.status === 'dead') {
     parts.push(`<button class="card-action-btn card-action-retry" onclick="event.stopPropagation();quickRetryTask('${t.id}')" title="Move back to Backlog">&#8617; Retry</button>`);
   } else if (t.status === 'done') {
     parts.push(`<button class="card-action-btn card-action-retry" onclick="event.stopPropagation();quickRetryTask('${t.id}')" title="Move back to Backlog">&#8617; Retry</button>`);
@@ -165,6 +166,13 @@ function buildCardActions(t) {
   return `<div class="card-actions">${parts.join('')}</div>`;
 }
 
+// conflictFilesText flattens t.conflict_files (repo path → files) into a
+// comma-separated list for display; empty when there were no conflicts.
+function conflictFilesText(t) {
+  if (!t.conflict_files) return '';
+  return Object.values(t.conflict_files).flat().join(', ');
+}
+
 function updateCard(card, t) {
   const isArchived = !!t.archived;
   const badgeClass = isArchived ? 'badge-archived' : `badge-${t.status}`;
@@ -172,8 +180,8 @@ function updateCard(card, t) {
   const showSpinner = t.status === 'in_progress' || t.status === 'committing';
   const showDiff = (t.status === 'waiting' || t.status === 'failed') && t.worktree_paths && Object.keys(t.worktree_paths).length > 0;
   card.style.opacity = isArchived ? '0.55' : '';
-  // Failed tasks in the waiting column get a red left border to distinguish them.
-  if (t.status === 'failed') {
+  // Failed and dead tasks in the waiting column get a red left border to distinguish them.
+  if (t.status === 'failed' || t.status === 'dead') {
     card.classList.add('card-failed-waiting');
   } else {
     card.classList.remove('card-failed-waiting');
@@ -189,6 +197,7 @@ function updateCard(card, t) {
       <div class="flex items-center gap-1.5">
         <span class="badge ${badgeClass}">${statusLabel}</span>
         ${showSpinner ? '<span class="spinner"></span>' : ''}
+        ${t.container ? `<span class="container-dot" title="Container up: ${escapeHtml(t.container)}"></span>` : ''}
       </div>
       <div class="flex items-center gap-1.5">
         ${t.mount_worktrees ? '<span class="text-[10px] text-v-muted" title="Sibling worktrees mounted">worktrees</span>' : ''}
@@ -207,6 +216,7 @@ function updateCard(card, t) {
       <span class="card-error-label">Error</span><span class="card-error-text">${escapeHtml(t.result.length > 160 ? t.result.slice(0, 160) + '\u2026' : t.result)}</span>
     </div>
     ${t.stop_reason ? `<div style="margin-top:4px;"><span class="badge badge-failed" style="font-size:9px;">${escapeHtml(t.stop_reason)}</span></div>` : ''}
+    ${conflictFilesText(t) ? `<div class="text-[10px] text-v-muted" style="margin-top:4px;">Conflicts in ${escapeHtml(conflictFilesText(t))}</div>` : ''}
     ` : t.status === 'waiting' && t.result ? `
     <div class="card-output-reason">
       <span class="card-output-label">Output</span><span class="card-output-text">${escapeHtml(t.result.length > 160 ? t.result.slice(0, 160) + '\u2026' : t.result)}</span>
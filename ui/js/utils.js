@@ -35,6 +35,23 @@ function formatTimeout(minutes) {
   return Math.floor(minutes / 60) + 'h' + (minutes % 60) + 'm';
 }
 
+function formatDuration(seconds) {
+  const s = Math.round(seconds);
+  if (s < 60) return s + 's';
+  if (s < 3600) return Math.floor(s / 60) + 'm' + (s % 60) + 's';
+  return Math.floor(s / 3600) + 'h' + Math.floor((s % 3600) / 60) + 'm';
+}
+
+function formatBytes(bytes) {
+  const units = ['B', 'KiB', 'MiB', 'GiB', 'TiB'];
+  let i = 0;
+  while (bytes >= 1024 && i < units.length - 1) {
+    bytes /= 1024;
+    i++;
+  }
+  return (i === 0 ? bytes : bytes.toFixed(1)) + ' ' + units[i];
+}
+
 // --- Mobile column navigation ---
 
 function scrollToColumn(wrapperId) {